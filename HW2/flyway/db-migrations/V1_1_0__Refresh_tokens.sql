@@ -0,0 +1,3 @@
+alter table public.token
+    add column if not exists refresh_token varchar default SUBSTR(UPPER(md5(random()::text)), 2, 22) unique,
+    add column if not exists refresh_expiration_time timestamptz default current_timestamp + interval '30days';
@@ -0,0 +1,2 @@
+alter table public.token
+    add column if not exists jwt_id varchar default SUBSTR(UPPER(md5(random()::text)), 2, 22) unique;
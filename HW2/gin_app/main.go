@@ -226,8 +226,12 @@ func main() {
 			return
 		}
 		item, user_ok := users.Load(f.ClientId)
+		if !user_ok {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Incorrect client credentials"})
+			return
+		}
 		user := item.(User)
-		if !user_ok || f.ClientSecret != user.ClientSecret {
+		if f.ClientSecret != user.ClientSecret {
 			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Incorrect client credentials"})
 			return
 		}
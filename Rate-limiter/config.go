@@ -0,0 +1,248 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Config holds the server configuration read from the environment.
+// It is loaded and validated once at startup by loadConfig.
+type Config struct {
+	// DatabaseURL is the postgres connection string (DATABASE_URL, required)
+	DatabaseURL string
+	// AppPort is the port the API listens on (APP_PORT, required)
+	AppPort string
+	// MetricsPort serves /metrics on its own port; empty disables it (METRICS_PORT)
+	MetricsPort string
+	// Release runs gin in release mode (RELEASE)
+	Release bool
+	// AdminToken enables /admin/users for callers presenting it (ADMIN_TOKEN)
+	AdminToken string
+
+	// RateLimitAlgorithm is token_bucket or sliding_window (RATE_LIMIT_ALGORITHM)
+	RateLimitAlgorithm string
+	// RateLimitCapacity is the bucket size, or the requests allowed per
+	// window (RATE_LIMIT_CAPACITY)
+	RateLimitCapacity float64
+	// RateLimitRefillRate is how many requests per second are refilled (RATE_LIMIT_REFILL_RATE)
+	RateLimitRefillRate float64
+	// RateLimitWindow is the sliding window length; by default it admits the
+	// same long-run rate as the bucket would (RATE_LIMIT_WINDOW)
+	RateLimitWindow time.Duration
+	// RateLimitIdleTTL is how long an idle bucket is kept (RATE_LIMIT_IDLE_TTL)
+	RateLimitIdleTTL time.Duration
+	// IdentityOrder is the order identity sources are tried in (RATE_LIMIT_IDENTITY_ORDER)
+	IdentityOrder []string
+	// RouteCosts is how many requests a call to each path counts as (RATE_LIMIT_ROUTE_COSTS)
+	RouteCosts map[string]float64
+	// RateLimitDebug logs every rate limit decision (RATE_LIMIT_DEBUG)
+	RateLimitDebug bool
+
+	// AllowedGrantTypes are the grant types the token endpoint accepts (TOKEN_ALLOWED_GRANT_TYPES)
+	AllowedGrantTypes map[string]bool
+	// ScopeTTLs overrides the access token lifetime per scope (TOKEN_SCOPE_TTLS)
+	ScopeTTLs map[string]time.Duration
+	// TokenSweepInterval is how often expired tokens are deleted (TOKEN_SWEEP_INTERVAL)
+	TokenSweepInterval time.Duration
+	// TokenFormat is opaque or jwt (TOKEN_FORMAT)
+	TokenFormat string
+	// JWTAlgorithm is HS256 or RS256 (JWT_ALGORITHM)
+	JWTAlgorithm string
+	// JWTSecret signs HS256 tokens (JWT_SECRET)
+	JWTSecret string
+	// JWTPrivateKeyFile is the PEM key that signs RS256 tokens (JWT_PRIVATE_KEY_FILE)
+	JWTPrivateKeyFile string
+
+	// DBTimeout bounds every database call (DB_TIMEOUT)
+	DBTimeout time.Duration
+	// DBWriteRate caps token inserts per second; zero leaves them unlimited (DB_WRITE_RATE)
+	DBWriteRate float64
+	// DBWriteQueueSize is how many inserts may wait for their turn (DB_WRITE_QUEUE_SIZE)
+	DBWriteQueueSize int
+}
+
+// loadConfig reads the configuration from environment variables, applies
+// defaults and validates the result. Every problem is reported at once, so
+// a misconfigured instance fails at boot with the full list.
+func loadConfig() (*Config, error) {
+	l := &loader{}
+
+	cfg := &Config{
+		DatabaseURL: l.string("DATABASE_URL", ""),
+		AppPort:     l.string("APP_PORT", ""),
+		MetricsPort: l.string("METRICS_PORT", ""),
+		Release:     l.bool("RELEASE", false),
+		AdminToken:  l.string("ADMIN_TOKEN", ""),
+
+		RateLimitAlgorithm:  l.string("RATE_LIMIT_ALGORITHM", "token_bucket"),
+		RateLimitCapacity:   l.float("RATE_LIMIT_CAPACITY", 10),
+		RateLimitRefillRate: l.float("RATE_LIMIT_REFILL_RATE", 2),
+		RateLimitIdleTTL:    l.duration("RATE_LIMIT_IDLE_TTL", 10*time.Minute),
+		IdentityOrder:       identityOrder,
+		RouteCosts:          routeCosts,
+		RateLimitDebug:      l.bool("RATE_LIMIT_DEBUG", false),
+
+		AllowedGrantTypes:  allowedGrantTypes,
+		ScopeTTLs:          map[string]time.Duration{},
+		TokenSweepInterval: l.duration("TOKEN_SWEEP_INTERVAL", 10*time.Minute),
+		TokenFormat:        l.string("TOKEN_FORMAT", "opaque"),
+		JWTAlgorithm:       l.string("JWT_ALGORITHM", JWTAlgHS256),
+		JWTSecret:          l.string("JWT_SECRET", ""),
+		JWTPrivateKeyFile:  l.string("JWT_PRIVATE_KEY_FILE", ""),
+
+		DBTimeout:        l.duration("DB_TIMEOUT", dbTimeout),
+		DBWriteRate:      l.float("DB_WRITE_RATE", 0),
+		DBWriteQueueSize: l.int("DB_WRITE_QUEUE_SIZE", 100),
+	}
+
+	l.require("DATABASE_URL", cfg.DatabaseURL)
+	l.require("APP_PORT", cfg.AppPort)
+
+	if val, ok := l.lookup("RATE_LIMIT_IDENTITY_ORDER"); ok {
+		order, err := parseIdentityOrder(val)
+		l.check("RATE_LIMIT_IDENTITY_ORDER", err)
+		cfg.IdentityOrder = order
+	}
+	if val, ok := l.lookup("RATE_LIMIT_ROUTE_COSTS"); ok {
+		costs, err := parseRouteCosts(val)
+		l.check("RATE_LIMIT_ROUTE_COSTS", err)
+		cfg.RouteCosts = costs
+	}
+	if val, ok := l.lookup("TOKEN_ALLOWED_GRANT_TYPES"); ok {
+		allowed, err := parseGrantTypes(val)
+		l.check("TOKEN_ALLOWED_GRANT_TYPES", err)
+		cfg.AllowedGrantTypes = allowed
+	}
+	if val, ok := l.lookup("TOKEN_SCOPE_TTLS"); ok {
+		ttls, err := parseScopeTTLs(val)
+		l.check("TOKEN_SCOPE_TTLS", err)
+		cfg.ScopeTTLs = ttls
+	}
+
+	switch cfg.RateLimitAlgorithm {
+	case "token_bucket":
+	case "sliding_window":
+		if cfg.RateLimitCapacity < 1 {
+			l.errs = append(l.errs, fmt.Errorf("RATE_LIMIT_CAPACITY: sliding window needs at least 1 request, got %.1f", cfg.RateLimitCapacity))
+		}
+		cfg.RateLimitWindow = l.duration("RATE_LIMIT_WINDOW", time.Duration(cfg.RateLimitCapacity/cfg.RateLimitRefillRate*float64(time.Second)))
+	default:
+		l.errs = append(l.errs, fmt.Errorf("RATE_LIMIT_ALGORITHM: %q is not one of token_bucket, sliding_window", cfg.RateLimitAlgorithm))
+	}
+
+	switch cfg.TokenFormat {
+	case "opaque":
+	case "jwt":
+		switch cfg.JWTAlgorithm {
+		case JWTAlgHS256:
+			l.require("JWT_SECRET", cfg.JWTSecret)
+		case JWTAlgRS256:
+			l.require("JWT_PRIVATE_KEY_FILE", cfg.JWTPrivateKeyFile)
+		default:
+			l.errs = append(l.errs, fmt.Errorf("JWT_ALGORITHM: %q is not one of HS256, RS256", cfg.JWTAlgorithm))
+		}
+	default:
+		l.errs = append(l.errs, fmt.Errorf("TOKEN_FORMAT: %q is not one of opaque, jwt", cfg.TokenFormat))
+	}
+
+	if err := errors.Join(l.errs...); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+// loader reads typed values from the environment and collects parse errors
+type loader struct {
+	errs []error
+}
+
+// lookup returns the value of an environment variable, treating an empty
+// value the same as an unset one
+func (l *loader) lookup(key string) (string, bool) {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return "", false
+	}
+	return value, true
+}
+
+// check records err, if any, as a problem with key
+func (l *loader) check(key string, err error) {
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%s: %w", key, err))
+	}
+}
+
+// require records an error when a mandatory variable is empty
+func (l *loader) require(key, value string) {
+	if value == "" {
+		l.errs = append(l.errs, errors.New(key+" is required"))
+	}
+}
+
+// string returns the variable as-is, or fallback when unset
+func (l *loader) string(key, fallback string) string {
+	if value, ok := l.lookup(key); ok {
+		return value
+	}
+	return fallback
+}
+
+// bool returns the variable parsed as a boolean
+func (l *loader) bool(key string, fallback bool) bool {
+	value, ok := l.lookup(key)
+	if !ok {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%s: %q is not a boolean", key, value))
+		return fallback
+	}
+	return b
+}
+
+// int returns the variable parsed as a positive integer
+func (l *loader) int(key string, fallback int) int {
+	value, ok := l.lookup(key)
+	if !ok {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		l.errs = append(l.errs, fmt.Errorf("%s: %q is not a positive number", key, value))
+		return fallback
+	}
+	return n
+}
+
+// float returns the variable parsed as a positive number
+func (l *loader) float(key string, fallback float64) float64 {
+	value, ok := l.lookup(key)
+	if !ok {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f <= 0 {
+		l.errs = append(l.errs, fmt.Errorf("%s: %q is not a positive number", key, value))
+		return fallback
+	}
+	return f
+}
+
+// duration returns the variable parsed as a positive duration
+func (l *loader) duration(key string, fallback time.Duration) time.Duration {
+	value, ok := l.lookup(key)
+	if !ok {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		l.errs = append(l.errs, fmt.Errorf("%s: %q is not a positive duration", key, value))
+		return fallback
+	}
+	return d
+}
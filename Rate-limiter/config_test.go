@@ -0,0 +1,186 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// configKeys are every variable loadConfig reads
+var configKeys = []string{
+	"DATABASE_URL", "APP_PORT", "METRICS_PORT", "RELEASE", "ADMIN_TOKEN",
+	"RATE_LIMIT_ALGORITHM", "RATE_LIMIT_CAPACITY", "RATE_LIMIT_REFILL_RATE",
+	"RATE_LIMIT_WINDOW", "RATE_LIMIT_IDLE_TTL", "RATE_LIMIT_IDENTITY_ORDER",
+	"RATE_LIMIT_ROUTE_COSTS", "RATE_LIMIT_DEBUG",
+	"TOKEN_ALLOWED_GRANT_TYPES", "TOKEN_SCOPE_TTLS", "TOKEN_SWEEP_INTERVAL",
+	"TOKEN_FORMAT", "JWT_ALGORITHM", "JWT_SECRET", "JWT_PRIVATE_KEY_FILE",
+	"DB_TIMEOUT", "DB_WRITE_RATE", "DB_WRITE_QUEUE_SIZE",
+}
+
+// setConfigEnv sets the environment to env until the test ends, with every
+// other config variable empty
+func setConfigEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+
+	for _, key := range configKeys {
+		t.Setenv(key, env[key])
+	}
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	setConfigEnv(t, map[string]string{
+		"DATABASE_URL": "postgres://localhost/test",
+		"APP_PORT":     "8000",
+	})
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if cfg.RateLimitAlgorithm != "token_bucket" || cfg.RateLimitCapacity != 10 || cfg.RateLimitRefillRate != 2 {
+		t.Errorf("rate limit = %s %.1f/%.1f, want token_bucket 10/2", cfg.RateLimitAlgorithm, cfg.RateLimitCapacity, cfg.RateLimitRefillRate)
+	}
+	if cfg.TokenFormat != "opaque" || cfg.DBTimeout != 3*time.Second || cfg.TokenSweepInterval != 10*time.Minute {
+		t.Errorf("config = %+v, want the documented defaults", cfg)
+	}
+	if !reflect.DeepEqual(cfg.IdentityOrder, identityOrder) || !reflect.DeepEqual(cfg.AllowedGrantTypes, allowedGrantTypes) {
+		t.Errorf("identity order %v, grant types %v; want the defaults", cfg.IdentityOrder, cfg.AllowedGrantTypes)
+	}
+	if cfg.DBWriteRate != 0 {
+		t.Errorf("DBWriteRate = %.1f, want writes unlimited", cfg.DBWriteRate)
+	}
+}
+
+func TestLoadConfigParses(t *testing.T) {
+	setConfigEnv(t, map[string]string{
+		"DATABASE_URL":              "postgres://localhost/test",
+		"APP_PORT":                  "8000",
+		"RELEASE":                   "true",
+		"RATE_LIMIT_ALGORITHM":      "sliding_window",
+		"RATE_LIMIT_CAPACITY":       "6",
+		"RATE_LIMIT_REFILL_RATE":    "2",
+		"RATE_LIMIT_IDENTITY_ORDER": "ip, client_id",
+		"RATE_LIMIT_ROUTE_COSTS":    "/token/=3",
+		"TOKEN_ALLOWED_GRANT_TYPES": "client_credentials",
+		"TOKEN_SCOPE_TTLS":          "admin=15m",
+		"TOKEN_FORMAT":              "jwt",
+		"JWT_ALGORITHM":             "RS256",
+		"JWT_PRIVATE_KEY_FILE":      "key.pem",
+		"DB_WRITE_RATE":             "2.5",
+	})
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if !cfg.Release {
+		t.Errorf("Release = false, want true")
+	}
+	// The window defaults to the rate the bucket would allow
+	if cfg.RateLimitWindow != 3*time.Second {
+		t.Errorf("RateLimitWindow = %s, want 3s", cfg.RateLimitWindow)
+	}
+	if !reflect.DeepEqual(cfg.IdentityOrder, []string{IdentityIP, IdentityClientID}) {
+		t.Errorf("IdentityOrder = %v", cfg.IdentityOrder)
+	}
+	if !reflect.DeepEqual(cfg.RouteCosts, map[string]float64{"/token/": 3}) {
+		t.Errorf("RouteCosts = %v", cfg.RouteCosts)
+	}
+	if !reflect.DeepEqual(cfg.AllowedGrantTypes, map[string]bool{"client_credentials": true}) {
+		t.Errorf("AllowedGrantTypes = %v", cfg.AllowedGrantTypes)
+	}
+	if cfg.ScopeTTLs["admin"] != 15*time.Minute {
+		t.Errorf("ScopeTTLs = %v", cfg.ScopeTTLs)
+	}
+	if cfg.DBWriteRate != 2.5 || cfg.DBWriteQueueSize != 100 {
+		t.Errorf("DB writes = %.1f per second, queue %d; want 2.5, 100", cfg.DBWriteRate, cfg.DBWriteQueueSize)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want []string
+	}{
+		{
+			name: "missing required",
+			env:  map[string]string{"DATABASE_URL": "", "APP_PORT": ""},
+			want: []string{"DATABASE_URL is required", "APP_PORT is required"},
+		},
+		{
+			name: "bad numbers",
+			env: map[string]string{
+				"RATE_LIMIT_CAPACITY":    "lots",
+				"RATE_LIMIT_REFILL_RATE": "-1",
+				"DB_WRITE_QUEUE_SIZE":    "0",
+				"DB_TIMEOUT":             "3",
+				"RATE_LIMIT_DEBUG":       "yes please",
+			},
+			want: []string{"RATE_LIMIT_CAPACITY", "RATE_LIMIT_REFILL_RATE", "DB_WRITE_QUEUE_SIZE", "DB_TIMEOUT", "RATE_LIMIT_DEBUG"},
+		},
+		{
+			name: "bad lists",
+			env: map[string]string{
+				"RATE_LIMIT_IDENTITY_ORDER": "cookie",
+				"RATE_LIMIT_ROUTE_COSTS":    "/token/",
+				"TOKEN_ALLOWED_GRANT_TYPES": "password",
+				"TOKEN_SCOPE_TTLS":          "admin=forever",
+			},
+			want: []string{"RATE_LIMIT_IDENTITY_ORDER", "RATE_LIMIT_ROUTE_COSTS", "TOKEN_ALLOWED_GRANT_TYPES", "TOKEN_SCOPE_TTLS"},
+		},
+		{
+			name: "unknown choices",
+			env: map[string]string{
+				"RATE_LIMIT_ALGORITHM": "fixed_window",
+				"TOKEN_FORMAT":         "paseto",
+			},
+			want: []string{"RATE_LIMIT_ALGORITHM", "TOKEN_FORMAT"},
+		},
+		{
+			name: "sliding window",
+			env: map[string]string{
+				"RATE_LIMIT_ALGORITHM": "sliding_window",
+				"RATE_LIMIT_CAPACITY":  "0.5",
+				"RATE_LIMIT_WINDOW":    "-1s",
+			},
+			want: []string{"RATE_LIMIT_CAPACITY", "RATE_LIMIT_WINDOW"},
+		},
+		{
+			name: "jwt without a secret",
+			env:  map[string]string{"TOKEN_FORMAT": "jwt"},
+			want: []string{"JWT_SECRET is required"},
+		},
+		{
+			name: "jwt without a key",
+			env:  map[string]string{"TOKEN_FORMAT": "jwt", "JWT_ALGORITHM": "RS256"},
+			want: []string{"JWT_PRIVATE_KEY_FILE is required"},
+		},
+		{
+			name: "jwt algorithm",
+			env:  map[string]string{"TOKEN_FORMAT": "jwt", "JWT_ALGORITHM": "ES256"},
+			want: []string{"JWT_ALGORITHM"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{"DATABASE_URL": "postgres://localhost/test", "APP_PORT": "8000"}
+			for key, value := range tt.env {
+				env[key] = value
+			}
+			setConfigEnv(t, env)
+
+			_, err := loadConfig()
+			if err == nil {
+				t.Fatalf("loadConfig succeeded, want errors mentioning %v", tt.want)
+			}
+			// Every problem is reported at once
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %s", err, want)
+				}
+			}
+		})
+	}
+}
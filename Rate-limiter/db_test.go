@@ -0,0 +1,536 @@
+//go:build integration
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// testSchema mirrors the HW2 flyway migrations
+const testSchema = `
+create table if not exists public.token(
+    client_id VARCHAR(50),
+    access_scope varchar,
+    access_token varchar default SUBSTR(UPPER(md5(random()::text)), 2, 22),
+    expiration_time timestamptz default current_timestamp + interval '2hours',
+    unique(client_id, access_scope)
+);
+create table if not exists public.user (
+    client_id varchar(50) unique,
+    client_secret varchar(100),
+    scope varchar[]
+);
+alter table public.token
+    add column if not exists refresh_token varchar default SUBSTR(UPPER(md5(random()::text)), 2, 22) unique,
+    add column if not exists refresh_expiration_time timestamptz default current_timestamp + interval '30days';
+alter table public.token
+    add column if not exists jwt_id varchar default SUBSTR(UPPER(md5(random()::text)), 2, 22) unique;
+`
+
+// useTestDB points dbconn at the database in DATABASE_URL until the test
+// ends, creating the tables if needed. The queries name the public schema,
+// so the database must be a throwaway one.
+func useTestDB(t *testing.T) {
+	t.Helper()
+
+	url := os.Getenv("DATABASE_URL")
+	if url == "" {
+		t.Skip("DATABASE_URL is not set")
+	}
+	pool, err := pgxpool.New(context.Background(), url)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(pool.Close)
+	if _, err := pool.Exec(context.Background(), testSchema); err != nil {
+		t.Fatalf("failed to create tables: %v", err)
+	}
+
+	previous := dbconn
+	dbconn = pool
+	t.Cleanup(func() { dbconn = previous })
+}
+
+// registerTestUser registers a client with a random id and removes it, its
+// tokens and its cache entry when the test ends
+func registerTestUser(t *testing.T, scope ...string) string {
+	t.Helper()
+
+	buf := make([]byte, 6)
+	rand.Read(buf)
+	client_id := "test-" + hex.EncodeToString(buf)
+	if err := RegisterUser(context.Background(), UserInfo{client_id, "secret", scope}); err != nil {
+		t.Fatalf("RegisterUser: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx := context.Background()
+		dbconn.Exec(ctx, "delete from token where client_id=$1", client_id)
+		dbconn.Exec(ctx, "delete from public.user where client_id=$1", client_id)
+		users.Delete(client_id)
+		tokens.Range(func(key, value any) bool {
+			if value.(TokenInfo).ClientID == client_id {
+				tokens.Delete(key)
+			}
+			return true
+		})
+	})
+	return client_id
+}
+
+func TestGetAllUsers(t *testing.T) {
+	useTestDB(t)
+
+	seed := make([]UserInfo, 3)
+	for i := range seed {
+		buf := make([]byte, 6)
+		rand.Read(buf)
+		seed[i] = UserInfo{"test-" + hex.EncodeToString(buf), "secret", []string{"read"}}
+	}
+	t.Cleanup(func() {
+		for _, user := range seed {
+			dbconn.Exec(context.Background(), "delete from public.user where client_id=$1", user.Client_id)
+			users.Delete(user.Client_id)
+		}
+	})
+	SeedUsers(seed)
+
+	// Returns with however many users there are
+	GetAllUsers()
+
+	for _, user := range seed {
+		cached, ok := loadUser(user.Client_id)
+		if !ok {
+			t.Errorf("user %s not cached after GetAllUsers", user.Client_id)
+			continue
+		}
+		if cached.ClientSecret != "secret" || len(cached.Scopes) != 1 || cached.Scopes[0] != "read" {
+			t.Errorf("cached user %s = %+v", user.Client_id, cached)
+		}
+	}
+}
+
+func TestGetUserLoadsUncached(t *testing.T) {
+	useTestDB(t)
+	client_id := registerTestUser(t, "read", "write")
+	users.Delete(client_id)
+
+	user, ok, err := getUser(context.Background(), client_id)
+	if err != nil || !ok {
+		t.Fatalf("getUser = %v, %v; want the registered user", ok, err)
+	}
+	if len(user.Scopes) != 2 {
+		t.Errorf("user scopes = %v, want read and write", user.Scopes)
+	}
+	if _, ok, err := getUser(context.Background(), "test-missing"); ok || err != nil {
+		t.Errorf("getUser of an unknown client = %v, %v; want not ok without an error", ok, err)
+	}
+}
+
+func TestAdminRegisterThenToken(t *testing.T) {
+	useTestDB(t)
+	r := newTestRouter(t, &Config{AdminToken: "admin-secret"})
+
+	buf := make([]byte, 6)
+	rand.Read(buf)
+	client_id := "test-" + hex.EncodeToString(buf)
+	t.Cleanup(func() {
+		ctx := context.Background()
+		dbconn.Exec(ctx, "delete from token where client_id=$1", client_id)
+		dbconn.Exec(ctx, "delete from public.user where client_id=$1", client_id)
+		users.Delete(client_id)
+	})
+
+	body := `{"client_id":"` + client_id + `","client_secret":"secret","scope":["read"]}`
+	if rec := postAdminUser(r, "admin-secret", body); rec.Code != http.StatusCreated {
+		t.Fatalf("register status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+	// The new client can get a token without a restart
+	rec := postForm(r, "/token/", url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {client_id},
+		"client_secret": {"secret"},
+		"scope":         {"read"},
+	})
+	if rec.Code != http.StatusOK {
+		t.Errorf("token status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+
+	if rec := postAdminUser(r, "admin-secret", body); rec.Code != http.StatusConflict {
+		t.Errorf("second register status = %d, want %d: %s", rec.Code, http.StatusConflict, rec.Body)
+	}
+}
+
+func TestConcurrentAddTokenInsertsOnce(t *testing.T) {
+	useTestDB(t)
+	client_id := registerTestUser(t, "read")
+
+	const callers = 20
+	var wg sync.WaitGroup
+	got := make([]string, callers)
+	errs := make([]error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			got[i], _, _, errs[i] = AddToken(context.Background(), client_id, "read")
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range got {
+		if errs[i] != nil {
+			t.Fatalf("AddToken: %v", errs[i])
+		}
+		if got[i] != got[0] {
+			t.Errorf("caller %d got %q, caller 0 got %q", i, got[i], got[0])
+		}
+	}
+	var rows int
+	if err := dbconn.QueryRow(context.Background(), "select count(*) from token where client_id=$1", client_id).Scan(&rows); err != nil {
+		t.Fatalf("failed to count tokens: %v", err)
+	}
+	if rows != 1 {
+		t.Errorf("stored %d tokens, want 1", rows)
+	}
+}
+
+func TestMultiScopeToken(t *testing.T) {
+	useTestDB(t)
+	client_id := registerTestUser(t, "read", "write")
+
+	token, _, _, err := AddToken(context.Background(), client_id, "read write")
+	if err != nil {
+		t.Fatalf("AddToken: %v", err)
+	}
+	info, err := CheckToken(context.Background(), token)
+	if err != nil {
+		t.Fatalf("CheckToken: %v", err)
+	}
+	if info.AccessScope != "read write" {
+		t.Errorf("CheckToken scope = %q, want %q", info.AccessScope, "read write")
+	}
+	if cached, _, _, ok := mustLoadUser(t, client_id).Token("read write"); !ok || cached != token {
+		t.Errorf("cached token = %q, want %q", cached, token)
+	}
+
+	// Each scope set has a token of its own
+	single, _, _, err := AddToken(context.Background(), client_id, "read")
+	if err != nil {
+		t.Fatalf("AddToken(read): %v", err)
+	}
+	if single == token {
+		t.Errorf("AddToken(read) reused the token of read write")
+	}
+}
+
+func TestCheckInactiveToken(t *testing.T) {
+	useTestDB(t)
+	client_id := registerTestUser(t, "read")
+	r := newTestRouter(t, &Config{})
+
+	token, _, _, err := AddToken(context.Background(), client_id, "read")
+	if err != nil {
+		t.Fatalf("AddToken: %v", err)
+	}
+	if body := getCheck(t, r, token); body["active"] != true || body["client_id"] != client_id {
+		t.Errorf("/check/ of a new token = %v, want it active for %s", body, client_id)
+	}
+
+	if _, err := dbconn.Exec(context.Background(), "update token set expiration_time=now() - interval '1 second' where access_token=$1", token); err != nil {
+		t.Fatalf("failed to expire the token: %v", err)
+	}
+	tokens.Delete(token)
+	if body := getCheck(t, r, token); body["active"] != false || body["exp"] != nil {
+		t.Errorf("/check/ of an expired token = %v, want only active=false", body)
+	}
+	if body := getCheck(t, r, "unknown-token"); body["active"] != false || body["exp"] != nil {
+		t.Errorf("/check/ of an unknown token = %v, want only active=false", body)
+	}
+}
+
+func TestScopeTTLsExpiresIn(t *testing.T) {
+	useTestDB(t)
+	useScopeTTLs(t, map[string]time.Duration{"admin": 15 * time.Minute})
+	client_id := registerTestUser(t, "read", "admin")
+	r := newTestRouter(t, &Config{})
+
+	expiresIn := func(scope string) float64 {
+		t.Helper()
+		rec := postForm(r, "/token/", url.Values{
+			"grant_type":    {"client_credentials"},
+			"client_id":     {client_id},
+			"client_secret": {"secret"},
+			"scope":         {scope},
+		})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("scope %s: status = %d: %s", scope, rec.Code, rec.Body)
+		}
+		var body map[string]any
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("failed to decode %s: %v", rec.Body, err)
+		}
+		return body["expires_in"].(float64)
+	}
+
+	// Allow a few seconds for the requests themselves
+	if got := expiresIn("admin"); got > 15*60 || got < 15*60-5 {
+		t.Errorf("admin expires_in = %v, want about 900", got)
+	}
+	if got := expiresIn("read"); got > 2*60*60 || got < 2*60*60-5 {
+		t.Errorf("read expires_in = %v, want about 7200", got)
+	}
+
+	// The stored expiry matches what the client was told
+	var stored time.Time
+	if err := dbconn.QueryRow(context.Background(), "select expiration_time from token where client_id=$1 and access_scope='admin'", client_id).Scan(&stored); err != nil {
+		t.Fatalf("failed to read the expiry: %v", err)
+	}
+	if left := time.Until(stored); left > 15*time.Minute || left < 15*time.Minute-5*time.Second {
+		t.Errorf("stored admin token expires in %s, want about 15m", left)
+	}
+}
+
+func TestRefreshToken(t *testing.T) {
+	useTestDB(t)
+	client_id := registerTestUser(t, "read")
+
+	token, refresh_token, _, err := AddToken(context.Background(), client_id, "read")
+	if err != nil {
+		t.Fatalf("AddToken: %v", err)
+	}
+	if token == "" || refresh_token == "" {
+		t.Fatalf("AddToken = %q, %q; want both tokens", token, refresh_token)
+	}
+
+	refreshed, scope, err := RefreshToken(context.Background(), refresh_token)
+	if err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+	if refreshed == token || scope != "read" {
+		t.Errorf("RefreshToken = %q, %q; want a new token for read", refreshed, scope)
+	}
+	if _, err := CheckToken(context.Background(), token); !errors.Is(err, ErrNoToken) {
+		t.Errorf("CheckToken of the replaced token error = %v, want %v", err, ErrNoToken)
+	}
+	if info, err := CheckToken(context.Background(), refreshed); err != nil || info.ClientID != client_id {
+		t.Errorf("CheckToken of the new token = %+v, %v; want it to belong to %s", info, err, client_id)
+	}
+	if cached, _, _, ok := mustLoadUser(t, client_id).Token("read"); !ok || cached != refreshed {
+		t.Errorf("cached token = %q, want %q", cached, refreshed)
+	}
+
+	// The refresh token outlives the access tokens it issues
+	if _, _, err := RefreshToken(context.Background(), refresh_token); err != nil {
+		t.Errorf("second RefreshToken: %v", err)
+	}
+}
+
+func TestTokenExpiryFollowsClock(t *testing.T) {
+	useTestDB(t)
+	client_id := registerTestUser(t, "read")
+	now := time.Now()
+	setTokenClock(t, now)
+
+	token, refresh_token, expires, err := AddToken(context.Background(), client_id, "read")
+	if err != nil {
+		t.Fatalf("AddToken: %v", err)
+	}
+
+	// Both the cached copy and the stored row are judged by the clock
+	setTokenClock(t, expires.Add(-time.Second))
+	if _, err := CheckToken(context.Background(), token); err != nil {
+		t.Errorf("CheckToken a second before expiry: %v", err)
+	}
+	setTokenClock(t, expires.Add(time.Second))
+	if _, err := CheckToken(context.Background(), token); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("CheckToken a second after expiry error = %v, want %v", err, ErrTokenExpired)
+	}
+
+	// The refresh token outlives the access token by the 30 days of the schema
+	setTokenClock(t, now.Add(31*24*time.Hour))
+	if _, _, err := RefreshToken(context.Background(), refresh_token); !errors.Is(err, ErrRefreshTokenExpired) {
+		t.Errorf("RefreshToken after 31 days error = %v, want %v", err, ErrRefreshTokenExpired)
+	}
+}
+
+func TestRefreshTokenExpired(t *testing.T) {
+	useTestDB(t)
+	client_id := registerTestUser(t, "read")
+
+	_, refresh_token, _, err := AddToken(context.Background(), client_id, "read")
+	if err != nil {
+		t.Fatalf("AddToken: %v", err)
+	}
+	if _, err := dbconn.Exec(context.Background(), "update token set refresh_expiration_time=now() - interval '1 second' where refresh_token=$1", refresh_token); err != nil {
+		t.Fatalf("failed to expire the refresh token: %v", err)
+	}
+
+	if _, _, err := RefreshToken(context.Background(), refresh_token); !errors.Is(err, ErrRefreshTokenExpired) {
+		t.Errorf("RefreshToken error = %v, want %v", err, ErrRefreshTokenExpired)
+	}
+}
+
+func TestRefreshTokenRevoked(t *testing.T) {
+	useTestDB(t)
+	client_id := registerTestUser(t, "read")
+
+	_, refresh_token, _, err := AddToken(context.Background(), client_id, "read")
+	if err != nil {
+		t.Fatalf("AddToken: %v", err)
+	}
+	if err := RevokeToken(context.Background(), client_id, refresh_token); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+
+	if _, _, err := RefreshToken(context.Background(), refresh_token); !errors.Is(err, ErrNoRefreshToken) {
+		t.Errorf("RefreshToken error = %v, want %v", err, ErrNoRefreshToken)
+	}
+	if _, _, err := RefreshToken(context.Background(), "unknown-refresh-token"); !errors.Is(err, ErrNoRefreshToken) {
+		t.Errorf("RefreshToken of an unknown token error = %v, want %v", err, ErrNoRefreshToken)
+	}
+}
+
+func TestRevokeToken(t *testing.T) {
+	useTestDB(t)
+	client_id := registerTestUser(t, "read")
+	other := registerTestUser(t, "read")
+
+	token, _, expires, err := AddToken(context.Background(), client_id, "read")
+	if err != nil {
+		t.Fatalf("AddToken: %v", err)
+	}
+	if _, err := CheckToken(context.Background(), token); err != nil {
+		t.Fatalf("CheckToken before revoking: %v", err)
+	}
+	if !expires.After(tokenClock()) {
+		t.Fatalf("token expires at %v, before the test could revoke it", expires)
+	}
+
+	// Another client can't revoke it
+	if err := RevokeToken(context.Background(), other, token); err != nil {
+		t.Fatalf("RevokeToken by another client: %v", err)
+	}
+	if _, err := CheckToken(context.Background(), token); err != nil {
+		t.Fatalf("CheckToken after another client's revoke: %v", err)
+	}
+
+	if err := RevokeToken(context.Background(), client_id, token); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+	if _, err := CheckToken(context.Background(), token); !errors.Is(err, ErrNoToken) {
+		t.Errorf("CheckToken of a revoked token error = %v, want %v", err, ErrNoToken)
+	}
+	if cached, _, _, ok := mustLoadUser(t, client_id).Token("read"); ok {
+		t.Errorf("revoked token %q still cached for the user", cached)
+	}
+
+	// Revoking a token that is already gone is not an error
+	if err := RevokeToken(context.Background(), client_id, token); err != nil {
+		t.Errorf("second RevokeToken: %v", err)
+	}
+}
+
+func TestRevokeByRefreshToken(t *testing.T) {
+	useTestDB(t)
+	client_id := registerTestUser(t, "read")
+
+	token, refresh_token, _, err := AddToken(context.Background(), client_id, "read")
+	if err != nil {
+		t.Fatalf("AddToken: %v", err)
+	}
+	if err := RevokeToken(context.Background(), client_id, refresh_token); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+
+	// The access token issued alongside goes too
+	if _, err := CheckToken(context.Background(), token); !errors.Is(err, ErrNoToken) {
+		t.Errorf("CheckToken error = %v, want %v", err, ErrNoToken)
+	}
+}
+
+func TestRevokeJWT(t *testing.T) {
+	useTestDB(t)
+	useJWTSigner(t)
+	client_id := registerTestUser(t, "read")
+
+	db_token, _, expires, err := AddToken(context.Background(), client_id, "read")
+	if err != nil {
+		t.Fatalf("AddToken: %v", err)
+	}
+	token, err := issueAccessToken(context.Background(), client_id, "read", db_token, expires)
+	if err != nil {
+		t.Fatalf("issueAccessToken: %v", err)
+	}
+	claims, err := jwtSigner.Verify(token)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	// The JWT must not hand out the opaque token it stands for
+	if claims.ID == "" || claims.ID == db_token {
+		t.Errorf("jti = %q, want a random id other than the database token", claims.ID)
+	}
+
+	if err := RevokeToken(context.Background(), client_id, token); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+	if _, err := CheckToken(context.Background(), db_token); !errors.Is(err, ErrNoToken) {
+		t.Errorf("CheckToken of the database token error = %v, want %v", err, ErrNoToken)
+	}
+	if _, _, err := ValidateJWT(token); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("ValidateJWT error = %v, want %v", err, ErrTokenRevoked)
+	}
+}
+
+func TestRefreshRevokesJWT(t *testing.T) {
+	useTestDB(t)
+	useJWTSigner(t)
+	client_id := registerTestUser(t, "read")
+
+	db_token, refresh_token, expires, err := AddToken(context.Background(), client_id, "read")
+	if err != nil {
+		t.Fatalf("AddToken: %v", err)
+	}
+	token, err := issueAccessToken(context.Background(), client_id, "read", db_token, expires)
+	if err != nil {
+		t.Fatalf("issueAccessToken: %v", err)
+	}
+	refreshed, _, err := RefreshToken(context.Background(), refresh_token)
+	if err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+	refreshedJWT, err := issueAccessToken(context.Background(), client_id, "read", refreshed, expires)
+	if err != nil {
+		t.Fatalf("issueAccessToken: %v", err)
+	}
+
+	// The revocation lives in the token table, so every instance sees it
+	if _, _, err := ValidateJWT(token); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("ValidateJWT of the replaced token error = %v, want %v", err, ErrTokenRevoked)
+	}
+	if clientID, _, err := ValidateJWT(refreshedJWT); err != nil || clientID != client_id {
+		t.Errorf("ValidateJWT of the new token = %q, %v; want %s", clientID, err, client_id)
+	}
+}
+
+// mustLoadUser returns a cached user, failing the test if there is none
+func mustLoadUser(t *testing.T, client_id string) *User {
+	t.Helper()
+
+	user, ok := loadUser(client_id)
+	if !ok {
+		t.Fatalf("user %s not cached", client_id)
+	}
+	return user
+}
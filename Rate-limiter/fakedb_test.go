@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// useFakeDB points dbconn at a local server that hands each connection to
+// handle, until the test ends, and bounds database calls by timeout
+func useFakeDB(t *testing.T, timeout time.Duration, handle func(net.Conn)) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go handle(conn)
+		}
+	}()
+
+	pool, err := pgxpool.New(context.Background(), "postgres://test@"+listener.Addr().String()+"/test?sslmode=disable")
+	if err != nil {
+		t.Fatalf("failed to create pool: %v", err)
+	}
+	previousConn, previousTimeout := dbconn, dbTimeout
+	dbconn, dbTimeout = pool, timeout
+	t.Cleanup(func() {
+		dbconn, dbTimeout = previousConn, previousTimeout
+		listener.Close()
+		wg.Wait()
+		pool.Close()
+	})
+}
+
+// useStalledDB makes every database call hang until it times out
+func useStalledDB(t *testing.T, timeout time.Duration) {
+	t.Helper()
+
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	useFakeDB(t, timeout, func(conn net.Conn) {
+		defer conn.Close()
+		<-release
+	})
+}
+
+// useBrokenDB makes every database call fail right away
+func useBrokenDB(t *testing.T) {
+	t.Helper()
+
+	useFakeDB(t, time.Minute, func(conn net.Conn) {
+		conn.Close()
+	})
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrInvalidToken error = errors.New("invalid token")
+
+// ErrTokenRevoked is returned for a validly signed JWT that was revoked
+var ErrTokenRevoked error = errors.New("token revoked")
+
+// JWT signing algorithms supported for access tokens
+const (
+	JWTAlgHS256 = "HS256"
+	JWTAlgRS256 = "RS256"
+)
+
+// jwtSigner signs access tokens as JWTs when set; nil keeps opaque DB tokens
+var jwtSigner *JWTSigner
+
+// jwtIDExists reports whether the database token a jti maps to still exists.
+// Revoking or refreshing that token deletes or replaces its jwt_id, so the
+// JWTs issued for it stop working on every instance at once.
+var jwtIDExists = jwt_id_exists
+
+// JWTSigner issues and verifies access tokens as signed JWTs, so they can be
+// checked without a database lookup
+type JWTSigner struct {
+	alg        string
+	secret     []byte
+	privateKey *rsa.PrivateKey
+}
+
+// jwtHeader is the JOSE header of an access token
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+}
+
+// jwtClaims are the claims carried by an access token. The jti is the random
+// jwt_id of the database token the JWT was issued for, so revoking that token
+// revokes it.
+type jwtClaims struct {
+	ID        string `json:"jti"`
+	ClientID  string `json:"client_id"`
+	Scope     string `json:"scope"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// NewHS256Signer creates a signer using HMAC-SHA256 with a shared secret
+func NewHS256Signer(secret []byte) (*JWTSigner, error) {
+	if len(secret) < 32 {
+		return nil, errors.New("HS256 secret must be at least 32 bytes")
+	}
+	return &JWTSigner{alg: JWTAlgHS256, secret: secret}, nil
+}
+
+// NewRS256Signer creates a signer using RSA-SHA256 with a PEM encoded private key
+func NewRS256Signer(privateKeyPEM []byte) (*JWTSigner, error) {
+	block, _ := pem.Decode(privateKeyPEM)
+	if block == nil {
+		return nil, errors.New("failed to decode private key PEM")
+	}
+
+	// Accept both PKCS#1 ("RSA PRIVATE KEY") and PKCS#8 ("PRIVATE KEY") keys
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return &JWTSigner{alg: JWTAlgRS256, privateKey: key}, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not an RSA key")
+	}
+	return &JWTSigner{alg: JWTAlgRS256, privateKey: key}, nil
+}
+
+// Issue returns a token with the given jti for the client and scope that expires after ttl
+func (s *JWTSigner) Issue(id string, clientID string, scope string, ttl time.Duration) (string, error) {
+	now := tokenClock()
+	header, err := json.Marshal(jwtHeader{Alg: s.alg, Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(jwtClaims{
+		ID:        id,
+		ClientID:  clientID,
+		Scope:     scope,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := jwtEncode(header) + "." + jwtEncode(claims)
+	signature, err := s.sign([]byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + jwtEncode(signature), nil
+}
+
+// Verify checks the token's signature and expiry and returns its claims
+func (s *JWTSigner) Verify(token string) (jwtClaims, error) {
+	var claims jwtClaims
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return claims, ErrInvalidToken
+	}
+
+	var header jwtHeader
+	if err := jwtDecode(parts[0], &header); err != nil || header.Alg != s.alg {
+		// Never let the token pick its own algorithm
+		return claims, ErrInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !s.verify([]byte(parts[0]+"."+parts[1]), signature) {
+		return claims, ErrInvalidToken
+	}
+
+	if err := jwtDecode(parts[1], &claims); err != nil || claims.ClientID == "" || claims.ID == "" {
+		return claims, ErrInvalidToken
+	}
+	if !time.Unix(claims.ExpiresAt, 0).After(tokenClock()) {
+		return claims, ErrTokenExpired
+	}
+	return claims, nil
+}
+
+// sign computes the signature over the JWT signing input
+func (s *JWTSigner) sign(input []byte) ([]byte, error) {
+	if s.alg == JWTAlgHS256 {
+		mac := hmac.New(sha256.New, s.secret)
+		mac.Write(input)
+		return mac.Sum(nil), nil
+	}
+	hash := sha256.Sum256(input)
+	return rsa.SignPKCS1v15(rand.Reader, s.privateKey, crypto.SHA256, hash[:])
+}
+
+// verify checks a signature over the JWT signing input
+func (s *JWTSigner) verify(input []byte, signature []byte) bool {
+	if s.alg == JWTAlgHS256 {
+		mac := hmac.New(sha256.New, s.secret)
+		mac.Write(input)
+		return hmac.Equal(mac.Sum(nil), signature)
+	}
+	hash := sha256.Sum256(input)
+	return rsa.VerifyPKCS1v15(&s.privateKey.PublicKey, crypto.SHA256, hash[:], signature) == nil
+}
+
+// ValidateJWT checks a JWT access token's signature and expiry, and that the
+// database token it was issued for hasn't been revoked, and returns the client
+// and scope it was issued for
+func ValidateJWT(token string) (clientID, scope string, err error) {
+	info, err := validateJWT(context.Background(), token)
+	return info.ClientID, info.AccessScope, err
+}
+
+// validateJWT is ValidateJWT that also reports when the token expires
+func validateJWT(ctx context.Context, token string) (TokenInfo, error) {
+	if jwtSigner == nil {
+		return TokenInfo{}, errors.New("JWT access tokens are not enabled")
+	}
+	claims, err := jwtSigner.Verify(token)
+	if err != nil {
+		return TokenInfo{}, err
+	}
+	exists, err := jwtIDExists(ctx, claims.ID)
+	if err != nil {
+		return TokenInfo{}, err
+	}
+	if !exists {
+		return TokenInfo{}, ErrTokenRevoked
+	}
+	return TokenInfo{claims.ClientID, claims.Scope, time.Unix(claims.ExpiresAt, 0)}, nil
+}
+
+// jwtEncode encodes a JWT segment as unpadded base64url
+func jwtEncode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// jwtDecode decodes a base64url JWT segment into v
+func jwtDecode(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useJWTSigner issues JWT access tokens with an HS256 signer until the test ends
+func useJWTSigner(t *testing.T) *JWTSigner {
+	t.Helper()
+
+	signer, err := NewHS256Signer([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatalf("NewHS256Signer: %v", err)
+	}
+	previous := jwtSigner
+	jwtSigner = signer
+	t.Cleanup(func() { jwtSigner = previous })
+	return signer
+}
+
+// useJWTIDs stands in for the token table until the test ends, holding a
+// database token for each of the jtis
+func useJWTIDs(t *testing.T, ids ...string) {
+	t.Helper()
+
+	previous := jwtIDExists
+	jwtIDExists = func(ctx context.Context, jwt_id string) (bool, error) {
+		for _, id := range ids {
+			if id == jwt_id {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	t.Cleanup(func() { jwtIDExists = previous })
+}
+
+func TestValidateJWT(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	setTokenClock(t, now)
+	signer := useJWTSigner(t)
+	useJWTIDs(t, "jwt-id")
+
+	token, err := signer.Issue("jwt-id", "client", "read write", time.Hour)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	parts := strings.Split(token, ".")
+	forged, err := signer.Issue("jwt-id", "other-client", "read write", time.Hour)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		now     time.Time
+		wantErr error
+	}{
+		{"valid", token, now, nil},
+		{"expired", token, now.Add(time.Hour), ErrTokenExpired},
+		{"tampered claims", parts[0] + "." + strings.Split(forged, ".")[1] + "." + parts[2], now, ErrInvalidToken},
+		{"tampered signature", parts[0] + "." + parts[1] + ".AAAA", now, ErrInvalidToken},
+		{"not a JWT", "jwt-id", now, ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTokenClock(t, tt.now)
+			clientID, scope, err := ValidateJWT(tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ValidateJWT error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (clientID != "client" || scope != "read write") {
+				t.Errorf("ValidateJWT = %q, %q; want client, read write", clientID, scope)
+			}
+		})
+	}
+}
+
+func TestRevokedJWT(t *testing.T) {
+	setTokenClock(t, time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	signer := useJWTSigner(t)
+	// Revoking the database token behind revoked-id removed it from the table
+	useJWTIDs(t, "active-id")
+
+	token, err := signer.Issue("revoked-id", "client", "read", time.Hour)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	// A second JWT for the same database token goes down with the first
+	again, err := signer.Issue("revoked-id", "client", "read", 30*time.Minute)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	for _, tok := range []string{token, again} {
+		if _, _, err := ValidateJWT(tok); !errors.Is(err, ErrTokenRevoked) {
+			t.Errorf("ValidateJWT error = %v, want %v", err, ErrTokenRevoked)
+		}
+	}
+
+	active, err := signer.Issue("active-id", "client", "read", time.Hour)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if _, _, err := ValidateJWT(active); err != nil {
+		t.Errorf("ValidateJWT of an unrevoked token: %v", err)
+	}
+}
+
+func TestValidateJWTDatabaseError(t *testing.T) {
+	setTokenClock(t, time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	signer := useJWTSigner(t)
+	previous := jwtIDExists
+	jwtIDExists = func(ctx context.Context, jwt_id string) (bool, error) {
+		return false, context.DeadlineExceeded
+	}
+	t.Cleanup(func() { jwtIDExists = previous })
+
+	token, err := signer.Issue("jwt-id", "client", "read", time.Hour)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	// An unreachable database must not pass for a revoked token
+	if _, _, err := ValidateJWT(token); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ValidateJWT error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestJWTWithoutIDRejected(t *testing.T) {
+	setTokenClock(t, time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	signer := useJWTSigner(t)
+
+	// Without a jti the token could never be revoked
+	token, err := signer.Issue("", "client", "read", time.Hour)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if _, _, err := ValidateJWT(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ValidateJWT error = %v, want %v", err, ErrInvalidToken)
+	}
+}
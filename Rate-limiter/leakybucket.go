@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrWriteQueueFull error = errors.New("database write queue is full, please try again later")
+
+// dbWriteLimiter smooths token inserts into Postgres when set; nil writes right away
+var dbWriteLimiter *LeakyBucket
+
+// LeakyBucket runs queued functions one at a time at a steady rate, so
+// bursts of requests turn into an even stream of database writes.
+// Unlike the client-facing limiters it delays work instead of rejecting
+// it, until the queue is full.
+type LeakyBucket struct {
+	queue    chan func()
+	interval time.Duration
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewLeakyBucket starts a bucket running up to ratePerSecond functions per
+// second with room for queueSize waiting ones
+func NewLeakyBucket(ratePerSecond float64, queueSize int) *LeakyBucket {
+	lb := &LeakyBucket{
+		queue:    make(chan func(), queueSize),
+		interval: time.Duration(float64(time.Second) / ratePerSecond),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go lb.run()
+	return lb
+}
+
+// Enqueue schedules fn to run, or returns ErrWriteQueueFull without
+// running it when the queue has no room left
+func (lb *LeakyBucket) Enqueue(fn func()) error {
+	select {
+	case lb.queue <- fn:
+		return nil
+	default:
+		return ErrWriteQueueFull
+	}
+}
+
+// Stop stops running queued functions; ones still waiting are dropped
+func (lb *LeakyBucket) Stop() {
+	lb.stopOnce.Do(func() { close(lb.stop) })
+	<-lb.done
+}
+
+// run takes one function off the queue per interval
+func (lb *LeakyBucket) run() {
+	defer close(lb.done)
+
+	ticker := time.NewTicker(lb.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-lb.stop:
+			return
+		case fn := <-lb.queue:
+			fn()
+		}
+
+		// Wait out the rest of the slot before the next function
+		select {
+		case <-lb.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dbWrite runs a database write through dbWriteLimiter and waits for it to
+// finish. When ctx is done first it stops waiting, and the write is skipped
+// if it hasn't started yet; either way the context's error is returned.
+func dbWrite(ctx context.Context, write func()) error {
+	if dbWriteLimiter == nil {
+		write()
+		return nil
+	}
+
+	done := make(chan struct{})
+	var skipped error
+	if err := dbWriteLimiter.Enqueue(func() {
+		defer close(done)
+		if skipped = ctx.Err(); skipped == nil {
+			write()
+		}
+	}); err != nil {
+		return err
+	}
+
+	select {
+	case <-done:
+		return skipped
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// useDBWriteLimiter routes dbWrite through lb until the test ends
+func useDBWriteLimiter(t *testing.T, lb *LeakyBucket) {
+	t.Helper()
+
+	previous := dbWriteLimiter
+	dbWriteLimiter = lb
+	t.Cleanup(func() {
+		lb.Stop()
+		dbWriteLimiter = previous
+	})
+}
+
+func TestLeakyBucketRate(t *testing.T) {
+	const rate, writes = 50, 6
+	lb := NewLeakyBucket(rate, writes)
+	defer lb.Stop()
+
+	var mutex sync.Mutex
+	var ran []time.Time
+	var wg sync.WaitGroup
+	wg.Add(writes)
+	start := time.Now()
+	for i := 0; i < writes; i++ {
+		err := lb.Enqueue(func() {
+			defer wg.Done()
+			mutex.Lock()
+			ran = append(ran, time.Now())
+			mutex.Unlock()
+		})
+		if err != nil {
+			t.Fatalf("Enqueue %d: %v", i, err)
+		}
+	}
+	wg.Wait()
+
+	// A burst is spread out to one write per interval
+	interval := time.Second / rate
+	if elapsed := time.Since(start); elapsed < (writes-1)*interval {
+		t.Errorf("%d writes took %s, want at least %s at %d per second", writes, elapsed, (writes-1)*interval, rate)
+	}
+	for i := 1; i < len(ran); i++ {
+		// Leave some slack for the ticker firing early after a slow write
+		if gap := ran[i].Sub(ran[i-1]); gap < interval/2 {
+			t.Errorf("write %d ran %s after the previous one, want about %s", i, gap, interval)
+		}
+	}
+}
+
+func TestLeakyBucketQueueFull(t *testing.T) {
+	lb := NewLeakyBucket(1000, 2)
+	defer lb.Stop()
+
+	// Hold the bucket with a write that doesn't finish until released
+	started, release := make(chan struct{}), make(chan struct{})
+	if err := lb.Enqueue(func() {
+		close(started)
+		<-release
+	}); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	<-started
+	defer close(release)
+
+	for i := 0; i < 2; i++ {
+		if err := lb.Enqueue(func() {}); err != nil {
+			t.Fatalf("Enqueue %d into a queue with room: %v", i, err)
+		}
+	}
+	if err := lb.Enqueue(func() {}); !errors.Is(err, ErrWriteQueueFull) {
+		t.Errorf("Enqueue into a full queue error = %v, want %v", err, ErrWriteQueueFull)
+	}
+}
+
+func TestDBWriteQueueFull(t *testing.T) {
+	lb := NewLeakyBucket(1000, 1)
+	useDBWriteLimiter(t, lb)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	go dbWrite(context.Background(), func() {
+		close(started)
+		<-release
+	})
+	<-started
+	defer close(release)
+	if err := lb.Enqueue(func() {}); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	ran := false
+	if err := dbWrite(context.Background(), func() { ran = true }); !errors.Is(err, ErrWriteQueueFull) {
+		t.Errorf("dbWrite error = %v, want %v", err, ErrWriteQueueFull)
+	}
+	if ran {
+		t.Errorf("write ran although the queue was full")
+	}
+}
+
+func TestDBWriteSkippedAfterTimeout(t *testing.T) {
+	lb := NewLeakyBucket(1000, 2)
+	useDBWriteLimiter(t, lb)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	go dbWrite(context.Background(), func() {
+		close(started)
+		<-release
+	})
+	<-started
+
+	// The caller gives up while its write is still queued
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	ran := make(chan struct{}, 1)
+	if err := dbWrite(ctx, func() { ran <- struct{}{} }); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("dbWrite error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	close(release)
+
+	// Once its turn comes, the abandoned write is dropped
+	done := make(chan struct{})
+	if err := lb.Enqueue(func() { close(done) }); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	<-done
+	select {
+	case <-ran:
+		t.Errorf("write ran after its caller timed out")
+	default:
+	}
+}
@@ -2,11 +2,14 @@ package main
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"log"
+	"math"
 	"net/http"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -16,26 +19,142 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/joho/godotenv"
+	"golang.org/x/sync/singleflight"
 )
 
+// User is a cached client. It is shared by every request for the client, so
+// its token slots may only be touched through its methods; the secret and
+// scopes never change once the user is cached. Each token slot belongs to
+// the scope set in TokenScopes, a sorted space-delimited set as returned by
+// parseScopes.
 type User struct {
-	ClientSecret string
-	Scopes       []string
-	Tokens       []string
+	ClientSecret  string
+	Scopes        []string
+	TokenScopes   []string
+	Tokens        []string
+	RefreshTokens []string
+	Expirations   []time.Time // when each cached access token expires
+	mutex         sync.RWMutex
 }
 
+// newUser creates a user with an empty token slot for each of its scopes;
+// slots for sets of several scopes are added as tokens are cached for them
+func newUser(clientSecret string, scopes []string) *User {
+	return &User{
+		ClientSecret:  clientSecret,
+		Scopes:        scopes,
+		TokenScopes:   slices.Clone(scopes),
+		Tokens:        make([]string, len(scopes)),
+		RefreshTokens: make([]string, len(scopes)),
+		Expirations:   make([]time.Time, len(scopes)),
+	}
+}
+
+// Token returns the user's cached token pair for scope and when the access
+// token expires. An expired access token is treated as not cached.
+func (u *User) Token(scope string) (string, string, time.Time, bool) {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+
+	for i := range u.Tokens {
+		if u.TokenScopes[i] == scope && u.Tokens[i] != "" && u.RefreshTokens[i] != "" && u.Expirations[i].After(tokenClock()) {
+			return u.Tokens[i], u.RefreshTokens[i], u.Expirations[i], true
+		}
+	}
+	return "", "", time.Time{}, false
+}
+
+// SetToken caches the user's current token pair for scope, with the expiry of the
+// access token, adding a slot the first time a scope set is cached
+func (u *User) SetToken(scope string, token string, refresh_token string, expires time.Time) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	for i := range u.Tokens {
+		if u.TokenScopes[i] == scope {
+			u.Tokens[i] = token
+			u.RefreshTokens[i] = refresh_token
+			u.Expirations[i] = expires
+			return
+		}
+	}
+	u.TokenScopes = append(u.TokenScopes, scope)
+	u.Tokens = append(u.Tokens, token)
+	u.RefreshTokens = append(u.RefreshTokens, refresh_token)
+	u.Expirations = append(u.Expirations, expires)
+}
+
+// ClearExpiredTokens forgets every cached token pair whose access token
+// expired by now, and returns how many it dropped
+func (u *User) ClearExpiredTokens(now time.Time) int {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	cleared := 0
+	for i := range u.Tokens {
+		if u.Tokens[i] != "" && !u.Expirations[i].After(now) {
+			u.Tokens[i] = ""
+			u.RefreshTokens[i] = ""
+			u.Expirations[i] = time.Time{}
+			cleared++
+		}
+	}
+	return cleared
+}
+
+// ClearToken forgets the user's token pair for scope if access_token is still the cached one
+func (u *User) ClearToken(scope string, access_token string) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	for i := range u.Tokens {
+		if u.TokenScopes[i] == scope && u.Tokens[i] == access_token {
+			u.Tokens[i] = ""
+			u.RefreshTokens[i] = ""
+			u.Expirations[i] = time.Time{}
+			return
+		}
+	}
+}
+
+// TokenInfo describes a cached access token; AccessScope is the
+// space-delimited set of scopes it grants
 type TokenInfo struct {
 	ClientID       string
 	AccessScope    string
 	ExpirationTime time.Time
 }
 
+// Clock returns the current time; tests substitute a fake one to control time
+type Clock func() time.Time
+
+// Limiter decides whether a client may send another request
+type Limiter interface {
+	IsAllowed(clientID string) bool
+	// IsAllowedN is IsAllowed for a request counting as cost requests
+	IsAllowedN(clientID string, cost float64) bool
+	// State reports a client's current limit without counting a request
+	State(clientID string) LimitState
+	// Clients returns how many clients the limiter keeps state for
+	Clients() int
+}
+
+// LimitState is a snapshot of a client's rate limit, reported in response headers
+type LimitState struct {
+	Limit     float64
+	Remaining float64
+	// ResetIn is how long until the client gains another request, zero if it is at its limit already
+	ResetIn time.Duration
+	Now     time.Time
+}
+
 // TokenBucket represents a token bucket rate limiter
 type TokenBucket struct {
 	tokens         float64
 	capacity       float64
 	fillRate       float64
 	lastRefillTime time.Time
+	clock          Clock
 	mutex          sync.Mutex
 }
 
@@ -44,142 +163,562 @@ type RateLimiter struct {
 	limiters  sync.Map // map[string]*TokenBucket
 	capacity  float64
 	fillRate  float64
+	clock     Clock
 	globalMux sync.Mutex
 }
 
+// users caches a *User per client_id
 var users sync.Map
+
+// tokenIssues makes concurrent AddToken calls for the same client and scope
+// share a single lookup and insert, so they all get the same token
+var tokenIssues singleflight.Group
 var tokens sync.Map
 
+// tokenIssuer looks up or inserts the token behind a deduplicated AddToken
+var tokenIssuer = issueToken
+
+// tokenClock is the time source for all token expiry checks
+var tokenClock Clock = time.Now
+
+// accessTokenLifetime matches the expiration_time default of the token table
+// and applies to every scope without a TTL in scopeTTLs
+const accessTokenLifetime = 2 * time.Hour
+
+// scopeTTLs overrides the access token lifetime per scope, so that tokens
+// for sensitive scopes can be short-lived
+var scopeTTLs = map[string]time.Duration{}
+
+// tokenLifetime returns how long an access token for a space-delimited set
+// of scopes lives: the shortest lifetime of any scope in the set
+func tokenLifetime(scope string) time.Duration {
+	var lifetime time.Duration
+	for _, s := range strings.Fields(scope) {
+		ttl, ok := scopeTTLs[s]
+		if !ok {
+			ttl = accessTokenLifetime
+		}
+		if lifetime == 0 || ttl < lifetime {
+			lifetime = ttl
+		}
+	}
+	if lifetime == 0 {
+		return accessTokenLifetime
+	}
+	return lifetime
+}
+
 // rateLimiter is the global rate limiter instance
-var rateLimiter *RateLimiter
+var rateLimiter Limiter
+
+// Client identity sources used by the rate limiting middleware
+const (
+	IdentityClientID = "client_id" // client_id form field on /token/ and /revoke/
+	IdentityToken    = "token"     // client of the Bearer token on /check/
+	IdentityIP       = "ip"        // client IP address, shared by everyone behind it
+)
+
+// identityOrder is the order in which identity sources are tried
+var identityOrder = []string{IdentityClientID, IdentityToken, IdentityIP}
+
+// routeCosts is how many requests a call to each path counts as against the
+// client's limit; paths not listed cost 1. Issuing a token writes to the
+// database, so it is charged more than a check that is usually cached.
+var routeCosts = map[string]float64{
+	"/token/": 5,
+	"/check/": 1,
+}
+
+// rateLimitDebug enables per-request logging of rate limit decisions
+var rateLimitDebug bool
+
+// TokenRequest holds the form fields accepted by the token endpoint
+// Which fields are required depends on the grant type
+type TokenRequest struct {
+	ClientId     string `form:"client_id"`
+	Scope        string `form:"scope"`
+	ClientSecret string `form:"client_secret"`
+	GrantType    string `form:"grant_type" binding:"required"`
+	RefreshToken string `form:"refresh_token"`
+}
+
+// UserInfo describes a client as listed in users.json and accepted by /admin/users
+type UserInfo struct {
+	Client_id     string   `json:"client_id" binding:"required"`
+	Client_secret string   `json:"client_secret" binding:"required"`
+	Scope         []string `json:"scope" binding:"required,min=1"`
+}
+
+// RevokeRequest holds the form fields accepted by the revocation endpoint
+type RevokeRequest struct {
+	ClientId     string `form:"client_id" binding:"required"`
+	ClientSecret string `form:"client_secret" binding:"required"`
+	Token        string `form:"token" binding:"required"`
+}
+
+// grantHandler issues a token for one OAuth grant type and writes the response
+type grantHandler func(ctx *gin.Context, req TokenRequest)
+
+// grantHandlers maps every supported grant type to its handler
+var grantHandlers = map[string]grantHandler{
+	"client_credentials": handleClientCredentials,
+	"refresh_token":      handleRefreshToken,
+}
+
+// allowedGrantTypes is the subset of grantHandlers enabled on the token endpoint
+var allowedGrantTypes = map[string]bool{
+	"client_credentials": true,
+	"refresh_token":      true,
+}
 
 var dbconn *pgxpool.Pool
+
+// dbTimeout bounds every database call, so a stalled connection fails the
+// request instead of hanging it
+var dbTimeout = 3 * time.Second
+
 var (
-	ErrNoToken      error = errors.New("nonexistent token")
-	ErrTokenExpired error = errors.New("token expired")
-	ErrRateLimited  error = errors.New("rate limit exceeded, please try again later")
+	ErrNoToken             error = errors.New("nonexistent token")
+	ErrTokenExpired        error = errors.New("token expired")
+	ErrNoRefreshToken      error = errors.New("nonexistent refresh token")
+	ErrRefreshTokenExpired error = errors.New("refresh token expired")
+	ErrUserExists          error = errors.New("user already exists")
+	ErrRateLimited         error = errors.New("rate limit exceeded, please try again later")
+	ErrDatabaseTimeout     error = errors.New("database did not respond in time, please try again later")
+	ErrDatabaseUnavailable error = errors.New("database unavailable, please try again later")
 )
 
+// withDBTimeout returns a context for a single database call
+func withDBTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, dbTimeout)
+}
+
+// GetAllUsers warms the users cache with every user in the database,
+// retrying until the query succeeds. Users added later are loaded by getUser.
 func GetAllUsers() {
 	for {
-		rows, err := dbconn.Query(context.Background(), "select * from public.user")
+		count, err := loadAllUsers(context.Background())
 		if err != nil {
-			rows.Close()
-			if err == pgx.ErrNoRows {
-				continue
-			}
-			log.Fatal("Error getting all users at startup: ", err)
-		}
-		type ID_USER struct {
-			id   string
-			user User
+			log.Println("Error getting all users at startup, retrying: ", err)
+			time.Sleep(time.Second)
+			continue
 		}
-		usrs := make([]ID_USER, 0, 1000)
-		for rows.Next() {
-			var user User
-			var id string
-			err := rows.Scan(&id, &user.ClientSecret, &user.Scopes)
-			if err != nil {
-				log.Fatal("Error scanning user at startup: ", err)
+		log.Printf("Successfull getting all %d users at startup", count)
+		return
+	}
+}
+
+// SeedUsers adds the given users to the database, leaving existing ones
+// untouched, and retries until every insert has succeeded
+func SeedUsers(seed []UserInfo) {
+	for _, user := range seed {
+		for {
+			ctx, cancel := withDBTimeout(context.Background())
+			_, err := dbconn.Exec(ctx, "insert into public.user(client_id, client_secret, scope) values ($1, $2, $3) on conflict (client_id) do nothing", user.Client_id, user.Client_secret, user.Scope)
+			cancel()
+			if err == nil {
+				break
 			}
-			user.Tokens = make([]string, len(user.Scopes))
-			usrs = append(usrs, ID_USER{id, user})
+			log.Printf("Error adding user %s at startup, retrying: %v", user.Client_id, err)
+			time.Sleep(time.Second)
 		}
-		rows.Close()
-		if len(usrs) == 1000 {
-			for i := range usrs {
-				users.Store(usrs[i].id, usrs[i].user)
-			}
-			log.Println("Successfull getting all users at startup")
-			return
+	}
+}
+
+// loadAllUsers stores every user in the database in the users cache
+func loadAllUsers(ctx context.Context) (int, error) {
+	ctx, cancel := withDBTimeout(ctx)
+	defer cancel()
+
+	rows, err := dbconn.Query(ctx, "select client_id, client_secret, scope from public.user")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		id, user, err := scanUser(rows)
+		if err != nil {
+			return 0, err
 		}
-		time.Sleep(time.Second)
+		users.Store(id, user)
+		count++
+	}
+	return count, rows.Err()
+}
+
+// getUser returns a user from the cache, loading it from the database on a
+// miss. Unknown users are reported as not ok with a nil error.
+func getUser(ctx context.Context, client_id string) (*User, bool, error) {
+	if user, ok := loadUser(client_id); ok {
+		return user, true, nil
 	}
+
+	user, err := userLookup(ctx, client_id)
+	if err == pgx.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	// Another request may have loaded the user first, keep a single copy
+	item, _ := users.LoadOrStore(client_id, user)
+	return item.(*User), true, nil
+}
+
+// userLookup reads the user behind a getUser cache miss
+var userLookup = get_user
+
+// get_user reads a user from the database, returning pgx.ErrNoRows for
+// unknown ones
+func get_user(ctx context.Context, client_id string) (*User, error) {
+	ctx, cancel := withDBTimeout(ctx)
+	defer cancel()
+
+	row := dbconn.QueryRow(ctx, "select client_id, client_secret, scope from public.user where client_id=$1", client_id)
+	_, user, err := scanUser(row)
+	return user, err
+}
+
+// loadUser returns a user from the cache only
+func loadUser(client_id string) (*User, bool) {
+	item, ok := users.Load(client_id)
+	if !ok {
+		return nil, false
+	}
+	return item.(*User), true
+}
+
+// RegisterUser adds a new client to the database and the users cache,
+// so it can request tokens right away
+func RegisterUser(ctx context.Context, info UserInfo) error {
+	ctx, cancel := withDBTimeout(ctx)
+	defer cancel()
+
+	row := dbconn.QueryRow(ctx, "insert into public.user(client_id, client_secret, scope) values ($1, $2, $3) on conflict (client_id) do nothing returning client_id", info.Client_id, info.Client_secret, info.Scope)
+	var id string
+	err := row.Scan(&id)
+	if err == pgx.ErrNoRows {
+		return ErrUserExists
+	}
+	if err != nil {
+		return err
+	}
+
+	users.Store(id, newUser(info.Client_secret, info.Scope))
+	return nil
+}
+
+// scanUser reads a client_id, client_secret, scope row into a User
+func scanUser(row pgx.Row) (string, *User, error) {
+	var id, clientSecret string
+	var scopes []string
+	if err := row.Scan(&id, &clientSecret, &scopes); err != nil {
+		return "", nil, err
+	}
+	return id, newUser(clientSecret, scopes), nil
 }
 
-func get_token(context context.Context, client_id string, scope string) string {
-	row := dbconn.QueryRow(context, "select access_token, expiration_time from token where client_id=$1 and access_scope=$2", client_id, scope)
-	var token string
+func get_token(ctx context.Context, client_id string, scope string) (string, string, time.Time, error) {
+	ctx, cancel := withDBTimeout(ctx)
+	defer cancel()
+	defer observeTokenQuery("lookup", time.Now())
+
+	row := dbconn.QueryRow(ctx, "select access_token, refresh_token, expiration_time from token where client_id=$1 and access_scope=$2", client_id, scope)
+	var token, refresh_token string
 	var exp_time time.Time
-	err := row.Scan(&token, &exp_time)
+	err := row.Scan(&token, &refresh_token, &exp_time)
 	if err == pgx.ErrNoRows {
-		return ""
+		return "", "", time.Time{}, nil
 	}
 	if err != nil {
-		log.Fatal("Error getting token: ", err)
+		return "", "", time.Time{}, err
 	}
-	if exp_time.Before(time.Now()) {
-		dbconn.Exec(context, "delete from token where access_token=$1", token)
-		return ""
+	if exp_time.Before(tokenClock()) {
+		dbconn.Exec(ctx, "delete from token where access_token=$1", token)
+		return "", "", time.Time{}, nil
 	}
-	return token
+	return token, refresh_token, exp_time, nil
 }
 
-func AddToken(context context.Context, client_id string, scope string) string {
+// get_jwt_id returns the jti that JWTs issued for an access token carry
+func get_jwt_id(ctx context.Context, token string) (string, error) {
+	ctx, cancel := withDBTimeout(ctx)
+	defer cancel()
+
+	var jwt_id string
+	err := dbconn.QueryRow(ctx, "select jwt_id from token where access_token=$1", token).Scan(&jwt_id)
+	if err == pgx.ErrNoRows {
+		return "", ErrNoToken
+	}
+	return jwt_id, err
+}
+
+// jwt_id_exists reports whether a token row with the jwt_id is still stored
+func jwt_id_exists(ctx context.Context, jwt_id string) (bool, error) {
+	ctx, cancel := withDBTimeout(ctx)
+	defer cancel()
+
+	var exists bool
+	err := dbconn.QueryRow(ctx, "select exists(select 1 from token where jwt_id=$1)", jwt_id).Scan(&exists)
+	return exists, err
+}
+
+// issuedToken is the result of a token issue shared by concurrent AddToken calls
+type issuedToken struct {
+	token        string
+	refreshToken string
+	expires      time.Time
+}
+
+// AddToken returns the client's access and refresh token for scope and when
+// the access token expires, issuing new ones if needed.
+// scope is a sorted space-delimited set as returned by parseScopes.
+func AddToken(ctx context.Context, client_id string, scope string) (string, string, time.Time, error) {
 	// Check local cache
-	if item, ok := users.Load(client_id); ok {
-		user := item.(User)
-		for i := range user.Tokens {
-			if user.Scopes[i] == scope && user.Tokens[i] != "" {
-				return user.Tokens[i]
-			}
+	if user, ok := loadUser(client_id); ok {
+		if token, refresh_token, expires, ok := user.Token(scope); ok {
+			return token, refresh_token, expires, nil
+		}
+	}
+
+	// The shared call must not fail for everyone when the first caller goes away
+	sharedCtx := context.WithoutCancel(ctx)
+	result, err, _ := tokenIssues.Do(client_id+" "+scope, func() (any, error) {
+		token, refresh_token, expires, err := tokenIssuer(sharedCtx, client_id, scope)
+		return issuedToken{token, refresh_token, expires}, err
+	})
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+	issued := result.(issuedToken)
+	return issued.token, issued.refreshToken, issued.expires, nil
+}
+
+// issueToken returns the client's stored token for scope, inserting a new one
+// if there is none
+func issueToken(ctx context.Context, client_id string, scope string) (string, string, time.Time, error) {
+	token, refresh_token, expires, err := get_token(ctx, client_id, scope)
+	if err != nil || token != "" {
+		return token, refresh_token, expires, err
+	}
+
+	// The timeout covers the wait in the write queue as well as the insert
+	insertCtx, cancel := withDBTimeout(ctx)
+	defer cancel()
+	expires = tokenClock().Add(tokenLifetime(scope))
+	if werr := dbWrite(insertCtx, func() {
+		defer observeTokenQuery("insert", time.Now())
+		row := dbconn.QueryRow(insertCtx, "insert into token(client_id, access_scope, expiration_time) VALUES($1, $2, $3) returning access_token, refresh_token", client_id, scope, expires)
+		err = row.Scan(&token, &refresh_token)
+	}); werr != nil {
+		return "", "", time.Time{}, werr
+	}
+	if err != nil {
+		return get_token(ctx, client_id, scope)
+	}
+	return token, refresh_token, expires, nil
+}
+
+// RefreshToken issues a new access token for the client and scope the refresh
+// token was issued to. The refresh token itself stays valid until it expires.
+func RefreshToken(ctx context.Context, refreshToken string) (accessToken, scope string, err error) {
+	var info TokenInfo
+	accessToken, info, err = refreshAccessToken(ctx, refreshToken)
+	return accessToken, info.AccessScope, err
+}
+
+// refreshAccessToken is RefreshToken that also reports the client the new
+// access token belongs to and when it expires
+func refreshAccessToken(ctx context.Context, refreshToken string) (accessToken string, info TokenInfo, err error) {
+	ctx, cancel := withDBTimeout(ctx)
+	defer cancel()
+	defer observeTokenQuery("refresh", time.Now())
+
+	row := dbconn.QueryRow(ctx, "select client_id, access_scope, access_token, refresh_expiration_time from token where refresh_token=$1", refreshToken)
+	var id, scope, old_token string
+	var refresh_exp_time time.Time
+	err = row.Scan(&id, &scope, &old_token, &refresh_exp_time)
+	if err == pgx.ErrNoRows {
+		return "", TokenInfo{}, ErrNoRefreshToken
+	}
+	if err != nil {
+		return "", TokenInfo{}, err
+	}
+	if refresh_exp_time.Before(tokenClock()) {
+		return "", TokenInfo{}, ErrRefreshTokenExpired
+	}
+
+	// Only replace the token we looked at, so concurrent refreshes agree on one.
+	// A new jwt_id revokes the JWTs issued for the old token.
+	var exp_time time.Time
+	row = dbconn.QueryRow(ctx, "update token set access_token=default, jwt_id=default, expiration_time=$3 where refresh_token=$1 and access_token=$2 returning access_token, expiration_time", refreshToken, old_token, tokenClock().Add(tokenLifetime(scope)))
+	err = row.Scan(&accessToken, &exp_time)
+	if err == pgx.ErrNoRows {
+		// Someone else refreshed first, hand out their token
+		row = dbconn.QueryRow(ctx, "select access_token, expiration_time from token where refresh_token=$1", refreshToken)
+		err = row.Scan(&accessToken, &exp_time)
+		if err == pgx.ErrNoRows {
+			return "", TokenInfo{}, ErrNoRefreshToken
+		}
+	}
+	if err != nil {
+		return "", TokenInfo{}, err
+	}
+
+	info = TokenInfo{id, scope, exp_time}
+	tokens.Delete(old_token)
+	tokens.Store(accessToken, info)
+	if user, ok := loadUser(id); ok {
+		user.SetToken(scope, accessToken, refreshToken, exp_time)
+	}
+	return accessToken, info, nil
+}
+
+// RevokeToken invalidates one of the client's tokens, either its access or
+// its refresh token, together with the other token issued alongside it.
+// A JWT access token is revoked through the database token its jti maps to.
+// Revoking a token that does not exist is not an error.
+func RevokeToken(ctx context.Context, client_id string, token string) error {
+	ctx, cancel := withDBTimeout(ctx)
+	defer cancel()
+
+	if jwtSigner != nil {
+		if claims, err := jwtSigner.Verify(token); err == nil && claims.ClientID == client_id {
+			token = claims.ID
 		}
 	}
 
-	token := get_token(context, client_id, scope)
-	if token != "" {
-		return token
+	row := dbconn.QueryRow(ctx, "delete from token where client_id=$1 and (access_token=$2 or refresh_token=$2 or jwt_id=$2) returning access_token, access_scope", client_id, token)
+	var access_token, scope string
+	err := row.Scan(&access_token, &scope)
+	if err == pgx.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	evictToken(client_id, scope, access_token)
+	return nil
+}
+
+// evictToken drops a deleted token from the tokens and users caches
+func evictToken(client_id string, scope string, access_token string) {
+	tokens.Delete(access_token)
+	if user, ok := loadUser(client_id); ok {
+		user.ClearToken(scope, access_token)
 	}
-	row := dbconn.QueryRow(context, "insert into token(client_id, access_scope) VALUES($1, $2) returning access_token", client_id, scope)
-	err := row.Scan(&token)
+}
+
+// SweepExpiredTokens deletes tokens whose access and refresh tokens have both
+// expired, and drops expired access tokens from the tokens and users caches.
+// It returns the number of rows deleted.
+func SweepExpiredTokens(ctx context.Context) (int, error) {
+	ctx, cancel := withDBTimeout(ctx)
+	defer cancel()
+
+	now := tokenClock()
+	rows, err := dbconn.Query(ctx, "delete from token where expiration_time < $1 and (refresh_expiration_time is null or refresh_expiration_time < $1) returning client_id, access_scope, access_token", now)
 	if err != nil {
-		return get_token(context, client_id, scope)
+		return 0, err
+	}
+	defer rows.Close()
+
+	deleted := 0
+	for rows.Next() {
+		var client_id, scope, access_token string
+		if err := rows.Scan(&client_id, &scope, &access_token); err != nil {
+			return deleted, err
+		}
+		evictToken(client_id, scope, access_token)
+		deleted++
+	}
+
+	// Rows kept for their refresh token may still have expired access tokens cached
+	tokens.Range(func(key, value any) bool {
+		if !value.(TokenInfo).ExpirationTime.After(now) {
+			tokens.Delete(key)
+		}
+		return true
+	})
+	clearExpiredUserTokens(now)
+	return deleted, rows.Err()
+}
+
+// clearExpiredUserTokens drops expired access tokens from every cached user
+func clearExpiredUserTokens(now time.Time) {
+	users.Range(func(_, value any) bool {
+		value.(*User).ClearExpiredTokens(now)
+		return true
+	})
+}
+
+// runTokenSweeper calls SweepExpiredTokens every interval, forever
+func runTokenSweeper(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		deleted, err := SweepExpiredTokens(context.Background())
+		if err != nil {
+			log.Println("Error sweeping expired tokens: ", err)
+			continue
+		}
+		if deleted > 0 {
+			log.Printf("Swept %d expired tokens", deleted)
+		}
 	}
-	return token
 }
 
-func CheckToken(context context.Context, token string) (string, string, error) {
+// CheckToken returns the client, scope and expiry of an opaque access token
+func CheckToken(ctx context.Context, token string) (TokenInfo, error) {
 	// check local cache
 	if item, ok := tokens.Load(token); ok {
 		token_info := item.(TokenInfo)
-		id := token_info.ClientID
-		scope := token_info.AccessScope
-		tim := token_info.ExpirationTime
-		if tim.After(time.Now()) {
-			return id, scope, nil
+		if token_info.ExpirationTime.After(tokenClock()) {
+			return token_info, nil
 		}
 		tokens.Delete(token)
 	}
-	row := dbconn.QueryRow(context, "select client_id, access_scope, expiration_time from token where access_token=$1", token)
-	var id, scope string
+	ctx, cancel := withDBTimeout(ctx)
+	defer cancel()
+
+	row := dbconn.QueryRow(ctx, "select client_id, access_scope, refresh_token, expiration_time from token where access_token=$1", token)
+	var id, scope, refresh_token string
 	var exp_time time.Time
-	err := row.Scan(&id, &scope, &exp_time)
+	err := row.Scan(&id, &scope, &refresh_token, &exp_time)
 	if err == pgx.ErrNoRows {
-		return "", "", ErrNoToken
+		return TokenInfo{}, ErrNoToken
+	}
+	if err != nil {
+		return TokenInfo{}, err
 	}
-	if exp_time.Before(time.Now()) {
-		return "", "", ErrTokenExpired
+	if exp_time.Before(tokenClock()) {
+		return TokenInfo{}, ErrTokenExpired
 	}
-	tokens.Store(token, TokenInfo{id, scope, exp_time})
+	info := TokenInfo{id, scope, exp_time}
+	tokens.Store(token, info)
 	// Set new token for (user, scope)
-	if item, ok := users.Load(id); ok {
-		user := item.(User)
-		for i := range user.Tokens {
-			if user.Scopes[i] == scope {
-				user.Tokens[i] = token
-				break
-			}
-		}
+	if user, ok := loadUser(id); ok {
+		user.SetToken(scope, token, refresh_token, exp_time)
 	}
-	return id, scope, nil
+	return info, nil
 }
 
 // NewRateLimiter creates a new rate limiter with specified capacity and rate per second
 func NewRateLimiter(capacity float64, ratePerSecond float64) *RateLimiter {
+	return NewRateLimiterWithClock(capacity, ratePerSecond, time.Now)
+}
+
+// NewRateLimiterWithClock creates a rate limiter whose buckets read the time from clock
+func NewRateLimiterWithClock(capacity float64, ratePerSecond float64, clock Clock) *RateLimiter {
 	return &RateLimiter{
 		limiters:  sync.Map{},
 		capacity:  capacity,
 		fillRate:  ratePerSecond,
+		clock:     clock,
 		globalMux: sync.Mutex{},
 	}
 }
@@ -205,7 +744,8 @@ func (rl *RateLimiter) getLimiter(clientID string) *TokenBucket {
 		tokens:         rl.capacity,
 		capacity:       rl.capacity,
 		fillRate:       rl.fillRate,
-		lastRefillTime: time.Now(),
+		lastRefillTime: rl.clock(),
+		clock:          rl.clock,
 		mutex:          sync.Mutex{},
 	}
 	rl.limiters.Store(clientID, bucket)
@@ -214,7 +754,7 @@ func (rl *RateLimiter) getLimiter(clientID string) *TokenBucket {
 
 // refill refills the token bucket based on elapsed time
 func (tb *TokenBucket) refill() {
-	now := time.Now()
+	now := tb.clock()
 	elapsedTime := now.Sub(tb.lastRefillTime).Seconds()
 
 	// Calculate tokens to add
@@ -232,6 +772,12 @@ func (tb *TokenBucket) refill() {
 
 // Allow checks if a request is allowed based on rate limiting
 func (tb *TokenBucket) Allow() bool {
+	return tb.AllowN(1)
+}
+
+// AllowN checks if a request costing n tokens is allowed, taking nothing
+// from the bucket when it holds fewer than n
+func (tb *TokenBucket) AllowN(n float64) bool {
 	tb.mutex.Lock()
 	defer tb.mutex.Unlock()
 
@@ -239,18 +785,551 @@ func (tb *TokenBucket) Allow() bool {
 	tb.refill()
 
 	// Check if we have enough tokens
-	if tb.tokens >= 1 {
-		tb.tokens--
+	if tb.tokens >= n {
+		tb.tokens -= n
 		return true
 	}
 
 	return false
 }
 
+// Remaining refills the bucket and returns how many tokens are left,
+// without consuming any
+func (tb *TokenBucket) Remaining() float64 {
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+
+	tb.refill()
+	return tb.tokens
+}
+
+// NextTokenIn returns how long until the bucket gains its next whole token,
+// or zero when it is already full
+func (tb *TokenBucket) NextTokenIn() time.Duration {
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+
+	tb.refill()
+	if tb.tokens >= tb.capacity || tb.fillRate <= 0 {
+		return 0
+	}
+	missing := math.Floor(tb.tokens) + 1 - tb.tokens
+	return time.Duration(missing / tb.fillRate * float64(time.Second))
+}
+
+// Tokens returns the number of tokens currently left in the bucket
+func (tb *TokenBucket) Tokens() float64 {
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+
+	return tb.tokens
+}
+
+// LastRefillTime returns when the bucket was last refilled
+func (tb *TokenBucket) LastRefillTime() time.Time {
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+
+	return tb.lastRefillTime
+}
+
+// EvictIdle removes the buckets of clients that have not been seen for ttl
+// and whose bucket has refilled completely, so dropping them loses nothing.
+// It returns the number of buckets removed.
+func (rl *RateLimiter) EvictIdle(ttl time.Duration) int {
+	now := rl.clock()
+	evicted := 0
+
+	rl.limiters.Range(func(key, value any) bool {
+		bucket := value.(*TokenBucket)
+		if now.Sub(bucket.LastRefillTime()) < ttl || bucket.Remaining() < rl.capacity {
+			return true
+		}
+
+		rl.globalMux.Lock()
+		// Double-check the bucket was not replaced while we were looking at it
+		if current, exists := rl.limiters.Load(key); exists && current == bucket {
+			rl.limiters.Delete(key)
+			evicted++
+		}
+		rl.globalMux.Unlock()
+		return true
+	})
+
+	return evicted
+}
+
+// StartJanitor evicts idle buckets every interval until stop is called
+func (rl *RateLimiter) StartJanitor(interval time.Duration, ttl time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if evicted := rl.EvictIdle(ttl); evicted > 0 && rateLimitDebug {
+					log.Printf("Rate limiter janitor evicted %d idle buckets", evicted)
+				}
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
 // IsAllowed checks if a request from a client is allowed
 func (rl *RateLimiter) IsAllowed(clientID string) bool {
+	return rl.IsAllowedN(clientID, 1)
+}
+
+// IsAllowedN checks if a request costing cost tokens is allowed. A cost
+// above the capacity takes the whole bucket, since it could never be paid.
+func (rl *RateLimiter) IsAllowedN(clientID string, cost float64) bool {
 	limiter := rl.getLimiter(clientID)
-	return limiter.Allow()
+	allowed := limiter.AllowN(math.Min(cost, rl.capacity))
+
+	if rateLimitDebug {
+		log.Printf("Rate limit decision: client=%s cost=%.1f allowed=%t tokens=%.2f/%.0f", clientID, cost, allowed, limiter.Tokens(), rl.capacity)
+	}
+
+	return allowed
+}
+
+// Clients returns the number of buckets, idle ones not yet evicted included
+func (rl *RateLimiter) Clients() int {
+	count := 0
+	rl.limiters.Range(func(key, value any) bool {
+		count++
+		return true
+	})
+	return count
+}
+
+// State reports the client's bucket without consuming a token
+func (rl *RateLimiter) State(clientID string) LimitState {
+	bucket := rl.getLimiter(clientID)
+	return LimitState{
+		Limit:     rl.capacity,
+		Remaining: math.Floor(bucket.Remaining()),
+		ResetIn:   bucket.NextTokenIn(),
+		Now:       rl.clock(),
+	}
+}
+
+// SlidingWindowLimiter allows each client at most limit requests in any
+// rolling window. Unlike the token bucket it never lets a full burst through
+// right after another one.
+type SlidingWindowLimiter struct {
+	windows   sync.Map // map[string]*slidingWindow
+	limit     int
+	window    time.Duration
+	clock     Clock
+	globalMux sync.Mutex
+}
+
+// slidingWindow logs the times of a client's last allowed requests in a ring buffer
+type slidingWindow struct {
+	times []time.Time
+	next  int // oldest entry, overwritten by the next allowed request
+	mutex sync.Mutex
+}
+
+// NewSlidingWindowLimiter creates a limiter allowing limit requests per window
+func NewSlidingWindowLimiter(limit int, window time.Duration) *SlidingWindowLimiter {
+	return NewSlidingWindowLimiterWithClock(limit, window, time.Now)
+}
+
+// NewSlidingWindowLimiterWithClock creates a sliding window limiter that reads the time from clock
+func NewSlidingWindowLimiterWithClock(limit int, window time.Duration, clock Clock) *SlidingWindowLimiter {
+	return &SlidingWindowLimiter{
+		windows:   sync.Map{},
+		limit:     limit,
+		window:    window,
+		clock:     clock,
+		globalMux: sync.Mutex{},
+	}
+}
+
+// getWindow gets or creates the request log for a client
+func (sw *SlidingWindowLimiter) getWindow(clientID string) *slidingWindow {
+	if w, exists := sw.windows.Load(clientID); exists {
+		return w.(*slidingWindow)
+	}
+
+	sw.globalMux.Lock()
+	defer sw.globalMux.Unlock()
+
+	// Double-check after acquiring the lock
+	if w, exists := sw.windows.Load(clientID); exists {
+		return w.(*slidingWindow)
+	}
+
+	w := &slidingWindow{times: make([]time.Time, sw.limit)}
+	sw.windows.Store(clientID, w)
+	return w
+}
+
+// IsAllowed checks if a request from a client is allowed
+func (sw *SlidingWindowLimiter) IsAllowed(clientID string) bool {
+	return sw.IsAllowedN(clientID, 1)
+}
+
+// IsAllowedN checks if a request counting as cost requests is allowed.
+// Fractional costs round up, and a cost above the limit takes every slot.
+func (sw *SlidingWindowLimiter) IsAllowedN(clientID string, cost float64) bool {
+	w := sw.getWindow(clientID)
+	now := sw.clock()
+	n := min(max(int(math.Ceil(cost)), 1), sw.limit)
+
+	w.mutex.Lock()
+	// The buffer holds the last limit requests oldest first, so the request
+	// is allowed when the n oldest of them have left the window
+	newest := w.times[(w.next+n-1)%len(w.times)]
+	allowed := newest.IsZero() || now.Sub(newest) >= sw.window
+	if allowed {
+		for range n {
+			w.times[w.next] = now
+			w.next = (w.next + 1) % len(w.times)
+		}
+	}
+	w.mutex.Unlock()
+
+	if rateLimitDebug {
+		log.Printf("Rate limit decision: client=%s cost=%d allowed=%t window=%s limit=%d", clientID, n, allowed, sw.window, sw.limit)
+	}
+
+	return allowed
+}
+
+// Clients returns the number of clients with a request log
+func (sw *SlidingWindowLimiter) Clients() int {
+	count := 0
+	sw.windows.Range(func(key, value any) bool {
+		count++
+		return true
+	})
+	return count
+}
+
+// State reports how many requests the client has left in the current window
+func (sw *SlidingWindowLimiter) State(clientID string) LimitState {
+	w := sw.getWindow(clientID)
+	now := sw.clock()
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	// Walk from oldest to newest; the first request still inside the window
+	// is the next one to free up a slot
+	used := 0
+	var resetIn time.Duration
+	for i := range w.times {
+		t := w.times[(w.next+i)%len(w.times)]
+		if t.IsZero() || now.Sub(t) >= sw.window {
+			continue
+		}
+		if used == 0 {
+			resetIn = t.Add(sw.window).Sub(now)
+		}
+		used++
+	}
+
+	return LimitState{
+		Limit:     float64(sw.limit),
+		Remaining: float64(sw.limit - used),
+		ResetIn:   resetIn,
+		Now:       now,
+	}
+}
+
+// setRateLimitHeaders reports a client's limit state on the response
+func setRateLimitHeaders(c *gin.Context, state LimitState) {
+	resetAt := state.Now.Add(state.ResetIn)
+	// Round up so clients never retry before the request has freed up
+	if resetAt.Truncate(time.Second) != resetAt {
+		resetAt = resetAt.Truncate(time.Second).Add(time.Second)
+	}
+
+	c.Header("X-RateLimit-Limit", strconv.FormatFloat(state.Limit, 'f', 0, 64))
+	c.Header("X-RateLimit-Remaining", strconv.FormatFloat(state.Remaining, 'f', 0, 64))
+	c.Header("X-RateLimit-Reset", strconv.FormatInt(resetAt.Unix(), 10))
+}
+
+// parseIdentityOrder parses a comma separated list of identity sources
+func parseIdentityOrder(value string) ([]string, error) {
+	order := make([]string, 0, 3)
+	for _, source := range strings.Split(value, ",") {
+		source = strings.TrimSpace(source)
+		switch source {
+		case IdentityClientID, IdentityToken, IdentityIP:
+			order = append(order, source)
+		case "":
+		default:
+			return nil, errors.New("unknown identity source: " + source)
+		}
+	}
+	if len(order) == 0 {
+		return nil, errors.New("identity order is empty")
+	}
+	return order, nil
+}
+
+// parseScopeTTLs parses a comma separated list of scope=duration pairs,
+// e.g. "admin=15m,write=1h"
+func parseScopeTTLs(value string) (map[string]time.Duration, error) {
+	ttls := make(map[string]time.Duration)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		scope, rawTTL, found := strings.Cut(part, "=")
+		scope = strings.TrimSpace(scope)
+		if !found || scope == "" {
+			return nil, errors.New("expected scope=duration, got " + part)
+		}
+		ttl, err := time.ParseDuration(strings.TrimSpace(rawTTL))
+		if err != nil || ttl <= 0 {
+			return nil, errors.New("TTL of " + scope + " is not a positive duration")
+		}
+		ttls[scope] = ttl
+	}
+	return ttls, nil
+}
+
+// parseRouteCosts parses a comma separated list of path=cost pairs,
+// e.g. "/token/=5,/check/=1"
+func parseRouteCosts(value string) (map[string]float64, error) {
+	costs := make(map[string]float64)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		path, rawCost, found := strings.Cut(part, "=")
+		if !found || path == "" {
+			return nil, errors.New("expected path=cost, got " + part)
+		}
+		cost, err := strconv.ParseFloat(strings.TrimSpace(rawCost), 64)
+		if err != nil || cost <= 0 {
+			return nil, errors.New("cost of " + path + " is not a positive number")
+		}
+		costs[strings.TrimSpace(path)] = cost
+	}
+	return costs, nil
+}
+
+// routeCost returns how many requests a call to path counts as
+func routeCost(path string) float64 {
+	if cost, ok := routeCosts[path]; ok {
+		return cost
+	}
+	return 1
+}
+
+// resolveClientID identifies the client of a request by trying each identity
+// source in order, returning the first non-empty identity and its source.
+// The IP address is always used as a last resort.
+func resolveClientID(c *gin.Context, order []string) (string, string) {
+	for _, source := range order {
+		clientID := ""
+		switch source {
+		case IdentityClientID:
+			// For token and revocation endpoints, get client ID from form
+			if c.Request.URL.Path == "/token/" || c.Request.URL.Path == "/revoke/" {
+				clientID = c.PostForm("client_id")
+			}
+		case IdentityToken:
+			// For check endpoint, extract client ID from token
+			if c.Request.URL.Path == "/check/" {
+				parts := strings.Split(c.GetHeader("Authorization"), " ")
+				if len(parts) == 2 && parts[0] == "Bearer" {
+					if jwtSigner != nil {
+						// The signature is enough to tell who is calling
+						if claims, err := jwtSigner.Verify(parts[1]); err == nil {
+							clientID = claims.ClientID
+						}
+					} else if tokenItem, exists := tokens.Load(parts[1]); exists {
+						clientID = tokenItem.(TokenInfo).ClientID
+					}
+				}
+			}
+		case IdentityIP:
+			clientID = c.ClientIP()
+		}
+		if clientID != "" {
+			return clientID, source
+		}
+	}
+
+	return c.ClientIP(), IdentityIP
+}
+
+// parseGrantTypes parses a comma-separated list of grant types,
+// rejecting any that have no registered handler
+func parseGrantTypes(value string) (map[string]bool, error) {
+	allowed := make(map[string]bool)
+	for _, part := range strings.Split(value, ",") {
+		grantType := strings.TrimSpace(part)
+		if grantType == "" {
+			continue
+		}
+		if _, ok := grantHandlers[grantType]; !ok {
+			return nil, errors.New("unknown grant type: " + grantType)
+		}
+		allowed[grantType] = true
+	}
+	if len(allowed) == 0 {
+		return nil, errors.New("at least one grant type is required")
+	}
+	return allowed, nil
+}
+
+// parseScopes splits a space-delimited scope parameter into a sorted set,
+// so the same scopes in any order map to the same token
+func parseScopes(scope string) []string {
+	scopes := strings.Fields(scope)
+	slices.Sort(scopes)
+	return slices.Compact(scopes)
+}
+
+// issueAccessToken returns the access token handed to the client: the opaque
+// database token, or a signed JWT for the same client and scope when enabled.
+// A JWT expires together with the database token it stands for, and carries
+// that token's random jwt_id as its jti so the database token itself is never
+// exposed in the JWT. Signing can only fail with the system's random source,
+// so callers may treat any error as a database one.
+func issueAccessToken(ctx context.Context, client_id string, scope string, dbToken string, expires time.Time) (string, error) {
+	if jwtSigner == nil {
+		return dbToken, nil
+	}
+	jwt_id, err := get_jwt_id(ctx, dbToken)
+	if err != nil {
+		return "", err
+	}
+	return jwtSigner.Issue(jwt_id, client_id, scope, expires.Sub(tokenClock()))
+}
+
+// expiresIn returns the remaining lifetime of an access token in whole
+// seconds, as reported in expires_in
+func expiresIn(expires time.Time) int {
+	return int(expires.Sub(tokenClock()).Seconds())
+}
+
+// respondDBError logs a failed database call and answers the request with
+// a 503. Database failures are usually transient, so the client is told to
+// retry instead of the request hanging or the server going down.
+func respondDBError(ctx *gin.Context, msg string, err error) {
+	log.Println(msg, err)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": ErrDatabaseTimeout.Error()})
+	case errors.Is(err, ErrWriteQueueFull):
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": ErrDatabaseUnavailable.Error()})
+	}
+}
+
+// handleClientCredentials issues a token for the client_credentials grant
+func handleClientCredentials(ctx *gin.Context, f TokenRequest) {
+	if f.ClientId == "" || f.Scope == "" || f.ClientSecret == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Missing some of following form fields: client_id, scope, client_secret, grant_type"})
+		return
+	}
+	user, user_ok, err := getUser(ctx, f.ClientId)
+	if err != nil {
+		respondDBError(ctx, "Error loading user: ", err)
+		return
+	}
+	if !user_ok {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Incorrect client credentials"})
+		return
+	}
+	if f.ClientSecret != user.ClientSecret {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Incorrect client credentials"})
+		return
+	}
+	scopes := parseScopes(f.Scope)
+	if len(scopes) == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Wrong scope"})
+		return
+	}
+	for _, scope := range scopes {
+		is_pos_scope := false
+		for i := range user.Scopes {
+			if user.Scopes[i] == scope {
+				is_pos_scope = true
+				break
+			}
+		}
+		if !is_pos_scope {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Wrong scope: " + scope})
+			return
+		}
+	}
+	scope := strings.Join(scopes, " ")
+	token, refresh_token, expires, err := AddToken(ctx, f.ClientId, scope)
+	if err != nil {
+		respondDBError(ctx, "Error adding token: ", err)
+		return
+	}
+	if token == "" {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	token, err = issueAccessToken(ctx, f.ClientId, scope, token, expires)
+	if err != nil {
+		respondDBError(ctx, "Error issuing token: ", err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"access_token":   token,
+		"expires_in":     expiresIn(expires),
+		"refresh_token":  refresh_token,
+		"scope":          scope,
+		"security_level": "normal",
+		"token_type":     "Bearer",
+	})
+}
+
+// handleRefreshToken issues a new access token for the refresh_token grant,
+// without asking for the client secret again
+func handleRefreshToken(ctx *gin.Context, f TokenRequest) {
+	if f.RefreshToken == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Missing some of following form fields: refresh_token, grant_type"})
+		return
+	}
+	token, info, err := refreshAccessToken(ctx, f.RefreshToken)
+	if errors.Is(err, ErrNoRefreshToken) || errors.Is(err, ErrRefreshTokenExpired) {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_grant",
+			"error_description": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		respondDBError(ctx, "Error refreshing token: ", err)
+		return
+	}
+	token, err = issueAccessToken(ctx, info.ClientID, info.AccessScope, token, info.ExpirationTime)
+	if err != nil {
+		respondDBError(ctx, "Error issuing token: ", err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"access_token":   token,
+		"expires_in":     expiresIn(info.ExpirationTime),
+		"refresh_token":  f.RefreshToken,
+		"scope":          info.AccessScope,
+		"security_level": "normal",
+		"token_type":     "Bearer",
+	})
 }
 
 func main() {
@@ -258,31 +1337,50 @@ func main() {
 		log.Println("Error loading .env file\n" + err.Error())
 	}
 
-	// Initialize rate limiter with default values
-	// These can be overridden via environment variables
-	bucketCapacity := 10.0
-	refillRate := 2.0
-
-	// Check for environment variable overrides
-	if val, exists := os.LookupEnv("RATE_LIMIT_CAPACITY"); exists {
-		if parsed, err := strconv.ParseFloat(val, 64); err == nil {
-			bucketCapacity = parsed
-		}
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Fatal(err)
 	}
+	identityOrder = cfg.IdentityOrder
+	routeCosts = cfg.RouteCosts
+	rateLimitDebug = cfg.RateLimitDebug
+	allowedGrantTypes = cfg.AllowedGrantTypes
+	scopeTTLs = cfg.ScopeTTLs
+	dbTimeout = cfg.DBTimeout
 
-	if val, exists := os.LookupEnv("RATE_LIMIT_REFILL_RATE"); exists {
-		if parsed, err := strconv.ParseFloat(val, 64); err == nil {
-			refillRate = parsed
+	// Issue signed JWTs instead of opaque tokens so /check/ needs no database
+	if cfg.TokenFormat == "jwt" {
+		if cfg.JWTAlgorithm == JWTAlgRS256 {
+			var key []byte
+			key, err = os.ReadFile(cfg.JWTPrivateKeyFile)
+			if err == nil {
+				jwtSigner, err = NewRS256Signer(key)
+			}
+		} else {
+			jwtSigner, err = NewHS256Signer([]byte(cfg.JWTSecret))
+		}
+		if err != nil {
+			log.Fatalf("Invalid JWT configuration: %v", err)
 		}
+		log.Printf("Issuing %s signed JWT access tokens", jwtSigner.alg)
 	}
 
 	// Create rate limiter instance
-	rateLimiter = NewRateLimiter(bucketCapacity, refillRate)
-	log.Printf("Rate limiter initialized with capacity: %.1f, refill rate: %.1f per second", bucketCapacity, refillRate)
+	switch cfg.RateLimitAlgorithm {
+	case "token_bucket":
+		// Buckets idle for RATE_LIMIT_IDLE_TTL are full again and can be dropped
+		limiter := NewRateLimiter(cfg.RateLimitCapacity, cfg.RateLimitRefillRate)
+		stopJanitor := limiter.StartJanitor(cfg.RateLimitIdleTTL/2, cfg.RateLimitIdleTTL)
+		defer stopJanitor()
+		rateLimiter = limiter
+		log.Printf("Rate limiter initialized with capacity: %.1f, refill rate: %.1f per second, idle TTL: %s", cfg.RateLimitCapacity, cfg.RateLimitRefillRate, cfg.RateLimitIdleTTL)
+	case "sliding_window":
+		rateLimiter = NewSlidingWindowLimiter(int(cfg.RateLimitCapacity), cfg.RateLimitWindow)
+		log.Printf("Rate limiter initialized with sliding window: %d requests per %s", int(cfg.RateLimitCapacity), cfg.RateLimitWindow)
+	}
 
 	for {
-		var err error
-		dbconn, err = pgxpool.New(context.Background(), os.Getenv("DATABASE_URL"))
+		dbconn, err = pgxpool.New(context.Background(), cfg.DatabaseURL)
 		if err != nil {
 			log.Printf("Unable to create connection pool: %v\n", err)
 			time.Sleep(time.Second)
@@ -294,11 +1392,6 @@ func main() {
 	defer dbconn.Close()
 
 	{
-		type UserInfo struct {
-			Client_id     string   `json:"client_id"`
-			Client_secret string   `json:"client_secret"`
-			Scope         []string `json:"scope"`
-		}
 		file, err := os.ReadFile("users.json")
 		if err != nil {
 			log.Fatalln("Error while reading users: ", err.Error())
@@ -307,56 +1400,68 @@ func main() {
 		if err := json.Unmarshal(file, &users); err != nil {
 			log.Fatalln("Error while reading users: ", err.Error())
 		}
-		for _, user := range users {
-			dbconn.Exec(context.Background(), "insert into public.user(client_id, client_secret, scope) values ($1, $2, $3)", user.Client_id, user.Client_secret, user.Scope)
-		}
-
+		SeedUsers(users)
 	}
 
 	GetAllUsers()
 
-	if os.Getenv("RELEASE") == "true" {
+	// Expired tokens are otherwise only deleted when they happen to be read
+	go runTokenSweeper(cfg.TokenSweepInterval)
+
+	// Spread token inserts out so bursts of new clients don't hammer the database
+	if cfg.DBWriteRate > 0 {
+		dbWriteLimiter = NewLeakyBucket(cfg.DBWriteRate, cfg.DBWriteQueueSize)
+		defer dbWriteLimiter.Stop()
+		log.Printf("Database writes limited to %.1f per second, queue size: %d", cfg.DBWriteRate, cfg.DBWriteQueueSize)
+	}
+
+	// Metrics go on their own port so they aren't exposed with the public API
+	if cfg.MetricsPort != "" {
+		go func() {
+			if err := serveMetrics(":" + cfg.MetricsPort); err != nil {
+				log.Fatalf("Metrics server failed: %v", err)
+			}
+		}()
+		log.Printf("Serving metrics on port %s", cfg.MetricsPort)
+	}
+
+	if cfg.Release {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
+	r := newRouter(cfg)
+	log.Println("Server started")
+
+	if err := r.Run(":" + cfg.AppPort); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// newRouter sets up the public endpoints behind the rate limiter
+func newRouter(cfg *Config) *gin.Engine {
 	r := gin.New()
 
 	// Rate limiting middleware
 	rateLimitMiddleware := func(c *gin.Context) {
-		// For token endpoint, get client ID from form
-		clientID := ""
-		if c.Request.URL.Path == "/token/" {
-			clientID = c.PostForm("client_id")
-		} else if c.Request.URL.Path == "/check/" {
-			// For check endpoint, extract client ID from token
-			authHeader := c.GetHeader("Authorization")
-			parts := strings.Split(authHeader, " ")
-			if len(parts) == 2 && parts[0] == "Bearer" {
-				token := parts[1]
-				// Extract client ID from token using our existing function
-				if tokenItem, exists := tokens.Load(token); exists {
-					tokenInfo := tokenItem.(TokenInfo)
-					clientID = tokenInfo.ClientID
-				}
-			}
+		// Identify the client, falling back to the IP address
+		clientID, source := resolveClientID(c, identityOrder)
+		if rateLimitDebug {
+			log.Printf("Rate limit identity: path=%s source=%s client=%s", c.Request.URL.Path, source, clientID)
 		}
 
-		// If we couldn't identify the client, use IP address as fallback
-		if clientID == "" {
-			clientID = c.ClientIP()
-		}
+		// Check if request is allowed, reporting the bucket state either way
+		allowed := rateLimiter.IsAllowedN(clientID, routeCost(c.Request.URL.Path))
+		state := rateLimiter.State(clientID)
+		observeRateLimit(c.FullPath(), source, allowed)
+		setRateLimitHeaders(c, state)
 
-		// Check if request is allowed
-		if !rateLimiter.IsAllowed(clientID) {
-			// Add standard rate limiting headers
-			c.Header("Retry-After", "1")
-			c.Header("X-RateLimit-Limit", strconv.FormatFloat(rateLimiter.capacity, 'f', 0, 64))
-			c.Header("X-RateLimit-Remaining", "0")
-			c.Header("X-RateLimit-Reset", strconv.FormatInt(time.Now().Unix()+1, 10))
+		if !allowed {
+			retryAfter := strconv.FormatFloat(math.Max(1, math.Ceil(state.ResetIn.Seconds())), 'f', 0, 64)
+			c.Header("Retry-After", retryAfter)
 
 			c.JSON(http.StatusTooManyRequests, gin.H{
 				"error":       ErrRateLimited.Error(),
-				"retry_after": "1", // Suggest retry after 1 second
+				"retry_after": retryAfter,
 			})
 			c.Abort()
 			return
@@ -367,65 +1472,78 @@ func main() {
 
 	// Apply rate limiting middleware to all endpoints
 	r.Use(rateLimitMiddleware)
-
-	// Get instance port from environment
-	instancePort := os.Getenv("APP_PORT")
-	if instancePort == "" {
-		log.Fatal("APP_PORT environment variable is required")
-	}
 	// // middleware that logs the current instance
 	// r.Use(func(c *gin.Context) {
-	// 	log.Printf("Instance on port %s handling request: %s %s", instancePort, c.Request.Method, c.Request.URL.Path)
+	// 	log.Printf("Instance on port %s handling request: %s %s", cfg.AppPort, c.Request.Method, c.Request.URL.Path)
 	// 	c.Next()
 	// })
 
 	r.POST("token/", func(ctx *gin.Context) {
-		type Form struct {
-			ClientId     string `form:"client_id" binding:"required"`
-			Scope        string `form:"scope" binding:"required"`
-			ClientSecret string `form:"client_secret" binding:"required"`
-			GrantType    string `form:"grant_type" binding:"required"`
-		}
-		var f Form
+		var f TokenRequest
 		if err := ctx.ShouldBind(&f); err != nil {
 			log.Println(err.Error())
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Missing some of following form fields: client_id, scope, client_secret, grant_type"})
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Missing form field: grant_type"})
 			return
 		}
-		if f.GrantType != "client_credentials" {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Incorrect grant type"})
+		handler, ok := grantHandlers[f.GrantType]
+		if !ok || !allowedGrantTypes[f.GrantType] {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error":             "unsupported_grant_type",
+				"error_description": "Grant type '" + f.GrantType + "' is not supported",
+			})
 			return
 		}
-		item, user_ok := users.Load(f.ClientId)
-		user := item.(User)
-		if !user_ok || f.ClientSecret != user.ClientSecret {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Incorrect client credentials"})
+		handler(ctx, f)
+	})
+	// Runtime user registration is only enabled when an admin token is configured
+	if adminToken := cfg.AdminToken; adminToken != "" {
+		r.POST("admin/users", func(ctx *gin.Context) {
+			header := ctx.GetHeader("Authorization")
+			ar := strings.Split(header, " ")
+			if len(ar) != 2 || ar[0] != "Bearer" || subtle.ConstantTimeCompare([]byte(ar[1]), []byte(adminToken)) != 1 {
+				ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Incorrect admin credentials"})
+				return
+			}
+			var info UserInfo
+			if err := ctx.ShouldBindJSON(&info); err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": "Missing some of following fields: client_id, client_secret, scope"})
+				return
+			}
+			err := RegisterUser(ctx, info)
+			if errors.Is(err, ErrUserExists) {
+				ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+			if err != nil {
+				respondDBError(ctx, "Error registering user: ", err)
+				return
+			}
+			ctx.JSON(http.StatusCreated, gin.H{
+				"client_id": info.Client_id,
+				"scope":     info.Scope,
+			})
+		})
+	}
+	r.POST("revoke/", func(ctx *gin.Context) {
+		var f RevokeRequest
+		if err := ctx.ShouldBind(&f); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Missing some of following form fields: client_id, client_secret, token"})
 			return
 		}
-		is_pos_scope := false
-		for i := range user.Scopes {
-			if user.Scopes[i] == f.Scope {
-				is_pos_scope = true
-				break
-			}
+		user, user_ok, err := getUser(ctx, f.ClientId)
+		if err != nil {
+			respondDBError(ctx, "Error loading user: ", err)
+			return
 		}
-		if !is_pos_scope {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Wrong scope"})
+		if !user_ok || f.ClientSecret != user.ClientSecret {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Incorrect client credentials"})
 			return
 		}
-		token := AddToken(ctx, f.ClientId, f.Scope)
-		if token == "" {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		if err := RevokeToken(ctx, f.ClientId, f.Token); err != nil {
+			respondDBError(ctx, "Error revoking token: ", err)
 			return
 		}
-		ctx.JSON(http.StatusOK, gin.H{
-			"access_token":   token,
-			"expires_in":     7200,
-			"refresh_token":  "",
-			"scope":          f.Scope,
-			"security_level": "normal",
-			"token_type":     "Bearer",
-		})
+		ctx.Status(http.StatusOK)
 	})
 	r.GET("check/", func(ctx *gin.Context) {
 		header := ctx.GetHeader("Authorization")
@@ -434,24 +1552,33 @@ func main() {
 			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Incorrect 'Authorization' header"})
 			return
 		}
-		client_id, score, err := CheckToken(ctx, ar[1])
+		var info TokenInfo
+		var err error
+		if jwtSigner != nil {
+			info, err = validateJWT(ctx, ar[1])
+		} else {
+			info, err = CheckToken(ctx, ar[1])
+		}
+		if err != nil && !errors.Is(err, ErrNoToken) && !errors.Is(err, ErrTokenExpired) &&
+			!errors.Is(err, ErrInvalidToken) && !errors.Is(err, ErrTokenRevoked) {
+			respondDBError(ctx, "Error checking token: ", err)
+			return
+		}
+		// Answer in the shape of RFC 7662 token introspection: a token that
+		// is unknown, expired or forged is simply not active
 		if err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			ctx.JSON(http.StatusOK, gin.H{"active": false})
 			return
 		}
 		ctx.JSON(http.StatusOK, gin.H{
-			"client_id": client_id,
-			"scope":     score,
+			"active":             true,
+			"client_id":          info.ClientID,
+			"scope":              info.AccessScope,
+			"token_type":         "Bearer",
+			"exp":                info.ExpirationTime.Unix(),
+			"expires_at":         info.ExpirationTime.UTC().Format(time.RFC3339),
+			"expires_in_seconds": int(info.ExpirationTime.Sub(tokenClock()).Seconds()),
 		})
 	})
-	log.Println("Server started")
-
-	//obtaining port from env
-	port := os.Getenv("APP_PORT")
-	if port == "" {
-		port = "8000"
-	}
-	if err := r.Run(":" + port); err != nil {
-		log.Fatal(err)
-	}
+	return r
 }
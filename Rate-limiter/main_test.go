@@ -0,0 +1,942 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// setTokenClock makes tokenClock return now until the test ends
+func setTokenClock(t *testing.T, now time.Time) {
+	t.Helper()
+
+	previous := tokenClock
+	tokenClock = func() time.Time { return now }
+	t.Cleanup(func() { tokenClock = previous })
+}
+
+// cacheUser puts user in the users cache until the test ends
+func cacheUser(t *testing.T, client_id string, user *User) {
+	t.Helper()
+
+	users.Store(client_id, user)
+	t.Cleanup(func() { users.Delete(client_id) })
+}
+
+// newTestRouter returns the public endpoints with a rate limit no test
+// reaches, restoring the previous limiter when the test ends
+func newTestRouter(t *testing.T, cfg *Config) *gin.Engine {
+	t.Helper()
+
+	previous := rateLimiter
+	rateLimiter = NewRateLimiter(1000, 1000)
+	t.Cleanup(func() { rateLimiter = previous })
+	return newRouter(cfg)
+}
+
+// formRequest returns a POST of form values to path
+func formRequest(path string, form url.Values) *http.Request {
+	req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+// postForm sends form values to path on r and returns the response
+func postForm(r *gin.Engine, path string, form url.Values) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, formRequest(path, form))
+	return rec
+}
+
+// useUserLookup makes getUser misses find users instead of querying the
+// database until the test ends
+func useUserLookup(t *testing.T, users map[string]*User) {
+	t.Helper()
+
+	previous := userLookup
+	userLookup = func(ctx context.Context, client_id string) (*User, error) {
+		if user, ok := users[client_id]; ok {
+			return user, nil
+		}
+		return nil, pgx.ErrNoRows
+	}
+	t.Cleanup(func() { userLookup = previous })
+}
+
+func TestGetUserCachesLookups(t *testing.T) {
+	var lookups atomic.Int32
+	previous := userLookup
+	userLookup = func(ctx context.Context, client_id string) (*User, error) {
+		lookups.Add(1)
+		return newUser("secret", []string{"read"}), nil
+	}
+	t.Cleanup(func() { userLookup = previous })
+	t.Cleanup(func() { users.Delete("lazy-client") })
+
+	// Users missing from the startup load are read once, then served from the cache
+	first, ok, err := getUser(context.Background(), "lazy-client")
+	if err != nil || !ok {
+		t.Fatalf("getUser = %v, %v; want the user", ok, err)
+	}
+	second, _, _ := getUser(context.Background(), "lazy-client")
+	if first != second {
+		t.Errorf("getUser returned two different copies of the user")
+	}
+	if n := lookups.Load(); n != 1 {
+		t.Errorf("looked the user up %d times, want 1", n)
+	}
+}
+
+func TestTokenUnknownClient(t *testing.T) {
+	useUserLookup(t, nil)
+	r := newTestRouter(t, &Config{})
+
+	rec := postForm(r, "/token/", url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {"nobody"},
+		"client_secret": {"secret"},
+		"scope":         {"read"},
+	})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusBadRequest, rec.Body)
+	}
+	if !strings.Contains(rec.Body.String(), "Incorrect client credentials") {
+		t.Errorf("body = %s, want the incorrect credentials error", rec.Body)
+	}
+	if _, ok := loadUser("nobody"); ok {
+		t.Errorf("unknown client was cached")
+	}
+}
+
+func TestTokenWrongSecret(t *testing.T) {
+	useUserLookup(t, map[string]*User{"client": newUser("secret", []string{"read"})})
+	t.Cleanup(func() { users.Delete("client") })
+	r := newTestRouter(t, &Config{})
+
+	rec := postForm(r, "/token/", url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {"client"},
+		"client_secret": {"guess"},
+		"scope":         {"read"},
+	})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d: %s", rec.Code, http.StatusBadRequest, rec.Body)
+	}
+}
+
+// postAdminUser sends a registration to /admin/users on r with adminToken
+func postAdminUser(r *gin.Engine, adminToken string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/admin/users", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if adminToken != "" {
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAdminUsersRejected(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured string
+		token      string
+		body       string
+		want       int
+	}{
+		{"disabled", "", "admin-secret", `{"client_id":"c","client_secret":"s","scope":["read"]}`, http.StatusNotFound},
+		{"no token", "admin-secret", "", `{"client_id":"c","client_secret":"s","scope":["read"]}`, http.StatusUnauthorized},
+		{"wrong token", "admin-secret", "guess", `{"client_id":"c","client_secret":"s","scope":["read"]}`, http.StatusUnauthorized},
+		{"no scope", "admin-secret", "admin-secret", `{"client_id":"c","client_secret":"s","scope":[]}`, http.StatusBadRequest},
+		{"no secret", "admin-secret", "admin-secret", `{"client_id":"c","scope":["read"]}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRouter(t, &Config{AdminToken: tt.configured})
+			rec := postAdminUser(r, tt.token, tt.body)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if _, ok := loadUser("c"); ok {
+				t.Errorf("rejected user was cached")
+			}
+		})
+	}
+}
+
+func TestUserTokenSkipsExpired(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	setTokenClock(t, now)
+
+	user := newUser("secret", []string{"read", "write"})
+	user.SetToken("read", "read-token", "read-refresh", now.Add(time.Minute))
+	user.SetToken("write", "write-token", "write-refresh", now.Add(-time.Second))
+
+	token, refresh_token, expires, ok := user.Token("read")
+	if !ok || token != "read-token" || refresh_token != "read-refresh" || !expires.Equal(now.Add(time.Minute)) {
+		t.Errorf("Token(read) = %q, %q, %v, %v; want the cached pair", token, refresh_token, expires, ok)
+	}
+	if token, _, _, ok := user.Token("write"); ok {
+		t.Errorf("Token(write) returned expired token %q", token)
+	}
+}
+
+func TestAddTokenReportsCachedExpiry(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	setTokenClock(t, now)
+
+	user := newUser("secret", []string{"read"})
+	user.SetToken("read", "read-token", "read-refresh", now.Add(10*time.Minute))
+	cacheUser(t, "cached-client", user)
+
+	token, _, expires, err := AddToken(context.Background(), "cached-client", "read")
+	if err != nil {
+		t.Fatalf("AddToken: %v", err)
+	}
+	if token != "read-token" {
+		t.Errorf("AddToken token = %q, want the cached one", token)
+	}
+	if got := expiresIn(expires); got != 600 {
+		t.Errorf("expires_in = %d, want the remaining 600 seconds", got)
+	}
+}
+
+func TestClearExpiredUserTokens(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	setTokenClock(t, now.Add(-time.Hour))
+
+	user := newUser("secret", []string{"read", "write"})
+	user.SetToken("read", "read-token", "read-refresh", now.Add(time.Minute))
+	user.SetToken("write", "write-token", "write-refresh", now)
+	cacheUser(t, "sweep-client", user)
+
+	clearExpiredUserTokens(now)
+
+	user.mutex.RLock()
+	defer user.mutex.RUnlock()
+	if user.Tokens[0] != "read-token" {
+		t.Errorf("live token was cleared: %q", user.Tokens[0])
+	}
+	if user.Tokens[1] != "" || user.RefreshTokens[1] != "" || !user.Expirations[1].IsZero() {
+		t.Errorf("expired token still cached: %q", user.Tokens[1])
+	}
+}
+
+func TestConcurrentAddTokenIssuesOnce(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	setTokenClock(t, now)
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	previous := tokenIssuer
+	tokenIssuer = func(ctx context.Context, client_id string, scope string) (string, string, time.Time, error) {
+		n := calls.Add(1)
+		<-release
+		return "token-" + strconv.Itoa(int(n)), "refresh", now.Add(time.Hour), nil
+	}
+	t.Cleanup(func() { tokenIssuer = previous })
+
+	const callers = 50
+	var ready, done sync.WaitGroup
+	ready.Add(callers)
+	done.Add(callers)
+	got := make([]string, callers)
+	errs := make([]error, callers)
+	for i := 0; i < callers; i++ {
+		go func(i int) {
+			defer done.Done()
+			ready.Done()
+			got[i], _, _, errs[i] = AddToken(context.Background(), "concurrent-client", "read")
+		}(i)
+	}
+	// Hold the first issue open until every caller has had time to join it
+	ready.Wait()
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	done.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("issued %d tokens, want 1", n)
+	}
+	for i := range got {
+		if errs[i] != nil {
+			t.Fatalf("AddToken: %v", errs[i])
+		}
+		if got[i] != got[0] {
+			t.Errorf("caller %d got %q, caller 0 got %q", i, got[i], got[0])
+		}
+	}
+}
+
+func TestConcurrentUserTokenAccess(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	setTokenClock(t, now)
+
+	user := newUser("secret", []string{"read", "write"})
+	cacheUser(t, "race-client", user)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				n := strconv.Itoa(i*1000 + j)
+				scope := []string{"read", "write"}[j%2]
+				// Read tokens are already expired, for the sweeps to find
+				user.SetToken(scope, "token-"+n, "refresh-"+n, now.Add(time.Duration(j%2*2-1)*time.Minute))
+				if token, refresh_token, _, ok := user.Token(scope); ok && strings.TrimPrefix(token, "token-") != strings.TrimPrefix(refresh_token, "refresh-") {
+					t.Errorf("Token(%s) = %q, %q; a torn pair", scope, token, refresh_token)
+					return
+				}
+				if cached, ok := loadUser("race-client"); ok && j%3 == 0 {
+					cached.ClearToken(scope, "token-"+n)
+				}
+				if j%10 == 0 {
+					clearExpiredUserTokens(now)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	user.ClearExpiredTokens(now)
+	user.mutex.RLock()
+	defer user.mutex.RUnlock()
+	if user.Tokens[0] != "" {
+		t.Errorf("expired token still cached after the sweep: %q", user.Tokens[0])
+	}
+}
+
+func TestEvictToken(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	setTokenClock(t, now)
+
+	user := newUser("secret", []string{"read", "write"})
+	user.SetToken("read", "read-token", "read-refresh", now.Add(time.Hour))
+	user.SetToken("write", "write-token", "write-refresh", now.Add(time.Hour))
+	cacheUser(t, "evict-client", user)
+	tokens.Store("read-token", TokenInfo{"evict-client", "read", now.Add(time.Hour)})
+	t.Cleanup(func() { tokens.Delete("read-token") })
+
+	evictToken("evict-client", "read", "read-token")
+	// A token already replaced in the user's slot leaves the new one alone
+	evictToken("evict-client", "write", "old-write-token")
+
+	if _, ok := tokens.Load("read-token"); ok {
+		t.Errorf("revoked token still in the tokens cache")
+	}
+	if token, _, _, ok := user.Token("read"); ok {
+		t.Errorf("Token(read) = %q after revoking it, want none", token)
+	}
+	if token, _, _, ok := user.Token("write"); !ok || token != "write-token" {
+		t.Errorf("Token(write) = %q, %v; want the live write-token", token, ok)
+	}
+}
+
+func TestUserTokenScopeSets(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	setTokenClock(t, now)
+
+	user := newUser("secret", []string{"read", "write"})
+	user.SetToken("read write", "both-token", "both-refresh", now.Add(time.Minute))
+	user.SetToken("read", "read-token", "read-refresh", now.Add(time.Minute))
+
+	if token, _, _, ok := user.Token("read write"); !ok || token != "both-token" {
+		t.Errorf("Token(read write) = %q, %v; want both-token", token, ok)
+	}
+	// A token for a scope set is not handed out for one of its scopes
+	if token, _, _, ok := user.Token("read"); !ok || token != "read-token" {
+		t.Errorf("Token(read) = %q, %v; want read-token", token, ok)
+	}
+	if token, _, _, ok := user.Token("write"); ok {
+		t.Errorf("Token(write) = %q, want none", token)
+	}
+
+	user.SetToken("read write", "new-both-token", "both-refresh", now.Add(time.Minute))
+	if len(user.Tokens) != 3 {
+		t.Errorf("user has %d token slots, want 3 after replacing a token", len(user.Tokens))
+	}
+	user.ClearToken("read write", "new-both-token")
+	if token, _, _, ok := user.Token("read write"); ok {
+		t.Errorf("Token(read write) = %q after clearing it, want none", token)
+	}
+}
+
+func TestAddTokenCachedScopeSet(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	setTokenClock(t, now)
+
+	user := newUser("secret", []string{"read", "write"})
+	user.SetToken("read write", "both-token", "both-refresh", now.Add(time.Hour))
+	cacheUser(t, "scope-set-client", user)
+
+	token, _, _, err := AddToken(context.Background(), "scope-set-client", "read write")
+	if err != nil {
+		t.Fatalf("AddToken: %v", err)
+	}
+	if token != "both-token" {
+		t.Errorf("AddToken token = %q, want the cached one", token)
+	}
+}
+
+func TestClientCredentialsScopes(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	setTokenClock(t, now)
+
+	var issued []string
+	previous := tokenIssuer
+	tokenIssuer = func(ctx context.Context, client_id string, scope string) (string, string, time.Time, error) {
+		issued = append(issued, scope)
+		return "token", "refresh", now.Add(time.Hour), nil
+	}
+	t.Cleanup(func() { tokenIssuer = previous })
+	cacheUser(t, "scopes-client", newUser("secret", []string{"read", "write", "admin"}))
+
+	tests := []struct {
+		scope     string
+		wantCode  int
+		wantScope string
+	}{
+		{"write read", 200, "read write"},
+		{"read  write read", 200, "read write"},
+		{"admin", 200, "admin"},
+		{"read delete", 400, ""},
+		{"delete", 400, ""},
+		{"   ", 400, ""},
+	}
+	for _, tt := range tests {
+		issued = nil
+		rec := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rec)
+		c.Request = httptest.NewRequest("POST", "/token/", nil)
+		handleClientCredentials(c, TokenRequest{ClientId: "scopes-client", Scope: tt.scope, ClientSecret: "secret", GrantType: "client_credentials"})
+
+		if rec.Code != tt.wantCode {
+			t.Errorf("scope %q: status = %d, want %d: %s", tt.scope, rec.Code, tt.wantCode, rec.Body)
+			continue
+		}
+		if tt.wantCode != 200 {
+			// A partly allowed request gets no token at all
+			if len(issued) != 0 {
+				t.Errorf("scope %q: issued tokens for %q, want none", tt.scope, issued)
+			}
+			continue
+		}
+		if len(issued) != 1 || issued[0] != tt.wantScope {
+			t.Errorf("scope %q: issued tokens for %q, want one for %q", tt.scope, issued, tt.wantScope)
+		}
+		if !strings.Contains(rec.Body.String(), `"scope":"`+tt.wantScope+`"`) {
+			t.Errorf("scope %q: response %s does not grant %q", tt.scope, rec.Body, tt.wantScope)
+		}
+	}
+}
+
+func TestTokenBucketRefill(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiterWithClock(3, 2, func() time.Time { return now })
+
+	for i := 0; i < 3; i++ {
+		if !limiter.IsAllowed("client") {
+			t.Fatalf("request %d denied from a full bucket of 3", i)
+		}
+	}
+	if limiter.IsAllowed("client") {
+		t.Fatalf("request allowed from an empty bucket")
+	}
+
+	// Two tokens a second: a quarter second is not enough, half a second is
+	now = now.Add(250 * time.Millisecond)
+	if limiter.IsAllowed("client") {
+		t.Errorf("request allowed with half a token refilled")
+	}
+	now = now.Add(250 * time.Millisecond)
+	if !limiter.IsAllowed("client") {
+		t.Errorf("request denied after a whole token refilled")
+	}
+
+	// However long the client is idle, the bucket holds no more than its capacity
+	now = now.Add(time.Hour)
+	if got := limiter.getLimiter("client").Remaining(); got != 3 {
+		t.Errorf("tokens after an hour idle = %v, want the capacity of 3", got)
+	}
+}
+
+func TestAllowNFractionalCosts(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiterWithClock(2, 1, func() time.Time { return now })
+
+	for i := 0; i < 4; i++ {
+		if !limiter.IsAllowedN("client", 0.5) {
+			t.Fatalf("request %d costing 0.5 denied with %.1f tokens left", i, limiter.getLimiter("client").Tokens())
+		}
+	}
+	if limiter.IsAllowedN("client", 0.5) {
+		t.Errorf("fifth request costing 0.5 allowed from an empty bucket")
+	}
+
+	// Half a second refills half a token
+	now = now.Add(500 * time.Millisecond)
+	if !limiter.IsAllowedN("client", 0.5) {
+		t.Errorf("request costing 0.5 denied after refilling 0.5")
+	}
+}
+
+func TestAllowNRejectsWithoutConsuming(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiterWithClock(5, 1, func() time.Time { return now })
+
+	if !limiter.IsAllowedN("client", 3) {
+		t.Fatalf("request costing 3 denied from a full bucket of 5")
+	}
+	// Costing more than the 2 tokens left, it is turned away
+	if limiter.IsAllowedN("client", 3) {
+		t.Errorf("request costing 3 allowed with 2 tokens left")
+	}
+	if got := limiter.getLimiter("client").Tokens(); got != 2 {
+		t.Errorf("tokens after the rejected request = %v, want the 2 left untouched", got)
+	}
+	if !limiter.IsAllowedN("client", 2) {
+		t.Errorf("request costing 2 denied with 2 tokens left")
+	}
+}
+
+func TestAllowNCostAboveCapacity(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiterWithClock(3, 1, func() time.Time { return now })
+
+	// Such a request takes the whole bucket instead of never getting through
+	if !limiter.IsAllowedN("client", 10) {
+		t.Fatalf("request costing more than the capacity denied from a full bucket")
+	}
+	if limiter.IsAllowed("client") {
+		t.Errorf("request allowed after the bucket was emptied")
+	}
+}
+
+func TestParseRouteCosts(t *testing.T) {
+	costs, err := parseRouteCosts(" /token/ = 5, /check/=0.5 ,")
+	if err != nil {
+		t.Fatalf("parseRouteCosts: %v", err)
+	}
+	if costs["/token/"] != 5 || costs["/check/"] != 0.5 || len(costs) != 2 {
+		t.Errorf("parseRouteCosts = %v", costs)
+	}
+	for _, bad := range []string{"/token/", "/token/=0", "/token/=-1", "/token/=five", "=5"} {
+		if _, err := parseRouteCosts(bad); err == nil {
+			t.Errorf("parseRouteCosts(%q) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestMiddlewareChargesRouteCost(t *testing.T) {
+	previousCosts := routeCosts
+	routeCosts = map[string]float64{"/token/": 5, "/check/": 1}
+	t.Cleanup(func() { routeCosts = previousCosts })
+	// Both routes draw from the same bucket
+	previousOrder := identityOrder
+	identityOrder = []string{IdentityIP}
+	t.Cleanup(func() { identityOrder = previousOrder })
+	useUserLookup(t, nil)
+	r := newTestRouter(t, &Config{})
+	rateLimiter = NewRateLimiter(6, 0.001)
+
+	form := url.Values{"grant_type": {"client_credentials"}, "client_id": {"nobody"}, "client_secret": {"s"}, "scope": {"read"}}
+	if rec := postForm(r, "/token/", form); rec.Code == http.StatusTooManyRequests {
+		t.Fatalf("first /token/ request rate limited")
+	}
+	// The 1 token left pays for a check but not for another token request
+	if rec := postForm(r, "/token/", form); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("second /token/ status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	req := httptest.NewRequest("GET", "/check/", nil)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code == http.StatusTooManyRequests {
+		t.Errorf("/check/ rate limited with a token left")
+	}
+}
+
+func TestDBCallsTimeOut(t *testing.T) {
+	useStalledDB(t, 50*time.Millisecond)
+
+	calls := map[string]func(ctx context.Context) error{
+		"getUser": func(ctx context.Context) error {
+			_, _, err := getUser(ctx, "stalled-client")
+			return err
+		},
+		"get_token": func(ctx context.Context) error {
+			_, _, _, err := get_token(ctx, "stalled-client", "read")
+			return err
+		},
+		"CheckToken": func(ctx context.Context) error {
+			_, err := CheckToken(ctx, "stalled-token")
+			return err
+		},
+		"RefreshToken": func(ctx context.Context) error {
+			_, _, err := RefreshToken(ctx, "stalled-refresh-token")
+			return err
+		},
+		"RevokeToken": func(ctx context.Context) error {
+			return RevokeToken(ctx, "stalled-client", "stalled-token")
+		},
+	}
+	for name, call := range calls {
+		t.Run(name, func(t *testing.T) {
+			start := time.Now()
+			err := call(context.Background())
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("error = %v, want %v", err, context.DeadlineExceeded)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("call returned after %s, want it cut off by the 50ms timeout", elapsed)
+			}
+		})
+	}
+}
+
+func TestTokenEndpointTimeout(t *testing.T) {
+	useStalledDB(t, 50*time.Millisecond)
+	r := newTestRouter(t, &Config{})
+
+	rec := postForm(r, "/token/", url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {"stalled-client"},
+		"client_secret": {"secret"},
+		"scope":         {"read"},
+	})
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d: %s", rec.Code, http.StatusServiceUnavailable, rec.Body)
+	}
+	if !strings.Contains(rec.Body.String(), ErrDatabaseTimeout.Error()) {
+		t.Errorf("body = %s, want the database timeout error", rec.Body)
+	}
+}
+
+func TestDatabaseErrorsAnswer503(t *testing.T) {
+	useBrokenDB(t)
+	r := newTestRouter(t, &Config{})
+
+	tests := []struct {
+		name string
+		req  func() *http.Request
+	}{
+		{"client_credentials", func() *http.Request {
+			return formRequest("/token/", url.Values{"grant_type": {"client_credentials"}, "client_id": {"c"}, "client_secret": {"s"}, "scope": {"read"}})
+		}},
+		{"refresh_token", func() *http.Request {
+			return formRequest("/token/", url.Values{"grant_type": {"refresh_token"}, "refresh_token": {"r"}})
+		}},
+		{"revoke", func() *http.Request {
+			return formRequest("/revoke/", url.Values{"client_id": {"c"}, "client_secret": {"s"}, "token": {"t"}})
+		}},
+		{"check", func() *http.Request {
+			req := httptest.NewRequest("GET", "/check/", nil)
+			req.Header.Set("Authorization", "Bearer unknown-token")
+			return req
+		}},
+	}
+	// Run everything twice: the server keeps answering after a failure
+	for i := 0; i < 2; i++ {
+		for _, tt := range tests {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, tt.req())
+			if rec.Code != http.StatusServiceUnavailable {
+				t.Errorf("%s: status = %d, want %d: %s", tt.name, rec.Code, http.StatusServiceUnavailable, rec.Body)
+			}
+			if !strings.Contains(rec.Body.String(), ErrDatabaseUnavailable.Error()) {
+				t.Errorf("%s: body = %s, want the database unavailable error", tt.name, rec.Body)
+			}
+		}
+	}
+}
+
+func TestLoadAllUsersReturnsError(t *testing.T) {
+	useBrokenDB(t)
+
+	// GetAllUsers retries on this error instead of exiting
+	if _, err := loadAllUsers(context.Background()); err == nil {
+		t.Errorf("loadAllUsers succeeded against a broken database")
+	}
+}
+
+// getCheck asks /check/ on r about token and decodes the answer
+func getCheck(t *testing.T, r *gin.Engine, token string) map[string]any {
+	t.Helper()
+
+	req := httptest.NewRequest("GET", "/check/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode %s: %v", rec.Body, err)
+	}
+	return body
+}
+
+func TestCheckActiveToken(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	setTokenClock(t, now)
+	tokens.Store("active-token", TokenInfo{"client", "read write", now.Add(10 * time.Minute)})
+	t.Cleanup(func() { tokens.Delete("active-token") })
+	r := newTestRouter(t, &Config{})
+
+	body := getCheck(t, r, "active-token")
+	want := map[string]any{
+		"active":             true,
+		"client_id":          "client",
+		"scope":              "read write",
+		"token_type":         "Bearer",
+		"exp":                float64(now.Add(10 * time.Minute).Unix()),
+		"expires_at":         "2024-01-01T12:10:00Z",
+		"expires_in_seconds": float64(600),
+	}
+	if !reflect.DeepEqual(body, want) {
+		t.Errorf("/check/ = %v, want %v", body, want)
+	}
+}
+
+func TestCheckInactiveJWT(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	setTokenClock(t, now)
+	signer := useJWTSigner(t)
+	useJWTIDs(t, "jwt-id")
+	r := newTestRouter(t, &Config{})
+
+	active, err := signer.Issue("jwt-id", "client", "read", time.Hour)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	revoked, err := signer.Issue("revoked-id", "client", "read", time.Hour)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if body := getCheck(t, r, active); body["active"] != true || body["exp"] != float64(now.Add(time.Hour).Unix()) {
+		t.Errorf("/check/ of an active token = %v, want it active until %d", body, now.Add(time.Hour).Unix())
+	}
+
+	// Introspection answers 200 with only active=false for every dead token
+	for name, token := range map[string]string{"revoked": revoked, "unknown": "not-a-jwt"} {
+		if body := getCheck(t, r, token); !reflect.DeepEqual(body, map[string]any{"active": false}) {
+			t.Errorf("/check/ of a %s token = %v, want only active=false", name, body)
+		}
+	}
+	setTokenClock(t, now.Add(2*time.Hour))
+	if body := getCheck(t, r, active); !reflect.DeepEqual(body, map[string]any{"active": false}) {
+		t.Errorf("/check/ of an expired token = %v, want only active=false", body)
+	}
+}
+
+// useScopeTTLs sets the per-scope token lifetimes until the test ends
+func useScopeTTLs(t *testing.T, ttls map[string]time.Duration) {
+	t.Helper()
+
+	previous := scopeTTLs
+	scopeTTLs = ttls
+	t.Cleanup(func() { scopeTTLs = previous })
+}
+
+func TestTokenLifetime(t *testing.T) {
+	useScopeTTLs(t, map[string]time.Duration{"admin": 15 * time.Minute, "write": time.Hour})
+
+	tests := []struct {
+		scope string
+		want  time.Duration
+	}{
+		{"read", accessTokenLifetime},
+		{"write", time.Hour},
+		{"admin", 15 * time.Minute},
+		// A token is only as long-lived as its most sensitive scope
+		{"read write", time.Hour},
+		{"admin read write", 15 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := tokenLifetime(tt.scope); got != tt.want {
+			t.Errorf("tokenLifetime(%q) = %s, want %s", tt.scope, got, tt.want)
+		}
+	}
+}
+
+func TestParseScopeTTLs(t *testing.T) {
+	ttls, err := parseScopeTTLs(" admin=15m, write = 1h ,")
+	if err != nil {
+		t.Fatalf("parseScopeTTLs: %v", err)
+	}
+	want := map[string]time.Duration{"admin": 15 * time.Minute, "write": time.Hour}
+	if !reflect.DeepEqual(ttls, want) {
+		t.Errorf("parseScopeTTLs = %v, want %v", ttls, want)
+	}
+	for _, bad := range []string{"admin", "admin=", "admin=0s", "admin=-1m", "=15m", "admin=soon"} {
+		if _, err := parseScopeTTLs(bad); err == nil {
+			t.Errorf("parseScopeTTLs(%q) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestRateLimitHeadersCountDown(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	limiters := map[string]Limiter{
+		"token bucket":   NewRateLimiterWithClock(3, 1, clock),
+		"sliding window": NewSlidingWindowLimiterWithClock(3, 3*time.Second, clock),
+	}
+
+	for name, limiter := range limiters {
+		t.Run(name, func(t *testing.T) {
+			if got := limiter.State("client").Remaining; got != 3 {
+				t.Fatalf("Remaining before any request = %v, want 3", got)
+			}
+			for want := 2; want >= 0; want-- {
+				if !limiter.IsAllowed("client") {
+					t.Fatalf("request %d denied", 3-want)
+				}
+
+				rec := httptest.NewRecorder()
+				c, _ := gin.CreateTestContext(rec)
+				setRateLimitHeaders(c, limiter.State("client"))
+				if got := rec.Header().Get("X-RateLimit-Remaining"); got != strconv.Itoa(want) {
+					t.Errorf("X-RateLimit-Remaining = %s, want %d", got, want)
+				}
+				if got := rec.Header().Get("X-RateLimit-Limit"); got != "3" {
+					t.Errorf("X-RateLimit-Limit = %s, want 3", got)
+				}
+			}
+			if limiter.IsAllowed("client") {
+				t.Errorf("request over the limit allowed")
+			}
+			if got := limiter.State("other").Remaining; got != 3 {
+				t.Errorf("another client's Remaining = %v, want 3", got)
+			}
+		})
+	}
+}
+
+func TestRateLimitResetHeaderRoundsUp(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+
+	setRateLimitHeaders(c, LimitState{Limit: 3, Remaining: 0, ResetIn: 1500 * time.Millisecond, Now: now})
+	if got, want := rec.Header().Get("X-RateLimit-Reset"), strconv.FormatInt(now.Add(2*time.Second).Unix(), 10); got != want {
+		t.Errorf("X-RateLimit-Reset = %s, want %s", got, want)
+	}
+}
+
+// maxInWindow returns the most of times, sorted, that fall in any half-open
+// span of length window
+func maxInWindow(times []time.Time, window time.Duration) int {
+	most := 0
+	for i, start := range times {
+		n := 0
+		for _, t := range times[i:] {
+			if t.Sub(start) >= window {
+				break
+			}
+			n++
+		}
+		most = max(most, n)
+	}
+	return most
+}
+
+func TestSlidingWindowStopsBoundaryBursts(t *testing.T) {
+	const limit = 10
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+	clock := func() time.Time { return now }
+
+	tests := []struct {
+		name    string
+		limiter Limiter
+		want    int
+	}{
+		// A full bucket plus what refills before the first request leaves the window
+		{"token bucket", NewRateLimiterWithClock(limit, limit, clock), 2*limit - 1},
+		{"sliding window", NewSlidingWindowLimiterWithClock(limit, time.Second, clock), limit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Send as many requests as are allowed every 100ms for two seconds
+			var allowed []time.Time
+			for now = start; now.Before(start.Add(2 * time.Second)); now = now.Add(100 * time.Millisecond) {
+				for tt.limiter.IsAllowed("client") {
+					allowed = append(allowed, now)
+				}
+			}
+
+			if got := maxInWindow(allowed, time.Second); got != tt.want {
+				t.Errorf("let %d requests through within one second, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvictIdleBuckets(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	var mu sync.Mutex
+	now := start
+	setNow := func(t time.Time) {
+		mu.Lock()
+		defer mu.Unlock()
+		now = t
+	}
+	clock := func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	rl := NewRateLimiterWithClock(10, 1, clock)
+
+	for i := 0; i < 100; i++ {
+		rl.IsAllowed(fmt.Sprintf("idle-%d", i))
+	}
+	// Drained too far to refill before the TTL passes
+	for i := 0; i < 10; i++ {
+		rl.IsAllowed("drained")
+	}
+	setNow(start.Add(5500 * time.Millisecond))
+	rl.IsAllowed("recent")
+
+	setNow(start.Add(6 * time.Second))
+	if evicted := rl.EvictIdle(5 * time.Second); evicted != 100 {
+		t.Errorf("EvictIdle evicted %d buckets, want 100", evicted)
+	}
+	if clients := rl.Clients(); clients != 2 {
+		t.Errorf("Clients = %d after eviction, want the drained and recent ones", clients)
+	}
+
+	// Once refilled, the rest go as well
+	setNow(start.Add(time.Minute))
+	stop := rl.StartJanitor(time.Millisecond, 5*time.Second)
+	defer stop()
+	deadline := time.Now().Add(time.Second)
+	for rl.Clients() != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if clients := rl.Clients(); clients != 0 {
+		t.Errorf("Clients = %d after the janitor ran, want 0", clients)
+	}
+	stop()
+}
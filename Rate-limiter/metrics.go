@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Client classes of rate limited requests: identified by client_id or
+// token, or only by the IP address shared by everyone behind it
+const (
+	ClientClassIdentified = "identified"
+	ClientClassIP         = "ip"
+)
+
+// metricsRegistry holds the service's collectors, served by the admin server
+var metricsRegistry = prometheus.NewRegistry()
+
+var (
+	rateLimitDecisions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "rate_limiter_requests_total",
+		Help: "Requests checked by the rate limiter, by route, client class and decision.",
+	}, []string{"route", "client_class", "decision"})
+
+	tokenDBDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "rate_limiter_token_db_duration_seconds",
+		Help:    "Latency of the token endpoint's database queries.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 2, 13),
+	}, []string{"query"})
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		rateLimitDecisions,
+		tokenDBDuration,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "rate_limiter_active_buckets",
+			Help: "Clients the rate limiter currently keeps state for.",
+		}, func() float64 {
+			if rateLimiter == nil {
+				return 0
+			}
+			return float64(rateLimiter.Clients())
+		}),
+	)
+}
+
+// observeRateLimit counts a rate limiting decision. route is the matched
+// route pattern, so unknown paths can't grow the label set.
+func observeRateLimit(route string, source string, allowed bool) {
+	if route == "" {
+		route = "unmatched"
+	}
+	class := ClientClassIdentified
+	if source == IdentityIP {
+		class = ClientClassIP
+	}
+	decision := "allowed"
+	if !allowed {
+		decision = "rejected"
+	}
+	rateLimitDecisions.WithLabelValues(route, class, decision).Inc()
+}
+
+// observeTokenQuery records how long a token endpoint query took since start
+func observeTokenQuery(query string, start time.Time) {
+	tokenDBDuration.WithLabelValues(query).Observe(time.Since(start).Seconds())
+}
+
+// serveMetrics serves /metrics on addr, apart from the public endpoints
+func serveMetrics(addr string) error {
+	return http.ListenAndServe(addr, metricsHandler())
+}
+
+// metricsHandler serves the collectors in metricsRegistry on /metrics
+func metricsHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{}))
+	return mux
+}
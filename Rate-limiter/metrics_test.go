@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// scrapeMetrics fetches /metrics and returns each sample by its name and labels
+func scrapeMetrics(t *testing.T) map[string]float64 {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	metricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("/metrics status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	samples := make(map[string]float64)
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndex(line, " ")
+		value, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			t.Fatalf("failed to parse sample %q: %v", line, err)
+		}
+		samples[line[:i]] = value
+	}
+	return samples
+}
+
+func TestMetricsCountDecisions(t *testing.T) {
+	useUserLookup(t, nil)
+	r := newTestRouter(t, &Config{})
+	// Room for one /token/ request per client
+	rateLimiter = NewRateLimiter(5, 0.001)
+
+	const (
+		identifiedAllowed  = `rate_limiter_requests_total{client_class="identified",decision="allowed",route="/token/"}`
+		identifiedRejected = `rate_limiter_requests_total{client_class="identified",decision="rejected",route="/token/"}`
+		ipAllowed          = `rate_limiter_requests_total{client_class="ip",decision="allowed",route="/check/"}`
+	)
+	before := scrapeMetrics(t)
+
+	form := url.Values{"grant_type": {"client_credentials"}, "client_id": {"metrics-client"}, "client_secret": {"s"}, "scope": {"read"}}
+	postForm(r, "/token/", form)
+	postForm(r, "/token/", form)
+	postForm(r, "/token/", form)
+	// Without a token to go by, /check/ falls back to the IP address
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/check/", nil))
+
+	after := scrapeMetrics(t)
+	for sample, want := range map[string]float64{identifiedAllowed: 1, identifiedRejected: 2, ipAllowed: 1} {
+		if got := after[sample] - before[sample]; got != want {
+			t.Errorf("%s moved by %v, want %v", sample, got, want)
+		}
+	}
+	if got := after["rate_limiter_active_buckets"]; got != 2 {
+		t.Errorf("rate_limiter_active_buckets = %v, want 2", got)
+	}
+}
+
+func TestMetricsTokenQueryLatency(t *testing.T) {
+	useBrokenDB(t)
+
+	const lookups = `rate_limiter_token_db_duration_seconds_count{query="lookup"}`
+	before := scrapeMetrics(t)
+	// Failed queries are timed too
+	get_token(context.Background(), "metrics-client", "read")
+	after := scrapeMetrics(t)
+	if got := after[lookups] - before[lookups]; got != 1 {
+		t.Errorf("%s moved by %v, want 1", lookups, got)
+	}
+}
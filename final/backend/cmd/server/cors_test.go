@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"videostreaming/internal/config"
+	"videostreaming/internal/metrics"
+	"videostreaming/internal/service/video"
+	"videostreaming/internal/storage/memory"
+)
+
+func TestCORS(t *testing.T) {
+	tests := []struct {
+		name            string
+		origins         []string
+		origin          string
+		wantAllowOrigin string
+		wantCredentials bool
+	}{
+		{"listed origin", []string{"https://app.example.com"}, "https://app.example.com", "https://app.example.com", true},
+		{"unlisted origin", []string{"https://app.example.com"}, "https://evil.example.com", "", false},
+		// Browsers reject credentials for a wildcard, so they are never offered
+		{"wildcard", []string{"*"}, "https://evil.example.com", "*", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, _ := newTestFileStorage(t)
+			svc := video.NewService(memory.NewVideoStorage(), files, nil, nil)
+			cfg := &config.Config{CORSAllowedOrigins: tt.origins}
+			handler := newRESTServer(cfg, svc, files, metrics.New(prometheus.NewRegistry()), nil).Handler
+
+			req := httptest.NewRequest(http.MethodGet, "/health", nil)
+			req.Header.Set("Origin", tt.origin)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantAllowOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantAllowOrigin)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Credentials") == "true"; got != tt.wantCredentials {
+				t.Errorf("credentials allowed = %v, want %v", got, tt.wantCredentials)
+			}
+		})
+	}
+}
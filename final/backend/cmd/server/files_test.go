@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"math/rand"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"videostreaming/internal/config"
+	"videostreaming/internal/metrics"
+	"videostreaming/internal/storage/filesystem"
+)
+
+// newTestFileStorage returns file storage rooted in a temporary directory
+func newTestFileStorage(t *testing.T) (*filesystem.FileSystemStorage, string) {
+	t.Helper()
+
+	root := t.TempDir()
+	files, err := filesystem.NewFileSystemStorage(root, "http://localhost:8080", []byte("test-signing-key"))
+	if err != nil {
+		t.Fatalf("NewFileSystemStorage: %v", err)
+	}
+	return files, root
+}
+
+// requestURI returns the path and query of a URL handed out by file storage
+func requestURI(t *testing.T, raw string) string {
+	t.Helper()
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("failed to parse %q: %v", raw, err)
+	}
+	return u.RequestURI()
+}
+
+func TestFileUploadLargeBody(t *testing.T) {
+	files, root := newTestFileStorage(t)
+	uploadURL, err := files.GenerateUploadURL(context.Background(), "videos/v1/source.mp4", "video/mp4", time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateUploadURL: %v", err)
+	}
+
+	// Stream the form as it is written, hashing the content on the way out
+	const size = 50 << 20
+	body, form := io.Pipe()
+	writer := multipart.NewWriter(form)
+	sent := sha256.New()
+	go func() {
+		part, err := writer.CreateFormFile("file", "source.mp4")
+		if err != nil {
+			form.CloseWithError(err)
+			return
+		}
+		content := io.LimitReader(rand.New(rand.NewSource(1)), size)
+		if _, err := io.Copy(io.MultiWriter(part, sent), content); err != nil {
+			form.CloseWithError(err)
+			return
+		}
+		form.CloseWithError(writer.Close())
+	}()
+
+	req := httptest.NewRequest(http.MethodPost, requestURI(t, uploadURL), body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rec := httptest.NewRecorder()
+	handleFileUpload(files)(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("upload status = %d (%s), want 200", rec.Code, rec.Body.String())
+	}
+
+	stored, err := os.Open(filepath.Join(root, "videos", "v1", "source.mp4"))
+	if err != nil {
+		t.Fatalf("stored file: %v", err)
+	}
+	defer stored.Close()
+	received := sha256.New()
+	n, err := io.Copy(received, stored)
+	if err != nil {
+		t.Fatalf("reading stored file: %v", err)
+	}
+	if n != size {
+		t.Fatalf("stored %d bytes, want %d", n, size)
+	}
+	if !bytes.Equal(received.Sum(nil), sent.Sum(nil)) {
+		t.Errorf("stored file differs from the uploaded one")
+	}
+}
+
+func TestFileDownloadRanges(t *testing.T) {
+	files, root := newTestFileStorage(t)
+	content := make([]byte, 1000)
+	for i := range content {
+		content[i] = byte(i)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "videos", "v1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "videos", "v1", "source.mp4"), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(root, "videos", "v1", "source.mp4"), modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	downloadURL, err := files.GenerateDownloadURL(context.Background(), "videos/v1/source.mp4", time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateDownloadURL: %v", err)
+	}
+	// Go through the real routes, so keys with several segments are covered
+	router := newRESTServer(&config.Config{}, nil, files, metrics.New(prometheus.NewRegistry()), nil).Handler
+
+	tests := []struct {
+		name       string
+		header     string
+		value      string
+		wantStatus int
+		wantRange  string
+		wantBody   []byte
+	}{
+		{"whole file", "", "", http.StatusOK, "", content},
+		{"from the start", "Range", "bytes=0-", http.StatusPartialContent, "bytes 0-999/1000", content},
+		{"middle of the file", "Range", "bytes=100-199", http.StatusPartialContent, "bytes 100-199/1000", content[100:200]},
+		{"last bytes", "Range", "bytes=-10", http.StatusPartialContent, "bytes 990-999/1000", content[990:]},
+		{"past the end", "Range", "bytes=1000-", http.StatusRequestedRangeNotSatisfiable, "bytes */1000", nil},
+		{"not modified", "If-Modified-Since", modTime.Format(http.TimeFormat), http.StatusNotModified, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, requestURI(t, downloadURL), nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d (%s), want %d", rec.Code, rec.Body.String(), tt.wantStatus)
+			}
+			if got := rec.Header().Get("Content-Range"); got != tt.wantRange {
+				t.Errorf("Content-Range = %q, want %q", got, tt.wantRange)
+			}
+			if tt.wantBody != nil {
+				if !bytes.Equal(rec.Body.Bytes(), tt.wantBody) {
+					t.Errorf("body is %d bytes, want the %d requested", rec.Body.Len(), len(tt.wantBody))
+				}
+				if got, want := rec.Header().Get("Content-Length"), fmt.Sprint(len(tt.wantBody)); got != want {
+					t.Errorf("Content-Length = %s, want %s", got, want)
+				}
+			}
+		})
+	}
+}
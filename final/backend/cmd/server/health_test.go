@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"videostreaming/internal/config"
+	"videostreaming/internal/metrics"
+	"videostreaming/internal/service/video"
+	"videostreaming/internal/storage/memory"
+)
+
+// pingStorage is storage whose Ping fails with err
+type pingStorage struct {
+	*memory.VideoStorage
+	err error
+}
+
+func (s *pingStorage) Ping(ctx context.Context) error {
+	return s.err
+}
+
+func TestHealthReady(t *testing.T) {
+	tests := []struct {
+		name       string
+		storageErr error
+		engineErr  error
+		wantCode   int
+		wantFailed []string
+	}{
+		{"all up", nil, nil, http.StatusOK, []string{}},
+		{"storage down", errors.New("server selection timeout"), nil, http.StatusServiceUnavailable, []string{"storage"}},
+		{"both down", errors.New("server selection timeout"), errors.New("connection refused"), http.StatusServiceUnavailable, []string{"mediamtx", "storage"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, _ := newTestFileStorage(t)
+			storage := &pingStorage{VideoStorage: memory.NewVideoStorage(), err: tt.storageErr}
+			checks := []readinessCheck{
+				{name: "mediamtx", ping: func(ctx context.Context) error { return tt.engineErr }},
+				{name: "storage", ping: storage.Ping},
+			}
+			svc := video.NewService(storage, files, nil, nil)
+			handler := newRESTServer(&config.Config{}, svc, files, metrics.New(prometheus.NewRegistry()), checks).Handler
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+			if w.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantCode)
+			}
+
+			var body struct {
+				Status string            `json:"status"`
+				Failed []string          `json:"failed"`
+				Checks map[string]string `json:"checks"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatalf("decoding body: %v", err)
+			}
+			if !slices.Equal(body.Failed, tt.wantFailed) {
+				t.Errorf("failed = %v, want %v", body.Failed, tt.wantFailed)
+			}
+			if tt.storageErr != nil && body.Checks["storage"] != tt.storageErr.Error() {
+				t.Errorf("storage check = %q, want the ping error", body.Checks["storage"])
+			}
+			if tt.engineErr == nil && body.Checks["mediamtx"] != "ok" {
+				t.Errorf("mediamtx check = %q, want ok", body.Checks["mediamtx"])
+			}
+
+			// Liveness doesn't depend on anything
+			w = httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+			if w.Code != http.StatusOK {
+				t.Errorf("/health status = %d, want 200 regardless of dependencies", w.Code)
+			}
+		})
+	}
+}
@@ -2,29 +2,55 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"math"
+	"mime/multipart"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.mongodb.org/mongo-driver/mongo"
+	mongooptions "go.mongodb.org/mongo-driver/mongo/options"
 	"google.golang.org/grpc"
-
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"videostreaming/internal/auth"
+	"videostreaming/internal/config"
+	"videostreaming/internal/logging"
+	"videostreaming/internal/metrics"
+	"videostreaming/internal/service/notification"
 	"videostreaming/internal/service/streaming"
 	"videostreaming/internal/service/transcode"
 	"videostreaming/internal/service/video"
+	"videostreaming/internal/storage/cache"
+	"videostreaming/internal/storage/cloud"
 	"videostreaming/internal/storage/filesystem"
 	"videostreaming/internal/storage/memory"
+	"videostreaming/internal/storage/mongodb"
+	"videostreaming/internal/storage/postgres"
 	pb "videostreaming/proto/video"
 )
 
@@ -65,31 +91,118 @@ func (a *TranscodingServiceAdapter) GetTranscodingStatus(ctx context.Context, vi
 	}, nil
 }
 
+// ExtractThumbnail delegates to the underlying transcode service
+func (a *TranscodingServiceAdapter) ExtractThumbnail(ctx context.Context, inputPath string, atSeconds float64, outputPath string) error {
+	return a.transcodeService.ExtractThumbnail(ctx, inputPath, atSeconds, outputPath)
+}
+
+// SubscribeTranscodingStatus delegates to the underlying transcode service
+func (a *TranscodingServiceAdapter) SubscribeTranscodingStatus(videoID string) (<-chan struct{}, func()) {
+	return a.transcodeService.SubscribeTranscodingStatus(videoID)
+}
+
+// GetMediaInfo probes a file and converts the result for the video service
+func (a *TranscodingServiceAdapter) GetMediaInfo(ctx context.Context, inputPath string) (*video.MediaInfo, error) {
+	info, err := a.transcodeService.GetMediaInfo(ctx, inputPath)
+	if err != nil {
+		return nil, err
+	}
+	
+	return &video.MediaInfo{
+		DurationSeconds: int64(math.Round(info.Duration)),
+		Resolution:      transcode.SourceResolution(info),
+	}, nil
+}
+
+// RestoreTranscodingJobs converts exported jobs into transcode jobs and saves them
+func (a *TranscodingServiceAdapter) RestoreTranscodingJobs(ctx context.Context, videoID string, inputPath string, jobs []*video.TranscodingJob) error {
+	transcodeJobs := make([]*transcode.TranscodingJob, 0, len(jobs))
+	for _, job := range jobs {
+		transcodeJobs = append(transcodeJobs, &transcode.TranscodingJob{
+			ID:           job.JobID,
+			Resolution:   job.Resolution,
+			Status:       job.Status,
+			Progress:     job.Progress,
+			ErrorMessage: job.ErrorMessage,
+		})
+	}
+	
+	return a.transcodeService.RestoreTranscodingJobs(ctx, videoID, inputPath, transcodeJobs)
+}
+
 func main() {
+	// Log JSON to stdout; the standard log package is routed through it too.
+	// The level is raised or lowered once the configuration is loaded.
+	logLevel := new(slog.LevelVar)
+	logger := slog.New(logging.NewHandler(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: logLevel})))
+	slog.SetDefault(logger)
+
 	// Load environment variables
 	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using environment variables")
+		logger.Info("No .env file found, using environment variables")
+	}
+
+	// Load and validate configuration
+	cfg, err := config.Load()
+	if err != nil {
+		fatal(logger, "Failed to load configuration", "error", err)
 	}
+	logLevel.Set(cfg.LogLevel)
 
 	// Set up storage directories
-	mediaDir := getEnv("MEDIA_DIR", "./media")
-	if err := os.MkdirAll(mediaDir, 0755); err != nil {
-		log.Fatalf("Failed to create media directory: %v", err)
+	if err := os.MkdirAll(cfg.MediaDir, 0755); err != nil {
+		fatal(logger, "Failed to create media directory", "dir", cfg.MediaDir, "error", err)
+	}
+
+	// Sign download URLs with the configured secret, or a throwaway one
+	signingKey := []byte(cfg.DownloadURLSecret)
+	if len(signingKey) == 0 {
+		logger.Warn("DOWNLOAD_URL_SECRET not set, download links will stop working on restart")
+		signingKey = make([]byte, 32)
+		if _, err := rand.Read(signingKey); err != nil {
+			fatal(logger, "Failed to generate download URL signing key", "error", err)
+		}
 	}
 
 	// Create file storage for videos and thumbnails
-	baseURL := getEnv("BASE_URL", "http://localhost:8080")
-	fileStorage, err := filesystem.NewFileSystemStorage(mediaDir, baseURL)
+	fileStorage, err := filesystem.NewFileSystemStorage(cfg.MediaDir, cfg.BaseURL, signingKey)
 	if err != nil {
-		log.Fatalf("Failed to create file storage: %v", err)
+		fatal(logger, "Failed to create file storage", "dir", cfg.MediaDir, "error", err)
+	}
+
+	// Keep video metadata and transcoding jobs in the configured backend
+	videoStorage, transcodeStorage, closeStorage, err := openStorage(cfg)
+	if err != nil {
+		fatal(logger, "Failed to open storage", "backend", cfg.StorageBackend, "error", err)
+	}
+	defer closeStorage()
+	
+	// Cache hot video metadata in front of the store unless disabled
+	if cfg.StorageCacheSize > 0 {
+		videoStorage = cache.NewVideoStorage(videoStorage, cfg.StorageCacheSize, cfg.StorageCacheTTL)
 	}
 
-	// Create in-memory storage for video metadata
-	videoStorage := memory.NewVideoStorage()
+	// Export metrics on /metrics from the default Prometheus registry
+	serviceMetrics := metrics.New(prometheus.DefaultRegisterer)
 
 	// Create mock implementations for development
-	ffmpegClient := &mockFFmpegClient{}
-	notificationService := &mockNotificationService{}
+	ffmpegClient := &mockFFmpegClient{logger: logger}
+	notificationService := &mockNotificationService{logger: logger}
+	
+	// Tell integrators about finished videos through their callback URLs
+	var transcodeNotifier transcode.NotificationService = notificationService
+	var videoNotifier video.Notifier = notificationService
+	if cfg.WebhookSecret != "" {
+		webhooks := notification.NewWebhookNotificationService(
+			notificationService,
+			videoStorage,
+			[]byte(cfg.WebhookSecret),
+			notification.WithTimeout(cfg.WebhookTimeout),
+			notification.WithMaxRetries(cfg.WebhookMaxRetries),
+			notification.WithLogger(logger),
+		)
+		transcodeNotifier, videoNotifier = webhooks, webhooks
+	}
 	
 	// Create real MediaMTX streaming engine
 	// The MediaMTX server is running on:
@@ -97,125 +210,461 @@ func main() {
 	// - HLS port 8888
 	// - WebRTC port 8889
 	streamingEngine := streaming.NewMediaMTXEngine(
-		getEnv("RTMP_URL", "rtmp://localhost:1935/live"),
-		getEnv("HLS_URL", "http://localhost:8888/live"),
-		getEnv("WEBRTC_URL", "http://localhost:8889/live"),
+		cfg.RTMPURL,
+		cfg.HLSURL,
+		cfg.WebRTCURL,
+		cfg.MediaMTXAPIURL,
+		streaming.WithPlaybackURLTemplate(cfg.PlaybackURLTemplate),
 	)
 	
 	// Create transcoding service
-	transcodingService := transcode.NewService(
-		&mockTranscodeStorage{}, 
+	transcodeOptions := []transcode.Option{
+		transcode.WithMaxRenditions(cfg.MaxRenditions),
+		transcode.WithMaxRetries(cfg.MaxTranscodeRetries),
+		transcode.WithCodec(cfg.TranscodeCodec),
+		transcode.WithAudioBitrate(cfg.TranscodeAudioBitrate),
+		transcode.WithLogger(logger),
+		transcode.WithMetrics(serviceMetrics),
+	}
+	if cfg.TranscodeBitrates != "" {
+		bitrates, err := transcode.ParseBitrates(cfg.TranscodeBitrates)
+		if err != nil {
+			fatal(logger, "Failed to parse TRANSCODE_BITRATES", "error", err)
+		}
+		transcodeOptions = append(transcodeOptions, transcode.WithBitrates(bitrates))
+	}
+	transcodingService, err := transcode.NewService(
+		transcodeStorage,
 		ffmpegClient, 
 		fileStorage, // Use fileStorage instead of S3Storage 
-		notificationService,
+		transcodeNotifier,
+		transcodeOptions...,
 	)
+	if err != nil {
+		fatal(logger, "Failed to create transcoding service", "error", err)
+	}
 	
 	// Create adapter for the transcoding service
 	transcodeAdapter := &TranscodingServiceAdapter{
 		transcodeService: transcodingService,
 	}
 
+	// The storage LRU already caches video metadata, so GetVideo only
+	// coalesces reads in front of it instead of caching a second copy
+	videoCacheTTL := cfg.VideoCacheTTL
+	if cfg.StorageCacheSize > 0 {
+		videoCacheTTL = 0
+	}
+
+	videoOptions := []video.Option{
+		video.WithMaxDescriptionLength(cfg.MaxDescriptionLength),
+		video.WithVideoCacheTTL(videoCacheTTL),
+		video.WithNotifier(videoNotifier),
+		video.WithRequireActivePublisher(cfg.RequireActivePublisher),
+		// Request bodies name any user they like, so only trust tokens
+		video.WithRequireAuthentication(cfg.AuthServiceURL != ""),
+		video.WithMaxViewersPerStream(int64(cfg.MaxStreamViewers)),
+		video.WithUserStorageQuota(int64(cfg.UserStorageQuota)),
+		video.WithTrashRetention(cfg.TrashRetention),
+		video.WithRecordingRetention(cfg.RecordingRetention, cfg.RecordingRetentionByUser),
+		video.WithDeleteRecordingAfterVOD(cfg.DeleteRecordingAfterVOD),
+		video.WithDedupeScope(video.DedupeScope(cfg.DedupeScope)),
+		video.WithLogger(logger),
+		video.WithMetrics(serviceMetrics),
+	}
+	
+	// Turn ended streams into videos from their MediaMTX recording
+	if cfg.AutoArchiveStreams {
+		// Load has already checked the recordings sit inside the media directory
+		keyPrefix, _ := cfg.RecordingsKeyPrefix()
+		pathPrefix := ""
+		if rtmpURL, err := url.Parse(cfg.RTMPURL); err == nil {
+			pathPrefix = strings.Trim(rtmpURL.Path, "/")
+		}
+		recordings := streaming.NewRecordingLocator(cfg.RecordingsDir, keyPrefix, pathPrefix)
+		videoOptions = append(videoOptions, video.WithAutoArchive(recordings))
+	}
+	
+	// Enable signed-cookie HLS access when a CloudFront key pair is configured
+	if cfg.CloudFrontKeyPairID != "" {
+		privateKey, err := os.ReadFile(cfg.CloudFrontPrivateKeyFile)
+		if err != nil {
+			fatal(logger, "Failed to read CloudFront private key", "file", cfg.CloudFrontPrivateKeyFile, "error", err)
+		}
+		cookieSigner, err := cloud.NewCloudFrontCookieSigner(cfg.CloudFrontKeyPairID, privateKey, cfg.CDNURL, cfg.CDNCookieDomain)
+		if err != nil {
+			fatal(logger, "Failed to create cookie signer", "error", err)
+		}
+		videoOptions = append(videoOptions, video.WithCookieSigner(cookieSigner))
+	}
+
 	// Create video service
 	videoService := video.NewService(
 		videoStorage,
 		fileStorage, // Use fileStorage instead of S3Storage
 		transcodeAdapter, // Use the adapter instead of the raw transcoding service
 		streamingEngine,
+		videoOptions...,
 	)
 
+	// Mark videos READY or FAILED as their transcoding jobs finish
+	notificationService.onTranscodingComplete = videoService.HandleTranscodingFinished
+
+	// Pick up jobs interrupted by the last shutdown
+	if err := transcodingService.LoadActiveJobs(context.Background()); err != nil {
+		logger.Error("Failed to load active transcoding jobs", "error", err)
+	}
+
+	// Background workers stop when the server shuts down
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Delete live stream recordings once they pass their owner's retention period
+	if cfg.SweepsRecordings() {
+		sweeper := streaming.NewRecordingSweeper(cfg.RecordingsDir, videoService, cfg.RecordingSweepInterval)
+		go sweeper.Run(ctx)
+	}
+
+	// Permanently remove deleted videos once they pass the trash retention
+	if cfg.TrashRetention > 0 {
+		go videoService.RunTrashPurge(ctx, cfg.TrashPurgeInterval)
+	}
+
+	// Readiness fails while a dependency needed to serve traffic is down
+	readinessChecks := []readinessCheck{{name: "mediamtx", ping: streamingEngine.Ping}}
+	if pinger, ok := videoStorage.(video.Pinger); ok {
+		readinessChecks = append(readinessChecks, readinessCheck{name: "storage", ping: pinger.Ping})
+	}
+
+	// Both servers share one certificate; without it they serve plaintext
+	tlsConfig, err := loadTLSConfig(cfg)
+	if err != nil {
+		fatal(logger, "Failed to load TLS certificate", "cert_file", cfg.TLSCertFile, "key_file", cfg.TLSKeyFile, "error", err)
+	}
+	if tlsConfig == nil {
+		logger.Warn("TLS_CERT_FILE not set, serving plaintext; tokens and stream keys are sent unencrypted")
+	}
+
 	// Start gRPC server
-	go startGRPCServer(videoService)
+	grpcServer := startGRPCServer(logger, cfg, tlsConfig, videoService, serviceMetrics)
 
 	// Start REST API server
-	go startRESTServer(videoService, fileStorage)
+	httpServer := startRESTServer(logger, cfg, tlsConfig, videoService, fileStorage, serviceMetrics, readinessChecks)
 
 	// Wait for termination signal
-	waitForSignal()
+	waitForSignal(logger)
+	cancel()
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancelShutdown()
+	shutdown(shutdownCtx, logger, grpcServer, httpServer)
+	logger.Info("Server stopped")
+}
+
+// fatal logs msg as an error and exits, like log.Fatal
+func fatal(logger *slog.Logger, msg string, args ...any) {
+	logger.Error(msg, args...)
+	os.Exit(1)
+}
+
+// storageStartupTimeout bounds connecting to the storage backend and
+// preparing its schema at startup
+const storageStartupTimeout = 30 * time.Second
+
+// openStorage connects to the backend chosen by STORAGE_BACKEND and prepares
+// it for use, creating missing indexes or tables. The returned function
+// closes the connection.
+func openStorage(cfg *config.Config) (video.Storage, transcode.TranscodeStorage, func(), error) {
+	ctx, cancel := context.WithTimeout(context.Background(), storageStartupTimeout)
+	defer cancel()
+
+	switch cfg.StorageBackend {
+	case "mongodb":
+		client, err := mongo.Connect(ctx, mongooptions.Client().ApplyURI(cfg.MongoURI))
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to connect: %w", err)
+		}
+		closeClient := func() { client.Disconnect(context.Background()) }
+		if err := client.Ping(ctx, nil); err != nil {
+			closeClient()
+			return nil, nil, nil, fmt.Errorf("failed to ping: %w", err)
+		}
+
+		videoStorage := mongodb.NewVideoStorage(client, cfg.MongoDatabase)
+		transcodeStorage := mongodb.NewTranscodeStorage(client, cfg.MongoDatabase)
+		if err := videoStorage.EnsureIndexes(ctx); err != nil {
+			closeClient()
+			return nil, nil, nil, err
+		}
+		if err := transcodeStorage.EnsureIndexes(ctx); err != nil {
+			closeClient()
+			return nil, nil, nil, err
+		}
+		return videoStorage, transcodeStorage, closeClient, nil
+
+	case "postgres":
+		pool, err := pgxpool.New(ctx, cfg.DatabaseURL)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to connect: %w", err)
+		}
+		if err := pool.Ping(ctx); err != nil {
+			pool.Close()
+			return nil, nil, nil, fmt.Errorf("failed to ping: %w", err)
+		}
+
+		// Migrate creates the transcoding job table as well
+		videoStorage := postgres.NewVideoStorage(pool)
+		if err := videoStorage.Migrate(ctx); err != nil {
+			pool.Close()
+			return nil, nil, nil, err
+		}
+		return videoStorage, postgres.NewTranscodeStorage(pool), pool.Close, nil
+
+	default:
+		return memory.NewVideoStorage(), memory.NewTranscodeStorage(), func() {}, nil
+	}
+}
+
+// loadTLSConfig returns the TLS configuration of the configured certificate,
+// or nil when TLS is not enabled
+func loadTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	if !cfg.TLSEnabled() {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
 }
 
-func startGRPCServer(videoService *video.Service) {
-	port := getEnv("GRPC_PORT", "50051")
+// startGRPCServer listens on the gRPC port and serves in the background,
+// over TLS when tlsConfig is set
+func startGRPCServer(logger *slog.Logger, cfg *config.Config, tlsConfig *tls.Config, videoService *video.Service, serviceMetrics *metrics.Metrics) *grpc.Server {
+	port := cfg.GRPCPort
 	listener, err := net.Listen("tcp", fmt.Sprintf(":%s", port))
 	if err != nil {
-		log.Fatalf("Failed to listen: %v", err)
+		fatal(logger, "Failed to listen", "port", port, "error", err)
 	}
 
-	grpcServer := grpc.NewServer()
-	pb.RegisterVideoServiceServer(grpcServer, videoService)
+	interceptors := []grpc.UnaryServerInterceptor{
+		logging.UnaryServerInterceptor,
+		serviceMetrics.UnaryServerInterceptor,
+	}
+	var streamInterceptors []grpc.StreamServerInterceptor
+	// Callers act as the client their token was issued to
+	if cfg.AuthServiceURL != "" {
+		checker := auth.NewTokenChecker(cfg.AuthServiceURL)
+		interceptors = append(interceptors, auth.UnaryServerInterceptor(checker))
+		streamInterceptors = append(streamInterceptors, auth.StreamServerInterceptor(checker))
+	}
+
+	serverOptions := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(interceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+	}
+	if tlsConfig != nil {
+		serverOptions = append(serverOptions, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
 
-	log.Printf("Starting gRPC server on port %s", port)
-	if err := grpcServer.Serve(listener); err != nil {
-		log.Fatalf("Failed to start gRPC server: %v", err)
+	grpcServer := grpc.NewServer(serverOptions...)
+	pb.RegisterVideoServiceServer(grpcServer, videoService)
+	if cfg.GRPCReflection {
+		reflection.Register(grpcServer)
 	}
+
+	logger.Info("Starting gRPC server", "port", port, "tls", tlsConfig != nil)
+	go func() {
+		if err := grpcServer.Serve(listener); err != nil {
+			fatal(logger, "Failed to start gRPC server", "port", port, "error", err)
+		}
+	}()
+	return grpcServer
+}
+
+// startRESTServer serves the REST API in the background, over TLS with
+// HTTP/2 when tlsConfig is set
+func startRESTServer(logger *slog.Logger, cfg *config.Config, tlsConfig *tls.Config, videoService *video.Service, fileStorage *filesystem.FileSystemStorage, serviceMetrics *metrics.Metrics, readinessChecks []readinessCheck) *http.Server {
+	server := newRESTServer(cfg, videoService, fileStorage, serviceMetrics, readinessChecks)
+	server.TLSConfig = tlsConfig
+
+	logger.Info("Starting REST server", "port", cfg.HTTPPort, "tls", tlsConfig != nil)
+	go func() {
+		var err error
+		if tlsConfig != nil {
+			// The certificate is already in TLSConfig; net/http negotiates HTTP/2 over ALPN
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			fatal(logger, "Failed to start REST server", "port", cfg.HTTPPort, "error", err)
+		}
+	}()
+	return server
 }
 
-func startRESTServer(videoService *video.Service, fileStorage *filesystem.FileSystemStorage) {
+// newRESTServer builds the REST API server without starting it
+func newRESTServer(cfg *config.Config, videoService *video.Service, fileStorage *filesystem.FileSystemStorage, serviceMetrics *metrics.Metrics, readinessChecks []readinessCheck) *http.Server {
 	router := chi.NewRouter()
 
 	// Middleware
-	router.Use(middleware.Logger)
+	router.Use(logging.RequestID)
+	router.Use(logging.RequestLogger(slog.Default()))
+	router.Use(serviceMetrics.HTTPMiddleware)
 	router.Use(middleware.Recoverer)
 	router.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
-		AllowCredentials: true,
+		AllowedOrigins:   cfg.CORSAllowedOrigins,
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", logging.RequestIDHeader},
+		ExposedHeaders:   []string{logging.RequestIDHeader},
+		// Credentials are only sent to origins that were listed explicitly
+		AllowCredentials: !cfg.AllowsAnyOrigin(),
 		MaxAge:           300, // Maximum value not ignored by any of major browsers
 	}))
 
 	// Define your REST API routes here
+	// Liveness: the process is up and serving
 	router.Get("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
 	})
+	router.Get("/health/ready", handleReady(readinessChecks))
+	router.Handle("/metrics", promhttp.Handler())
 
 	// File upload/download endpoints
 	router.Post("/upload", handleFileUpload(fileStorage))
-	router.Get("/download/{path:.+}", handleFileDownload(fileStorage))
+	// A wildcard, since chi's regexp parameters never span a "/"
+	router.Get("/download/*", handleFileDownload(fileStorage))
 
 	// API routes
 	router.Route("/api/v1", func(r chi.Router) {
+		// Requests act as the client their token was issued to
+		if cfg.AuthServiceURL != "" {
+			r.Use(auth.HTTPMiddleware(auth.NewTokenChecker(cfg.AuthServiceURL)))
+		}
+		
 		r.Route("/videos", func(r chi.Router) {
 			r.Get("/", handleListVideos(videoService))
+			r.Get("/search", handleSearchVideos(videoService))
+			r.Get("/batch", handleGetVideosBatch(videoService))
 			r.Post("/", handleInitiateUpload(videoService))
 			r.Get("/{videoID}", handleGetVideo(videoService))
+			r.Patch("/{videoID}", handleUpdateVideo(videoService))
 			r.Delete("/{videoID}", handleDeleteVideo(videoService))
 			r.Post("/{videoID}/complete", handleCompleteUpload(videoService))
+			r.Post("/{videoID}/thumbnail", handleInitiateThumbnailUpload(videoService))
+			r.Post("/{videoID}/thumbnail/complete", handleSetThumbnail(videoService))
+			r.Post("/{videoID}/thumbnail/capture", handleCaptureThumbnail(videoService))
+			r.Post("/{videoID}/playback-cookies", handlePlaybackCookies(videoService))
+			r.Post("/{videoID}/archive", handleArchiveVideo(videoService))
+			r.Post("/{videoID}/restore", handleRestoreFromArchive(videoService))
+			r.Post("/{videoID}/undelete", handleUndeleteVideo(videoService))
 		})
 
 		r.Route("/streams", func(r chi.Router) {
 			r.Get("/", handleListStreams(videoService))
 			r.Post("/key", handleGetStreamKey(videoService))
+			r.Post("/key/rotate", handleRotateStreamKey(videoService))
 			r.Post("/", handleStartStream(videoService))
+			r.Get("/batch", handleGetStreamsBatch(videoService))
+			r.Get("/browse", handleBrowseStreams(videoService))
 			r.Delete("/{streamID}", handleEndStream(videoService))
+			r.Post("/{streamID}/join", handleJoinStream(videoService))
+			r.Post("/{streamID}/leave", handleLeaveStream(videoService))
+			r.Post("/{streamID}/messages", handlePostStreamMessage(videoService))
+			r.Get("/{streamID}/messages", handleGetStreamMessages(videoService))
 			r.Get("/{streamID}", handleGetStream(videoService)) // Add this line to handle GET request for a specific stream
 		})
 	})
 
-	port := getEnv("HTTP_PORT", "8080")
-	server := &http.Server{
-		Addr:    fmt.Sprintf(":%s", port),
+	return &http.Server{
+		Addr:    fmt.Sprintf(":%s", cfg.HTTPPort),
 		Handler: router,
 	}
-
-	log.Printf("Starting REST server on port %s", port)
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		log.Fatalf("Failed to start REST server: %v", err)
-	}
 }
 
-func waitForSignal() {
+func waitForSignal(logger *slog.Logger) {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 	sig := <-sigChan
-	log.Printf("Received signal: %s, shutting down...", sig)
+	logger.Info("Received signal, shutting down", "signal", sig.String())
+}
+
+// shutdown stops both servers, letting in-flight requests finish until ctx
+// expires. Requests still running after that are cut off.
+func shutdown(ctx context.Context, logger *slog.Logger, grpcServer *grpc.Server, httpServer *http.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	if err := httpServer.Shutdown(ctx); err != nil {
+		logger.Error("REST server did not shut down cleanly", "error", err)
+		httpServer.Close()
+	}
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		logger.Warn("gRPC server did not shut down in time, forcing stop")
+		grpcServer.Stop()
+	}
+}
+
+// readinessCheck pings one dependency the server needs to serve traffic
+type readinessCheck struct {
+	name string
+	ping func(ctx context.Context) error
 }
 
-func getEnv(key, fallback string) string {
-	if value, exists := os.LookupEnv(key); exists {
-		return value
+// readinessTimeout bounds how long a readiness probe waits on all dependencies
+const readinessTimeout = 2 * time.Second
+
+// handleReady pings every dependency concurrently and answers 503 listing
+// the failed ones, so load balancers stop routing to a broken instance
+func handleReady(checks []readinessCheck) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+		defer cancel()
+
+		errs := make([]error, len(checks))
+		var wg sync.WaitGroup
+		for i, check := range checks {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs[i] = check.ping(ctx)
+			}()
+		}
+		wg.Wait()
+
+		results := make(map[string]string, len(checks))
+		failed := make([]string, 0)
+		for i, check := range checks {
+			results[check.name] = "ok"
+			if errs[i] != nil {
+				results[check.name] = errs[i].Error()
+				failed = append(failed, check.name)
+			}
+		}
+
+		status, code := "ready", http.StatusOK
+		if len(failed) > 0 {
+			status, code = "unavailable", http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": status,
+			"failed": failed,
+			"checks": results,
+		})
 	}
-	return fallback
 }
 
 // File handling functions
@@ -228,35 +677,64 @@ func handleFileUpload(fs *filesystem.FileSystemStorage) http.HandlerFunc {
 		}
 
 		// Get the path from the query
-		path := r.URL.Query().Get("path")
+		query := r.URL.Query()
+		path := query.Get("path")
 		if path == "" {
 			http.Error(w, "Path is required", http.StatusBadRequest)
 			return
 		}
 
-		// Parse the multipart form, 32MB max
-		if err := r.ParseMultipartForm(32 << 20); err != nil {
-			http.Error(w, fmt.Sprintf("Failed to parse form: %v", err), http.StatusBadRequest)
+		// Only accept links handed out by GenerateUploadURL
+		if err := fs.VerifyUploadURL(path, query.Get("expires"), query.Get("sig"), time.Now()); err != nil {
+			http.Error(w, fmt.Sprintf("Forbidden: %v", err), http.StatusForbidden)
 			return
 		}
 
-		// Get the file
-		file, header, err := r.FormFile("file")
+		// Read the multipart body as a stream so uploads never sit in memory
+		reader, err := r.MultipartReader()
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to get file: %v", err), http.StatusBadRequest)
+			http.Error(w, fmt.Sprintf("Failed to parse form: %v", err), http.StatusBadRequest)
 			return
 		}
-		defer file.Close()
 
-		// Read the file
-		buffer := make([]byte, header.Size)
-		if _, err := file.Read(buffer); err != nil {
-			http.Error(w, fmt.Sprintf("Failed to read file: %v", err), http.StatusInternalServerError)
-			return
+		// Find the file part
+		var part *multipart.Part
+		for {
+			part, err = reader.NextPart()
+			if err == io.EOF {
+				http.Error(w, "Failed to get file: no file part in form", http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Failed to read form: %v", err), http.StatusBadRequest)
+				return
+			}
+			if part.FormName() == "file" {
+				break
+			}
+			part.Close()
 		}
+		defer part.Close()
 
-		// Save the file
-		if err := fs.SaveFile(path, buffer); err != nil {
+		// Copy the file to disk
+		dst, err := fs.CreateFile(path)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, filesystem.ErrInvalidPath) {
+				status = http.StatusBadRequest
+			}
+			http.Error(w, fmt.Sprintf("Failed to save file: %v", err), status)
+			return
+		}
+		_, copyErr := io.Copy(dst, part)
+		closeErr := dst.Close()
+		if copyErr != nil || closeErr != nil {
+			// Don't leave a truncated upload behind
+			fs.DeleteFile(r.Context(), path)
+			err := copyErr
+			if err == nil {
+				err = closeErr
+			}
 			http.Error(w, fmt.Sprintf("Failed to save file: %v", err), http.StatusInternalServerError)
 			return
 		}
@@ -268,8 +746,9 @@ func handleFileUpload(fs *filesystem.FileSystemStorage) http.HandlerFunc {
 
 func handleFileDownload(fs *filesystem.FileSystemStorage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		pathParam := chi.URLParam(r, "path")
-		if pathParam == "" {
+		// chi matches the escaped path when there is one
+		pathParam, err := url.PathUnescape(chi.URLParam(r, "*"))
+		if err != nil || pathParam == "" {
 			http.Error(w, "Path is required", http.StatusBadRequest)
 			return
 		}
@@ -277,55 +756,69 @@ func handleFileDownload(fs *filesystem.FileSystemStorage) http.HandlerFunc {
 		// Clean the path to prevent directory traversal
 		path := filepath.Clean(pathParam)
 
-		// Get the file data
-		data, err := fs.ReadFile(path)
+		// Only serve links handed out by GenerateDownloadURL
+		query := r.URL.Query()
+		if err := fs.VerifyDownloadURL(path, query.Get("expires"), query.Get("sig"), time.Now()); err != nil {
+			http.Error(w, fmt.Sprintf("Forbidden: %v", err), http.StatusForbidden)
+			return
+		}
+
+		// Open the file without reading it all into memory
+		file, info, err := fs.OpenFile(path)
 		if err != nil {
 			if os.IsNotExist(err) {
 				http.Error(w, "File not found", http.StatusNotFound)
+			} else if errors.Is(err, filesystem.ErrInvalidPath) {
+				http.Error(w, "Invalid path", http.StatusBadRequest)
 			} else {
 				http.Error(w, fmt.Sprintf("Failed to read file: %v", err), http.StatusInternalServerError)
 			}
 			return
 		}
+		defer file.Close()
 
-		// Set content type based on file extension
-		contentType := http.DetectContentType(data)
-		if ext := filepath.Ext(path); ext != "" {
-			switch ext {
-			case ".mp4":
-				contentType = "video/mp4"
-			case ".m3u8":
-				contentType = "application/vnd.apple.mpegurl"
-			case ".ts":
-				contentType = "video/mp2t"
-			case ".jpg", ".jpeg":
-				contentType = "image/jpeg"
-			case ".png":
-				contentType = "image/png"
-			}
+		// Set content type based on file extension; ServeContent sniffs the rest
+		switch filepath.Ext(path) {
+		case ".mp4":
+			w.Header().Set("Content-Type", "video/mp4")
+		case ".m3u8":
+			w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		case ".ts":
+			w.Header().Set("Content-Type", "video/mp2t")
+		case ".jpg", ".jpeg":
+			w.Header().Set("Content-Type", "image/jpeg")
+		case ".png":
+			w.Header().Set("Content-Type", "image/png")
 		}
 
-		w.Header().Set("Content-Type", contentType)
-		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(data)))
-		w.WriteHeader(http.StatusOK)
-		w.Write(data)
+		// ServeContent answers Range requests with 206 Partial Content and
+		// uses the modification time for If-Modified-Since and friends
+		http.ServeContent(w, r, info.Name(), info.ModTime(), file)
 	}
 }
 
 // Mock implementations for development purposes
 
-type mockFFmpegClient struct{}
+type mockFFmpegClient struct {
+	logger *slog.Logger
+}
 
-func (m *mockFFmpegClient) TranscodeVideo(ctx context.Context, inputPath string, outputPath string, options transcode.TranscodeOptions) error {
-	log.Printf("Mocking transcoding: %s to %s with resolution %v", inputPath, outputPath, options.Resolution)
-	// Simulate transcoding delay
-	time.Sleep(2 * time.Second)
+func (m *mockFFmpegClient) TranscodeVideo(ctx context.Context, inputPath string, outputPath string, options transcode.TranscodeOptions, onProgress transcode.ProgressFunc) error {
+	m.logger.InfoContext(ctx, "Mocking transcoding", "input_path", inputPath, "output_path", outputPath, "resolution", options.Resolution.String())
+	// Simulate transcoding delay, reporting progress along the way
+	for progress := float32(25); progress <= 100; progress += 25 {
+		time.Sleep(500 * time.Millisecond)
+		if onProgress != nil {
+			onProgress(progress)
+		}
+	}
 	return nil
 }
 
 func (m *mockFFmpegClient) GetMediaInfo(ctx context.Context, filePath string) (*transcode.MediaInfo, error) {
 	// Return mock media info
 	return &transcode.MediaInfo{
+		HasVideo:  true,
 		Duration:  120.5,
 		Width:     1920,
 		Height:    1080,
@@ -335,41 +828,32 @@ func (m *mockFFmpegClient) GetMediaInfo(ctx context.Context, filePath string) (*
 	}, nil
 }
 
-type mockTranscodeStorage struct{}
-
-func (m *mockTranscodeStorage) SaveTranscodingJob(ctx context.Context, job *transcode.TranscodingJob) error {
-	log.Printf("Saving transcoding job: %s for video: %s", job.ID, job.VideoID)
+func (m *mockFFmpegClient) ExtractThumbnail(ctx context.Context, inputPath string, atSeconds float64, outputPath string) error {
+	m.logger.InfoContext(ctx, "Mocking thumbnail extraction", "input_path", inputPath, "at_seconds", atSeconds, "output_path", outputPath)
 	return nil
 }
 
-func (m *mockTranscodeStorage) GetTranscodingJobs(ctx context.Context, videoID string) ([]*transcode.TranscodingJob, error) {
-	// Return mock jobs
-	jobs := []*transcode.TranscodingJob{
-		{
-			ID:        "job-1",
-			VideoID:   videoID,
-			Status:    pb.TranscodingStatus_TRANSCODING_STATUS_COMPLETED,
-			Progress:  100.0,
-			Resolution: pb.VideoResolution_VIDEO_RESOLUTION_720P,
-		},
-	}
-	return jobs, nil
+type mockNotificationService struct {
+	logger *slog.Logger
+	// onTranscodingComplete lets the video service react to finished jobs
+	onTranscodingComplete func(ctx context.Context, videoID string) error
 }
 
-func (m *mockTranscodeStorage) UpdateTranscodingJob(ctx context.Context, job *transcode.TranscodingJob) error {
-	log.Printf("Updating transcoding job: %s, progress: %.2f%%", job.ID, job.Progress)
+func (m *mockNotificationService) NotifyTranscodingComplete(ctx context.Context, videoID string, status pb.TranscodingStatus) error {
+	m.logger.InfoContext(ctx, "Transcoding complete", "video_id", videoID, "status", status.String())
+	if m.onTranscodingComplete != nil {
+		return m.onTranscodingComplete(ctx, videoID)
+	}
 	return nil
 }
 
-type mockNotificationService struct{}
-
-func (m *mockNotificationService) NotifyTranscodingComplete(ctx context.Context, videoID string, status pb.TranscodingStatus) error {
-	log.Printf("Transcoding complete for video: %s with status: %v", videoID, status)
+func (m *mockNotificationService) NotifyVideoFailed(ctx context.Context, videoID string, userID string, reason string) error {
+	m.logger.InfoContext(ctx, "Notifying user that video failed", "video_id", videoID, "user_id", userID, "reason", reason)
 	return nil
 }
 
 func (m *mockNotificationService) NotifyTranscodingProgress(ctx context.Context, videoID string, progress float32) error {
-	log.Printf("Transcoding progress for video: %s is %.2f%%", videoID, progress)
+	m.logger.InfoContext(ctx, "Transcoding progress", "video_id", videoID, "progress", progress)
 	return nil
 }
 
@@ -383,120 +867,731 @@ func (m *mockStreamingEngine) GetRTMPURL() string {
 	return "rtmp://streaming.example.com/live"
 }
 
-func (m *mockStreamingEngine) GetStreamPlaybackURL(streamID string) string {
-	return fmt.Sprintf("https://streaming.example.com/hls/%s.m3u8", streamID)
+func (m *mockStreamingEngine) GetViewerCount(ctx context.Context, streamID string) (int64, error) {
+	return 0, nil
 }
 
-// HTTP handler implementations
-
-func handleListVideos(svc *video.Service) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// Implementation for listing videos
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"videos": []}`))
-	}
+func (m *mockStreamingEngine) IsStreamActive(streamID string) bool {
+	return true
 }
 
-func handleGetVideo(svc *video.Service) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// Implementation for getting video details
-		videoID := chi.URLParam(r, "videoID")
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(fmt.Sprintf(`{"id": "%s", "title": "Sample Video"}`, videoID)))
-	}
+func (m *mockStreamingEngine) GetWebRTCPlaybackURL(streamID string) string {
+	return fmt.Sprintf("https://streaming.example.com/webrtc/%s", streamID)
 }
 
-func handleInitiateUpload(svc *video.Service) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// Implementation for initiating video upload
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"upload_id": "upload-123", "upload_url": "https://example.com/upload"}`))
-	}
+func (m *mockStreamingEngine) GetStreamPlaybackURL(streamID string) string {
+	return fmt.Sprintf("https://streaming.example.com/hls/%s.m3u8", streamID)
 }
 
-func handleCompleteUpload(svc *video.Service) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// Implementation for completing video upload
-		videoID := chi.URLParam(r, "videoID")
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(fmt.Sprintf(`{"video_id": "%s", "status": "processing"}`, videoID)))
-	}
-}
+// HTTP handler implementations
 
-func handleDeleteVideo(svc *video.Service) http.HandlerFunc {
+func handleListVideos(svc *video.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Implementation for deleting video
+		query := r.URL.Query()
+		
+		pageSize := int32(20) // Default page size
+		if size, err := strconv.Atoi(query.Get("page_size")); err == nil && size > 0 {
+			pageSize = int32(size)
+		}
+		
+		// Filters and the order are named like status=ready or sort_by=views_desc
+		status, err := parseEnum(query.Get("status"), "VIDEO_STATUS_", pb.VideoStatus_value)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid status: %v", err), http.StatusBadRequest)
+			return
+		}
+		visibility, err := parseEnum(query.Get("visibility"), "VIDEO_VISIBILITY_", pb.VideoVisibility_value)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid visibility: %v", err), http.StatusBadRequest)
+			return
+		}
+		sortBy, err := parseEnum(query.Get("sort_by"), "VIDEO_SORT_BY_", pb.VideoSortBy_value)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid sort_by: %v", err), http.StatusBadRequest)
+			return
+		}
+		
+		response, err := svc.ListVideos(r.Context(), &pb.ListVideosRequest{
+			UserId:     query.Get("user_id"),
+			PageSize:   pageSize,
+			PageToken:  query.Get("page_token"),
+			Status:     pb.VideoStatus(status),
+			Visibility: pb.VideoVisibility(visibility),
+			SortBy:     pb.VideoSortBy(sortBy),
+		})
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to list videos: %v", err), statusFromError(err))
+			return
+		}
+		
+		videos := make([]map[string]interface{}, 0, len(response.Videos))
+		for _, v := range response.Videos {
+			videos = append(videos, videoJSON(v))
+		}
+		
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"success": true}`))
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"videos":          videos,
+			"next_page_token": response.NextPageToken,
+			"total_count":     response.TotalCount,
+		})
 	}
 }
 
-func handleListStreams(svc *video.Service) http.HandlerFunc {
+func handleSearchVideos(svc *video.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-			// Get query parameters
-		userID := r.URL.Query().Get("user_id")
-		pageSizeStr := r.URL.Query().Get("page_size")
-		pageToken := r.URL.Query().Get("page_token")
+		query := r.URL.Query()
 		
 		pageSize := int32(20) // Default page size
-		if pageSizeStr != "" {
-			if size, err := strconv.Atoi(pageSizeStr); err == nil && size > 0 {
-				pageSize = int32(size)
-			}
+		if size, err := strconv.Atoi(query.Get("page_size")); err == nil && size > 0 {
+			pageSize = int32(size)
 		}
 		
-		// Call the service to list streams
-		response, err := svc.GetLiveStreams(r.Context(), &pb.GetLiveStreamsRequest{
-			UserId:    userID,
+		response, err := svc.SearchVideos(r.Context(), &pb.SearchVideosRequest{
+			Query:     query.Get("q"),
+			UserId:    query.Get("user_id"),
 			PageSize:  pageSize,
-			PageToken: pageToken,
+			PageToken: query.Get("page_token"),
 		})
-		
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to list streams: %v", err), http.StatusInternalServerError)
+			http.Error(w, fmt.Sprintf("Failed to search videos: %v", err), statusFromError(err))
 			return
 		}
 		
-		// Convert the streams to a format suitable for JSON
-		streams := make([]map[string]interface{}, 0, len(response.Streams))
-		for _, stream := range response.Streams {
-			streams = append(streams, map[string]interface{}{
-				"stream_id":     stream.StreamId,
-				"user_id":       stream.UserId,
-				"title":         stream.Title,
-				"description":   stream.Description,
-				"thumbnail_url": stream.ThumbnailUrl,
-				"playback_url":  stream.PlaybackUrl,
-				"viewer_count":  stream.ViewerCount,
-				"started_at":    stream.StartedAt.AsTime(),
-				"tags":          stream.Tags,
+		videos := make([]map[string]interface{}, 0, len(response.Videos))
+		for _, v := range response.Videos {
+			videos = append(videos, map[string]interface{}{
+				"id":               v.Id,
+				"title":            v.Title,
+				"description":      v.Description,
+				"user_id":          v.UserId,
+				"thumbnail_url":    v.ThumbnailUrl,
+				"duration_seconds": v.DurationSeconds,
+				"file_size_bytes":  v.FileSizeBytes,
+				"view_count":       v.ViewCount,
+				"created_at":       v.CreatedAt.AsTime(),
+				"tags":             v.Tags,
 			})
 		}
 		
-		// Send response
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
-			"streams":        streams,
+			"videos":          videos,
 			"next_page_token": response.NextPageToken,
-			"total_count":    response.TotalCount,
+			"total_count":     response.TotalCount,
 		})
 	}
 }
 
-func handleGetStreamKey(svc *video.Service) http.HandlerFunc {
+func handleGetVideo(svc *video.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Parse request body
-		var requestData struct {
-			UserID string `json:"user_id"`
-		}
+		// Get video ID from URL params
+		videoID := chi.URLParam(r, "videoID")
 		
-		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+		response, err := svc.GetVideo(r.Context(), &pb.GetVideoRequest{
+			VideoId: videoID,
+			UserId:  r.URL.Query().Get("user_id"),
+		})
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to get video: %v", err), statusFromError(err))
+			return
+		}
+		
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(videoJSON(response))
+	}
+}
+
+func handleUpdateVideo(svc *video.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		videoID := chi.URLParam(r, "videoID")
+		
+		// Fields left out of the body are left as they are
+		var requestData struct {
+			UserID      string    `json:"user_id"`
+			Title       *string   `json:"title"`
+			Description *string   `json:"description"`
+			Tags        *[]string `json:"tags"`
+			Visibility  *string   `json:"visibility"`
+		}
+		
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		
+		req := &pb.UpdateVideoRequest{
+			VideoId:     videoID,
+			UserId:      requestData.UserID,
+			Title:       requestData.Title,
+			Description: requestData.Description,
+		}
+		if requestData.Tags != nil {
+			req.Tags = &pb.TagList{Tags: *requestData.Tags}
+		}
+		if requestData.Visibility != nil {
+			visibility, err := parseEnum(*requestData.Visibility, "VIDEO_VISIBILITY_", pb.VideoVisibility_value)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Invalid visibility: %v", err), http.StatusBadRequest)
+				return
+			}
+			req.Visibility = pb.VideoVisibility(visibility).Enum()
+		}
+		
+		response, err := svc.UpdateVideo(r.Context(), req)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to update video: %v", err), statusFromError(err))
+			return
+		}
+		
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(videoJSON(response))
+	}
+}
+
+func handleInitiateUpload(svc *video.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var requestData struct {
+			Title         string   `json:"title"`
+			Description   string   `json:"description"`
+			UserID        string   `json:"user_id"`
+			FileSizeBytes int64    `json:"file_size_bytes"`
+			ContentType   string   `json:"content_type"`
+			Visibility    string   `json:"visibility"`
+			Tags          []string `json:"tags"`
+			CallbackURL   string   `json:"callback_url"`
+		}
+		
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		
+		visibility, err := parseEnum(requestData.Visibility, "VIDEO_VISIBILITY_", pb.VideoVisibility_value)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid visibility: %v", err), http.StatusBadRequest)
+			return
+		}
+		
+		response, err := svc.InitiateUpload(r.Context(), &pb.InitiateUploadRequest{
+			Title:         requestData.Title,
+			Description:   requestData.Description,
+			UserId:        requestData.UserID,
+			FileSizeBytes: requestData.FileSizeBytes,
+			ContentType:   requestData.ContentType,
+			Visibility:    pb.VideoVisibility(visibility),
+			Tags:          requestData.Tags,
+			CallbackUrl:   requestData.CallbackURL,
+		})
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to initiate upload: %v", err), statusFromError(err))
+			return
+		}
+		
+		// Large files are uploaded in parts, each to its own URL
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"upload_id":       response.UploadId,
+			"video_id":        response.VideoId,
+			"upload_url":      response.UploadUrl,
+			"part_urls":       response.PartUrls,
+			"part_size_bytes": response.PartSizeBytes,
+		})
+	}
+}
+
+func handleCompleteUpload(svc *video.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		videoID := chi.URLParam(r, "videoID")
+		
+		var requestData struct {
+			UploadID string `json:"upload_id"`
+			Parts    []struct {
+				PartNumber int32  `json:"part_number"`
+				ETag       string `json:"etag"`
+			} `json:"parts"`
+		}
+		
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		
+		parts := make([]*pb.UploadPart, 0, len(requestData.Parts))
+		for _, part := range requestData.Parts {
+			parts = append(parts, &pb.UploadPart{PartNumber: part.PartNumber, Etag: part.ETag})
+		}
+		
+		response, err := svc.CompleteUpload(r.Context(), &pb.CompleteUploadRequest{
+			UploadId: requestData.UploadID,
+			VideoId:  videoID,
+			Parts:    parts,
+		})
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to complete upload: %v", err), statusFromError(err))
+			return
+		}
+		
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"video_id": response.VideoId,
+			"status":   enumName(response.Status.String(), "VIDEO_STATUS_"),
+		})
+	}
+}
+
+// videoJSON returns the JSON representation of a video in REST responses
+func videoJSON(v *pb.Video) map[string]interface{} {
+	return map[string]interface{}{
+		"id":               v.Id,
+		"title":            v.Title,
+		"description":      v.Description,
+		"user_id":          v.UserId,
+		"thumbnail_url":    v.ThumbnailUrl,
+		"video_url":        v.VideoUrl,
+		"duration_seconds": v.DurationSeconds,
+		"file_size_bytes":  v.FileSizeBytes,
+		"view_count":       v.ViewCount,
+		"status":           enumName(v.Status.String(), "VIDEO_STATUS_"),
+		"status_reason":    v.StatusReason,
+		"visibility":       enumName(v.Visibility.String(), "VIDEO_VISIBILITY_"),
+		"created_at":       v.CreatedAt.AsTime(),
+		"updated_at":       v.UpdatedAt.AsTime(),
+		"tags":             v.Tags,
+	}
+}
+
+// parseEnum parses an enum value named in a request without its prefix and
+// in any case, such as ready for VIDEO_STATUS_READY. An empty name is the
+// zero value, which leaves a filter unset.
+func parseEnum(name string, prefix string, values map[string]int32) (int32, error) {
+	if name == "" {
+		return 0, nil
+	}
+	value, ok := values[prefix+strings.ToUpper(name)]
+	if !ok {
+		return 0, fmt.Errorf("unknown value %q", name)
+	}
+	return value, nil
+}
+
+// enumName returns the lowercase name of an enum value without its prefix,
+// the form parseEnum accepts
+func enumName(name string, prefix string) string {
+	return strings.ToLower(strings.TrimPrefix(name, prefix))
+}
+
+func handleDeleteVideo(svc *video.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		videoID := chi.URLParam(r, "videoID")
+		
+		var requestData struct {
+			UserID string `json:"user_id"`
+		}
+		
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		
+		_, err := svc.DeleteVideo(r.Context(), &pb.DeleteVideoRequest{
+			VideoId: videoID,
+			UserId:  requestData.UserID,
+		})
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to delete video: %v", err), statusFromError(err))
+			return
+		}
+		
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]bool{
+			"success": true,
+		})
+	}
+}
+
+func handleUndeleteVideo(svc *video.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		videoID := chi.URLParam(r, "videoID")
+		
+		var requestData struct {
+			UserID string `json:"user_id"`
+		}
+		
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		
+		response, err := svc.RestoreVideo(r.Context(), &pb.RestoreVideoRequest{
+			VideoId: videoID,
+			UserId:  requestData.UserID,
+		})
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to restore video: %v", err), statusFromError(err))
+			return
+		}
+		
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":         response.Id,
+			"status":     response.Status,
+			"updated_at": response.UpdatedAt.AsTime(),
+		})
+	}
+}
+
+func handleInitiateThumbnailUpload(svc *video.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get video ID from URL params
+		videoID := chi.URLParam(r, "videoID")
+		
+		// Parse request body
+		var requestData struct {
+			UserID      string `json:"user_id"`
+			ContentType string `json:"content_type"`
+		}
+		
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		
+		// Call the service to generate the upload URL
+		response, err := svc.InitiateThumbnailUpload(r.Context(), &pb.InitiateThumbnailUploadRequest{
+			VideoId:     videoID,
+			UserId:      requestData.UserID,
+			ContentType: requestData.ContentType,
+		})
+		
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to initiate thumbnail upload: %v", err), statusFromError(err))
+			return
+		}
+		
+		// Send response
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"video_id":   response.VideoId,
+			"upload_url": response.UploadUrl,
+		})
+	}
+}
+
+func handleSetThumbnail(svc *video.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get video ID from URL params
+		videoID := chi.URLParam(r, "videoID")
+		
+		// Parse request body
+		var requestData struct {
+			UserID string `json:"user_id"`
+		}
+		
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		
+		// Call the service to point the video at the uploaded thumbnail
+		response, err := svc.SetThumbnail(r.Context(), videoID, requestData.UserID)
+		
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to set thumbnail: %v", err), statusFromError(err))
+			return
+		}
+		
+		// Send response
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":            response.Id,
+			"thumbnail_url": response.ThumbnailUrl,
+			"updated_at":    response.UpdatedAt.AsTime(),
+		})
+	}
+}
+
+func handleCaptureThumbnail(svc *video.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get video ID from URL params
+		videoID := chi.URLParam(r, "videoID")
+		
+		atSeconds, err := strconv.ParseFloat(r.URL.Query().Get("at"), 64)
+		if err != nil {
+			http.Error(w, "Query parameter 'at' must be a number of seconds", http.StatusBadRequest)
+			return
+		}
+		
+		// Parse request body
+		var requestData struct {
+			UserID string `json:"user_id"`
+		}
+		
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		
+		// Call the service to capture the thumbnail
+		response, err := svc.CaptureThumbnail(r.Context(), &pb.CaptureThumbnailRequest{
+			VideoId:   videoID,
+			UserId:    requestData.UserID,
+			AtSeconds: atSeconds,
+		})
+		
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to capture thumbnail: %v", err), statusFromError(err))
+			return
+		}
+		
+		// Send response
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":            response.Id,
+			"thumbnail_url": response.ThumbnailUrl,
+			"updated_at":    response.UpdatedAt.AsTime(),
+		})
+	}
+}
+
+func handleArchiveVideo(svc *video.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		videoID := chi.URLParam(r, "videoID")
+		
+		var requestData struct {
+			UserID     string `json:"user_id"`
+			KeepSource bool   `json:"keep_source"`
+		}
+		
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		
+		response, err := svc.ArchiveVideo(r.Context(), &pb.ArchiveVideoRequest{
+			VideoId:    videoID,
+			UserId:     requestData.UserID,
+			KeepSource: requestData.KeepSource,
+		})
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to archive video: %v", err), statusFromError(err))
+			return
+		}
+		
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":         response.Id,
+			"status":     response.Status,
+			"updated_at": response.UpdatedAt.AsTime(),
+		})
+	}
+}
+
+func handleRestoreFromArchive(svc *video.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		videoID := chi.URLParam(r, "videoID")
+		
+		var requestData struct {
+			UserID string `json:"user_id"`
+		}
+		
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		
+		response, err := svc.RestoreFromArchive(r.Context(), &pb.RestoreFromArchiveRequest{
+			VideoId: videoID,
+			UserId:  requestData.UserID,
+		})
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to restore video: %v", err), statusFromError(err))
+			return
+		}
+		
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":         response.Id,
+			"status":     response.Status,
+			"updated_at": response.UpdatedAt.AsTime(),
+		})
+	}
+}
+
+// statusFromError maps video service errors to HTTP status codes. Errors
+// without a domain meaning, such as storage failures, are a 500.
+func statusFromError(err error) int {
+	switch {
+	case errors.Is(err, video.ErrInvalidArgument):
+		return http.StatusBadRequest
+	case errors.Is(err, video.ErrUnauthenticated):
+		return http.StatusUnauthorized
+	case errors.Is(err, video.ErrInvalidStreamKey), errors.Is(err, video.ErrPermissionDenied):
+		return http.StatusForbidden
+	case errors.Is(err, video.ErrNotFound), errors.Is(err, video.ErrStreamNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, video.ErrFailedPrecondition):
+		return http.StatusConflict
+	case errors.Is(err, video.ErrStreamAtCapacity):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, video.ErrQuotaExceeded):
+		return http.StatusRequestEntityTooLarge
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func handlePlaybackCookies(svc *video.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get video ID from URL params
+		videoID := chi.URLParam(r, "videoID")
+		
+		// Parse request body
+		var requestData struct {
+			UserID string `json:"user_id"`
+		}
+		
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		
+		// Call the service to sign cookies for the video's HLS output
+		cookies, err := svc.IssuePlaybackCookies(r.Context(), videoID, requestData.UserID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to issue playback cookies: %v", err), statusFromError(err))
+			return
+		}
+		
+		var expiresAt time.Time
+		for _, cookie := range cookies {
+			http.SetCookie(w, cookie)
+			expiresAt = cookie.Expires
+		}
+		
+		// Send response
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"video_id":   videoID,
+			"expires_at": expiresAt,
+		})
+	}
+}
+
+func handleListStreams(svc *video.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+			// Get query parameters
+		userID := r.URL.Query().Get("user_id")
+		pageSizeStr := r.URL.Query().Get("page_size")
+		pageToken := r.URL.Query().Get("page_token")
+		
+		pageSize := int32(20) // Default page size
+		if pageSizeStr != "" {
+			if size, err := strconv.Atoi(pageSizeStr); err == nil && size > 0 {
+				pageSize = int32(size)
+			}
+		}
+		
+		// Call the service to list streams
+		response, err := svc.GetLiveStreams(r.Context(), &pb.GetLiveStreamsRequest{
+			UserId:    userID,
+			PageSize:  pageSize,
+			PageToken: pageToken,
+		})
+		
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to list streams: %v", err), statusFromError(err))
+			return
+		}
+		
+		// Convert the streams to a format suitable for JSON
+		streams := make([]map[string]interface{}, 0, len(response.Streams))
+		for _, stream := range response.Streams {
+			streams = append(streams, map[string]interface{}{
+				"stream_id":     stream.StreamId,
+				"user_id":       stream.UserId,
+				"title":         stream.Title,
+				"description":   stream.Description,
+				"thumbnail_url": stream.ThumbnailUrl,
+				"playback_url":  stream.PlaybackUrl,
+				"webrtc_playback_url": stream.WebrtcPlaybackUrl,
+				"viewer_count":  stream.ViewerCount,
+				"started_at":    stream.StartedAt.AsTime(),
+				"tags":          stream.Tags,
+				"category":      stream.Category,
+			})
+		}
+		
+		// Send response
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"streams":        streams,
+			"next_page_token": response.NextPageToken,
+			"total_count":    response.TotalCount,
+		})
+	}
+}
+
+// handleBrowseStreams lists active streams in the ?category= category,
+// or in every category when it is left out
+func handleBrowseStreams(svc *video.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		category := r.URL.Query().Get("category")
+		pageSizeStr := r.URL.Query().Get("page_size")
+		pageToken := r.URL.Query().Get("page_token")
+		
+		pageSize := int32(20) // Default page size
+		if pageSizeStr != "" {
+			if size, err := strconv.Atoi(pageSizeStr); err == nil && size > 0 {
+				pageSize = int32(size)
+			}
+		}
+		
+		response, err := svc.GetLiveStreamsByCategory(r.Context(), &pb.GetLiveStreamsByCategoryRequest{
+			Category:  category,
+			PageSize:  pageSize,
+			PageToken: pageToken,
+		})
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to browse streams: %v", err), statusFromError(err))
+			return
+		}
+		
+		streams := make([]map[string]interface{}, 0, len(response.Streams))
+		for _, stream := range response.Streams {
+			streams = append(streams, map[string]interface{}{
+				"stream_id":     stream.StreamId,
+				"user_id":       stream.UserId,
+				"title":         stream.Title,
+				"description":   stream.Description,
+				"thumbnail_url": stream.ThumbnailUrl,
+				"playback_url":  stream.PlaybackUrl,
+				"webrtc_playback_url": stream.WebrtcPlaybackUrl,
+				"viewer_count":  stream.ViewerCount,
+				"started_at":    stream.StartedAt.AsTime(),
+				"tags":          stream.Tags,
+				"category":      stream.Category,
+			})
+		}
+		
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"streams":        streams,
+			"next_page_token": response.NextPageToken,
+			"total_count":    response.TotalCount,
+		})
+	}
+}
+
+func handleGetStreamKey(svc *video.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Parse request body
+		var requestData struct {
+			UserID string `json:"user_id"`
+		}
+		
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
 			return
 		}
 		
@@ -506,7 +1601,7 @@ func handleGetStreamKey(svc *video.Service) http.HandlerFunc {
 		})
 		
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to get stream key: %v", err), http.StatusInternalServerError)
+			http.Error(w, fmt.Sprintf("Failed to get stream key: %v", err), statusFromError(err))
 			return
 		}
 		
@@ -519,6 +1614,35 @@ func handleGetStreamKey(svc *video.Service) http.HandlerFunc {
 	}
 }
 
+func handleRotateStreamKey(svc *video.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var requestData struct {
+			UserID           string `json:"user_id"`
+			EndActiveStreams bool   `json:"end_active_streams"`
+		}
+		
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		
+		response, err := svc.RotateStreamKey(r.Context(), &pb.RotateStreamKeyRequest{
+			UserId:           requestData.UserID,
+			EndActiveStreams: requestData.EndActiveStreams,
+		})
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to rotate stream key: %v", err), statusFromError(err))
+			return
+		}
+		
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"stream_key": response.StreamKey,
+			"rtmp_url":   response.RtmpUrl,
+		})
+	}
+}
+
 func handleStartStream(svc *video.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Parse request body
@@ -528,6 +1652,8 @@ func handleStartStream(svc *video.Service) http.HandlerFunc {
 			Title       string   `json:"title"`
 			Description string   `json:"description"`
 			Tags        []string `json:"tags"`
+			MaxViewers  int64    `json:"max_viewers"`
+			Category    string   `json:"category"`
 		}
 		
 		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
@@ -542,10 +1668,12 @@ func handleStartStream(svc *video.Service) http.HandlerFunc {
 			Title:       requestData.Title,
 			Description: requestData.Description,
 			Tags:        requestData.Tags,
+			MaxViewers:  requestData.MaxViewers,
+			Category:    requestData.Category,
 		})
 		
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to start stream: %v", err), http.StatusInternalServerError)
+			http.Error(w, fmt.Sprintf("Failed to start stream: %v", err), statusFromError(err))
 			return
 		}
 		
@@ -555,6 +1683,7 @@ func handleStartStream(svc *video.Service) http.HandlerFunc {
 			"stream_id":    response.StreamId,
 			"playback_url": response.PlaybackUrl,
 			"stream_key":   response.StreamKey,
+			"webrtc_playback_url": response.WebrtcPlaybackUrl,
 		})
 	}
 }
@@ -581,7 +1710,7 @@ func handleEndStream(svc *video.Service) http.HandlerFunc {
 		})
 		
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to end stream: %v", err), http.StatusInternalServerError)
+			http.Error(w, fmt.Sprintf("Failed to end stream: %v", err), statusFromError(err))
 			return
 		}
 		
@@ -593,6 +1722,235 @@ func handleEndStream(svc *video.Service) http.HandlerFunc {
 	}
 }
 
+// streamJoinRetrySeconds is the Retry-After sent to viewers turned away from a full stream
+const streamJoinRetrySeconds = 10
+
+func handleJoinStream(svc *video.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		streamID := chi.URLParam(r, "streamID")
+		
+		response, err := svc.JoinStream(r.Context(), &pb.JoinStreamRequest{
+			StreamId: streamID,
+		})
+		if err != nil {
+			status := statusFromError(err)
+			if status == http.StatusServiceUnavailable {
+				// A seat frees up as soon as another viewer leaves
+				w.Header().Set("Retry-After", strconv.Itoa(streamJoinRetrySeconds))
+			}
+			http.Error(w, fmt.Sprintf("Failed to join stream: %v", err), status)
+			return
+		}
+		
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"stream_id":           response.StreamId,
+			"playback_url":        response.PlaybackUrl,
+			"webrtc_playback_url": response.WebrtcPlaybackUrl,
+			"viewer_count":        response.ViewerCount,
+		})
+	}
+}
+
+func handleLeaveStream(svc *video.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		streamID := chi.URLParam(r, "streamID")
+		
+		response, err := svc.LeaveStream(r.Context(), &pb.LeaveStreamRequest{
+			StreamId: streamID,
+		})
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to leave stream: %v", err), statusFromError(err))
+			return
+		}
+		
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"stream_id":    response.StreamId,
+			"viewer_count": response.ViewerCount,
+		})
+	}
+}
+
+func handlePostStreamMessage(svc *video.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		streamID := chi.URLParam(r, "streamID")
+		
+		var requestData struct {
+			UserID string `json:"user_id"`
+			Text   string `json:"text"`
+		}
+		
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		
+		message, err := svc.PostStreamMessage(r.Context(), &pb.PostStreamMessageRequest{
+			StreamId: streamID,
+			UserId:   requestData.UserID,
+			Text:     requestData.Text,
+		})
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to post message: %v", err), statusFromError(err))
+			return
+		}
+		
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(streamMessageJSON(message))
+	}
+}
+
+func handleGetStreamMessages(svc *video.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		streamID := chi.URLParam(r, "streamID")
+		
+		req := &pb.GetStreamMessagesRequest{StreamId: streamID}
+		if pageSizeStr := r.URL.Query().Get("page_size"); pageSizeStr != "" {
+			if size, err := strconv.Atoi(pageSizeStr); err == nil && size > 0 {
+				req.PageSize = int32(size)
+			}
+		}
+		// Page backward with the created_at of the oldest message received so far
+		if beforeStr := r.URL.Query().Get("before"); beforeStr != "" {
+			before, err := time.Parse(time.RFC3339Nano, beforeStr)
+			if err != nil {
+				http.Error(w, "Invalid before parameter, expected an RFC 3339 time", http.StatusBadRequest)
+				return
+			}
+			req.Before = timestamppb.New(before)
+		}
+		
+		response, err := svc.GetStreamMessages(r.Context(), req)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to get messages: %v", err), statusFromError(err))
+			return
+		}
+		
+		messages := make([]map[string]interface{}, 0, len(response.Messages))
+		for _, message := range response.Messages {
+			messages = append(messages, streamMessageJSON(message))
+		}
+		
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"messages": messages,
+		})
+	}
+}
+
+// streamMessageJSON converts a chat message to its REST representation
+func streamMessageJSON(message *pb.StreamMessage) map[string]interface{} {
+	return map[string]interface{}{
+		"message_id": message.MessageId,
+		"stream_id":  message.StreamId,
+		"user_id":    message.UserId,
+		"text":       message.Text,
+		"created_at": message.CreatedAt.AsTime(),
+	}
+}
+
+func handleGetVideosBatch(svc *video.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Video IDs come as a comma-separated ids query parameter
+		var videoIDs []string
+		for _, id := range strings.Split(r.URL.Query().Get("ids"), ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				videoIDs = append(videoIDs, id)
+			}
+		}
+		
+		response, err := svc.GetVideos(r.Context(), &pb.GetVideosRequest{
+			VideoIds: videoIDs,
+			UserId:   r.URL.Query().Get("user_id"),
+		})
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to get videos: %v", err), statusFromError(err))
+			return
+		}
+		
+		videos := make([]map[string]interface{}, 0, len(response.Videos))
+		for _, v := range response.Videos {
+			videos = append(videos, map[string]interface{}{
+				"id":               v.Id,
+				"title":            v.Title,
+				"description":      v.Description,
+				"user_id":          v.UserId,
+				"thumbnail_url":    v.ThumbnailUrl,
+				"duration_seconds": v.DurationSeconds,
+				"file_size_bytes":  v.FileSizeBytes,
+				"view_count":       v.ViewCount,
+				"created_at":       v.CreatedAt.AsTime(),
+				"tags":             v.Tags,
+			})
+		}
+		
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"videos": videos,
+		})
+	}
+}
+
+func handleGetStreamsBatch(svc *video.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Stream IDs come as a comma-separated ids query parameter
+		var streamIDs []string
+		for _, id := range strings.Split(r.URL.Query().Get("ids"), ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				streamIDs = append(streamIDs, id)
+			}
+		}
+		
+		response, err := svc.GetStreamsByIDs(r.Context(), &pb.GetStreamsByIDsRequest{
+			StreamIds: streamIDs,
+		})
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to get streams: %v", err), statusFromError(err))
+			return
+		}
+		
+		streams := make([]map[string]interface{}, 0, len(response.Streams))
+		for _, stream := range response.Streams {
+			streams = append(streams, map[string]interface{}{
+				"stream_id":     stream.StreamId,
+				"user_id":       stream.UserId,
+				"title":         stream.Title,
+				"description":   stream.Description,
+				"thumbnail_url": stream.ThumbnailUrl,
+				"playback_url":  stream.PlaybackUrl,
+				"webrtc_playback_url": stream.WebrtcPlaybackUrl,
+				"viewer_count":  stream.ViewerCount,
+				"started_at":    stream.StartedAt.AsTime(),
+				"tags":          stream.Tags,
+				"category":      stream.Category,
+			})
+		}
+		
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"streams": streams,
+		})
+	}
+}
+
+// streamStatusName returns the lowercase name used for a stream status in JSON
+func streamStatusName(status pb.StreamStatus) string {
+	switch status {
+	case pb.StreamStatus_STREAM_STATUS_CREATED:
+		return "created"
+	case pb.StreamStatus_STREAM_STATUS_LIVE:
+		return "live"
+	case pb.StreamStatus_STREAM_STATUS_ENDED:
+		return "ended"
+	case pb.StreamStatus_STREAM_STATUS_ERROR:
+		return "error"
+	default:
+		return "unspecified"
+	}
+}
+
 func handleGetStream(svc *video.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Get stream ID from URL params
@@ -604,10 +1962,16 @@ func handleGetStream(svc *video.Service) http.HandlerFunc {
 		})
 		
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to get stream: %v", err), http.StatusInternalServerError)
+			http.Error(w, fmt.Sprintf("Failed to get stream: %v", err), statusFromError(err))
 			return
 		}
 		
+		// Ended streams also report when they stopped
+		var endedAt interface{}
+		if response.Stream.EndedAt != nil {
+			endedAt = response.Stream.EndedAt.AsTime()
+		}
+		
 		// Send response
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
@@ -617,9 +1981,13 @@ func handleGetStream(svc *video.Service) http.HandlerFunc {
 			"description":   response.Stream.Description,
 			"thumbnail_url": response.Stream.ThumbnailUrl,
 			"playback_url":  response.Stream.PlaybackUrl,
+			"webrtc_playback_url": response.Stream.WebrtcPlaybackUrl,
 			"viewer_count":  response.Stream.ViewerCount,
+			"status":        streamStatusName(response.Stream.Status),
 			"started_at":    response.Stream.StartedAt.AsTime(),
+			"ended_at":      endedAt,
 			"tags":          response.Stream.Tags,
+			"category":      response.Stream.Category,
 		})
 	}
 }
\ No newline at end of file
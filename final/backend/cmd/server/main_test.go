@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"videostreaming/internal/service/video"
+	"videostreaming/internal/storage/filesystem"
+	"videostreaming/internal/storage/memory"
+	pb "videostreaming/proto/video"
+)
+
+func TestStreamHandlersReportCategory(t *testing.T) {
+	storage := memory.NewVideoStorage()
+	err := storage.SaveLiveStream(context.Background(), &video.LiveStream{
+		StreamID:  "s1",
+		UserID:    "owner",
+		Title:     "Jam session",
+		Status:    pb.StreamStatus_STREAM_STATUS_LIVE,
+		StartedAt: time.Now(),
+		Category:  "music",
+	})
+	if err != nil {
+		t.Fatalf("SaveLiveStream: %v", err)
+	}
+	svc := video.NewService(storage, nil, nil, &mockStreamingEngine{})
+
+	router := chi.NewRouter()
+	router.Get("/streams/batch", handleGetStreamsBatch(svc))
+	router.Get("/streams/{streamID}", handleGetStream(svc))
+
+	get := func(target string, body any) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d: %s", target, rec.Code, rec.Body)
+		}
+		if err := json.NewDecoder(rec.Body).Decode(body); err != nil {
+			t.Fatalf("GET %s: failed to decode response: %v", target, err)
+		}
+	}
+
+	var stream struct {
+		Category string `json:"category"`
+	}
+	get("/streams/s1", &stream)
+	if stream.Category != "music" {
+		t.Errorf("GET stream category = %q, want music", stream.Category)
+	}
+
+	var batch struct {
+		Streams []struct {
+			Category string `json:"category"`
+		} `json:"streams"`
+	}
+	get("/streams/batch?ids=s1", &batch)
+	if len(batch.Streams) != 1 || batch.Streams[0].Category != "music" {
+		t.Errorf("GET streams batch = %+v, want s1 in music", batch.Streams)
+	}
+}
+
+func TestStatusFromError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{video.ErrInvalidArgument, http.StatusBadRequest},
+		{video.ErrUnauthenticated, http.StatusUnauthorized},
+		{video.ErrPermissionDenied, http.StatusForbidden},
+		{video.ErrInvalidStreamKey, http.StatusForbidden},
+		{video.ErrNotFound, http.StatusNotFound},
+		{video.ErrStreamNotFound, http.StatusNotFound},
+		{video.ErrFailedPrecondition, http.StatusConflict},
+		{video.ErrStreamAtCapacity, http.StatusServiceUnavailable},
+		{video.ErrQuotaExceeded, http.StatusRequestEntityTooLarge},
+		{errors.New("connection refused"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		// Services wrap the sentinels with details
+		err := fmt.Errorf("failed to get video: %w", tt.err)
+		if got := statusFromError(err); got != tt.want {
+			t.Errorf("statusFromError(%v) = %d, want %d", err, got, tt.want)
+		}
+	}
+}
+
+func TestVideoHandlersMapServiceErrors(t *testing.T) {
+	svc := video.NewService(memory.NewVideoStorage(), nil, nil, nil)
+
+	router := chi.NewRouter()
+	router.Post("/videos/{videoID}/thumbnail", handleSetThumbnail(svc))
+	router.Post("/videos/{videoID}/playback-cookies", handlePlaybackCookies(svc))
+
+	tests := []struct {
+		target string
+		want   int
+	}{
+		{"/videos/missing/thumbnail", http.StatusNotFound},
+		// Signed cookies need a signer the service wasn't given
+		{"/videos/missing/playback-cookies", http.StatusConflict},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(`{"user_id":"owner"}`)))
+		if rec.Code != tt.want {
+			t.Errorf("POST %s = %d, want %d: %s", tt.target, rec.Code, tt.want, rec.Body)
+		}
+	}
+}
+
+func TestVideoRESTHandlers(t *testing.T) {
+	storage := memory.NewVideoStorage()
+	err := storage.SaveVideo(context.Background(), &video.Video{
+		ID:         "v1",
+		Title:      "Draft",
+		UserID:     "owner",
+		Status:     pb.VideoStatus_VIDEO_STATUS_PROCESSING,
+		Visibility: pb.VideoVisibility_VIDEO_VISIBILITY_PRIVATE,
+		Tags:       []string{"draft"},
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("SaveVideo: %v", err)
+	}
+	files, err := filesystem.NewFileSystemStorage(t.TempDir(), "http://localhost:8080", []byte("test-signing-key"))
+	if err != nil {
+		t.Fatalf("NewFileSystemStorage: %v", err)
+	}
+	svc := video.NewService(storage, files, nil, nil, video.WithVideoCacheTTL(0))
+
+	router := chi.NewRouter()
+	router.Post("/videos", handleInitiateUpload(svc))
+	router.Get("/videos/{videoID}", handleGetVideo(svc))
+	router.Patch("/videos/{videoID}", handleUpdateVideo(svc))
+
+	do := func(method, target, body string, wantCode int) map[string]interface{} {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		if rec.Code != wantCode {
+			t.Fatalf("%s %s = %d, want %d: %s", method, target, rec.Code, wantCode, rec.Body)
+		}
+		var response map[string]interface{}
+		if wantCode == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+				t.Fatalf("%s %s: failed to decode response: %v", method, target, err)
+			}
+		}
+		return response
+	}
+
+	got := do(http.MethodGet, "/videos/v1?user_id=owner", "", http.StatusOK)
+	if got["title"] != "Draft" || got["status"] != "processing" || got["visibility"] != "private" {
+		t.Errorf("GET video = %v, want the stored draft", got)
+	}
+	do(http.MethodGet, "/videos/v1?user_id=someone", "", http.StatusForbidden)
+	do(http.MethodGet, "/videos/missing", "", http.StatusNotFound)
+
+	// Only the fields in the body change
+	got = do(http.MethodPatch, "/videos/v1", `{"user_id":"owner","title":"Final","visibility":"public"}`, http.StatusOK)
+	if got["title"] != "Final" || got["visibility"] != "public" {
+		t.Errorf("PATCH video = %v, want the new title and visibility", got)
+	}
+	if tags, _ := got["tags"].([]interface{}); len(tags) != 1 || tags[0] != "draft" {
+		t.Errorf("PATCH video tags = %v, want them untouched", got["tags"])
+	}
+	do(http.MethodPatch, "/videos/v1", `{"user_id":"someone","title":"Hijacked"}`, http.StatusForbidden)
+	do(http.MethodPatch, "/videos/v1", `{"user_id":"owner","visibility":"secret"}`, http.StatusBadRequest)
+
+	got = do(http.MethodPost, "/videos", `{"title":"Upload","user_id":"owner","file_size_bytes":1024,"content_type":"video/mp4"}`, http.StatusOK)
+	videoID, _ := got["video_id"].(string)
+	if videoID == "" || got["upload_url"] == "" {
+		t.Fatalf("POST videos = %v, want a video ID and upload URL", got)
+	}
+	got = do(http.MethodGet, "/videos/"+videoID+"?user_id=owner", "", http.StatusOK)
+	if got["title"] != "Upload" || got["status"] != "uploading" {
+		t.Errorf("GET uploaded video = %v, want it uploading", got)
+	}
+}
+
+func TestListVideosHandler(t *testing.T) {
+	storage := memory.NewVideoStorage()
+	base := time.Now()
+	for i, status := range []pb.VideoStatus{
+		pb.VideoStatus_VIDEO_STATUS_READY,
+		pb.VideoStatus_VIDEO_STATUS_PROCESSING,
+		pb.VideoStatus_VIDEO_STATUS_PROCESSING,
+		pb.VideoStatus_VIDEO_STATUS_PROCESSING,
+	} {
+		err := storage.SaveVideo(context.Background(), &video.Video{
+			ID:         fmt.Sprintf("v%d", i),
+			Title:      fmt.Sprintf("Video %d", i),
+			UserID:     "owner",
+			Status:     status,
+			Visibility: pb.VideoVisibility_VIDEO_VISIBILITY_PUBLIC,
+			ViewCount:  int64(i),
+			CreatedAt:  base.Add(time.Duration(i) * time.Minute),
+			UpdatedAt:  base,
+		})
+		if err != nil {
+			t.Fatalf("SaveVideo: %v", err)
+		}
+	}
+	svc := video.NewService(storage, nil, nil, nil, video.WithVideoCacheTTL(0))
+	handler := handleListVideos(svc)
+
+	type page struct {
+		Videos []struct {
+			ID string `json:"id"`
+		} `json:"videos"`
+		NextPageToken string `json:"next_page_token"`
+		TotalCount    int    `json:"total_count"`
+	}
+	list := func(query string) (page, int) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, "/videos?"+query, nil))
+		var p page
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&p); err != nil {
+				t.Fatalf("GET videos?%s: failed to decode response: %v", query, err)
+			}
+		}
+		return p, rec.Code
+	}
+
+	// Walk the processing videos, oldest first, a page at a time
+	var ids []string
+	query := "status=processing&sort_by=created_asc&page_size=2"
+	for {
+		p, code := list(query)
+		if code != http.StatusOK {
+			t.Fatalf("GET videos?%s = %d", query, code)
+		}
+		if p.TotalCount != 3 {
+			t.Errorf("total_count = %d, want 3", p.TotalCount)
+		}
+		for _, v := range p.Videos {
+			ids = append(ids, v.ID)
+		}
+		if p.NextPageToken == "" {
+			break
+		}
+		query = "status=processing&sort_by=created_asc&page_size=2&page_token=" + p.NextPageToken
+	}
+	if strings.Join(ids, ",") != "v1,v2,v3" {
+		t.Errorf("listed %v, want v1, v2, v3", ids)
+	}
+
+	if p, _ := list("sort_by=VIEWS_DESC&page_size=1"); len(p.Videos) != 1 || p.Videos[0].ID != "v3" {
+		t.Errorf("most viewed = %+v, want v3", p.Videos)
+	}
+	for _, query := range []string{"status=done", "visibility=hidden", "sort_by=random", "page_token=not-a-token"} {
+		if _, code := list(query); code != http.StatusBadRequest {
+			t.Errorf("GET videos?%s = %d, want %d", query, code, http.StatusBadRequest)
+		}
+	}
+}
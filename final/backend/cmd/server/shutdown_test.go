@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+
+	"videostreaming/internal/config"
+	"videostreaming/internal/metrics"
+	"videostreaming/internal/service/video"
+	"videostreaming/internal/storage/filesystem"
+	"videostreaming/internal/storage/memory"
+)
+
+func TestShutdownRESTServer(t *testing.T) {
+	files, err := filesystem.NewFileSystemStorage(t.TempDir(), "http://localhost:8080", []byte("test-signing-key"))
+	if err != nil {
+		t.Fatalf("NewFileSystemStorage: %v", err)
+	}
+	svc := video.NewService(memory.NewVideoStorage(), files, nil, nil)
+	server := newRESTServer(&config.Config{}, svc, files, metrics.New(prometheus.NewRegistry()), nil)
+
+	// Hold one request in flight so shutdown has something to wait for
+	started, release := make(chan struct{}), make(chan struct{})
+	handler := server.Handler
+	server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			close(started)
+			<-release
+		}
+		handler.ServeHTTP(w, r)
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(listener) }()
+
+	type result struct {
+		status int
+		err    error
+	}
+	requested := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String() + "/health")
+		if err != nil {
+			requested <- result{err: err}
+			return
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		requested <- result{status: resp.StatusCode}
+	}()
+	<-started
+
+	stopped := make(chan struct{})
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdown(ctx, slog.New(slog.NewTextHandler(io.Discard, nil)), grpc.NewServer(), server)
+		close(stopped)
+	}()
+
+	select {
+	case err := <-served:
+		if !errors.Is(err, http.ErrServerClosed) {
+			t.Fatalf("Serve returned %v, want %v", err, http.ErrServerClosed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server still serving after shutdown started")
+	}
+	select {
+	case <-stopped:
+		t.Fatal("shutdown returned before the in-flight request finished")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	if res := <-requested; res.err != nil || res.status != http.StatusOK {
+		t.Errorf("in-flight request = %d, %v; want it to finish with 200", res.status, res.err)
+	}
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdown did not return after the last request finished")
+	}
+}
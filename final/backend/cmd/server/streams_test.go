@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"videostreaming/internal/service/video"
+	"videostreaming/internal/storage/memory"
+	pb "videostreaming/proto/video"
+)
+
+// unreachableKeyStorage fails every stream key lookup
+type unreachableKeyStorage struct {
+	*memory.VideoStorage
+}
+
+func (s unreachableKeyStorage) GetStreamKey(ctx context.Context, userID string) (string, error) {
+	return "", errors.New("connection refused")
+}
+
+// idleStreamingEngine reports no publisher on any stream key
+type idleStreamingEngine struct {
+	mockStreamingEngine
+}
+
+func (e *idleStreamingEngine) IsStreamActive(streamID string) bool {
+	return false
+}
+
+func TestStreamHandlersMapServiceErrors(t *testing.T) {
+	storage := memory.NewVideoStorage()
+	if err := storage.SaveStreamKey(context.Background(), "owner", "good-key"); err != nil {
+		t.Fatalf("SaveStreamKey: %v", err)
+	}
+	services := map[string]*video.Service{
+		"default":      video.NewService(storage, nil, nil, &mockStreamingEngine{}),
+		"no storage":   video.NewService(unreachableKeyStorage{storage}, nil, nil, &mockStreamingEngine{}),
+		"no publisher": video.NewService(storage, nil, nil, &idleStreamingEngine{}, video.WithRequireActivePublisher(true)),
+	}
+
+	tests := []struct {
+		name    string
+		service string
+		method  string
+		target  string
+		body    string
+		want    int
+	}{
+		{"valid key", "default", http.MethodPost, "/streams", `{"user_id":"owner","stream_key":"good-key"}`, http.StatusOK},
+		{"wrong key", "default", http.MethodPost, "/streams", `{"user_id":"owner","stream_key":"bad-key"}`, http.StatusForbidden},
+		{"user without a key", "default", http.MethodPost, "/streams", `{"user_id":"other","stream_key":"good-key"}`, http.StatusForbidden},
+		{"no active publisher", "no publisher", http.MethodPost, "/streams", `{"user_id":"owner","stream_key":"good-key"}`, http.StatusConflict},
+		{"storage failure", "no storage", http.MethodPost, "/streams", `{"user_id":"owner","stream_key":"good-key"}`, http.StatusInternalServerError},
+		{"end missing stream", "default", http.MethodDelete, "/streams/missing", `{"user_id":"owner"}`, http.StatusNotFound},
+		{"join missing stream", "default", http.MethodPost, "/streams/missing/join", `{}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := services[tt.service]
+			router := chi.NewRouter()
+			router.Post("/streams", handleStartStream(svc))
+			router.Delete("/streams/{streamID}", handleEndStream(svc))
+			router.Post("/streams/{streamID}/join", handleJoinStream(svc))
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body)))
+			if rec.Code != tt.want {
+				t.Errorf("%s %s = %d, want %d: %s", tt.method, tt.target, rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestJoinAndLeaveStreamRoutes(t *testing.T) {
+	storage := memory.NewVideoStorage()
+	err := storage.SaveLiveStream(context.Background(), &video.LiveStream{
+		StreamID:  "s1",
+		UserID:    "owner",
+		Status:    pb.StreamStatus_STREAM_STATUS_LIVE,
+		StartedAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("SaveLiveStream: %v", err)
+	}
+	svc := video.NewService(storage, nil, nil, &mockStreamingEngine{})
+	router := chi.NewRouter()
+	router.Post("/streams/{streamID}/join", handleJoinStream(svc))
+	router.Post("/streams/{streamID}/leave", handleLeaveStream(svc))
+
+	// viewers posts to a stream route and returns the viewer count it reports
+	viewers := func(target string) int64 {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("POST %s = %d, want 200: %s", target, rec.Code, rec.Body)
+		}
+		var body struct {
+			StreamID    string `json:"stream_id"`
+			ViewerCount int64  `json:"viewer_count"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("decoding POST %s response: %v", target, err)
+		}
+		if body.StreamID != "s1" {
+			t.Errorf("POST %s reported stream %q, want s1", target, body.StreamID)
+		}
+		return body.ViewerCount
+	}
+
+	if got := viewers("/streams/s1/join"); got != 1 {
+		t.Errorf("viewer count after joining = %d, want 1", got)
+	}
+	for _, want := range []int64{0, 0} {
+		if got := viewers("/streams/s1/leave"); got != want {
+			t.Errorf("viewer count after leaving = %d, want %d", got, want)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/streams/missing/leave", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("leaving a missing stream = %d, want 404", rec.Code)
+	}
+}
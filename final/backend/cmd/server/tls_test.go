@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+
+	"videostreaming/internal/config"
+	"videostreaming/internal/metrics"
+	"videostreaming/internal/service/video"
+	"videostreaming/internal/storage/memory"
+	pb "videostreaming/proto/video"
+)
+
+// writeSelfSignedCert writes a certificate for 127.0.0.1 and its key into
+// dir and returns their paths, along with a pool trusting the certificate
+func writeSelfSignedCert(t *testing.T, dir string) (certFile, keyFile string, roots *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey: %v", err)
+	}
+
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("writing certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("writing key: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate: %v", err)
+	}
+	roots = x509.NewCertPool()
+	roots.AddCert(cert)
+	return certFile, keyFile, roots
+}
+
+// freePort returns a port nothing is listening on
+func freePort(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer listener.Close()
+	return strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+}
+
+func TestServersUseTLS(t *testing.T) {
+	certFile, keyFile, roots := writeSelfSignedCert(t, t.TempDir())
+	cfg := &config.Config{
+		TLSCertFile: certFile,
+		TLSKeyFile:  keyFile,
+		HTTPPort:    freePort(t),
+		GRPCPort:    freePort(t),
+	}
+	tlsConfig, err := loadTLSConfig(cfg)
+	if err != nil || tlsConfig == nil {
+		t.Fatalf("loadTLSConfig = %v, %v; want a configuration", tlsConfig, err)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	files, _ := newTestFileStorage(t)
+	svc := video.NewService(memory.NewVideoStorage(), files, nil, nil)
+	serviceMetrics := metrics.New(prometheus.NewRegistry())
+	grpcServer := startGRPCServer(logger, cfg, tlsConfig, svc, serviceMetrics)
+	t.Cleanup(grpcServer.Stop)
+	server := startRESTServer(logger, cfg, tlsConfig, svc, files, serviceMetrics, nil)
+	t.Cleanup(func() { server.Close() })
+
+	client := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}, ForceAttemptHTTP2: true},
+		Timeout:   5 * time.Second,
+	}
+	var resp *http.Response
+	// The server starts listening in the background
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		resp, err = client.Get("https://127.0.0.1:" + cfg.HTTPPort + "/health")
+		if err == nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("GET /health over HTTPS: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.ProtoMajor != 2 {
+		t.Errorf("GET /health = %d over %s, want 200 over HTTP/2", resp.StatusCode, resp.Proto)
+	}
+
+	conn, err := grpc.Dial("127.0.0.1:"+cfg.GRPCPort, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{RootCAs: roots})))
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// The service answering at all proves the handshake succeeded
+	err = conn.Invoke(ctx, "/video.VideoService/GetVideo", &pb.GetVideoRequest{VideoId: "missing"}, &pb.Video{}, grpc.WaitForReady(true))
+	if !strings.Contains(status.Convert(err).Message(), "not found") {
+		t.Errorf("GetVideo over TLS = %v, want the service's not found error", err)
+	}
+}
+
+func TestLoadTLSConfig(t *testing.T) {
+	if tlsConfig, err := loadTLSConfig(&config.Config{}); tlsConfig != nil || err != nil {
+		t.Errorf("loadTLSConfig without a certificate = %v, %v; want plaintext", tlsConfig, err)
+	}
+
+	dir := t.TempDir()
+	certFile, _, _ := writeSelfSignedCert(t, dir)
+	if _, err := loadTLSConfig(&config.Config{TLSCertFile: certFile, TLSKeyFile: filepath.Join(dir, "missing.pem")}); err == nil {
+		t.Errorf("loadTLSConfig with a missing key succeeded")
+	}
+}
@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"videostreaming/internal/service/video"
+)
+
+// ErrInvalidToken is returned when the token service rejects a token,
+// e.g. because it is unknown or has expired
+var ErrInvalidToken = errors.New("invalid token")
+
+// authorizationMetadataKey carries the Bearer token in gRPC metadata
+const authorizationMetadataKey = "authorization"
+
+// TokenChecker validates access tokens against the /check/ endpoint of the
+// OAuth token service
+type TokenChecker struct {
+	checkURL   string
+	httpClient *http.Client
+}
+
+// NewTokenChecker creates a checker for the token service at baseURL
+// (e.g. http://localhost:8000)
+func NewTokenChecker(baseURL string) *TokenChecker {
+	return &TokenChecker{
+		checkURL:   strings.TrimRight(baseURL, "/") + "/check/",
+		httpClient: &http.Client{Timeout: 3 * time.Second},
+	}
+}
+
+// checkResponse is the body of the token service's /check/ responses,
+// shaped like an RFC 7662 introspection response
+type checkResponse struct {
+	Active   bool   `json:"active"`
+	ClientID string `json:"client_id"`
+	Scope    string `json:"scope"`
+	Error    string `json:"error"`
+}
+
+// CheckToken returns the client ID and scope the token was issued for.
+// Tokens the service rejects are reported as ErrInvalidToken; any other
+// error means the service couldn't be asked.
+func (c *TokenChecker) CheckToken(ctx context.Context, token string) (clientID, scope string, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.checkURL, nil)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to query token service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body checkResponse
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			return "", "", fmt.Errorf("failed to decode token service response: %w", err)
+		}
+		if !body.Active {
+			return "", "", fmt.Errorf("%w: token is not active", ErrInvalidToken)
+		}
+		if body.ClientID == "" {
+			return "", "", fmt.Errorf("%w: token has no client", ErrInvalidToken)
+		}
+		return body.ClientID, body.Scope, nil
+	case http.StatusBadRequest, http.StatusUnauthorized:
+		// The service explains the rejection, e.g. "token expired"
+		json.NewDecoder(resp.Body).Decode(&body)
+		return "", "", fmt.Errorf("%w: %s", ErrInvalidToken, body.Error)
+	default:
+		return "", "", fmt.Errorf("token service returned status %d", resp.StatusCode)
+	}
+}
+
+// UnaryServerInterceptor authenticates every call with the Bearer token in
+// its authorization metadata and runs the handler as the token's client.
+// Calls without a valid token fail with codes.Unauthenticated, and calls
+// made while the token service is unreachable with codes.Unavailable.
+func UnaryServerInterceptor(checker *TokenChecker) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authenticate(ctx, checker)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor authenticates streaming calls the same way
+// UnaryServerInterceptor does unary ones
+func StreamServerInterceptor(checker *TokenChecker) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(ss.Context(), checker)
+		if err != nil {
+			return err
+		}
+		return handler(srv, authenticatedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authenticatedStream is a server stream whose context carries the caller's identity
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// authenticate checks the call's Bearer token and returns a context acting
+// as the token's client, or the gRPC status error to fail the call with
+func authenticate(ctx context.Context, checker *TokenChecker) (context.Context, error) {
+	token, ok := bearerToken(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "missing Bearer token in authorization metadata")
+	}
+
+	clientID, _, err := checker.CheckToken(ctx, token)
+	if errors.Is(err, ErrInvalidToken) {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to check token: %v", err)
+	}
+
+	return video.WithAuthenticatedUser(ctx, clientID), nil
+}
+
+// HTTPMiddleware authenticates REST requests carrying an "Authorization:
+// Bearer" header and runs the handler as the token's client. Requests
+// without the header pass through anonymously, leaving it to the service to
+// refuse what anonymous callers may not do. Invalid tokens get 401, and
+// requests made while the token service is unreachable get 503.
+func HTTPMiddleware(checker *TokenChecker) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get("Authorization")
+			if header == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			token, ok := parseBearer(header)
+			if !ok {
+				http.Error(w, "Malformed Authorization header, expected a Bearer token", http.StatusUnauthorized)
+				return
+			}
+
+			clientID, _, err := checker.CheckToken(r.Context(), token)
+			if errors.Is(err, ErrInvalidToken) {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Failed to check token: %v", err), http.StatusServiceUnavailable)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(video.WithAuthenticatedUser(r.Context(), clientID)))
+		})
+	}
+}
+
+// bearerToken returns the token of the "Bearer <token>" authorization metadata
+func bearerToken(ctx context.Context) (string, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", false
+	}
+	values := md.Get(authorizationMetadataKey)
+	if len(values) == 0 {
+		return "", false
+	}
+	return parseBearer(values[0])
+}
+
+// parseBearer returns the token of a "Bearer <token>" authorization value
+func parseBearer(value string) (string, bool) {
+	scheme, token, found := strings.Cut(value, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return token, true
+}
@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"videostreaming/internal/service/video"
+)
+
+// newTokenService fakes the /check/ endpoint, knowing a single valid token
+// and one that has expired
+func newTokenService(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer expired-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "token expired"})
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer good-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "token not found"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"active":    true,
+			"client_id": "alice",
+			"scope":     "read write",
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestHTTPMiddleware(t *testing.T) {
+	tokenService := newTokenService(t)
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	tests := []struct {
+		name         string
+		checkerURL   string
+		header       string
+		wantStatus   int
+		wantIdentity string
+	}{
+		{"anonymous", tokenService.URL, "", http.StatusOK, ""},
+		{"valid token", tokenService.URL, "Bearer good-token", http.StatusOK, "alice"},
+		{"scheme is case-insensitive", tokenService.URL, "bearer good-token", http.StatusOK, "alice"},
+		{"unknown token", tokenService.URL, "Bearer bad-token", http.StatusUnauthorized, ""},
+		{"expired token", tokenService.URL, "Bearer expired-token", http.StatusUnauthorized, ""},
+		{"not a Bearer token", tokenService.URL, "Basic YWxpY2U6cHc=", http.StatusUnauthorized, ""},
+		{"token service down", unreachable.URL, "Bearer good-token", http.StatusServiceUnavailable, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var identity string
+			handler := HTTPMiddleware(NewTokenChecker(tt.checkerURL))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				identity, _ = video.AuthenticatedUserID(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodDelete, "/api/v1/videos/v1", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if identity != tt.wantIdentity {
+				t.Errorf("identity = %q, want %q", identity, tt.wantIdentity)
+			}
+		})
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	tokenService := newTokenService(t)
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	tests := []struct {
+		name         string
+		checkerURL   string
+		metadata     metadata.MD
+		wantCode     codes.Code
+		wantMessage  string
+		wantIdentity string
+	}{
+		{"valid token", tokenService.URL, metadata.Pairs("authorization", "Bearer good-token"), codes.OK, "", "alice"},
+		{"missing token", tokenService.URL, metadata.MD{}, codes.Unauthenticated, "missing Bearer token", ""},
+		{"no metadata", tokenService.URL, nil, codes.Unauthenticated, "missing Bearer token", ""},
+		// The token service's reason is passed on to the caller
+		{"expired token", tokenService.URL, metadata.Pairs("authorization", "Bearer expired-token"), codes.Unauthenticated, "token expired", ""},
+		{"not a Bearer token", tokenService.URL, metadata.Pairs("authorization", "good-token"), codes.Unauthenticated, "missing Bearer token", ""},
+		{"token service down", unreachable.URL, metadata.Pairs("authorization", "Bearer good-token"), codes.Unavailable, "failed to check token", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.metadata != nil {
+				ctx = metadata.NewIncomingContext(ctx, tt.metadata)
+			}
+
+			var identity string
+			called := false
+			interceptor := UnaryServerInterceptor(NewTokenChecker(tt.checkerURL))
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/video.VideoService/DeleteVideo"}, func(ctx context.Context, req any) (any, error) {
+				called = true
+				identity, _ = video.AuthenticatedUserID(ctx)
+				return nil, nil
+			})
+
+			if code := status.Code(err); code != tt.wantCode {
+				t.Errorf("code = %v, want %v (error %v)", code, tt.wantCode, err)
+			}
+			if called != (tt.wantCode == codes.OK) {
+				t.Errorf("handler called = %v, want it called only for a valid token", called)
+			}
+			if identity != tt.wantIdentity {
+				t.Errorf("identity = %q, want %q", identity, tt.wantIdentity)
+			}
+			if message := status.Convert(err).Message(); !strings.Contains(message, tt.wantMessage) {
+				t.Errorf("message = %q, want it to mention %q", message, tt.wantMessage)
+			}
+		})
+	}
+}
+
+// fakeServerStream is a server stream with a fixed context
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s fakeServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	interceptor := StreamServerInterceptor(NewTokenChecker(newTokenService(t).URL))
+	handler := func(identity *string) grpc.StreamHandler {
+		return func(srv any, ss grpc.ServerStream) error {
+			*identity, _ = video.AuthenticatedUserID(ss.Context())
+			return nil
+		}
+	}
+
+	var identity string
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer good-token"))
+	if err := interceptor(nil, fakeServerStream{ctx: ctx}, &grpc.StreamServerInfo{}, handler(&identity)); err != nil {
+		t.Fatalf("interceptor: %v", err)
+	}
+	if identity != "alice" {
+		t.Errorf("identity = %q, want alice", identity)
+	}
+
+	identity = ""
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer expired-token"))
+	if err := interceptor(nil, fakeServerStream{ctx: ctx}, &grpc.StreamServerInfo{}, handler(&identity)); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expired token error = %v, want %v", err, codes.Unauthenticated)
+	}
+	if identity != "" {
+		t.Errorf("handler ran as %q with an expired token", identity)
+	}
+}
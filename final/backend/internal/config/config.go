@@ -0,0 +1,471 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultAllowedOrigins lets the frontend dev server call the REST API
+const defaultAllowedOrigins = "http://localhost:3000,http://127.0.0.1:3000"
+
+// Config holds the server configuration read from the environment.
+// Every field is loaded and validated once at startup by Load.
+type Config struct {
+	// MediaDir is the root directory for uploaded and transcoded files (MEDIA_DIR)
+	MediaDir string
+	// BaseURL is the public URL of the REST server used in generated links (BASE_URL)
+	BaseURL string
+	// DownloadURLSecret signs file download URLs; a random one is used when
+	// unset, so links stop working on restart (DOWNLOAD_URL_SECRET)
+	DownloadURLSecret string
+
+	// GRPCPort is the port the gRPC server listens on (GRPC_PORT)
+	GRPCPort string
+	// GRPCReflection exposes the gRPC reflection service so tools like
+	// grpcurl can list the server's services (GRPC_REFLECTION)
+	GRPCReflection bool
+	// HTTPPort is the port the REST server listens on (HTTP_PORT)
+	HTTPPort string
+	// LogLevel is the minimum level of the JSON logs: debug, info, warn or error (LOG_LEVEL)
+	LogLevel slog.Level
+	// TLSCertFile and TLSKeyFile are the PEM certificate and key both servers
+	// use for TLS; when unset they serve plaintext (TLS_CERT_FILE, TLS_KEY_FILE)
+	TLSCertFile string
+	TLSKeyFile  string
+	// CORSAllowedOrigins are the browser origins allowed to call the REST API;
+	// "*" allows any origin but turns off credentialed requests (CORS_ALLOWED_ORIGINS)
+	CORSAllowedOrigins []string
+	// AuthServiceURL is the OAuth token service whose /check/ endpoint
+	// authenticates gRPC calls and REST requests; once set, acting as a user
+	// takes a Bearer token. Empty leaves both unauthenticated (AUTH_SERVICE_URL)
+	AuthServiceURL string
+	// ShutdownTimeout is how long in-flight requests may run after a
+	// termination signal before they are cut off (SHUTDOWN_TIMEOUT)
+	ShutdownTimeout time.Duration
+
+	// RTMPURL is the MediaMTX RTMP ingest URL handed to streamers (RTMP_URL)
+	RTMPURL string
+	// HLSURL is the MediaMTX HLS base URL used for playback (HLS_URL)
+	HLSURL string
+	// WebRTCURL is the MediaMTX WebRTC base URL used for playback (WEBRTC_URL)
+	WebRTCURL string
+	// PlaybackURLTemplate is the HLS playback URL layout with {hls} and
+	// {stream} placeholders (PLAYBACK_URL_TEMPLATE)
+	PlaybackURLTemplate string
+	// MediaMTXAPIURL is the MediaMTX control API used to query stream state (MEDIAMTX_API_URL)
+	MediaMTXAPIURL string
+	// RequireActivePublisher makes StartStream check MediaMTX for a publisher
+	// on the stream key before going live (REQUIRE_ACTIVE_PUBLISHER)
+	RequireActivePublisher bool
+	// MaxStreamViewers caps concurrent viewers per live stream;
+	// zero means unlimited (MAX_STREAM_VIEWERS)
+	MaxStreamViewers int
+
+	// CDNURL is the CDN distribution URL fronting transcoded output (CDN_URL)
+	CDNURL string
+	// CDNCookieDomain is the domain signed playback cookies are scoped to (CDN_COOKIE_DOMAIN)
+	CDNCookieDomain string
+	// CloudFrontKeyPairID enables signed-cookie playback when set (CLOUDFRONT_KEY_PAIR_ID)
+	CloudFrontKeyPairID string
+	// CloudFrontPrivateKeyFile is the PEM key used to sign cookies (CLOUDFRONT_PRIVATE_KEY_FILE)
+	CloudFrontPrivateKeyFile string
+
+	// MaxDescriptionLength caps video and stream descriptions in characters;
+	// zero disables the cap (MAX_DESCRIPTION_LENGTH)
+	MaxDescriptionLength int
+	// UserStorageQuota caps the total upload size per user in bytes;
+	// zero means unlimited (USER_STORAGE_QUOTA)
+	UserStorageQuota int
+
+	// StorageBackend keeps video metadata and transcoding jobs in memory,
+	// mongodb or postgres; memory loses everything on restart (STORAGE_BACKEND)
+	StorageBackend string
+	// MongoURI is the connection string of the mongodb backend (MONGO_URI)
+	MongoURI string
+	// MongoDatabase is the database the mongodb backend uses (MONGO_DATABASE)
+	MongoDatabase string
+	// DatabaseURL is the connection string of the postgres backend (DATABASE_URL)
+	DatabaseURL string
+
+	// VideoCacheTTL is how long video metadata is cached in memory when
+	// the storage cache is off; zero disables the cache (VIDEO_CACHE_TTL)
+	VideoCacheTTL time.Duration
+	// StorageCacheSize is how many videos the storage LRU cache holds;
+	// zero bypasses the cache (STORAGE_CACHE_SIZE)
+	StorageCacheSize int
+	// StorageCacheTTL is how long a video stays in the storage cache (STORAGE_CACHE_TTL)
+	StorageCacheTTL time.Duration
+
+	// MaxRenditions caps how many resolutions are transcoded per video,
+	// keeping the highest; zero means no cap (MAX_RENDITIONS)
+	MaxRenditions int
+	// MaxTranscodeRetries is how often a transiently failed transcode is retried (MAX_TRANSCODE_RETRIES)
+	MaxTranscodeRetries int
+	// TranscodeBitrates overrides the video bitrate ladder as resolution=bitrate
+	// pairs, e.g. "1080p=5000k,720p=3000k"; every resolution from 360p to
+	// 2160p must be listed (TRANSCODE_BITRATES)
+	TranscodeBitrates string
+	// TranscodeCodec is the FFmpeg video encoder: libx264, libx265 or
+	// libvpx-vp9, which is packaged for DASH instead of HLS (TRANSCODE_CODEC)
+	TranscodeCodec string
+	// TranscodeAudioBitrate is the audio bitrate of every rendition (TRANSCODE_AUDIO_BITRATE)
+	TranscodeAudioBitrate string
+	// DedupeScope decides which earlier uploads an identical upload may share
+	// media with: off, user or global (DEDUPE_SCOPE)
+	DedupeScope string
+
+	// RecordingsDir is where MediaMTX writes live stream recordings (RECORDINGS_DIR)
+	RecordingsDir string
+	// RecordingRetention is how long recordings are kept before deletion;
+	// zero keeps them forever (RECORDING_RETENTION)
+	RecordingRetention time.Duration
+	// RecordingRetentionByUser overrides RecordingRetention for the streams of
+	// single users, as user=duration pairs, e.g. "alice=720h,bob=0"; zero
+	// keeps that user's recordings forever (RECORDING_RETENTION_BY_USER)
+	RecordingRetentionByUser map[string]time.Duration
+	// RecordingSweepInterval is how often expired recordings are looked for (RECORDING_SWEEP_INTERVAL)
+	RecordingSweepInterval time.Duration
+	// DeleteRecordingAfterVOD deletes a stream's recording once its archived
+	// video has been fully transcoded (RECORDING_DELETE_AFTER_VOD)
+	DeleteRecordingAfterVOD bool
+	// AutoArchiveStreams turns ended live streams into videos by transcoding
+	// their recording; RECORDINGS_DIR must lie inside MEDIA_DIR (AUTO_ARCHIVE_STREAMS)
+	AutoArchiveStreams bool
+
+	// WebhookSecret signs webhooks sent to video callback URLs; webhooks are
+	// only sent when it is set (WEBHOOK_SECRET)
+	WebhookSecret string
+	// WebhookTimeout bounds each webhook delivery attempt (WEBHOOK_TIMEOUT)
+	WebhookTimeout time.Duration
+	// WebhookMaxRetries is how often a failed webhook delivery is retried (WEBHOOK_MAX_RETRIES)
+	WebhookMaxRetries int
+
+	// TrashRetention is how long deleted videos stay restorable before they
+	// are purged; zero keeps them forever (TRASH_RETENTION)
+	TrashRetention time.Duration
+	// TrashPurgeInterval is how often expired deleted videos are looked for (TRASH_PURGE_INTERVAL)
+	TrashPurgeInterval time.Duration
+}
+
+// Load reads the configuration from environment variables, applies defaults
+// and validates the result. All problems are reported together so that a
+// misconfigured deployment fails at boot with the full list.
+func Load() (*Config, error) {
+	l := &loader{}
+
+	cfg := &Config{
+		MediaDir: l.string("MEDIA_DIR", "./media"),
+		BaseURL:  l.url("BASE_URL", "http://localhost:8080"),
+
+		DownloadURLSecret: l.string("DOWNLOAD_URL_SECRET", ""),
+
+		GRPCPort:       l.port("GRPC_PORT", "50051"),
+		GRPCReflection: l.bool("GRPC_REFLECTION", true),
+		HTTPPort:       l.port("HTTP_PORT", "8080"),
+
+		TLSCertFile: l.string("TLS_CERT_FILE", ""),
+		TLSKeyFile:  l.string("TLS_KEY_FILE", ""),
+
+		CORSAllowedOrigins: parseAllowedOrigins(l.string("CORS_ALLOWED_ORIGINS", defaultAllowedOrigins)),
+		AuthServiceURL:     l.url("AUTH_SERVICE_URL", ""),
+		ShutdownTimeout:    l.duration("SHUTDOWN_TIMEOUT", 15*time.Second),
+
+		RTMPURL:   l.url("RTMP_URL", "rtmp://localhost:1935/live"),
+		HLSURL:    l.url("HLS_URL", "http://localhost:8888/live"),
+		WebRTCURL: l.url("WEBRTC_URL", "http://localhost:8889/live"),
+
+		PlaybackURLTemplate: l.string("PLAYBACK_URL_TEMPLATE", "{hls}/{stream}/index.m3u8"),
+		MediaMTXAPIURL:      l.url("MEDIAMTX_API_URL", "http://localhost:9997"),
+
+		RequireActivePublisher: l.bool("REQUIRE_ACTIVE_PUBLISHER", false),
+		MaxStreamViewers:       l.int("MAX_STREAM_VIEWERS", 0),
+
+		CDNURL:                   l.url("CDN_URL", ""),
+		CDNCookieDomain:          l.string("CDN_COOKIE_DOMAIN", ""),
+		CloudFrontKeyPairID:      l.string("CLOUDFRONT_KEY_PAIR_ID", ""),
+		CloudFrontPrivateKeyFile: l.string("CLOUDFRONT_PRIVATE_KEY_FILE", ""),
+
+		MaxDescriptionLength: l.int("MAX_DESCRIPTION_LENGTH", 5000),
+		UserStorageQuota:     l.int("USER_STORAGE_QUOTA", 0),
+
+		StorageBackend: l.string("STORAGE_BACKEND", "memory"),
+		MongoURI:       l.string("MONGO_URI", ""),
+		MongoDatabase:  l.string("MONGO_DATABASE", "videostreaming"),
+		DatabaseURL:    l.string("DATABASE_URL", ""),
+
+		VideoCacheTTL:    l.duration("VIDEO_CACHE_TTL", 5*time.Second),
+		StorageCacheSize: l.int("STORAGE_CACHE_SIZE", 1000),
+		StorageCacheTTL:  l.duration("STORAGE_CACHE_TTL", 30*time.Second),
+
+		MaxRenditions:       l.int("MAX_RENDITIONS", 0),
+		MaxTranscodeRetries: l.int("MAX_TRANSCODE_RETRIES", 3),
+		DedupeScope:         l.string("DEDUPE_SCOPE", "user"),
+
+		TranscodeBitrates:     l.string("TRANSCODE_BITRATES", ""),
+		TranscodeCodec:        l.string("TRANSCODE_CODEC", "libx264"),
+		TranscodeAudioBitrate: l.string("TRANSCODE_AUDIO_BITRATE", "128k"),
+
+		RecordingsDir:          l.string("RECORDINGS_DIR", "./media/recordings"),
+		RecordingRetention:     l.duration("RECORDING_RETENTION", 7*24*time.Hour),
+		RecordingSweepInterval: l.duration("RECORDING_SWEEP_INTERVAL", time.Hour),
+		AutoArchiveStreams:     l.bool("AUTO_ARCHIVE_STREAMS", false),
+
+		RecordingRetentionByUser: l.durations("RECORDING_RETENTION_BY_USER"),
+		DeleteRecordingAfterVOD:  l.bool("RECORDING_DELETE_AFTER_VOD", false),
+
+		WebhookSecret:     l.string("WEBHOOK_SECRET", ""),
+		WebhookTimeout:    l.duration("WEBHOOK_TIMEOUT", 5*time.Second),
+		WebhookMaxRetries: l.int("WEBHOOK_MAX_RETRIES", 3),
+
+		TrashRetention:     l.duration("TRASH_RETENTION", 30*24*time.Hour),
+		TrashPurgeInterval: l.duration("TRASH_PURGE_INTERVAL", time.Hour),
+	}
+
+	logLevel := l.string("LOG_LEVEL", "info")
+	if err := cfg.LogLevel.UnmarshalText([]byte(logLevel)); err != nil {
+		l.errs = append(l.errs, fmt.Errorf("LOG_LEVEL: %q is not one of debug, info, warn, error", logLevel))
+	}
+
+	if !strings.Contains(cfg.PlaybackURLTemplate, "{stream}") {
+		l.errs = append(l.errs, fmt.Errorf("PLAYBACK_URL_TEMPLATE: %q has no {stream} placeholder", cfg.PlaybackURLTemplate))
+	}
+
+	if len(cfg.CORSAllowedOrigins) == 0 {
+		l.errs = append(l.errs, errors.New("CORS_ALLOWED_ORIGINS must list at least one origin"))
+	}
+
+	switch cfg.DedupeScope {
+	case "off", "user", "global":
+	default:
+		l.errs = append(l.errs, fmt.Errorf("DEDUPE_SCOPE: %q is not one of off, user, global", cfg.DedupeScope))
+	}
+
+	switch cfg.StorageBackend {
+	case "memory":
+	case "mongodb":
+		l.require("MONGO_URI", cfg.MongoURI)
+	case "postgres":
+		l.require("DATABASE_URL", cfg.DatabaseURL)
+	default:
+		l.errs = append(l.errs, fmt.Errorf("STORAGE_BACKEND: %q is not one of memory, mongodb, postgres", cfg.StorageBackend))
+	}
+
+	// Archived streams are transcoded through file storage, which is rooted at MEDIA_DIR
+	if cfg.AutoArchiveStreams {
+		if _, err := cfg.RecordingsKeyPrefix(); err != nil {
+			l.errs = append(l.errs, err)
+		}
+	}
+
+	// A certificate is useless without its key and vice versa
+	if cfg.TLSCertFile != "" {
+		l.require("TLS_KEY_FILE", cfg.TLSKeyFile)
+	}
+	if cfg.TLSKeyFile != "" {
+		l.require("TLS_CERT_FILE", cfg.TLSCertFile)
+	}
+
+	// Signed cookies need the whole key pair and distribution
+	if cfg.CloudFrontKeyPairID != "" {
+		l.require("CLOUDFRONT_PRIVATE_KEY_FILE", cfg.CloudFrontPrivateKeyFile)
+		l.require("CDN_URL", cfg.CDNURL)
+	}
+
+	if cfg.SweepsRecordings() && cfg.RecordingSweepInterval == 0 {
+		l.errs = append(l.errs, errors.New("RECORDING_SWEEP_INTERVAL must be positive when RECORDING_RETENTION or RECORDING_RETENTION_BY_USER is set"))
+	}
+	// Only archived streams have a video whose transcoding can finish
+	if cfg.DeleteRecordingAfterVOD && !cfg.AutoArchiveStreams {
+		l.errs = append(l.errs, errors.New("RECORDING_DELETE_AFTER_VOD needs AUTO_ARCHIVE_STREAMS"))
+	}
+	if cfg.WebhookSecret != "" && cfg.WebhookTimeout == 0 {
+		l.errs = append(l.errs, errors.New("WEBHOOK_TIMEOUT must be positive when WEBHOOK_SECRET is set"))
+	}
+	if cfg.TrashRetention > 0 && cfg.TrashPurgeInterval == 0 {
+		l.errs = append(l.errs, errors.New("TRASH_PURGE_INTERVAL must be positive when TRASH_RETENTION is set"))
+	}
+
+	if err := errors.Join(l.errs...); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// parseAllowedOrigins splits a comma separated origin list, dropping blank
+// entries and trailing slashes, which browsers never send in Origin
+func parseAllowedOrigins(env string) []string {
+	var origins []string
+	for _, origin := range strings.Split(env, ",") {
+		origin = strings.TrimRight(strings.TrimSpace(origin), "/")
+		if origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// TLSEnabled reports whether the servers should serve TLS
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+// AllowsAnyOrigin reports whether CORS_ALLOWED_ORIGINS contains the "*" wildcard
+func (c *Config) AllowsAnyOrigin() bool {
+	for _, origin := range c.CORSAllowedOrigins {
+		if origin == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// SweepsRecordings reports whether any recordings expire, so that the
+// recording sweeper has to run
+func (c *Config) SweepsRecordings() bool {
+	if c.RecordingRetention > 0 {
+		return true
+	}
+	for _, retention := range c.RecordingRetentionByUser {
+		if retention > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// RecordingsKeyPrefix returns where RECORDINGS_DIR sits in the file storage
+// rooted at MEDIA_DIR, e.g. "recordings/"
+func (c *Config) RecordingsKeyPrefix() (string, error) {
+	rel, err := filepath.Rel(c.MediaDir, c.RecordingsDir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("RECORDINGS_DIR: %q must be inside MEDIA_DIR %q to archive streams", c.RecordingsDir, c.MediaDir)
+	}
+	if rel == "." {
+		return "", nil
+	}
+	return filepath.ToSlash(rel) + "/", nil
+}
+
+// loader reads typed values from the environment and collects parse errors
+type loader struct {
+	errs []error
+}
+
+// lookup returns the value of an environment variable, treating an empty
+// value the same as an unset one
+func (l *loader) lookup(key string) (string, bool) {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return "", false
+	}
+	return value, true
+}
+
+// string returns the variable as-is, or fallback when unset
+func (l *loader) string(key, fallback string) string {
+	if value, ok := l.lookup(key); ok {
+		return value
+	}
+	return fallback
+}
+
+// require records an error when a mandatory variable is empty
+func (l *loader) require(key, value string) {
+	if value == "" {
+		l.errs = append(l.errs, fmt.Errorf("%s is required", key))
+	}
+}
+
+// int returns the variable parsed as a non-negative integer
+func (l *loader) int(key string, fallback int) int {
+	value, ok := l.lookup(key)
+	if !ok {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		l.errs = append(l.errs, fmt.Errorf("%s: %q is not a non-negative integer", key, value))
+		return fallback
+	}
+	return n
+}
+
+// bool returns the variable parsed as a boolean ("true", "false", "1", "0", ...)
+func (l *loader) bool(key string, fallback bool) bool {
+	value, ok := l.lookup(key)
+	if !ok {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%s: %q is not a boolean", key, value))
+		return fallback
+	}
+	return b
+}
+
+// duration returns the variable parsed as a non-negative time.Duration (e.g. "5s")
+func (l *loader) duration(key string, fallback time.Duration) time.Duration {
+	value, ok := l.lookup(key)
+	if !ok {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		l.errs = append(l.errs, fmt.Errorf("%s: %q is not a non-negative duration", key, value))
+		return fallback
+	}
+	return d
+}
+
+// durations returns the variable parsed as comma-separated key=duration
+// pairs, e.g. "alice=24h,bob=0", or nil when unset
+func (l *loader) durations(key string) map[string]time.Duration {
+	value, ok := l.lookup(key)
+	if !ok {
+		return nil
+	}
+	result := make(map[string]time.Duration)
+	for _, pair := range strings.Split(value, ",") {
+		name, raw, found := strings.Cut(strings.TrimSpace(pair), "=")
+		d, err := time.ParseDuration(strings.TrimSpace(raw))
+		if !found || strings.TrimSpace(name) == "" || err != nil || d < 0 {
+			l.errs = append(l.errs, fmt.Errorf("%s: %q is not a name=duration pair", key, pair))
+			continue
+		}
+		result[strings.TrimSpace(name)] = d
+	}
+	return result
+}
+
+// port returns the variable after checking it is a valid TCP port number
+func (l *loader) port(key, fallback string) string {
+	value := l.string(key, fallback)
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 || n > 65535 {
+		l.errs = append(l.errs, fmt.Errorf("%s: %q is not a valid port", key, value))
+	}
+	return value
+}
+
+// url returns the variable after checking it is an absolute URL.
+// An unset variable with an empty fallback is left empty.
+func (l *loader) url(key, fallback string) string {
+	value := l.string(key, fallback)
+	if value == "" {
+		return value
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		l.errs = append(l.errs, fmt.Errorf("%s: %q is not an absolute URL", key, value))
+	}
+	return value
+}
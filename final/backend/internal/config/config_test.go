@@ -0,0 +1,58 @@
+package config
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseAllowedOrigins(t *testing.T) {
+	tests := []struct {
+		env  string
+		want []string
+	}{
+		{"https://app.example.com", []string{"https://app.example.com"}},
+		{" https://a.example.com/ ,https://b.example.com", []string{"https://a.example.com", "https://b.example.com"}},
+		{"https://a.example.com,,", []string{"https://a.example.com"}},
+		{"*", []string{"*"}},
+		{" , ", nil},
+	}
+	for _, tt := range tests {
+		if got := parseAllowedOrigins(tt.env); !slices.Equal(got, tt.want) {
+			t.Errorf("parseAllowedOrigins(%q) = %q, want %q", tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestAllowsAnyOrigin(t *testing.T) {
+	tests := []struct {
+		origins []string
+		want    bool
+	}{
+		{parseAllowedOrigins(defaultAllowedOrigins), false},
+		{[]string{"*"}, true},
+		{[]string{"https://app.example.com", "*"}, true},
+	}
+	for _, tt := range tests {
+		cfg := &Config{CORSAllowedOrigins: tt.origins}
+		if got := cfg.AllowsAnyOrigin(); got != tt.want {
+			t.Errorf("AllowsAnyOrigin with %q = %v, want %v", tt.origins, got, tt.want)
+		}
+	}
+}
+
+func TestLoadAllowedOrigins(t *testing.T) {
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	// Without configuration only the local frontend is allowed
+	if cfg.AllowsAnyOrigin() || !slices.Contains(cfg.CORSAllowedOrigins, "http://localhost:3000") {
+		t.Errorf("default origins = %q, want the localhost frontend only", cfg.CORSAllowedOrigins)
+	}
+
+	t.Setenv("CORS_ALLOWED_ORIGINS", " , ")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "CORS_ALLOWED_ORIGINS") {
+		t.Errorf("Load with no origins error = %v, want it to mention CORS_ALLOWED_ORIGINS", err)
+	}
+}
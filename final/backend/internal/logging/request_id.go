@@ -0,0 +1,120 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// RequestIDHeader carries the request ID on REST requests and responses
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDMetadataKey carries the request ID in gRPC metadata
+const requestIDMetadataKey = "x-request-id"
+
+// maxRequestIDLength bounds client supplied IDs so they can't bloat the logs
+const maxRequestIDLength = 128
+
+// requestIDKey is the context key holding the request ID
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request ID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID stored in ctx, or "" if there is none
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// requestIDOrNew returns the caller's request ID when it is usable, or a new one
+func requestIDOrNew(requestID string) string {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return uuid.New().String()
+	}
+	return requestID
+}
+
+// RequestID is chi middleware that reads the X-Request-ID header, generating
+// an ID when it is missing, stores it in the request context and echoes it
+// on the response
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := requestIDOrNew(r.Header.Get(RequestIDHeader))
+		w.Header().Set(RequestIDHeader, requestID)
+		next.ServeHTTP(w, r.WithContext(WithRequestID(r.Context(), requestID)))
+	})
+}
+
+// RequestLogger is chi middleware that logs every request once it finishes.
+// It must run after RequestID so the log line carries the request ID.
+func RequestLogger(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			start := time.Now()
+			next.ServeHTTP(ww, r)
+
+			logger.InfoContext(r.Context(), "Handled request",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", ww.Status(),
+				"bytes", ww.BytesWritten(),
+				"duration", time.Since(start),
+			)
+		})
+	}
+}
+
+// UnaryServerInterceptor reads the x-request-id metadata, generating an ID
+// when it is missing, stores it in the call context and returns it in the
+// response header
+func UnaryServerInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	requestID := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(requestIDMetadataKey); len(values) > 0 {
+			requestID = values[0]
+		}
+	}
+	requestID = requestIDOrNew(requestID)
+
+	grpc.SetHeader(ctx, metadata.Pairs(requestIDMetadataKey, requestID))
+	return handler(WithRequestID(ctx, requestID), req)
+}
+
+// contextHandler adds the request ID from the context to every record
+type contextHandler struct {
+	slog.Handler
+}
+
+// NewHandler wraps h so records logged with a context carrying a request ID
+// get a request_id attribute
+func NewHandler(h slog.Handler) slog.Handler {
+	return contextHandler{h}
+}
+
+// Handle adds the request ID, if any, before passing the record on
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		record.AddAttrs(slog.String("request_id", requestID))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+// WithAttrs keeps the wrapper around handlers derived with Logger.With
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup keeps the wrapper around handlers derived with Logger.WithGroup
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
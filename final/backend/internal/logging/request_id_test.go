@@ -0,0 +1,70 @@
+package logging
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		// reused is whether the caller's ID is kept
+		reused bool
+	}{
+		{"caller supplied", "abc-123", true},
+		{"missing", "", false},
+		{"too long", strings.Repeat("x", maxRequestIDLength+1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = RequestIDFromContext(r.Context())
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				r.Header.Set(RequestIDHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if seen == "" || w.Header().Get(RequestIDHeader) != seen {
+				t.Errorf("context ID %q, response header %q; want the same ID", seen, w.Header().Get(RequestIDHeader))
+			}
+			if (seen == tt.header) != tt.reused {
+				t.Errorf("request ID = %q from header %q, want it reused = %v", seen, tt.header, tt.reused)
+			}
+		})
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	var seen string
+	handler := func(ctx context.Context, req any) (any, error) {
+		seen = RequestIDFromContext(ctx)
+		return nil, nil
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(requestIDMetadataKey, "abc-123"))
+	if _, err := UnaryServerInterceptor(ctx, nil, &grpc.UnaryServerInfo{}, handler); err != nil {
+		t.Fatalf("interceptor: %v", err)
+	}
+	if seen != "abc-123" {
+		t.Errorf("request ID = %q, want the one from metadata", seen)
+	}
+
+	if _, err := UnaryServerInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{}, handler); err != nil {
+		t.Fatalf("interceptor: %v", err)
+	}
+	if seen == "" || seen == "abc-123" {
+		t.Errorf("request ID = %q without metadata, want a new one", seen)
+	}
+}
@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// Metrics holds the Prometheus collectors of the video backend. It satisfies
+// the metrics interfaces of the video and transcode services.
+type Metrics struct {
+	requests          *prometheus.CounterVec
+	transcodeDuration *prometheus.HistogramVec
+	activeStreams     prometheus.Gauge
+}
+
+// New creates the collectors and registers them with reg
+func New(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "videostreaming_requests_total",
+			Help: "REST and gRPC calls handled, by transport, method and status.",
+		}, []string{"transport", "method", "status"}),
+		transcodeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "videostreaming_transcode_duration_seconds",
+			Help: "Time from a transcoding job's start to its completion or failure.",
+			// Jobs take from seconds for short clips up to hours for long 4K uploads
+			Buckets: prometheus.ExponentialBuckets(5, 2, 12),
+		}, []string{"resolution", "status"}),
+		activeStreams: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "videostreaming_active_live_streams",
+			Help: "Live streams started on this instance and not yet ended.",
+		}),
+	}
+	reg.MustRegister(m.requests, m.transcodeDuration, m.activeStreams)
+	return m
+}
+
+// ObserveTranscode records how long a finished transcoding job took
+func (m *Metrics) ObserveTranscode(resolution string, failed bool, duration time.Duration) {
+	status := "completed"
+	if failed {
+		status = "failed"
+	}
+	m.transcodeDuration.WithLabelValues(resolution, status).Observe(duration.Seconds())
+}
+
+// StreamStarted counts a live stream going live
+func (m *Metrics) StreamStarted() {
+	m.activeStreams.Inc()
+}
+
+// StreamEnded counts a live stream ending
+func (m *Metrics) StreamEnded() {
+	m.activeStreams.Dec()
+}
+
+// HTTPMiddleware is chi middleware counting requests by route pattern and
+// status code. Routes are used rather than paths to keep label values bounded.
+func (m *Metrics) HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		statusCode := ww.Status()
+		if statusCode == 0 {
+			statusCode = http.StatusOK
+		}
+		m.requests.WithLabelValues("rest", r.Method+" "+route, strconv.Itoa(statusCode)).Inc()
+	})
+}
+
+// UnaryServerInterceptor counts gRPC calls by full method name and status code
+func (m *Metrics) UnaryServerInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	resp, err := handler(ctx, req)
+	m.requests.WithLabelValues("grpc", info.FullMethod, status.Code(err).String()).Inc()
+	return resp, err
+}
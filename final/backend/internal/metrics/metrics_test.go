@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// requestCounts returns the request counter's values keyed by
+// "transport method status"
+func requestCounts(t *testing.T, reg *prometheus.Registry) map[string]float64 {
+	t.Helper()
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	counts := make(map[string]float64)
+	for _, family := range families {
+		if family.GetName() != "videostreaming_requests_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			counts[labels["transport"]+" "+labels["method"]+" "+labels["status"]] = metric.GetCounter().GetValue()
+		}
+	}
+	return counts
+}
+
+func TestHTTPMiddleware(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := New(reg)
+
+	router := chi.NewRouter()
+	router.Use(m.HTTPMiddleware)
+	router.Get("/api/v1/videos/{id}", func(w http.ResponseWriter, r *http.Request) {})
+
+	for _, path := range []string{"/api/v1/videos/v1", "/api/v1/videos/v2", "/nowhere"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	// Requests are labelled by route, so both videos share one series
+	counts := requestCounts(t, reg)
+	want := map[string]float64{
+		"rest GET /api/v1/videos/{id} 200": 2,
+		"rest GET unmatched 404":           1,
+	}
+	if len(counts) != len(want) {
+		t.Errorf("request counts = %v, want %v", counts, want)
+	}
+	for key, value := range want {
+		if counts[key] != value {
+			t.Errorf("requests %q = %v, want %v", key, counts[key], value)
+		}
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := New(reg)
+	info := &grpc.UnaryServerInfo{FullMethod: "/video.VideoService/GetVideo"}
+
+	m.UnaryServerInterceptor(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+		return nil, nil
+	})
+	m.UnaryServerInterceptor(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+		return nil, status.Error(codes.NotFound, "no such video")
+	})
+
+	counts := requestCounts(t, reg)
+	for _, key := range []string{"grpc /video.VideoService/GetVideo OK", "grpc /video.VideoService/GetVideo NotFound"} {
+		if counts[key] != 1 {
+			t.Errorf("requests %q = %v, want 1 in %v", key, counts[key], counts)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+
+	"videostreaming/internal/service/video"
+	pb "videostreaming/proto/video"
+)
+
+// SignatureHeader carries the HMAC-SHA256 of the webhook body, as
+// "sha256=<hex>", keyed with the shared webhook secret
+const SignatureHeader = "X-Webhook-Signature"
+
+// ErrForbiddenAddress is returned for callback URLs that resolve to a
+// loopback, link-local or private address, which webhooks must not reach
+var ErrForbiddenAddress = errors.New("callback URL resolves to a non-public address")
+
+// Webhook statuses reported in Payload.Status
+const (
+	StatusReady  = "ready"
+	StatusFailed = "failed"
+)
+
+// Notifier is the notification service webhooks are sent alongside
+type Notifier interface {
+	NotifyTranscodingComplete(ctx context.Context, videoID string, status pb.TranscodingStatus) error
+	NotifyTranscodingProgress(ctx context.Context, videoID string, progress float32) error
+	NotifyVideoFailed(ctx context.Context, videoID string, userID string, reason string) error
+}
+
+// VideoStore looks up the video a notification is about
+type VideoStore interface {
+	GetVideo(ctx context.Context, id string) (*video.Video, error)
+}
+
+// Payload is the JSON body POSTed to a video's callback URL
+type Payload struct {
+	VideoID   string    `json:"video_id"`
+	Status    string    `json:"status"`
+	Reason    string    `json:"reason,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// WebhookNotificationService calls a video's callback URL once the video is
+// ready or has failed, after passing every notification on to next.
+// Deliveries run in the background and are retried with exponential
+// backoff until a 2xx response or the retries run out. A receiver may see
+// the same event more than once and should treat it idempotently.
+type WebhookNotificationService struct {
+	next       Notifier
+	videos     VideoStore
+	secret     []byte
+	httpClient *http.Client
+	logger     *slog.Logger
+
+	maxRetries     int
+	retryBaseDelay time.Duration
+}
+
+// Option configures optional WebhookNotificationService settings
+type Option func(*WebhookNotificationService)
+
+// WithTimeout bounds each delivery attempt
+func WithTimeout(timeout time.Duration) Option {
+	return func(s *WebhookNotificationService) {
+		s.httpClient.Timeout = timeout
+	}
+}
+
+// WithMaxRetries sets how many times a failed delivery is retried
+func WithMaxRetries(n int) Option {
+	return func(s *WebhookNotificationService) {
+		s.maxRetries = n
+	}
+}
+
+// WithRetryBaseDelay sets the wait before the first retry; each further
+// retry waits twice as long as the previous one
+func WithRetryBaseDelay(d time.Duration) Option {
+	return func(s *WebhookNotificationService) {
+		s.retryBaseDelay = d
+	}
+}
+
+// WithLogger sets the logger for failed deliveries.
+// Without it the service logs to slog.Default().
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *WebhookNotificationService) {
+		s.logger = logger
+	}
+}
+
+// NewWebhookNotificationService creates a service signing webhooks with secret
+func NewWebhookNotificationService(next Notifier, videos VideoStore, secret []byte, opts ...Option) *WebhookNotificationService {
+	s := &WebhookNotificationService{
+		next:           next,
+		videos:         videos,
+		secret:         secret,
+		httpClient:     &http.Client{Timeout: 5 * time.Second, Transport: publicTransport()},
+		logger:         slog.Default(),
+		maxRetries:     3,
+		retryBaseDelay: time.Second,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// NotifyTranscodingComplete passes a finished job on and then sends the
+// ready webhook if that job was the video's last one. Failed videos are
+// reported through NotifyVideoFailed instead.
+func (s *WebhookNotificationService) NotifyTranscodingComplete(ctx context.Context, videoID string, status pb.TranscodingStatus) error {
+	err := s.next.NotifyTranscodingComplete(ctx, videoID, status)
+
+	v, getErr := s.videos.GetVideo(ctx, videoID)
+	if getErr != nil {
+		s.logger.ErrorContext(ctx, "Failed to load video for webhook", "video_id", videoID, "error", getErr)
+		return err
+	}
+	if v.Status == pb.VideoStatus_VIDEO_STATUS_READY {
+		s.send(ctx, v, StatusReady, "")
+	}
+
+	return err
+}
+
+// NotifyTranscodingProgress passes progress on; it doesn't trigger a webhook
+func (s *WebhookNotificationService) NotifyTranscodingProgress(ctx context.Context, videoID string, progress float32) error {
+	return s.next.NotifyTranscodingProgress(ctx, videoID, progress)
+}
+
+// NotifyVideoFailed passes the failure on and sends the failed webhook
+func (s *WebhookNotificationService) NotifyVideoFailed(ctx context.Context, videoID string, userID string, reason string) error {
+	err := s.next.NotifyVideoFailed(ctx, videoID, userID, reason)
+
+	v, getErr := s.videos.GetVideo(ctx, videoID)
+	if getErr != nil {
+		s.logger.ErrorContext(ctx, "Failed to load video for webhook", "video_id", videoID, "error", getErr)
+		return err
+	}
+	s.send(ctx, v, StatusFailed, reason)
+
+	return err
+}
+
+// send delivers the webhook for a video in the background, if it has a callback URL
+func (s *WebhookNotificationService) send(ctx context.Context, v *video.Video, status string, reason string) {
+	if v.CallbackURL == "" {
+		return
+	}
+
+	body, err := json.Marshal(Payload{
+		VideoID:   v.ID,
+		Status:    status,
+		Reason:    reason,
+		Timestamp: time.Now().UTC(),
+	})
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Failed to encode webhook", "video_id", v.ID, "error", err)
+		return
+	}
+
+	// Deliveries outlive the call that triggered them but keep its values,
+	// such as the request ID for logging
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		if err := s.deliver(ctx, v.CallbackURL, body); err != nil {
+			s.logger.ErrorContext(ctx, "Webhook delivery failed", "video_id", v.ID, "status", status, "error", err)
+		}
+	}()
+}
+
+// deliver POSTs a signed body to url, retrying failed attempts with
+// exponential backoff
+func (s *WebhookNotificationService) deliver(ctx context.Context, url string, body []byte) error {
+	err := s.post(ctx, url, body)
+	for attempt := 0; err != nil && !errors.Is(err, ErrForbiddenAddress) && attempt < s.maxRetries; attempt++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.retryBaseDelay << attempt):
+		}
+		err = s.post(ctx, url, body)
+	}
+	return err
+}
+
+// post makes a single delivery attempt, failing on anything but a 2xx response
+func (s *WebhookNotificationService) post(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(s.secret, body))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, which IsPrivate misses
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// publicTransport makes webhook requests that only ever connect to public
+// addresses. The check runs on the address actually dialed, after DNS
+// resolution, so a callback host can't be pointed at the internal network
+// once it has been accepted. Proxies are bypassed for the same reason.
+func publicTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return fmt.Errorf("failed to parse dialed address %s: %w", address, err)
+			}
+			if !isPublicAddr(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s", ErrForbiddenAddress, addrPort.Addr())
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return transport
+}
+
+// isPublicAddr reports whether addr may be reached by a webhook
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() &&
+		!addr.IsPrivate() &&
+		!addr.IsLoopback() &&
+		!addr.IsLinkLocalUnicast() &&
+		!sharedAddressSpace.Contains(addr)
+}
+
+// Sign returns the SignatureHeader value for a webhook body, letting
+// receivers check that a webhook came from this server
+func Sign(secret []byte, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
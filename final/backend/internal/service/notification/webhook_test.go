@@ -0,0 +1,151 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"videostreaming/internal/service/video"
+	pb "videostreaming/proto/video"
+)
+
+// nopNotifier is the notification service webhooks are sent alongside in tests
+type nopNotifier struct{}
+
+func (nopNotifier) NotifyTranscodingComplete(ctx context.Context, videoID string, status pb.TranscodingStatus) error {
+	return nil
+}
+
+func (nopNotifier) NotifyTranscodingProgress(ctx context.Context, videoID string, progress float32) error {
+	return nil
+}
+
+func (nopNotifier) NotifyVideoFailed(ctx context.Context, videoID string, userID string, reason string) error {
+	return nil
+}
+
+// videoStore serves a single video
+type videoStore struct {
+	video *video.Video
+}
+
+func (s videoStore) GetVideo(ctx context.Context, id string) (*video.Video, error) {
+	return s.video, nil
+}
+
+func TestWebhookPayloadAndSignature(t *testing.T) {
+	secret := []byte("webhook-secret")
+	type delivery struct {
+		body      []byte
+		signature string
+	}
+	deliveries := make(chan delivery, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		deliveries <- delivery{body, r.Header.Get(SignatureHeader)}
+	}))
+	defer server.Close()
+
+	v := &video.Video{ID: "v1", UserID: "owner", CallbackURL: server.URL}
+	s := NewWebhookNotificationService(nopNotifier{}, videoStore{v}, secret)
+	// The test server listens on loopback, which real deliveries refuse
+	s.httpClient = server.Client()
+
+	if err := s.NotifyVideoFailed(context.Background(), "v1", "owner", "bad input"); err != nil {
+		t.Fatalf("NotifyVideoFailed: %v", err)
+	}
+
+	var got delivery
+	select {
+	case got = <-deliveries:
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+	if got.signature != Sign(secret, got.body) {
+		t.Errorf("signature = %q, want %q", got.signature, Sign(secret, got.body))
+	}
+	var payload Payload
+	if err := json.Unmarshal(got.body, &payload); err != nil {
+		t.Fatalf("failed to decode payload: %v", err)
+	}
+	if payload.VideoID != "v1" || payload.Status != StatusFailed || payload.Reason != "bad input" || payload.Timestamp.IsZero() {
+		t.Errorf("payload = %+v, want the failed video", payload)
+	}
+}
+
+func TestWebhookRetriesServerErrors(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	s := NewWebhookNotificationService(nopNotifier{}, videoStore{}, []byte("secret"),
+		WithMaxRetries(3), WithRetryBaseDelay(time.Millisecond))
+	s.httpClient = server.Client()
+
+	if err := s.deliver(context.Background(), server.URL, []byte("{}")); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("webhook attempted %d times, want 3", got)
+	}
+}
+
+func TestWebhookRefusesNonPublicAddresses(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+	}))
+	defer server.Close()
+
+	s := NewWebhookNotificationService(nopNotifier{}, videoStore{}, []byte("secret"),
+		WithMaxRetries(3), WithRetryBaseDelay(time.Millisecond))
+
+	// Whether named by IP or by a host resolving to it, loopback is refused
+	port := server.URL[len("http://127.0.0.1"):]
+	for _, url := range []string{server.URL, "http://localhost" + port} {
+		if err := s.deliver(context.Background(), url, []byte("{}")); !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("deliver to %s error = %v, want %v", url, err, ErrForbiddenAddress)
+		}
+	}
+	if got := attempts.Load(); got != 0 {
+		t.Errorf("loopback server was called %d times", got)
+	}
+}
+
+func TestIsPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.0.0.1", false},
+		{"172.16.5.4", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+	}
+	for _, tt := range tests {
+		if got := isPublicAddr(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("isPublicAddr(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
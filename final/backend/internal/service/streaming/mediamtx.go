@@ -4,7 +4,13 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
 )
 
 // MediaMTXEngine implements the StreamingEngine interface using MediaMTX server
@@ -12,15 +18,46 @@ type MediaMTXEngine struct {
 	rtmpServerURL  string
 	hlsServerURL   string
 	webRTCServerURL string
+	apiURL         string
+	httpClient     *http.Client
+
+	// playbackURLTemplate builds HLS playback URLs; see WithPlaybackURLTemplate
+	playbackURLTemplate string
+}
+
+// DefaultPlaybackURLTemplate matches MediaMTX's default HLS layout
+const DefaultPlaybackURLTemplate = "{hls}/{stream}/index.m3u8"
+
+// Option configures optional MediaMTXEngine settings
+type Option func(*MediaMTXEngine)
+
+// WithPlaybackURLTemplate sets the layout of HLS playback URLs. The
+// placeholders {hls} and {stream} are replaced with the HLS base URL and
+// the stream ID, e.g. "{hls}/{stream}/stream.m3u8".
+func WithPlaybackURLTemplate(template string) Option {
+	return func(e *MediaMTXEngine) {
+		e.playbackURLTemplate = template
+	}
 }
 
-// NewMediaMTXEngine creates a new MediaMTX streaming engine
-func NewMediaMTXEngine(rtmpServerURL, hlsServerURL, webRTCServerURL string) *MediaMTXEngine {
-	return &MediaMTXEngine{
+// NewMediaMTXEngine creates a new MediaMTX streaming engine.
+// apiURL is the MediaMTX control API (e.g. http://localhost:9997).
+func NewMediaMTXEngine(rtmpServerURL, hlsServerURL, webRTCServerURL, apiURL string, opts ...Option) *MediaMTXEngine {
+	e := &MediaMTXEngine{
 		rtmpServerURL:  rtmpServerURL,
 		hlsServerURL:   hlsServerURL,
 		webRTCServerURL: webRTCServerURL,
+		apiURL:         strings.TrimRight(apiURL, "/"),
+		httpClient:     &http.Client{Timeout: 3 * time.Second},
+
+		playbackURLTemplate: DefaultPlaybackURLTemplate,
+	}
+
+	for _, opt := range opts {
+		opt(e)
 	}
+
+	return e
 }
 
 // GenerateStreamKey creates a unique stream key for a user
@@ -50,9 +87,10 @@ func (e *MediaMTXEngine) GetRTMPURL() string {
 // GetStreamPlaybackURL returns the playback URL for a stream
 // For MediaMTX, this is typically the HLS URL with the stream key
 func (e *MediaMTXEngine) GetStreamPlaybackURL(streamID string) string {
-	// In MediaMTX, the stream name in the URL is typically the stream key
-	// The actual playback URL will depend on how your frontend consumes the stream
-	return fmt.Sprintf("%s/%s/index.m3u8", e.hlsServerURL, streamID)
+	return strings.NewReplacer(
+		"{hls}", strings.TrimRight(e.hlsServerURL, "/"),
+		"{stream}", streamID,
+	).Replace(e.playbackURLTemplate)
 }
 
 // GetWebRTCPlaybackURL returns the WebRTC playback URL
@@ -60,12 +98,100 @@ func (e *MediaMTXEngine) GetWebRTCPlaybackURL(streamID string) string {
 	return fmt.Sprintf("%s/%s", e.webRTCServerURL, streamID)
 }
 
-// IsStreamActive checks if a stream is currently active on the server
-// This would typically involve calling an API on the MediaMTX server
-// or checking a status endpoint. For simplicity, we're returning true here.
+// IsStreamActive checks whether a publisher is currently sending the stream
+// by asking the MediaMTX API. Any failure to reach the API counts as inactive.
 func (e *MediaMTXEngine) IsStreamActive(streamID string) bool {
-	// In a real implementation, you would check if the stream is active on the MediaMTX server
-	// This could involve making an API call to MediaMTX's API endpoint
-	// For now, we'll just return true for demonstration purposes
-	return true
+	path, found, err := e.getPath(context.Background(), streamID)
+	if err != nil {
+		slog.Warn("Failed to check stream on MediaMTX", "stream_id", streamID, "error", err)
+		return false
+	}
+	return found && path.Source != nil
+}
+
+// Ping checks that the MediaMTX API answers
+func (e *MediaMTXEngine) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.apiURL+"/v3/paths/list?itemsPerPage=1", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to query MediaMTX API: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("MediaMTX API returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// mediaMTXPath is the subset of the MediaMTX /v3/paths/get response we use
+type mediaMTXPath struct {
+	Name    string            `json:"name"`
+	Source  json.RawMessage   `json:"source"`
+	Readers []json.RawMessage `json:"readers"`
+}
+
+// GetViewerCount returns how many clients are currently reading the stream.
+// A stream MediaMTX doesn't know about has no viewers.
+func (e *MediaMTXEngine) GetViewerCount(ctx context.Context, streamID string) (int64, error) {
+	path, found, err := e.getPath(ctx, streamID)
+	if err != nil {
+		return 0, err
+	}
+	if !found {
+		return 0, nil
+	}
+	return int64(len(path.Readers)), nil
+}
+
+// getPath fetches a stream's path from the MediaMTX API. found is false
+// when MediaMTX has no such path, which happens when nobody is publishing.
+func (e *MediaMTXEngine) getPath(ctx context.Context, streamID string) (*mediaMTXPath, bool, error) {
+	endpoint := fmt.Sprintf("%s/v3/paths/get/%s", e.apiURL, e.pathName(streamID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to query MediaMTX API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("MediaMTX API returned status %d", resp.StatusCode)
+	}
+
+	var path mediaMTXPath
+	if err := json.NewDecoder(resp.Body).Decode(&path); err != nil {
+		return nil, false, fmt.Errorf("failed to decode MediaMTX path: %w", err)
+	}
+
+	// A JSON null source means the path exists but has no publisher
+	if string(path.Source) == "null" {
+		path.Source = nil
+	}
+
+	return &path, true, nil
+}
+
+// pathName returns the MediaMTX path of a stream. Streams live under the
+// same prefix as the HLS base URL (e.g. "live/<streamID>").
+func (e *MediaMTXEngine) pathName(streamID string) string {
+	prefix := ""
+	if u, err := url.Parse(e.hlsServerURL); err == nil {
+		prefix = strings.Trim(u.Path, "/")
+	}
+	if prefix == "" {
+		return url.PathEscape(streamID)
+	}
+	return prefix + "/" + url.PathEscape(streamID)
 }
\ No newline at end of file
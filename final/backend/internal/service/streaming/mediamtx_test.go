@@ -0,0 +1,126 @@
+package streaming
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newFakeMediaMTX serves /v3/paths/get from fixed JSON bodies keyed by path
+// name, answering 404 like MediaMTX does for unknown paths, and an empty
+// /v3/paths/list
+func newFakeMediaMTX(t *testing.T, paths map[string]string) *MediaMTXEngine {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v3/paths/list", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"itemCount":0,"pageCount":0,"items":[]}`))
+	})
+	mux.HandleFunc("/v3/paths/get/", func(w http.ResponseWriter, r *http.Request) {
+		body, ok := paths[r.URL.Path[len("/v3/paths/get/"):]]
+		if !ok {
+			http.Error(w, `{"error":"path not found"}`, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return NewMediaMTXEngine("rtmp://localhost:1935/live", "http://localhost:8888/live", "http://localhost:8889/live", server.URL)
+}
+
+func TestIsStreamActive(t *testing.T) {
+	engine := newFakeMediaMTX(t, map[string]string{
+		"live/publishing": `{"name":"live/publishing","source":{"type":"rtmpConn","id":"c1"},"ready":true,"readers":[]}`,
+		"live/idle":       `{"name":"live/idle","source":null,"ready":false,"readers":[]}`,
+		"live/garbled":    `{"name":`,
+	})
+
+	tests := []struct {
+		streamID string
+		want     bool
+	}{
+		{"publishing", true},
+		{"idle", false},
+		{"unknown", false},
+		{"garbled", false},
+	}
+	for _, tt := range tests {
+		if got := engine.IsStreamActive(tt.streamID); got != tt.want {
+			t.Errorf("IsStreamActive(%q) = %v, want %v", tt.streamID, got, tt.want)
+		}
+	}
+}
+
+func TestIsStreamActiveAPIDown(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	engine := NewMediaMTXEngine("rtmp://localhost:1935/live", "http://localhost:8888/live", "", server.URL)
+
+	if engine.IsStreamActive("publishing") {
+		t.Errorf("IsStreamActive = true with the API unreachable, want false")
+	}
+}
+
+func TestGetViewerCount(t *testing.T) {
+	engine := newFakeMediaMTX(t, map[string]string{
+		"live/popular": `{"name":"live/popular","source":{"type":"rtmpConn","id":"c1"},"readers":[{"type":"hlsMuxer","id":""},{"type":"webRTCSession","id":"r1"},{"type":"rtspSession","id":"r2"}]}`,
+		"live/empty":   `{"name":"live/empty","source":{"type":"rtmpConn","id":"c2"},"readers":[]}`,
+	})
+
+	tests := []struct {
+		streamID string
+		want     int64
+	}{
+		{"popular", 3},
+		{"empty", 0},
+		// Not on MediaMTX at all, e.g. the publisher disconnected
+		{"unknown", 0},
+	}
+	for _, tt := range tests {
+		got, err := engine.GetViewerCount(context.Background(), tt.streamID)
+		if err != nil {
+			t.Errorf("GetViewerCount(%q): %v", tt.streamID, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("GetViewerCount(%q) = %d, want %d", tt.streamID, got, tt.want)
+		}
+	}
+}
+
+func TestGetViewerCountAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	t.Cleanup(server.Close)
+	engine := NewMediaMTXEngine("rtmp://localhost:1935/live", "http://localhost:8888/live", "", server.URL)
+
+	if _, err := engine.GetViewerCount(context.Background(), "popular"); err == nil {
+		t.Errorf("GetViewerCount succeeded while the API fails")
+	}
+}
+
+func TestPing(t *testing.T) {
+	if err := newFakeMediaMTX(t, nil).Ping(context.Background()); err != nil {
+		t.Errorf("Ping: %v", err)
+	}
+
+	// An API answering errors is as unusable as one that is down
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	}))
+	t.Cleanup(failing.Close)
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	for _, apiURL := range []string{failing.URL, down.URL} {
+		engine := NewMediaMTXEngine("rtmp://localhost:1935/live", "http://localhost:8888/live", "", apiURL)
+		if err := engine.Ping(context.Background()); err == nil {
+			t.Errorf("Ping of %s succeeded, want an error", apiURL)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package streaming
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RetentionPolicy decides how long the recordings of a stream are kept,
+// e.g. by the plan of the user the stream key belongs to. Zero keeps them
+// forever.
+type RetentionPolicy interface {
+	RecordingRetention(ctx context.Context, streamKey string) (time.Duration, error)
+}
+
+// FixedRetention keeps the recordings of every stream for the same time
+type FixedRetention time.Duration
+
+// RecordingRetention returns the fixed retention whatever the stream
+func (r FixedRetention) RecordingRetention(ctx context.Context, streamKey string) (time.Duration, error) {
+	return time.Duration(r), nil
+}
+
+// RecordingSweeper deletes live stream recordings written by MediaMTX once
+// they are older than the retention period of their stream. MediaMTX
+// stores each stream's segments in a directory named after its stream key
+// under the recordings root.
+type RecordingSweeper struct {
+	dir      string
+	policy   RetentionPolicy
+	interval time.Duration
+}
+
+// NewRecordingSweeper creates a sweeper for the recordings under dir
+func NewRecordingSweeper(dir string, policy RetentionPolicy, interval time.Duration) *RecordingSweeper {
+	return &RecordingSweeper{
+		dir:      dir,
+		policy:   policy,
+		interval: interval,
+	}
+}
+
+// Run sweeps immediately and then on every interval until ctx is done
+func (s *RecordingSweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		removed, err := s.Sweep(ctx, time.Now())
+		if err != nil {
+			slog.Error("Recording sweep failed", "dir", s.dir, "error", err)
+		}
+		if removed > 0 {
+			slog.Info("Recording sweep removed expired files", "dir", s.dir, "removed", removed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep removes recording files last modified longer ago than their
+// stream's retention period, along with stream directories left empty, and
+// returns the number of files removed. A stream whose retention can't be
+// looked up is skipped until the next sweep.
+func (s *RecordingSweeper) Sweep(ctx context.Context, now time.Time) (int, error) {
+	removed := 0
+	var errs []error
+
+	// Every file in a stream directory shares the directory's retention
+	retentions := make(map[string]time.Duration)
+	failed := make(map[string]bool)
+
+	err := filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == s.dir {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		streamDir := filepath.Dir(path)
+		if failed[streamDir] {
+			return nil
+		}
+		retention, ok := retentions[streamDir]
+		if !ok {
+			streamKey := ""
+			if streamDir != s.dir {
+				streamKey = filepath.Base(streamDir)
+			}
+			retention, err = s.policy.RecordingRetention(ctx, streamKey)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to look up retention for %s: %w", streamDir, err))
+				failed[streamDir] = true
+				return nil
+			}
+			retentions[streamDir] = retention
+		}
+		if retention == 0 {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(now.Add(-retention)) {
+			return nil
+		}
+
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove recording %s: %w", path, err)
+		}
+		removed++
+		return nil
+	})
+	if err != nil {
+		return removed, errors.Join(append(errs, err)...)
+	}
+
+	// Drop per-stream directories that no longer hold any recordings
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return removed, errors.Join(errs...)
+		}
+		return removed, errors.Join(append(errs, err)...)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			// Remove fails on non-empty directories, which is what we want
+			os.Remove(filepath.Join(s.dir, entry.Name()))
+		}
+	}
+
+	return removed, errors.Join(errs...)
+}
+
+// RecordingLocator finds the files MediaMTX recorded for a publishing session.
+// MediaMTX names each segment after the time it was started, under a
+// directory named after the path the publisher pushed to.
+type RecordingLocator struct {
+	dir        string
+	keyPrefix  string
+	pathPrefix string
+}
+
+// NewRecordingLocator creates a locator for the recordings under dir.
+// keyPrefix is where dir sits in file storage (e.g. "recordings/") and
+// pathPrefix is the MediaMTX path publishers push below (e.g. "live").
+func NewRecordingLocator(dir, keyPrefix, pathPrefix string) *RecordingLocator {
+	return &RecordingLocator{
+		dir:        dir,
+		keyPrefix:  keyPrefix,
+		pathPrefix: pathPrefix,
+	}
+}
+
+// playlistExt marks the concat playlists FindRecording writes for sessions
+// recorded to several segments
+const playlistExt = ".ffconcat"
+
+// FindRecording returns the file storage key of the recording of streamKey
+// since the given time. Sessions longer than MediaMTX's segment duration
+// span several files; for those it writes an ffconcat playlist of the
+// segments next to them, which ffmpeg reads as a single input, and returns
+// the playlist's key instead.
+func (l *RecordingLocator) FindRecording(ctx context.Context, streamKey string, since time.Time) (string, error) {
+	streamPath := path.Join(l.pathPrefix, streamKey)
+	streamDir := filepath.Join(l.dir, filepath.FromSlash(streamPath))
+	entries, err := os.ReadDir(streamDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read recordings for %s: %w", streamPath, err)
+	}
+
+	// Segment names start with their timestamp, so name order is time order
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	var segments []string
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) == playlistExt {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return "", err
+		}
+		// Segments from earlier sessions were last written before this one began
+		if info.ModTime().Before(since) {
+			continue
+		}
+		segments = append(segments, entry.Name())
+	}
+
+	switch len(segments) {
+	case 0:
+		return "", fmt.Errorf("no recording found for %s since %s", streamPath, since.Format(time.RFC3339))
+	case 1:
+		return l.keyPrefix + path.Join(streamPath, segments[0]), nil
+	}
+
+	playlist := strings.TrimSuffix(segments[0], filepath.Ext(segments[0])) + playlistExt
+	var b strings.Builder
+	b.WriteString("ffconcat version 1.0\n")
+	for _, segment := range segments {
+		fmt.Fprintf(&b, "file '%s'\n", strings.ReplaceAll(segment, "'", `'\''`))
+	}
+	if err := os.WriteFile(filepath.Join(streamDir, playlist), []byte(b.String()), 0644); err != nil {
+		return "", fmt.Errorf("failed to write playlist for %s: %w", streamPath, err)
+	}
+	return l.keyPrefix + path.Join(streamPath, playlist), nil
+}
+
+// playlistSegments returns the segments a playlist FindRecording wrote lists
+func playlistSegments(playlistPath string) ([]string, error) {
+	data, err := os.ReadFile(playlistPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []string
+	for _, line := range strings.Split(string(data), "\n") {
+		name, ok := strings.CutPrefix(line, "file ")
+		if !ok {
+			continue
+		}
+		name = strings.ReplaceAll(strings.TrimSuffix(strings.TrimPrefix(name, "'"), "'"), `'\''`, "'")
+		// The playlist only ever names files next to it
+		if name != filepath.Base(name) {
+			return nil, fmt.Errorf("playlist %s lists %s outside its directory", playlistPath, name)
+		}
+		segments = append(segments, name)
+	}
+	return segments, nil
+}
+
+// DeleteRecording removes a recording FindRecording returned, along with
+// every segment a playlist lists. Keys outside the recordings are left
+// alone, as are recordings already gone.
+func (l *RecordingLocator) DeleteRecording(ctx context.Context, key string) error {
+	name, ok := strings.CutPrefix(key, l.keyPrefix)
+	if !ok || !filepath.IsLocal(filepath.FromSlash(name)) {
+		return fmt.Errorf("%s is not a recording", key)
+	}
+	recordingPath := filepath.Join(l.dir, filepath.FromSlash(name))
+
+	if filepath.Ext(recordingPath) == playlistExt {
+		segments, err := playlistSegments(recordingPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return fmt.Errorf("failed to read playlist %s: %w", key, err)
+		}
+		for _, segment := range segments {
+			segmentPath := filepath.Join(filepath.Dir(recordingPath), segment)
+			if err := os.Remove(segmentPath); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to delete segment %s of recording %s: %w", segment, key, err)
+			}
+		}
+	}
+
+	if err := os.Remove(recordingPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete recording %s: %w", key, err)
+	}
+	return nil
+}
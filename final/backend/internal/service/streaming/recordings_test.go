@@ -0,0 +1,180 @@
+package streaming
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// keyRetention looks retention up by stream key, failing for unknown keys
+type keyRetention map[string]time.Duration
+
+func (r keyRetention) RecordingRetention(ctx context.Context, streamKey string) (time.Duration, error) {
+	retention, ok := r[streamKey]
+	if !ok {
+		return 0, errors.New("unknown stream key")
+	}
+	return retention, nil
+}
+
+// writeRecording creates a recording last modified at modTime
+func writeRecording(t *testing.T, path string, modTime time.Time) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("segment"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecordingSweeperPerStreamRetention(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	old := now.Add(-48 * time.Hour)
+
+	writeRecording(t, filepath.Join(dir, "short", "a.mp4"), old)
+	writeRecording(t, filepath.Join(dir, "short", "b.mp4"), now.Add(-time.Hour))
+	writeRecording(t, filepath.Join(dir, "long", "a.mp4"), old)
+	writeRecording(t, filepath.Join(dir, "forever", "a.mp4"), old)
+	writeRecording(t, filepath.Join(dir, "unknown", "a.mp4"), old)
+
+	sweeper := NewRecordingSweeper(dir, keyRetention{
+		"short":   24 * time.Hour,
+		"long":    7 * 24 * time.Hour,
+		"forever": 0,
+	}, time.Hour)
+	removed, err := sweeper.Sweep(context.Background(), now)
+	if err == nil {
+		t.Errorf("Sweep hid the failed retention lookup")
+	}
+	if removed != 1 {
+		t.Errorf("Sweep removed %d files, want 1", removed)
+	}
+
+	for path, wantKept := range map[string]bool{
+		"short/a.mp4":   false,
+		"short/b.mp4":   true,
+		"long/a.mp4":    true,
+		"forever/a.mp4": true,
+		"unknown/a.mp4": true,
+	} {
+		_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(path)))
+		if kept := err == nil; kept != wantKept {
+			t.Errorf("%s kept = %v, want %v", path, kept, wantKept)
+		}
+	}
+}
+
+func TestRecordingSweeperFixedRetention(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	writeRecording(t, filepath.Join(dir, "key", "a.mp4"), now.Add(-48*time.Hour))
+
+	removed, err := NewRecordingSweeper(dir, FixedRetention(24*time.Hour), time.Hour).Sweep(context.Background(), now)
+	if err != nil {
+		t.Fatalf("Sweep: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("Sweep removed %d files, want 1", removed)
+	}
+	// The emptied stream directory goes as well
+	if _, err := os.Stat(filepath.Join(dir, "key")); !os.IsNotExist(err) {
+		t.Errorf("empty stream directory kept: %v", err)
+	}
+}
+
+func TestRecordingLocatorDeleteRecording(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "live", "key", "a.mp4")
+	writeRecording(t, path, time.Now())
+	locator := NewRecordingLocator(dir, "recordings/", "live")
+
+	for _, key := range []string{"videos/a.mp4", "recordings/../secret", "recordings//etc/passwd"} {
+		if err := locator.DeleteRecording(context.Background(), key); err == nil {
+			t.Errorf("DeleteRecording(%q) succeeded for a key outside the recordings", key)
+		}
+	}
+
+	if err := locator.DeleteRecording(context.Background(), "recordings/live/key/a.mp4"); err != nil {
+		t.Fatalf("DeleteRecording: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("recording still exists: %v", err)
+	}
+	// Deleting it again is fine
+	if err := locator.DeleteRecording(context.Background(), "recordings/live/key/a.mp4"); err != nil {
+		t.Errorf("DeleteRecording of a deleted recording: %v", err)
+	}
+}
+
+func TestRecordingLocatorConcatenatesSegments(t *testing.T) {
+	dir := t.TempDir()
+	started := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	streamDir := filepath.Join(dir, "live", "key")
+
+	// An earlier session, then three segments of the one that just ended
+	writeRecording(t, filepath.Join(streamDir, "2024-01-09_10-00-00.mp4"), started.Add(-24*time.Hour))
+	writeRecording(t, filepath.Join(streamDir, "2024-01-10_12-00-00.mp4"), started.Add(time.Hour))
+	writeRecording(t, filepath.Join(streamDir, "2024-01-10_13-00-00.mp4"), started.Add(2*time.Hour))
+	writeRecording(t, filepath.Join(streamDir, "2024-01-10_14-00-00.mp4"), started.Add(2*time.Hour+time.Minute))
+	locator := NewRecordingLocator(dir, "recordings/", "live")
+
+	key, err := locator.FindRecording(context.Background(), "key", started)
+	if err != nil {
+		t.Fatalf("FindRecording: %v", err)
+	}
+	if key != "recordings/live/key/2024-01-10_12-00-00.ffconcat" {
+		t.Fatalf("FindRecording = %q, want the session's playlist", key)
+	}
+
+	playlist, err := os.ReadFile(filepath.Join(streamDir, "2024-01-10_12-00-00.ffconcat"))
+	if err != nil {
+		t.Fatalf("failed to read playlist: %v", err)
+	}
+	want := "ffconcat version 1.0\n" +
+		"file '2024-01-10_12-00-00.mp4'\n" +
+		"file '2024-01-10_13-00-00.mp4'\n" +
+		"file '2024-01-10_14-00-00.mp4'\n"
+	if string(playlist) != want {
+		t.Errorf("playlist =\n%s\nwant\n%s", playlist, want)
+	}
+
+	// Deleting the recording takes every segment of the session with it
+	if err := locator.DeleteRecording(context.Background(), key); err != nil {
+		t.Fatalf("DeleteRecording: %v", err)
+	}
+	entries, err := os.ReadDir(streamDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "2024-01-09_10-00-00.mp4" {
+		t.Errorf("left %v in the stream directory, want only the earlier session", entries)
+	}
+}
+
+func TestRecordingLocatorSingleSegment(t *testing.T) {
+	dir := t.TempDir()
+	started := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	writeRecording(t, filepath.Join(dir, "live", "key", "2024-01-10_12-00-00.mp4"), started.Add(time.Minute))
+	locator := NewRecordingLocator(dir, "recordings/", "live")
+
+	key, err := locator.FindRecording(context.Background(), "key", started)
+	if err != nil {
+		t.Fatalf("FindRecording: %v", err)
+	}
+	if key != "recordings/live/key/2024-01-10_12-00-00.mp4" {
+		t.Errorf("FindRecording = %q, want the segment itself", key)
+	}
+
+	if _, err := locator.FindRecording(context.Background(), "key", started.Add(time.Hour)); err == nil {
+		t.Errorf("FindRecording found a recording for a session without segments")
+	}
+}
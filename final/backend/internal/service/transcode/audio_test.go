@@ -0,0 +1,78 @@
+package transcode_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"videostreaming/internal/service/transcode"
+	"videostreaming/internal/storage/memory"
+	pb "videostreaming/proto/video"
+)
+
+// transcodeAll runs every rendition of a video through ffmpeg and returns
+// the jobs the service created
+func transcodeAll(t *testing.T, ffmpeg *fakeFFmpeg, opts ...transcode.Option) []*pb.TranscodingJob {
+	t.Helper()
+
+	notifications := newFakeNotifications()
+	svc, err := transcode.NewService(memory.NewTranscodeStorage(), ffmpeg, nil, notifications, opts...)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	if err := svc.StartTranscoding(context.Background(), "v1", "videos/v1/source"); err != nil {
+		t.Fatalf("StartTranscoding: %v", err)
+	}
+
+	status, err := svc.GetTranscodingStatus(context.Background(), "v1")
+	if err != nil {
+		t.Fatalf("GetTranscodingStatus: %v", err)
+	}
+	for range status.Jobs {
+		select {
+		case <-notifications.done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("transcoding did not finish")
+		}
+	}
+	return status.Jobs
+}
+
+func TestAudioOnlyInputGetsOneAudioJob(t *testing.T) {
+	ffmpeg := &fakeFFmpeg{info: &transcode.MediaInfo{Duration: 1800, Bitrate: 128000, Codec: "mp3"}}
+	jobs := transcodeAll(t, ffmpeg)
+
+	if len(jobs) != 1 || jobs[0].Resolution != pb.VideoResolution_VIDEO_RESOLUTION_AUDIO_ONLY {
+		t.Fatalf("jobs = %v, want a single AUDIO_ONLY job", jobs)
+	}
+	if len(ffmpeg.options) != 1 {
+		t.Fatalf("TranscodeVideo called %d times, want once", len(ffmpeg.options))
+	}
+	options := ffmpeg.options[0]
+	if !options.AudioOnly || options.AudioBitrate != "128k" || options.Format != "hls" {
+		t.Errorf("options = %+v, want 128k audio-only HLS", options)
+	}
+	if options.VideoBitrate != "" || options.Codec != "" || options.FrameRate != 0 {
+		t.Errorf("options = %+v, want no video encoding settings", options)
+	}
+}
+
+func TestVideoInputGetsResolutionLadder(t *testing.T) {
+	ffmpeg := &fakeFFmpeg{info: &transcode.MediaInfo{HasVideo: true, Duration: 60, Width: 1920, Height: 1080}}
+	jobs := transcodeAll(t, ffmpeg)
+
+	// A 1080p source is transcoded down to 360p and never to audio alone
+	if len(jobs) != 4 {
+		t.Fatalf("got %d jobs, want 1080p, 720p, 480p and 360p", len(jobs))
+	}
+	for _, job := range jobs {
+		if job.Resolution == pb.VideoResolution_VIDEO_RESOLUTION_AUDIO_ONLY {
+			t.Errorf("video input got an AUDIO_ONLY job")
+		}
+	}
+	for _, options := range ffmpeg.options {
+		if options.AudioOnly {
+			t.Errorf("options for %v are audio-only", options.Resolution)
+		}
+	}
+}
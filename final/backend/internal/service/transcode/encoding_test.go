@@ -0,0 +1,110 @@
+package transcode_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"videostreaming/internal/service/transcode"
+	"videostreaming/internal/storage/memory"
+	pb "videostreaming/proto/video"
+)
+
+// customLadder is a bitrate for every resolution the service may target
+var customLadder = map[pb.VideoResolution]string{
+	pb.VideoResolution_VIDEO_RESOLUTION_2160P: "20000k",
+	pb.VideoResolution_VIDEO_RESOLUTION_1440P: "10000k",
+	pb.VideoResolution_VIDEO_RESOLUTION_1080P: "6000k",
+	pb.VideoResolution_VIDEO_RESOLUTION_720P:  "3500k",
+	pb.VideoResolution_VIDEO_RESOLUTION_480P:  "1200k",
+	pb.VideoResolution_VIDEO_RESOLUTION_360P:  "600k",
+}
+
+func TestCustomBitratesReachFFmpeg(t *testing.T) {
+	ffmpeg := &fakeFFmpeg{info: &transcode.MediaInfo{HasVideo: true, Duration: 60, Width: 1280, Height: 720}}
+	transcodeAll(t, ffmpeg, transcode.WithBitrates(customLadder), transcode.WithAudioBitrate("96k"))
+
+	if len(ffmpeg.options) != 3 {
+		t.Fatalf("TranscodeVideo called %d times, want 720p, 480p and 360p", len(ffmpeg.options))
+	}
+	for _, options := range ffmpeg.options {
+		if want := customLadder[options.Resolution]; options.VideoBitrate != want {
+			t.Errorf("%v video bitrate = %q, want %q", options.Resolution, options.VideoBitrate, want)
+		}
+		if options.AudioBitrate != "96k" {
+			t.Errorf("%v audio bitrate = %q, want 96k", options.Resolution, options.AudioBitrate)
+		}
+	}
+}
+
+func TestCodecSelection(t *testing.T) {
+	tests := []struct {
+		codec  string
+		format string
+	}{
+		{transcode.CodecH264, "hls"},
+		{transcode.CodecH265, "hls"},
+		{transcode.CodecVP9, "dash"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.codec, func(t *testing.T) {
+			ffmpeg := &fakeFFmpeg{info: &transcode.MediaInfo{HasVideo: true, Duration: 60, Width: 854, Height: 480}}
+			transcodeAll(t, ffmpeg, transcode.WithCodec(tt.codec))
+
+			if len(ffmpeg.options) == 0 {
+				t.Fatal("TranscodeVideo was never called")
+			}
+			for _, options := range ffmpeg.options {
+				if options.Codec != tt.codec || options.Format != tt.format {
+					t.Errorf("%v encoded with %s as %s, want %s as %s", options.Resolution, options.Codec, options.Format, tt.codec, tt.format)
+				}
+			}
+		})
+	}
+}
+
+func TestNewServiceValidatesEncoding(t *testing.T) {
+	partial := make(map[pb.VideoResolution]string)
+	for resolution, bitrate := range customLadder {
+		partial[resolution] = bitrate
+	}
+	delete(partial, pb.VideoResolution_VIDEO_RESOLUTION_480P)
+
+	tests := []struct {
+		name string
+		opts []transcode.Option
+		want string
+	}{
+		{"missing bitrate", []transcode.Option{transcode.WithBitrates(partial)}, "480p"},
+		{"no audio bitrate", []transcode.Option{transcode.WithAudioBitrate("")}, "audio bitrate"},
+		{"unknown codec", []transcode.Option{transcode.WithCodec("libtheora")}, "libtheora"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := transcode.NewService(memory.NewTranscodeStorage(), &fakeFFmpeg{}, nil, nil, tt.opts...)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("NewService error = %v, want one mentioning %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseBitrates(t *testing.T) {
+	got, err := transcode.ParseBitrates("1080p=6000k, 720p = 3500k")
+	if err != nil {
+		t.Fatalf("ParseBitrates: %v", err)
+	}
+	want := map[pb.VideoResolution]string{
+		pb.VideoResolution_VIDEO_RESOLUTION_1080P: "6000k",
+		pb.VideoResolution_VIDEO_RESOLUTION_720P:  "3500k",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseBitrates = %v, want %v", got, want)
+	}
+
+	for _, ladder := range []string{"720p", "720p=", "4k=20000k", ""} {
+		if _, err := transcode.ParseBitrates(ladder); err == nil {
+			t.Errorf("ParseBitrates(%q) succeeded, want an error", ladder)
+		}
+	}
+}
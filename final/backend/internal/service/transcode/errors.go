@@ -0,0 +1,8 @@
+package transcode
+
+import "errors"
+
+// ErrTranscodeRetryable marks a transcoding failure as transient. FFmpegClient
+// implementations wrap it (fmt.Errorf("%w: ...", ErrTranscodeRetryable)) for
+// errors worth retrying, such as a storage timeout or a killed worker.
+var ErrTranscodeRetryable = errors.New("retryable transcoding failure")
@@ -0,0 +1,97 @@
+package transcode_test
+
+import (
+	"context"
+	"sync"
+
+	"videostreaming/internal/service/transcode"
+	"videostreaming/internal/storage/memory"
+	pb "videostreaming/proto/video"
+)
+
+// fakeFFmpeg probes every input as audio-only, so each video gets a single
+// job, and reports progress ticks while it "transcodes"
+type fakeFFmpeg struct {
+	// info, when set, is returned by GetMediaInfo instead of a minute of audio
+	info  *transcode.MediaInfo
+	ticks []float32
+	// failures are returned by the first TranscodeVideo calls, in order
+	failures []error
+	// release, when set, holds each tick until it receives
+	release chan struct{}
+
+	mutex sync.Mutex
+	calls int
+	// options are those of every TranscodeVideo call, in order
+	options []transcode.TranscodeOptions
+}
+
+func (f *fakeFFmpeg) TranscodeVideo(ctx context.Context, inputPath string, outputPath string, options transcode.TranscodeOptions, onProgress transcode.ProgressFunc) error {
+	f.mutex.Lock()
+	call := f.calls
+	f.calls++
+	f.options = append(f.options, options)
+	f.mutex.Unlock()
+
+	if call < len(f.failures) {
+		return f.failures[call]
+	}
+	for _, tick := range f.ticks {
+		if f.release != nil {
+			<-f.release
+		}
+		if onProgress != nil {
+			onProgress(tick)
+		}
+	}
+	return nil
+}
+
+func (f *fakeFFmpeg) GetMediaInfo(ctx context.Context, filePath string) (*transcode.MediaInfo, error) {
+	if f.info != nil {
+		return f.info, nil
+	}
+	return &transcode.MediaInfo{Duration: 60}, nil
+}
+
+func (f *fakeFFmpeg) ExtractThumbnail(ctx context.Context, inputPath string, atSeconds float64, outputPath string) error {
+	return nil
+}
+
+// fakeNotifications records progress reports and signals finished videos
+type fakeNotifications struct {
+	mutex    sync.Mutex
+	progress []float32
+	done     chan pb.TranscodingStatus
+}
+
+func newFakeNotifications() *fakeNotifications {
+	return &fakeNotifications{done: make(chan pb.TranscodingStatus, 1)}
+}
+
+func (n *fakeNotifications) NotifyTranscodingComplete(ctx context.Context, videoID string, status pb.TranscodingStatus) error {
+	n.done <- status
+	return nil
+}
+
+func (n *fakeNotifications) NotifyTranscodingProgress(ctx context.Context, videoID string, progress float32) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.progress = append(n.progress, progress)
+	return nil
+}
+
+// recordingStorage keeps the progress of every job update it is given
+type recordingStorage struct {
+	*memory.TranscodeStorage
+
+	mutex    sync.Mutex
+	progress []float32
+}
+
+func (s *recordingStorage) UpdateTranscodingJob(ctx context.Context, job *transcode.TranscodingJob) error {
+	s.mutex.Lock()
+	s.progress = append(s.progress, job.Progress)
+	s.mutex.Unlock()
+	return s.TranscodeStorage.UpdateTranscodingJob(ctx, job)
+}
@@ -0,0 +1,27 @@
+package transcode_test
+
+import (
+	"testing"
+
+	"videostreaming/internal/service/transcode"
+	pb "videostreaming/proto/video"
+)
+
+func TestSourceResolution(t *testing.T) {
+	tests := []struct {
+		info transcode.MediaInfo
+		want pb.VideoResolution
+	}{
+		{transcode.MediaInfo{HasVideo: true, Width: 3840, Height: 2160}, pb.VideoResolution_VIDEO_RESOLUTION_2160P},
+		{transcode.MediaInfo{HasVideo: true, Width: 1920, Height: 1080}, pb.VideoResolution_VIDEO_RESOLUTION_1080P},
+		{transcode.MediaInfo{HasVideo: true, Width: 1080, Height: 1920}, pb.VideoResolution_VIDEO_RESOLUTION_1080P},
+		{transcode.MediaInfo{HasVideo: true, Width: 1280, Height: 720}, pb.VideoResolution_VIDEO_RESOLUTION_720P},
+		{transcode.MediaInfo{HasVideo: true, Width: 320, Height: 240}, pb.VideoResolution_VIDEO_RESOLUTION_240P},
+		{transcode.MediaInfo{Duration: 60}, pb.VideoResolution_VIDEO_RESOLUTION_AUDIO_ONLY},
+	}
+	for _, tt := range tests {
+		if got := transcode.SourceResolution(&tt.info); got != tt.want {
+			t.Errorf("SourceResolution(%+v) = %v, want %v", tt.info, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package transcode_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"videostreaming/internal/metrics"
+	"videostreaming/internal/service/transcode"
+	"videostreaming/internal/storage/memory"
+	pb "videostreaming/proto/video"
+)
+
+func TestTranscodeDurationIsObserved(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	notifications := newFakeNotifications()
+	svc, err := transcode.NewService(memory.NewTranscodeStorage(), &fakeFFmpeg{}, nil, notifications,
+		transcode.WithMetrics(metrics.New(reg)))
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+
+	if err := svc.StartTranscoding(context.Background(), "v1", "videos/v1/source.mp3"); err != nil {
+		t.Fatalf("StartTranscoding: %v", err)
+	}
+	select {
+	case <-notifications.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("transcoding did not finish")
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	samples := make(map[string]uint64)
+	for _, family := range families {
+		if family.GetName() != "videostreaming_transcode_duration_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			samples[labels["resolution"]+" "+labels["status"]] += metric.GetHistogram().GetSampleCount()
+		}
+	}
+	job, _ := svc.GetJob("v1", pb.VideoResolution_VIDEO_RESOLUTION_AUDIO_ONLY)
+	if job == nil || job.Status != pb.TranscodingStatus_TRANSCODING_STATUS_COMPLETED {
+		t.Fatalf("job = %+v, want it COMPLETED", job)
+	}
+	if len(samples) != 1 || samples["audio completed"] != 1 {
+		t.Errorf("transcode duration samples = %v, want one for the completed audio job", samples)
+	}
+}
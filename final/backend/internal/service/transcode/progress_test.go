@@ -0,0 +1,58 @@
+package transcode_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"videostreaming/internal/service/transcode"
+	"videostreaming/internal/storage/memory"
+	pb "videostreaming/proto/video"
+)
+
+func TestTranscodingProgressIsPersisted(t *testing.T) {
+	storage := &recordingStorage{TranscodeStorage: memory.NewTranscodeStorage()}
+	notifications := newFakeNotifications()
+	// A repeated and a backwards tick must not be stored
+	ffmpeg := &fakeFFmpeg{ticks: []float32{25, 50, 50, 40, 75}}
+	svc, err := transcode.NewService(storage, ffmpeg, nil, notifications)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+
+	if err := svc.StartTranscoding(context.Background(), "v1", "videos/v1/source.mp3"); err != nil {
+		t.Fatalf("StartTranscoding: %v", err)
+	}
+	select {
+	case status := <-notifications.done:
+		if status != pb.TranscodingStatus_TRANSCODING_STATUS_COMPLETED {
+			t.Fatalf("finished with %v, want COMPLETED", status)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("transcoding did not finish")
+	}
+
+	// PROCESSING at 0, every step forward, then COMPLETED at 100
+	storage.mutex.Lock()
+	stored := slices.Clone(storage.progress)
+	storage.mutex.Unlock()
+	if want := []float32{0, 25, 50, 75, 100}; !slices.Equal(stored, want) {
+		t.Errorf("stored progress = %v, want %v", stored, want)
+	}
+
+	notifications.mutex.Lock()
+	notified := slices.Clone(notifications.progress)
+	notifications.mutex.Unlock()
+	if want := []float32{25, 50, 75}; !slices.Equal(notified, want) {
+		t.Errorf("progress notifications = %v, want %v", notified, want)
+	}
+
+	status, err := svc.GetTranscodingStatus(context.Background(), "v1")
+	if err != nil {
+		t.Fatalf("GetTranscodingStatus: %v", err)
+	}
+	if status.Status != pb.TranscodingStatus_TRANSCODING_STATUS_COMPLETED || status.OverallProgress != 100 {
+		t.Errorf("status = %v at %.0f%%, want COMPLETED at 100%%", status.Status, status.OverallProgress)
+	}
+}
@@ -0,0 +1,67 @@
+package transcode_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"videostreaming/internal/logging"
+	"videostreaming/internal/service/transcode"
+	"videostreaming/internal/storage/memory"
+)
+
+func TestTranscodeLogsCarryRequestID(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(logging.NewHandler(slog.NewJSONHandler(&logs, nil)))
+
+	// A transient failure makes the transcode goroutine log a retry
+	notifications := newFakeNotifications()
+	ffmpeg := &fakeFFmpeg{failures: []error{fmt.Errorf("%w: encoder crashed", transcode.ErrTranscodeRetryable)}}
+	svc, err := transcode.NewService(memory.NewTranscodeStorage(), ffmpeg, nil, notifications,
+		transcode.WithRetryBaseDelay(time.Millisecond), transcode.WithLogger(logger))
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+
+	var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := svc.StartTranscoding(r.Context(), "v1", "videos/v1/source.mp3"); err != nil {
+			t.Errorf("StartTranscoding: %v", err)
+		}
+	})
+	handler = logging.RequestID(logging.RequestLogger(logger)(handler))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/videos/v1/complete", nil))
+	requestID := w.Header().Get(logging.RequestIDHeader)
+	if requestID == "" {
+		t.Fatalf("no %s on the response", logging.RequestIDHeader)
+	}
+
+	select {
+	case <-notifications.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("transcoding did not finish")
+	}
+
+	ids := make(map[string]string)
+	for _, line := range bytes.Split(bytes.TrimSpace(logs.Bytes()), []byte("\n")) {
+		var entry map[string]any
+		if err := json.Unmarshal(line, &entry); err != nil {
+			t.Fatalf("log line %q is not JSON: %v", line, err)
+		}
+		msg, _ := entry["msg"].(string)
+		ids[msg], _ = entry["request_id"].(string)
+	}
+	for _, msg := range []string{"Handled request", "Transcoding job failed, retrying"} {
+		if got, ok := ids[msg]; !ok {
+			t.Errorf("%q not logged:\n%s", msg, logs.String())
+		} else if got != requestID {
+			t.Errorf("%q logged with request ID %q, want %q", msg, got, requestID)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package transcode_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"videostreaming/internal/service/transcode"
+	"videostreaming/internal/storage/memory"
+	pb "videostreaming/proto/video"
+)
+
+func TestTranscodingRetries(t *testing.T) {
+	retryable := fmt.Errorf("%w: encoder crashed", transcode.ErrTranscodeRetryable)
+	tests := []struct {
+		name        string
+		failures    []error
+		wantStatus  pb.TranscodingStatus
+		wantRetries int
+	}{
+		{"two transient failures", []error{retryable, retryable}, pb.TranscodingStatus_TRANSCODING_STATUS_COMPLETED, 2},
+		{"too many transient failures", []error{retryable, retryable, retryable, retryable}, pb.TranscodingStatus_TRANSCODING_STATUS_FAILED, 3},
+		{"permanent failure", []error{errors.New("unsupported codec"), nil}, pb.TranscodingStatus_TRANSCODING_STATUS_FAILED, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notifications := newFakeNotifications()
+			ffmpeg := &fakeFFmpeg{failures: tt.failures}
+			svc, err := transcode.NewService(memory.NewTranscodeStorage(), ffmpeg, nil, notifications,
+				transcode.WithMaxRetries(3), transcode.WithRetryBaseDelay(time.Millisecond))
+			if err != nil {
+				t.Fatalf("NewService: %v", err)
+			}
+
+			if err := svc.StartTranscoding(context.Background(), "v1", "videos/v1/source.mp3"); err != nil {
+				t.Fatalf("StartTranscoding: %v", err)
+			}
+			select {
+			case <-notifications.done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("transcoding did not finish")
+			}
+
+			job, ok := svc.GetJob("v1", pb.VideoResolution_VIDEO_RESOLUTION_AUDIO_ONLY)
+			if !ok {
+				t.Fatal("no job for v1")
+			}
+			if job.Status != tt.wantStatus || job.RetryCount != tt.wantRetries {
+				t.Errorf("job ended %v after %d retries, want %v after %d", job.Status, job.RetryCount, tt.wantStatus, tt.wantRetries)
+			}
+			if wantError := tt.wantStatus == pb.TranscodingStatus_TRANSCODING_STATUS_FAILED; (job.ErrorMessage != "") != wantError {
+				t.Errorf("ErrorMessage = %q, want one = %v", job.ErrorMessage, wantError)
+			}
+		})
+	}
+}
@@ -2,8 +2,11 @@ package transcode
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -17,12 +20,18 @@ type TranscodeStorage interface {
 	SaveTranscodingJob(ctx context.Context, job *TranscodingJob) error
 	GetTranscodingJobs(ctx context.Context, videoID string) ([]*TranscodingJob, error)
 	UpdateTranscodingJob(ctx context.Context, job *TranscodingJob) error
+	ListActiveTranscodingJobs(ctx context.Context) ([]*TranscodingJob, error)
 }
 
-// FFmpegClient defines the interface for FFmpeg operations
+// ProgressFunc receives the completion percentage (0-100) of a running transcode
+type ProgressFunc func(progress float32)
+
+// FFmpegClient defines the interface for FFmpeg operations.
+// TranscodeVideo reports progress through onProgress when it is non-nil.
 type FFmpegClient interface {
-	TranscodeVideo(ctx context.Context, inputPath string, outputPath string, options TranscodeOptions) error
+	TranscodeVideo(ctx context.Context, inputPath string, outputPath string, options TranscodeOptions, onProgress ProgressFunc) error
 	GetMediaInfo(ctx context.Context, filePath string) (*MediaInfo, error)
+	ExtractThumbnail(ctx context.Context, inputPath string, atSeconds float64, outputPath string) error
 }
 
 // S3Storage defines the interface for S3 storage operations
@@ -50,10 +59,12 @@ type TranscodingJob struct {
 	StartTime      time.Time
 	CompletionTime *time.Time
 	ErrorMessage   string
+	RetryCount     int
 }
 
 // MediaInfo contains metadata about a media file
 type MediaInfo struct {
+	HasVideo  bool // False for audio-only media such as podcasts
 	Duration  float64
 	Width     int
 	Height    int
@@ -70,14 +81,27 @@ type TranscodeOptions struct {
 	Format       string
 	Codec        string
 	FrameRate    int
+	AudioOnly    bool // Drop the video and encode only the audio track
+}
+
+// Metrics records how transcoding jobs perform
+type Metrics interface {
+	ObserveTranscode(resolution string, failed bool, duration time.Duration)
 }
 
+// noopMetrics discards all metrics
+type noopMetrics struct{}
+
+func (noopMetrics) ObserveTranscode(string, bool, time.Duration) {}
+
 // Service handles video transcoding
 type Service struct {
 	storage             TranscodeStorage
 	ffmpegClient        FFmpegClient
 	s3Storage           S3Storage
 	notificationService NotificationService
+	logger              *slog.Logger
+	metrics             Metrics
 
 	// Configuration
 	outputKeyPrefix  string
@@ -85,32 +109,121 @@ type Service struct {
 	bitrates         map[pb.VideoResolution]string
 	audioBitrate     string
 	codec            string
-
-	// State
-	jobs     map[string]*jobState
+	maxRenditions    int
+	maxRetries       int
+	retryBaseDelay   time.Duration
+
+	// State: snapshots of every known job indexed by video and resolution.
+	// Workers own their job and publish copies through updateJob, so
+	// readers never see a job while it is being modified.
+	//
+	// The registry is the source of truth for jobs this instance runs and
+	// is always at least as fresh as storage; every change is written
+	// through to storage as a checkpoint. Storage is the source of truth
+	// across restarts: LoadActiveJobs rebuilds the registry from it.
+	jobs     map[string]map[pb.VideoResolution]*TranscodingJob
 	jobsLock sync.RWMutex
+
+	// Subscribers to each video's job changes, see SubscribeTranscodingStatus
+	watchers     map[string]map[chan struct{}]struct{}
+	watchersLock sync.Mutex
+}
+
+// Option configures optional Service settings
+type Option func(*Service)
+
+// WithMaxRenditions caps the number of resolutions transcoded per video,
+// keeping the highest ones. A value of zero or less means no cap.
+func WithMaxRenditions(n int) Option {
+	return func(s *Service) {
+		s.maxRenditions = n
+	}
+}
+
+// WithMaxRetries sets how many times a job is retried after a retryable failure
+func WithMaxRetries(n int) Option {
+	return func(s *Service) {
+		s.maxRetries = n
+	}
+}
+
+// WithRetryBaseDelay sets the wait before the first retry; each further
+// retry waits twice as long as the previous one
+func WithRetryBaseDelay(d time.Duration) Option {
+	return func(s *Service) {
+		s.retryBaseDelay = d
+	}
+}
+
+// WithBitrates replaces the video bitrate of each resolution, e.g.
+// {VIDEO_RESOLUTION_720P: "3000k"}. Every resolution of the ladder needs one.
+func WithBitrates(bitrates map[pb.VideoResolution]string) Option {
+	return func(s *Service) {
+		s.bitrates = bitrates
+	}
 }
 
-type jobState struct {
-	videoID   string
-	jobs      []*TranscodingJob
-	progress  float32
-	status    pb.TranscodingStatus
-	updatedAt time.Time
+// Video encoders the service can transcode with
+const (
+	CodecH264 = "libx264"
+	CodecH265 = "libx265"
+	CodecVP9  = "libvpx-vp9"
+)
+
+// codecFormats maps each supported encoder to the adaptive streaming format
+// its output is packaged in. VP9 can't go into MPEG-TS segments, so it is
+// served as WebM over DASH.
+var codecFormats = map[string]string{
+	CodecH264: "hls",
+	CodecH265: "hls",
+	CodecVP9:  "dash",
+}
+
+// WithCodec sets the FFmpeg video encoder: CodecH264, CodecH265 or CodecVP9
+func WithCodec(codec string) Option {
+	return func(s *Service) {
+		s.codec = codec
+	}
+}
+
+// WithAudioBitrate sets the audio bitrate of every rendition, e.g. 128k
+func WithAudioBitrate(bitrate string) Option {
+	return func(s *Service) {
+		s.audioBitrate = bitrate
+	}
+}
+
+// WithLogger sets the logger for job progress and failures.
+// Without it the service logs to slog.Default().
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Service) {
+		s.logger = logger
+	}
 }
 
-// NewService creates a new transcoding service
+// WithMetrics records job durations in metrics
+func WithMetrics(metrics Metrics) Option {
+	return func(s *Service) {
+		s.metrics = metrics
+	}
+}
+
+// NewService creates a new transcoding service. It fails when the options
+// leave a resolution the service may target without a bitrate.
 func NewService(
 	storage TranscodeStorage,
 	ffmpegClient FFmpegClient,
 	s3Storage S3Storage,
 	notificationService NotificationService,
-) *Service {
-	return &Service{
+	opts ...Option,
+) (*Service, error) {
+	s := &Service{
 		storage:             storage,
 		ffmpegClient:        ffmpegClient,
 		s3Storage:           s3Storage,
 		notificationService: notificationService,
+		logger:              slog.Default(),
+		metrics:             noopMetrics{},
 		outputKeyPrefix:     "transcoded/",
 		availableFormats:    []string{"hls", "mp4"},
 		bitrates: map[pb.VideoResolution]string{
@@ -122,10 +235,69 @@ func NewService(
 			pb.VideoResolution_VIDEO_RESOLUTION_1440P: "8000k",
 			pb.VideoResolution_VIDEO_RESOLUTION_2160P: "16000k",
 		},
-		audioBitrate: "128k",
-		codec:        "libx264",
-		jobs:         make(map[string]*jobState),
+		audioBitrate:   "128k",
+		codec:          CodecH264,
+		maxRetries:     3,
+		retryBaseDelay: 2 * time.Second,
+		jobs:           make(map[string]map[pb.VideoResolution]*TranscodingJob),
+		watchers:       make(map[string]map[chan struct{}]struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if err := s.validateEncoding(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// ladderResolutions lists every resolution determineTargetResolutions may pick
+var ladderResolutions = []pb.VideoResolution{
+	pb.VideoResolution_VIDEO_RESOLUTION_2160P,
+	pb.VideoResolution_VIDEO_RESOLUTION_1440P,
+	pb.VideoResolution_VIDEO_RESOLUTION_1080P,
+	pb.VideoResolution_VIDEO_RESOLUTION_720P,
+	pb.VideoResolution_VIDEO_RESOLUTION_480P,
+	pb.VideoResolution_VIDEO_RESOLUTION_360P,
+}
+
+// validateEncoding checks that every rendition can be encoded
+func (s *Service) validateEncoding() error {
+	for _, resolution := range ladderResolutions {
+		if s.bitrates[resolution] == "" {
+			return fmt.Errorf("no bitrate configured for %s", resolutionPath(resolution))
+		}
+	}
+	if _, ok := codecFormats[s.codec]; !ok {
+		return fmt.Errorf("unsupported video codec %q, want one of %s, %s, %s", s.codec, CodecH264, CodecH265, CodecVP9)
+	}
+	if s.audioBitrate == "" {
+		return errors.New("no audio bitrate configured")
+	}
+	return nil
+}
+
+// ParseBitrates parses a bitrate ladder written as comma separated
+// resolution=bitrate pairs, e.g. "1080p=5000k,720p=3000k"
+func ParseBitrates(ladder string) (map[pb.VideoResolution]string, error) {
+	byName := make(map[string]pb.VideoResolution)
+	for resolution := pb.VideoResolution_VIDEO_RESOLUTION_240P; resolution <= pb.VideoResolution_VIDEO_RESOLUTION_2160P; resolution++ {
+		byName[resolutionPath(resolution)] = resolution
 	}
+
+	bitrates := make(map[pb.VideoResolution]string)
+	for _, pair := range strings.Split(ladder, ",") {
+		name, bitrate, found := strings.Cut(strings.TrimSpace(pair), "=")
+		resolution, ok := byName[strings.TrimSpace(name)]
+		if !found || !ok || strings.TrimSpace(bitrate) == "" {
+			return nil, fmt.Errorf("invalid bitrate %q, want e.g. 720p=3000k", pair)
+		}
+		bitrates[resolution] = strings.TrimSpace(bitrate)
+	}
+	return bitrates, nil
 }
 
 // StartTranscoding begins the transcoding process for a video
@@ -137,11 +309,11 @@ func (s *Service) StartTranscoding(ctx context.Context, videoID string, inputPat
 	}
 
 	// Create transcoding jobs for different resolutions
-	resolutions := s.determineTargetResolutions(mediaInfo.Width, mediaInfo.Height)
+	resolutions := s.targetRenditions(mediaInfo)
 
 	for _, resolution := range resolutions {
 		jobID := uuid.New().String()
-		outputPath := fmt.Sprintf("%s%s/%s", s.outputKeyPrefix, videoID, s.getResolutionPath(resolution))
+		outputPath := fmt.Sprintf("%s%s/%s", s.outputKeyPrefix, videoID, resolutionPath(resolution))
 
 		job := &TranscodingJob{
 			ID:         jobID,
@@ -158,9 +330,11 @@ func (s *Service) StartTranscoding(ctx context.Context, videoID string, inputPat
 		if err := s.storage.SaveTranscodingJob(ctx, job); err != nil {
 			return fmt.Errorf("failed to save transcoding job: %w", err)
 		}
+		s.registerJob(job)
 
-		// Start transcoding in a goroutine
-		go s.processTranscoding(context.Background(), job)
+		// Start transcoding in a goroutine that outlives the request but
+		// keeps its values, such as the request ID for logging
+		go s.processTranscoding(context.WithoutCancel(ctx), job)
 	}
 
 	return nil
@@ -168,9 +342,15 @@ func (s *Service) StartTranscoding(ctx context.Context, videoID string, inputPat
 
 // GetTranscodingStatus returns the current status of the transcoding jobs for a video
 func (s *Service) GetTranscodingStatus(ctx context.Context, videoID string) (*pb.TranscodingStatusResponse, error) {
-	jobs, err := s.storage.GetTranscodingJobs(ctx, videoID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get transcoding jobs: %w", err)
+	jobs := s.videoJobs(videoID)
+
+	// Jobs started by another instance or before a restart are only in storage
+	if len(jobs) == 0 {
+		var err error
+		jobs, err = s.storage.GetTranscodingJobs(ctx, videoID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get transcoding jobs: %w", err)
+		}
 	}
 
 	if len(jobs) == 0 {
@@ -180,41 +360,20 @@ func (s *Service) GetTranscodingStatus(ctx context.Context, videoID string) (*pb
 		}, nil
 	}
 
-	// Calculate overall status and progress
-	var totalProgress float32
-	var errorCount int
-	var completedCount int
-
-	protoJobs := make([]*pb.TranscodingJob, len(jobs))
-
-	for i, job := range jobs {
-		totalProgress += job.Progress
-
-		if job.Status == pb.TranscodingStatus_TRANSCODING_STATUS_ERROR {
-			errorCount++
-		} else if job.Status == pb.TranscodingStatus_TRANSCODING_STATUS_COMPLETED {
-			completedCount++
-		}
-
-		protoJobs[i] = &pb.TranscodingJob{
+	// Snapshot the jobs first so the aggregate is computed from the same
+	// values that are returned, even while workers keep updating them
+	protoJobs := make([]*pb.TranscodingJob, 0, len(jobs))
+	for _, job := range jobs {
+		protoJobs = append(protoJobs, &pb.TranscodingJob{
 			JobId:        job.ID,
 			Resolution:   job.Resolution,
 			Status:       job.Status,
 			Progress:     job.Progress,
 			ErrorMessage: job.ErrorMessage,
-		}
+		})
 	}
 
-	overallProgress := totalProgress / float32(len(jobs))
-	var overallStatus pb.TranscodingStatus
-
-	if errorCount == len(jobs) {
-		overallStatus = pb.TranscodingStatus_TRANSCODING_STATUS_ERROR
-	} else if completedCount == len(jobs) {
-		overallStatus = pb.TranscodingStatus_TRANSCODING_STATUS_COMPLETED
-	} else {
-		overallStatus = pb.TranscodingStatus_TRANSCODING_STATUS_PROCESSING
-	}
+	overallStatus, overallProgress := aggregateJobStatus(protoJobs)
 
 	return &pb.TranscodingStatusResponse{
 		VideoId:         videoID,
@@ -224,12 +383,152 @@ func (s *Service) GetTranscodingStatus(ctx context.Context, videoID string) (*pb
 	}, nil
 }
 
+// RestoreTranscodingJobs saves previously exported jobs for a video without
+// re-running them. Output paths are derived the same way as for new jobs.
+func (s *Service) RestoreTranscodingJobs(ctx context.Context, videoID string, inputPath string, jobs []*TranscodingJob) error {
+	for _, job := range jobs {
+		job.VideoID = videoID
+		job.InputPath = inputPath
+		job.OutputPath = fmt.Sprintf("%s%s/%s", s.outputKeyPrefix, videoID, resolutionPath(job.Resolution))
+
+		if err := s.storage.SaveTranscodingJob(ctx, job); err != nil {
+			return fmt.Errorf("failed to save transcoding job: %w", err)
+		}
+		s.registerJob(job)
+	}
+
+	return nil
+}
+
+// LoadActiveJobs rebuilds the job registry from storage after a restart.
+// Jobs that were queued or processing when the previous process stopped
+// are started again, since nothing else would ever finish them.
+func (s *Service) LoadActiveJobs(ctx context.Context) error {
+	jobs, err := s.storage.ListActiveTranscodingJobs(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list active transcoding jobs: %w", err)
+	}
+
+	for _, job := range jobs {
+		s.registerJob(job)
+		go s.processTranscoding(context.Background(), job)
+	}
+
+	if len(jobs) > 0 {
+		s.logger.InfoContext(ctx, "Resumed interrupted transcoding jobs", "count", len(jobs))
+	}
+
+	return nil
+}
+
+// GetJob returns the current state of the job transcoding a video to one resolution
+func (s *Service) GetJob(videoID string, resolution pb.VideoResolution) (*TranscodingJob, bool) {
+	s.jobsLock.RLock()
+	defer s.jobsLock.RUnlock()
+
+	job, ok := s.jobs[videoID][resolution]
+	if !ok {
+		return nil, false
+	}
+
+	snapshot := *job
+	return &snapshot, true
+}
+
+// videoJobs returns snapshots of all jobs for a video, highest resolution first
+func (s *Service) videoJobs(videoID string) []*TranscodingJob {
+	s.jobsLock.RLock()
+	defer s.jobsLock.RUnlock()
+
+	jobs := make([]*TranscodingJob, 0, len(s.jobs[videoID]))
+	for _, job := range s.jobs[videoID] {
+		snapshot := *job
+		jobs = append(jobs, &snapshot)
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].Resolution > jobs[j].Resolution
+	})
+
+	return jobs
+}
+
+// registerJob records a snapshot of a job, replacing any earlier job for
+// the same video and resolution
+func (s *Service) registerJob(job *TranscodingJob) {
+	s.jobsLock.Lock()
+	defer s.jobsLock.Unlock()
+
+	byResolution, ok := s.jobs[job.VideoID]
+	if !ok {
+		byResolution = make(map[pb.VideoResolution]*TranscodingJob)
+		s.jobs[job.VideoID] = byResolution
+	}
+
+	snapshot := *job
+	byResolution[job.Resolution] = &snapshot
+}
+
+// updateJob publishes a job's new state to the registry and mirrors it to
+// storage, then tells the video's watchers about it
+func (s *Service) updateJob(ctx context.Context, job *TranscodingJob) error {
+	s.registerJob(job)
+	defer s.notifyWatchers(job.VideoID)
+	return s.storage.UpdateTranscodingJob(ctx, job)
+}
+
+// ExtractThumbnail captures a single frame of a video at the given offset and writes it as an image
+func (s *Service) ExtractThumbnail(ctx context.Context, inputPath string, atSeconds float64, outputPath string) error {
+	if err := s.ffmpegClient.ExtractThumbnail(ctx, inputPath, atSeconds, outputPath); err != nil {
+		return fmt.Errorf("failed to extract thumbnail: %w", err)
+	}
+
+	return nil
+}
+
+// aggregateJobStatus derives the overall status and average progress of a set of jobs.
+// When every job has finished but only some failed, the result is PARTIAL so
+// that a single failed rendition is not hidden behind a PROCESSING status.
+func aggregateJobStatus(jobs []*pb.TranscodingJob) (pb.TranscodingStatus, float32) {
+	if len(jobs) == 0 {
+		return pb.TranscodingStatus_TRANSCODING_STATUS_NOT_FOUND, 0
+	}
+
+	var totalProgress float32
+	var failedCount int
+	var completedCount int
+
+	for _, job := range jobs {
+		totalProgress += job.Progress
+
+		switch job.Status {
+		case pb.TranscodingStatus_TRANSCODING_STATUS_FAILED:
+			failedCount++
+		case pb.TranscodingStatus_TRANSCODING_STATUS_COMPLETED:
+			completedCount++
+		}
+	}
+
+	overallProgress := totalProgress / float32(len(jobs))
+
+	switch {
+	case failedCount == len(jobs):
+		return pb.TranscodingStatus_TRANSCODING_STATUS_FAILED, overallProgress
+	case completedCount == len(jobs):
+		return pb.TranscodingStatus_TRANSCODING_STATUS_COMPLETED, overallProgress
+	case failedCount > 0 && failedCount+completedCount == len(jobs):
+		return pb.TranscodingStatus_TRANSCODING_STATUS_PARTIAL, overallProgress
+	default:
+		return pb.TranscodingStatus_TRANSCODING_STATUS_PROCESSING, overallProgress
+	}
+}
+
 // processTranscoding handles the actual transcoding process for a job
 func (s *Service) processTranscoding(ctx context.Context, job *TranscodingJob) {
 	// Update job status to processing
 	job.Status = pb.TranscodingStatus_TRANSCODING_STATUS_PROCESSING
-	if err := s.storage.UpdateTranscodingJob(ctx, job); err != nil {
-		log.Printf("Failed to update transcoding job status: %v", err)
+	if err := s.updateJob(ctx, job); err != nil {
+		s.logger.ErrorContext(ctx, "Failed to update transcoding job status", "video_id", job.VideoID, "job_id", job.ID, "error", err)
 		return
 	}
 
@@ -238,33 +537,76 @@ func (s *Service) processTranscoding(ctx context.Context, job *TranscodingJob) {
 		Resolution:  job.Resolution,
 		VideoBitrate: s.bitrates[job.Resolution],
 		AudioBitrate: s.audioBitrate,
-		Format:       "hls", // Use HLS for adaptive streaming
+		Format:       codecFormats[s.codec],
 		Codec:        s.codec,
 		FrameRate:    30,
 	}
+	if job.Resolution == pb.VideoResolution_VIDEO_RESOLUTION_AUDIO_ONLY {
+		options.VideoBitrate = ""
+		options.Codec = ""
+		options.FrameRate = 0
+		options.AudioOnly = true
+	}
 
-	// Start transcoding
-	if err := s.ffmpegClient.TranscodeVideo(ctx, job.InputPath, job.OutputPath, options); err != nil {
+	// Persist and publish intermediate progress. Reports that don't move
+	// progress forward are dropped so stored values only ever increase.
+	onProgress := func(progress float32) {
+		if progress <= job.Progress || progress >= 100 {
+			return
+		}
+		job.Progress = progress
+		if err := s.updateJob(ctx, job); err != nil {
+			s.logger.ErrorContext(ctx, "Failed to update transcoding job progress", "video_id", job.VideoID, "job_id", job.ID, "error", err)
+		}
+		s.notificationService.NotifyTranscodingProgress(ctx, job.VideoID, progress)
+	}
+
+	// Start transcoding, retrying transient failures with exponential backoff
+	err := s.ffmpegClient.TranscodeVideo(ctx, job.InputPath, job.OutputPath, options, onProgress)
+	for err != nil && errors.Is(err, ErrTranscodeRetryable) && job.RetryCount < s.maxRetries {
+		delay := s.retryBaseDelay << job.RetryCount
+		job.RetryCount++
+		job.ErrorMessage = err.Error()
+		if err := s.updateJob(ctx, job); err != nil {
+			s.logger.ErrorContext(ctx, "Failed to update transcoding job retry", "video_id", job.VideoID, "job_id", job.ID, "error", err)
+		}
+		s.logger.WarnContext(ctx, "Transcoding job failed, retrying", "video_id", job.VideoID, "job_id", job.ID, "error", err,
+			"retry", job.RetryCount, "max_retries", s.maxRetries, "delay", delay)
+
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			continue
+		case <-time.After(delay):
+		}
+
+		err = s.ffmpegClient.TranscodeVideo(ctx, job.InputPath, job.OutputPath, options, onProgress)
+	}
+
+	if err != nil {
 		// Handle transcoding error
-		job.Status = pb.TranscodingStatus_TRANSCODING_STATUS_ERROR
+		job.Status = pb.TranscodingStatus_TRANSCODING_STATUS_FAILED
 		job.ErrorMessage = err.Error()
-		if err := s.storage.UpdateTranscodingJob(ctx, job); err != nil {
-			log.Printf("Failed to update transcoding job error: %v", err)
+		if err := s.updateJob(ctx, job); err != nil {
+			s.logger.ErrorContext(ctx, "Failed to update transcoding job error", "video_id", job.VideoID, "job_id", job.ID, "error", err)
 		}
+		s.metrics.ObserveTranscode(resolutionPath(job.Resolution), true, time.Since(job.StartTime))
 
 		// Notify about error
-		s.notificationService.NotifyTranscodingComplete(ctx, job.VideoID, pb.TranscodingStatus_TRANSCODING_STATUS_ERROR)
+		s.notificationService.NotifyTranscodingComplete(ctx, job.VideoID, pb.TranscodingStatus_TRANSCODING_STATUS_FAILED)
 		return
 	}
 
 	// Update job status to completed
 	job.Status = pb.TranscodingStatus_TRANSCODING_STATUS_COMPLETED
 	job.Progress = 100
+	job.ErrorMessage = ""
 	completionTime := time.Now()
 	job.CompletionTime = &completionTime
+	s.metrics.ObserveTranscode(resolutionPath(job.Resolution), false, completionTime.Sub(job.StartTime))
 
-	if err := s.storage.UpdateTranscodingJob(ctx, job); err != nil {
-		log.Printf("Failed to update transcoding job completion: %v", err)
+	if err := s.updateJob(ctx, job); err != nil {
+		s.logger.ErrorContext(ctx, "Failed to update transcoding job completion", "video_id", job.VideoID, "job_id", job.ID, "error", err)
 		return
 	}
 
@@ -272,6 +614,46 @@ func (s *Service) processTranscoding(ctx context.Context, job *TranscodingJob) {
 	s.notificationService.NotifyTranscodingComplete(ctx, job.VideoID, pb.TranscodingStatus_TRANSCODING_STATUS_COMPLETED)
 }
 
+// GetMediaInfo probes a media file through the FFmpeg client
+func (s *Service) GetMediaInfo(ctx context.Context, inputPath string) (*MediaInfo, error) {
+	return s.ffmpegClient.GetMediaInfo(ctx, inputPath)
+}
+
+// SourceResolution returns the ladder resolution matching the source media,
+// using the same thresholds as the renditions chosen for it
+func SourceResolution(mediaInfo *MediaInfo) pb.VideoResolution {
+	if !mediaInfo.HasVideo {
+		return pb.VideoResolution_VIDEO_RESOLUTION_AUDIO_ONLY
+	}
+
+	maxDimension := max(mediaInfo.Width, mediaInfo.Height)
+	switch {
+	case maxDimension >= 3840:
+		return pb.VideoResolution_VIDEO_RESOLUTION_2160P
+	case maxDimension >= 2560:
+		return pb.VideoResolution_VIDEO_RESOLUTION_1440P
+	case maxDimension >= 1920:
+		return pb.VideoResolution_VIDEO_RESOLUTION_1080P
+	case maxDimension >= 1280:
+		return pb.VideoResolution_VIDEO_RESOLUTION_720P
+	case maxDimension >= 854:
+		return pb.VideoResolution_VIDEO_RESOLUTION_480P
+	case maxDimension >= 640:
+		return pb.VideoResolution_VIDEO_RESOLUTION_360P
+	default:
+		return pb.VideoResolution_VIDEO_RESOLUTION_240P
+	}
+}
+
+// targetRenditions selects what to transcode a source into: the resolution
+// ladder for video, or a single audio rendition for audio-only media
+func (s *Service) targetRenditions(mediaInfo *MediaInfo) []pb.VideoResolution {
+	if !mediaInfo.HasVideo {
+		return []pb.VideoResolution{pb.VideoResolution_VIDEO_RESOLUTION_AUDIO_ONLY}
+	}
+	return s.determineTargetResolutions(mediaInfo.Width, mediaInfo.Height)
+}
+
 // determineTargetResolutions selects appropriate resolutions based on the source video
 func (s *Service) determineTargetResolutions(width int, height int) []pb.VideoResolution {
 	maxDimension := width
@@ -316,11 +698,16 @@ func (s *Service) determineTargetResolutions(width int, height int) []pb.VideoRe
 		resolutions = append(resolutions, pb.VideoResolution_VIDEO_RESOLUTION_360P)
 	}
 
+	// The ladder is ordered highest first, so this keeps the top renditions
+	if s.maxRenditions > 0 && len(resolutions) > s.maxRenditions {
+		resolutions = resolutions[:s.maxRenditions]
+	}
+
 	return resolutions
 }
 
-// getResolutionPath returns the path component based on resolution
-func (s *Service) getResolutionPath(resolution pb.VideoResolution) string {
+// resolutionPath returns the path component based on resolution
+func resolutionPath(resolution pb.VideoResolution) string {
 	switch resolution {
 	case pb.VideoResolution_VIDEO_RESOLUTION_240P:
 		return "240p"
@@ -336,6 +723,8 @@ func (s *Service) getResolutionPath(resolution pb.VideoResolution) string {
 		return "1440p"
 	case pb.VideoResolution_VIDEO_RESOLUTION_2160P:
 		return "2160p"
+	case pb.VideoResolution_VIDEO_RESOLUTION_AUDIO_ONLY:
+		return "audio"
 	default:
 		return "original"
 	}
@@ -0,0 +1,52 @@
+package transcode_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"videostreaming/internal/service/transcode"
+	"videostreaming/internal/storage/memory"
+	pb "videostreaming/proto/video"
+)
+
+func TestTranscodingStatusSurvivesRestart(t *testing.T) {
+	storage := memory.NewTranscodeStorage()
+	first, err := transcode.NewService(storage, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+
+	jobs := []*transcode.TranscodingJob{
+		{ID: "job-720p", Resolution: pb.VideoResolution_VIDEO_RESOLUTION_720P, Status: pb.TranscodingStatus_TRANSCODING_STATUS_COMPLETED, Progress: 100, StartTime: time.Now()},
+		{ID: "job-1080p", Resolution: pb.VideoResolution_VIDEO_RESOLUTION_1080P, Status: pb.TranscodingStatus_TRANSCODING_STATUS_COMPLETED, Progress: 100, StartTime: time.Now()},
+	}
+	if err := first.RestoreTranscodingJobs(context.Background(), "v1", "videos/v1/source.mp4", jobs); err != nil {
+		t.Fatalf("RestoreTranscodingJobs: %v", err)
+	}
+
+	// A fresh service over the same storage knows nothing but what was persisted
+	restarted, err := transcode.NewService(storage, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	if err := restarted.LoadActiveJobs(context.Background()); err != nil {
+		t.Fatalf("LoadActiveJobs: %v", err)
+	}
+
+	status, err := restarted.GetTranscodingStatus(context.Background(), "v1")
+	if err != nil {
+		t.Fatalf("GetTranscodingStatus: %v", err)
+	}
+	if status.Status != pb.TranscodingStatus_TRANSCODING_STATUS_COMPLETED {
+		t.Errorf("status = %v, want COMPLETED", status.Status)
+	}
+	if len(status.Jobs) != len(jobs) {
+		t.Fatalf("got %d jobs after restart, want %d", len(status.Jobs), len(jobs))
+	}
+	for i, job := range status.Jobs {
+		if job.JobId != jobs[i].ID || job.Resolution != jobs[i].Resolution {
+			t.Errorf("job %d = %s at %v, want %s at %v", i, job.JobId, job.Resolution, jobs[i].ID, jobs[i].Resolution)
+		}
+	}
+}
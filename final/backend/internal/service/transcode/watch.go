@@ -0,0 +1,45 @@
+package transcode
+
+// SubscribeTranscodingStatus registers interest in a video's jobs. The
+// returned channel receives a signal whenever one of them changes; signals
+// are coalesced, so a slow subscriber reads the latest state with
+// GetTranscodingStatus rather than every intermediate one. The caller must
+// call the returned function once it stops watching.
+func (s *Service) SubscribeTranscodingStatus(videoID string) (<-chan struct{}, func()) {
+	changed := make(chan struct{}, 1)
+
+	s.watchersLock.Lock()
+	defer s.watchersLock.Unlock()
+
+	watchers, ok := s.watchers[videoID]
+	if !ok {
+		watchers = make(map[chan struct{}]struct{})
+		s.watchers[videoID] = watchers
+	}
+	watchers[changed] = struct{}{}
+
+	unsubscribe := func() {
+		s.watchersLock.Lock()
+		defer s.watchersLock.Unlock()
+
+		delete(s.watchers[videoID], changed)
+		if len(s.watchers[videoID]) == 0 {
+			delete(s.watchers, videoID)
+		}
+	}
+	return changed, unsubscribe
+}
+
+// notifyWatchers signals every subscriber of a video that its jobs changed.
+// It never blocks: a subscriber with a signal pending has yet to catch up.
+func (s *Service) notifyWatchers(videoID string) {
+	s.watchersLock.Lock()
+	defer s.watchersLock.Unlock()
+
+	for changed := range s.watchers[videoID] {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package transcode_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"videostreaming/internal/service/transcode"
+	"videostreaming/internal/storage/memory"
+	pb "videostreaming/proto/video"
+)
+
+func TestSubscribeTranscodingStatus(t *testing.T) {
+	ffmpeg := &fakeFFmpeg{ticks: []float32{50, 75}, release: make(chan struct{})}
+	svc, err := transcode.NewService(memory.NewTranscodeStorage(), ffmpeg, nil, newFakeNotifications())
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	changed, unsubscribe := svc.SubscribeTranscodingStatus("v1")
+	defer unsubscribe()
+	otherChanged, unsubscribeOther := svc.SubscribeTranscodingStatus("v2")
+	defer unsubscribeOther()
+
+	// next waits for a change and returns the status it led to
+	next := func() *pb.TranscodingStatusResponse {
+		t.Helper()
+		select {
+		case <-changed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("no change signalled")
+		}
+		status, err := svc.GetTranscodingStatus(context.Background(), "v1")
+		if err != nil {
+			t.Fatalf("GetTranscodingStatus: %v", err)
+		}
+		return status
+	}
+
+	if err := svc.StartTranscoding(context.Background(), "v1", "videos/v1/source.mp3"); err != nil {
+		t.Fatalf("StartTranscoding: %v", err)
+	}
+	if status := next(); status.Status != pb.TranscodingStatus_TRANSCODING_STATUS_PROCESSING || status.OverallProgress != 0 {
+		t.Errorf("first change = %v at %.0f%%, want PROCESSING at 0%%", status.Status, status.OverallProgress)
+	}
+
+	// The job can't move past a tick until it is released
+	ffmpeg.release <- struct{}{}
+	if status := next(); status.Status != pb.TranscodingStatus_TRANSCODING_STATUS_PROCESSING || status.OverallProgress != 50 {
+		t.Errorf("after the first tick = %v at %.0f%%, want PROCESSING at 50%%", status.Status, status.OverallProgress)
+	}
+
+	// Signals coalesce, so the last tick and completion may arrive as one
+	ffmpeg.release <- struct{}{}
+	status := next()
+	for status.Status == pb.TranscodingStatus_TRANSCODING_STATUS_PROCESSING {
+		status = next()
+	}
+	if status.Status != pb.TranscodingStatus_TRANSCODING_STATUS_COMPLETED || status.OverallProgress != 100 {
+		t.Errorf("last change = %v at %.0f%%, want COMPLETED at 100%%", status.Status, status.OverallProgress)
+	}
+
+	select {
+	case <-otherChanged:
+		t.Error("a subscriber to another video was signalled")
+	default:
+	}
+}
@@ -0,0 +1,126 @@
+package video
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	pb "videostreaming/proto/video"
+)
+
+// ArchiveVideo frees a video's storage while keeping its record for history.
+// Transcoded renditions are deleted, and so is the source unless KeepSource
+// is set; the video moves to ARCHIVED. Media shared with duplicate uploads
+// is left in place. Archiving an archived video is a no-op.
+func (s *Service) ArchiveVideo(ctx context.Context, req *pb.ArchiveVideoRequest) (*pb.Video, error) {
+	userID, err := s.resolveActingUser(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	video, err := s.findVideo(ctx, req.VideoId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get video: %w", err)
+	}
+
+	if video.UserID != userID {
+		return nil, fmt.Errorf("%w: not authorized to archive this video", ErrPermissionDenied)
+	}
+
+	switch video.Status {
+	case pb.VideoStatus_VIDEO_STATUS_ARCHIVED:
+		return toProtoVideo(video), nil
+	case pb.VideoStatus_VIDEO_STATUS_READY, pb.VideoStatus_VIDEO_STATUS_FAILED:
+	default:
+		return nil, fmt.Errorf("%w: only ready or failed videos can be archived", ErrFailedPrecondition)
+	}
+
+	inUse, err := s.mediaInUse(ctx, video)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check shared media: %w", err)
+	}
+
+	// Files go first so a failed delete leaves the video playable and retryable
+	if !inUse {
+		if err := s.fileStorage.DeletePrefix(ctx, s.transcodedKeyPrefix+video.mediaID()+"/"); err != nil {
+			return nil, fmt.Errorf("failed to delete transcoded files: %w", err)
+		}
+		if !req.KeepSource {
+			if err := s.fileStorage.DeleteFile(ctx, s.sourceKey(video)); err != nil {
+				return nil, fmt.Errorf("failed to delete source file: %w", err)
+			}
+		}
+	}
+
+	video.Status = pb.VideoStatus_VIDEO_STATUS_ARCHIVED
+	video.StatusReason = ""
+	video.UpdatedAt = time.Now()
+
+	if err := s.storage.SaveVideo(ctx, video); err != nil {
+		return nil, fmt.Errorf("failed to update video status: %w", err)
+	}
+	s.videoCache.invalidate(video.ID)
+
+	return toProtoVideo(video), nil
+}
+
+// RestoreFromArchive brings an archived video back. Media that was kept
+// because duplicates share it is reused as is; otherwise the source is
+// transcoded again, which fails if it was deleted when archiving.
+func (s *Service) RestoreFromArchive(ctx context.Context, req *pb.RestoreFromArchiveRequest) (*pb.Video, error) {
+	userID, err := s.resolveActingUser(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	video, err := s.findVideo(ctx, req.VideoId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get video: %w", err)
+	}
+
+	if video.UserID != userID {
+		return nil, fmt.Errorf("%w: not authorized to restore this video", ErrPermissionDenied)
+	}
+
+	if video.Status != pb.VideoStatus_VIDEO_STATUS_ARCHIVED {
+		return nil, fmt.Errorf("%w: video is not archived", ErrFailedPrecondition)
+	}
+
+	inUse, err := s.mediaInUse(ctx, video)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check shared media: %w", err)
+	}
+
+	if inUse {
+		video.Status = pb.VideoStatus_VIDEO_STATUS_READY
+		video.UpdatedAt = time.Now()
+		if err := s.storage.SaveVideo(ctx, video); err != nil {
+			return nil, fmt.Errorf("failed to update video status: %w", err)
+		}
+		s.videoCache.invalidate(video.ID)
+		return toProtoVideo(video), nil
+	}
+
+	// Renditions are rebuilt under this video's own ID
+	video.SourceKey = s.sourceKey(video)
+	video.MediaID = ""
+	video.Status = pb.VideoStatus_VIDEO_STATUS_PROCESSING
+	video.UpdatedAt = time.Now()
+
+	if err := s.storage.SaveVideo(ctx, video); err != nil {
+		return nil, fmt.Errorf("failed to update video status: %w", err)
+	}
+	s.videoCache.invalidate(video.ID)
+
+	if err := s.transcodingService.StartTranscoding(ctx, video.ID, video.SourceKey); err != nil {
+		video.Status = pb.VideoStatus_VIDEO_STATUS_ARCHIVED
+		video.UpdatedAt = time.Now()
+		if saveErr := s.storage.SaveVideo(ctx, video); saveErr != nil {
+			return nil, fmt.Errorf("failed to update video status: %w", saveErr)
+		}
+		s.videoCache.invalidate(video.ID)
+		return nil, fmt.Errorf("%w: source media can't be transcoded again: %v", ErrFailedPrecondition, err)
+	}
+
+	return toProtoVideo(video), nil
+}
@@ -0,0 +1,134 @@
+package video
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// defaultVideoCacheTTL is how long GetVideo serves metadata without
+// going back to storage
+const defaultVideoCacheTTL = 5 * time.Second
+
+// defaultVideoCacheSize is how many videos GetVideo keeps cached at once
+const defaultVideoCacheSize = 1000
+
+// videoCache is a short-lived cache in front of Storage.GetVideo.
+// Concurrent misses for the same ID are coalesced into a single storage
+// read, so a sudden spike on one video costs one query instead of many.
+// It holds at most size entries; when full, expired entries are swept and
+// then the one closest to expiring makes room.
+type videoCache struct {
+	ttl   time.Duration
+	size  int
+	group singleflight.Group
+
+	mu      sync.Mutex
+	entries map[string]videoCacheEntry
+}
+
+type videoCacheEntry struct {
+	video     *Video
+	expiresAt time.Time
+}
+
+func newVideoCache(ttl time.Duration, size int) *videoCache {
+	return &videoCache{
+		ttl:     ttl,
+		size:    size,
+		entries: make(map[string]videoCacheEntry),
+	}
+}
+
+// get returns the video from the cache, loading it through load on a miss.
+// Callers receive their own copy and may modify it freely.
+func (c *videoCache) get(ctx context.Context, id string, load func(ctx context.Context, id string) (*Video, error)) (*Video, error) {
+	if video, ok := c.lookup(id); ok {
+		return video, nil
+	}
+
+	// The shared read must not be cut short if the first caller goes away
+	loadCtx := context.WithoutCancel(ctx)
+	v, err, _ := c.group.Do(id, func() (interface{}, error) {
+		video, err := load(loadCtx, id)
+		if err != nil {
+			return nil, err
+		}
+		c.store(id, video)
+		return video, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	video := *v.(*Video)
+	return &video, nil
+}
+
+func (c *videoCache) lookup(id string) (*Video, bool) {
+	if c.ttl <= 0 {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[id]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(c.entries, id)
+		return nil, false
+	}
+
+	video := *entry.video
+	return &video, true
+}
+
+func (c *videoCache) store(id string, video *Video) {
+	if c.ttl <= 0 || c.size <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[id]; !ok && len(c.entries) >= c.size {
+		c.evict()
+	}
+	c.entries[id] = videoCacheEntry{
+		video:     video,
+		expiresAt: time.Now().Add(c.ttl),
+	}
+}
+
+// evict drops every expired entry, or the one expiring soonest if none
+// has. The caller must hold c.mu.
+func (c *videoCache) evict() {
+	now := time.Now()
+	var oldest string
+	var oldestAt time.Time
+	for id, entry := range c.entries {
+		if now.After(entry.expiresAt) {
+			delete(c.entries, id)
+			continue
+		}
+		if oldest == "" || entry.expiresAt.Before(oldestAt) {
+			oldest, oldestAt = id, entry.expiresAt
+		}
+	}
+	if len(c.entries) >= c.size {
+		delete(c.entries, oldest)
+	}
+}
+
+// invalidate drops a cached video after it has been modified
+func (c *videoCache) invalidate(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, id)
+}
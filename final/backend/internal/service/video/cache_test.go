@@ -0,0 +1,106 @@
+package video_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"videostreaming/internal/service/video"
+	"videostreaming/internal/storage/memory"
+	pb "videostreaming/proto/video"
+)
+
+// countingStorage counts the GetVideo calls that reach storage
+type countingStorage struct {
+	video.Storage
+	reads atomic.Int64
+	// release, when set, holds every read until it is closed
+	release chan struct{}
+}
+
+func (s *countingStorage) GetVideo(ctx context.Context, id string) (*video.Video, error) {
+	s.reads.Add(1)
+	if s.release != nil {
+		<-s.release
+	}
+	return s.Storage.GetVideo(ctx, id)
+}
+
+func newCountingStorage(t *testing.T, ids ...string) *countingStorage {
+	t.Helper()
+
+	storage := memory.NewVideoStorage()
+	for _, id := range ids {
+		if err := storage.SaveVideo(context.Background(), testVideo(id, "owner", pb.VideoVisibility_VIDEO_VISIBILITY_PUBLIC)); err != nil {
+			t.Fatalf("SaveVideo(%s): %v", id, err)
+		}
+	}
+	return &countingStorage{Storage: storage}
+}
+
+func TestGetVideoCoalescesReads(t *testing.T) {
+	storage := newCountingStorage(t, "v1")
+	storage.release = make(chan struct{})
+	svc := video.NewService(storage, newFakeFileStorage(), nil, nil, video.WithVideoCacheTTL(0))
+
+	const callers = 20
+	var wg sync.WaitGroup
+	errs := make([]error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = svc.GetVideo(context.Background(), &pb.GetVideoRequest{VideoId: "v1"})
+		}(i)
+	}
+	// Let the first read start before the others arrive
+	for storage.reads.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(storage.release)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("caller %d: GetVideo: %v", i, err)
+		}
+	}
+	if reads := storage.reads.Load(); reads >= callers {
+		t.Errorf("%d callers made %d storage reads, want them coalesced", callers, reads)
+	}
+}
+
+func TestGetVideoCacheSize(t *testing.T) {
+	storage := newCountingStorage(t, "v1", "v2", "v3")
+	svc := video.NewService(storage, newFakeFileStorage(), nil, nil,
+		video.WithVideoCacheTTL(time.Minute), video.WithVideoCacheSize(2))
+
+	get := func(id string) {
+		t.Helper()
+		if _, err := svc.GetVideo(context.Background(), &pb.GetVideoRequest{VideoId: id}); err != nil {
+			t.Fatalf("GetVideo(%s): %v", id, err)
+		}
+	}
+
+	get("v1")
+	get("v2")
+	get("v1")
+	if reads := storage.reads.Load(); reads != 2 {
+		t.Fatalf("storage reads = %d, want 2 with both videos cached", reads)
+	}
+
+	// A third video pushes out the one cached first
+	get("v3")
+	get("v3")
+	get("v2")
+	if reads := storage.reads.Load(); reads != 3 {
+		t.Fatalf("storage reads = %d, want 3 with v2 and v3 cached", reads)
+	}
+	get("v1")
+	if reads := storage.reads.Load(); reads != 4 {
+		t.Errorf("storage reads = %d, want v1 read again after its eviction", reads)
+	}
+}
@@ -0,0 +1,142 @@
+package video
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "videostreaming/proto/video"
+)
+
+const (
+	// maxStreamMessageLength caps chat messages in characters
+	maxStreamMessageLength = 500
+	// defaultStreamMessagePageSize is how many messages GetStreamMessages
+	// returns when the request doesn't say
+	defaultStreamMessagePageSize = 50
+	// maxStreamMessagePageSize caps how many messages one GetStreamMessages call returns
+	maxStreamMessagePageSize = 200
+)
+
+// StreamMessage is a chat message posted to a live stream
+type StreamMessage struct {
+	ID        string
+	StreamID  string
+	UserID    string
+	Text      string
+	CreatedAt time.Time
+}
+
+// PostStreamMessage adds a chat message from the acting user to a live
+// stream. Streams that have ended no longer take messages.
+func (s *Service) PostStreamMessage(ctx context.Context, req *pb.PostStreamMessageRequest) (*pb.StreamMessage, error) {
+	userID, err := s.resolveActingUser(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if userID == "" {
+		return nil, fmt.Errorf("%w: user_id is required", ErrInvalidArgument)
+	}
+
+	text, err := sanitizeStreamMessage(req.Text)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := s.storage.GetLiveStreamByID(ctx, req.StreamId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get live stream: %w", err)
+	}
+	if stream.Status == pb.StreamStatus_STREAM_STATUS_ENDED {
+		return nil, fmt.Errorf("%w: stream has ended", ErrFailedPrecondition)
+	}
+
+	message := &StreamMessage{
+		ID:        uuid.New().String(),
+		StreamID:  stream.StreamID,
+		UserID:    userID,
+		Text:      text,
+		CreatedAt: time.Now(),
+	}
+	if err := s.storage.SaveStreamMessage(ctx, message); err != nil {
+		return nil, fmt.Errorf("failed to save stream message: %w", err)
+	}
+
+	return toStreamMessageProto(message), nil
+}
+
+// GetStreamMessages returns a stream's chat messages newest first. Without
+// Before it starts from the latest message; to page further back, pass the
+// CreatedAt of the oldest message received so far.
+func (s *Service) GetStreamMessages(ctx context.Context, req *pb.GetStreamMessagesRequest) (*pb.GetStreamMessagesResponse, error) {
+	limit := int(req.PageSize)
+	if limit <= 0 {
+		limit = defaultStreamMessagePageSize
+	}
+	if limit > maxStreamMessagePageSize {
+		limit = maxStreamMessagePageSize
+	}
+
+	before := time.Now()
+	if req.Before != nil {
+		before = req.Before.AsTime()
+	}
+
+	// Ended streams keep their chat history
+	if _, err := s.storage.GetLiveStreamByID(ctx, req.StreamId); err != nil {
+		return nil, fmt.Errorf("failed to get live stream: %w", err)
+	}
+
+	messages, err := s.storage.ListStreamMessages(ctx, req.StreamId, limit, before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stream messages: %w", err)
+	}
+
+	protoMessages := make([]*pb.StreamMessage, 0, len(messages))
+	for _, message := range messages {
+		protoMessages = append(protoMessages, toStreamMessageProto(message))
+	}
+
+	return &pb.GetStreamMessagesResponse{
+		Messages: protoMessages,
+	}, nil
+}
+
+// sanitizeStreamMessage strips control characters and invalid UTF-8 from a
+// chat message, then checks it is neither empty nor too long
+func sanitizeStreamMessage(text string) (string, error) {
+	text = strings.ToValidUTF8(text, "")
+	text = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, text)
+	text = strings.TrimSpace(text)
+
+	if text == "" {
+		return "", fmt.Errorf("%w: message text is required", ErrInvalidArgument)
+	}
+	if utf8.RuneCountInString(text) > maxStreamMessageLength {
+		return "", fmt.Errorf("%w: message exceeds %d characters", ErrInvalidArgument, maxStreamMessageLength)
+	}
+
+	return text, nil
+}
+
+// toStreamMessageProto converts a chat message to proto
+func toStreamMessageProto(message *StreamMessage) *pb.StreamMessage {
+	return &pb.StreamMessage{
+		MessageId: message.ID,
+		StreamId:  message.StreamID,
+		UserId:    message.UserID,
+		Text:      message.Text,
+		CreatedAt: timestamppb.New(message.CreatedAt),
+	}
+}
@@ -0,0 +1,119 @@
+package video_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"videostreaming/internal/service/video"
+	"videostreaming/internal/storage/memory"
+	pb "videostreaming/proto/video"
+)
+
+func TestPostStreamMessage(t *testing.T) {
+	storage := memory.NewVideoStorage()
+	saveLiveStreams(t, storage, "owner", "key-owner", 2)
+	svc := video.NewService(storage, nil, nil, &fakeStreamingEngine{})
+	ctx := video.WithAuthenticatedUser(context.Background(), "viewer")
+	const live, ended = "owner-key-owner-0", "owner-key-owner-1"
+
+	message, err := svc.PostStreamMessage(ctx, &pb.PostStreamMessageRequest{StreamId: live, Text: "  gg\x07 wp \n"})
+	if err != nil {
+		t.Fatalf("PostStreamMessage: %v", err)
+	}
+	if message.StreamId != live || message.UserId != "viewer" || message.Text != "gg wp" || message.MessageId == "" {
+		t.Errorf("posted message = %+v, want gg wp from viewer", message)
+	}
+
+	tests := []struct {
+		name     string
+		streamID string
+		text     string
+		wantErr  error
+	}{
+		{"longest allowed", live, strings.Repeat("é", 500), nil},
+		{"too long", live, strings.Repeat("é", 501), video.ErrInvalidArgument},
+		{"only whitespace", live, " \t\n", video.ErrInvalidArgument},
+		{"missing stream", "missing", "hello", video.ErrStreamNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.PostStreamMessage(ctx, &pb.PostStreamMessageRequest{StreamId: tt.streamID, Text: tt.text})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("PostStreamMessage error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Chat closes with the stream, but its history stays readable
+	if _, err := svc.PostStreamMessage(ctx, &pb.PostStreamMessageRequest{StreamId: ended, Text: "before the end"}); err != nil {
+		t.Fatalf("PostStreamMessage: %v", err)
+	}
+	if err := storage.EndLiveStream(context.Background(), ended, "owner"); err != nil {
+		t.Fatalf("EndLiveStream: %v", err)
+	}
+	if _, err := svc.PostStreamMessage(ctx, &pb.PostStreamMessageRequest{StreamId: ended, Text: "too late"}); !errors.Is(err, video.ErrFailedPrecondition) {
+		t.Errorf("PostStreamMessage to an ended stream error = %v, want %v", err, video.ErrFailedPrecondition)
+	}
+	resp, err := svc.GetStreamMessages(context.Background(), &pb.GetStreamMessagesRequest{StreamId: ended})
+	if err != nil {
+		t.Fatalf("GetStreamMessages: %v", err)
+	}
+	if len(resp.Messages) != 1 || resp.Messages[0].Text != "before the end" {
+		t.Errorf("ended stream chat = %v, want the message posted while it was live", resp.Messages)
+	}
+}
+
+func TestGetStreamMessagesPagesBackward(t *testing.T) {
+	storage := memory.NewVideoStorage()
+	saveLiveStreams(t, storage, "owner", "key-owner", 1)
+	svc := video.NewService(storage, nil, nil, &fakeStreamingEngine{})
+	const streamID = "owner-key-owner-0"
+
+	base := time.Now().Add(-time.Hour)
+	for i := 0; i < 7; i++ {
+		err := storage.SaveStreamMessage(context.Background(), &video.StreamMessage{
+			ID:        fmt.Sprintf("m%d", i),
+			StreamID:  streamID,
+			UserID:    "viewer",
+			Text:      "hello",
+			CreatedAt: base.Add(time.Duration(i) * time.Minute),
+		})
+		if err != nil {
+			t.Fatalf("SaveStreamMessage: %v", err)
+		}
+	}
+
+	// Each page starts where the oldest message of the previous one left off
+	var pages [][]string
+	req := &pb.GetStreamMessagesRequest{StreamId: streamID, PageSize: 3}
+	for {
+		resp, err := svc.GetStreamMessages(context.Background(), req)
+		if err != nil {
+			t.Fatalf("GetStreamMessages: %v", err)
+		}
+		if len(resp.Messages) == 0 {
+			break
+		}
+		var ids []string
+		for _, message := range resp.Messages {
+			ids = append(ids, message.MessageId)
+		}
+		pages = append(pages, ids)
+		req.Before = timestamppb.New(resp.Messages[len(resp.Messages)-1].CreatedAt.AsTime())
+		if len(pages) > 3 {
+			t.Fatalf("still paging after %v", pages)
+		}
+	}
+
+	want := [][]string{{"m6", "m5", "m4"}, {"m3", "m2", "m1"}, {"m0"}}
+	if !slices.EqualFunc(pages, want, slices.Equal[[]string]) {
+		t.Errorf("pages = %v, want %v", pages, want)
+	}
+}
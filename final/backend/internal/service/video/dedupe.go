@@ -0,0 +1,109 @@
+package video
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	pb "videostreaming/proto/video"
+)
+
+// DedupeScope controls which earlier uploads a new upload may share media with
+type DedupeScope string
+
+const (
+	// DedupeOff transcodes every upload, even identical ones
+	DedupeOff DedupeScope = "off"
+	// DedupeUser reuses media only from the uploader's own videos
+	DedupeUser DedupeScope = "user"
+	// DedupeGlobal reuses media from any user's videos
+	DedupeGlobal DedupeScope = "global"
+)
+
+// WithDedupeScope makes CompleteUpload link uploads whose content hash matches
+// a ready video to that video's media instead of transcoding them again
+func WithDedupeScope(scope DedupeScope) Option {
+	return func(s *Service) {
+		s.dedupeScope = scope
+	}
+}
+
+// mediaID returns the ID under which the video's source and transcoded files
+// are stored. Deduplicated videos share the files of the video they match.
+func (v *Video) mediaID() string {
+	if v.MediaID != "" {
+		return v.MediaID
+	}
+	return v.ID
+}
+
+// linkDuplicate hashes the uploaded file and, when a ready video with the
+// same content is in scope, points video at that video's media and marks it
+// READY. It reports whether the video was linked; either way the hash is
+// recorded on video for the caller to save.
+func (s *Service) linkDuplicate(ctx context.Context, video *Video) (bool, error) {
+	sourceKey := s.videoKeyPrefix + video.ID
+	hash, err := s.fileStorage.HashFile(ctx, sourceKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to hash upload: %w", err)
+	}
+	video.ContentHash = hash
+
+	candidates, err := s.storage.ListVideosByContentHash(ctx, hash)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up duplicate uploads: %w", err)
+	}
+
+	var original *Video
+	for _, candidate := range candidates {
+		// Media in the trash may be purged, so it is never linked to
+		if candidate.ID == video.ID || candidate.Status != pb.VideoStatus_VIDEO_STATUS_READY || candidate.DeletedAt != nil {
+			continue
+		}
+		if s.dedupeScope == DedupeUser && candidate.UserID != video.UserID {
+			continue
+		}
+		original = candidate
+		break
+	}
+	if original == nil {
+		return false, nil
+	}
+
+	video.MediaID = original.mediaID()
+	video.DurationSeconds = original.DurationSeconds
+	video.Resolution = original.Resolution
+	video.Status = pb.VideoStatus_VIDEO_STATUS_READY
+	video.StatusReason = ""
+	video.UpdatedAt = time.Now()
+
+	if err := s.storage.SaveVideo(ctx, video); err != nil {
+		return false, fmt.Errorf("failed to link duplicate upload: %w", err)
+	}
+	s.videoCache.invalidate(video.ID)
+
+	// The shared media replaces this upload's own copy
+	if err := s.fileStorage.DeleteFile(ctx, sourceKey); err != nil {
+		s.logger.ErrorContext(ctx, "Failed to delete duplicate upload", "video_id", video.ID, "user_id", video.UserID, "object_key", sourceKey, "error", err)
+	}
+
+	return true, nil
+}
+
+// mediaInUse reports whether any remaining video still plays from mediaID
+func (s *Service) mediaInUse(ctx context.Context, video *Video) (bool, error) {
+	if video.ContentHash == "" {
+		return false, nil
+	}
+
+	others, err := s.storage.ListVideosByContentHash(ctx, video.ContentHash)
+	if err != nil {
+		return false, err
+	}
+	for _, other := range others {
+		if other.ID != video.ID && other.mediaID() == video.mediaID() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,32 @@
+package video
+
+import "errors"
+
+var (
+	// ErrInvalidArgument is returned when a request fails validation
+	ErrInvalidArgument = errors.New("invalid argument")
+
+	// ErrNotFound is returned when a resource doesn't exist or has been deleted
+	ErrNotFound = errors.New("not found")
+
+	// ErrUnauthenticated is returned when a request carries no authenticated identity
+	ErrUnauthenticated = errors.New("unauthenticated")
+
+	// ErrPermissionDenied is returned when a user acts on a resource they don't own
+	ErrPermissionDenied = errors.New("permission denied")
+
+	// ErrFailedPrecondition is returned when the system isn't in a state that allows the request
+	ErrFailedPrecondition = errors.New("failed precondition")
+
+	// ErrInvalidStreamKey is returned when a stream key is missing or doesn't belong to the user
+	ErrInvalidStreamKey = errors.New("invalid stream key")
+
+	// ErrStreamNotFound is returned when a live stream doesn't exist or is no longer live
+	ErrStreamNotFound = errors.New("stream not found")
+
+	// ErrStreamAtCapacity is returned when a live stream has reached its viewer limit
+	ErrStreamAtCapacity = errors.New("stream at capacity")
+
+	// ErrQuotaExceeded is returned when an upload would take a user over their storage quota
+	ErrQuotaExceeded = errors.New("storage quota exceeded")
+)
@@ -0,0 +1,201 @@
+package video
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	pb "videostreaming/proto/video"
+)
+
+// exportFormatVersion is bumped whenever the export document layout changes
+const exportFormatVersion = 1
+
+// VideoExport is a portable JSON document holding everything known about a
+// video, used for backups and for moving a video between deployments
+type VideoExport struct {
+	Version         int                      `json:"version"`
+	ExportedAt      time.Time                `json:"exported_at"`
+	Video           ExportedVideo            `json:"video"`
+	TranscodingJobs []ExportedTranscodingJob `json:"transcoding_jobs"`
+}
+
+// ExportedVideo is the video metadata part of an export
+type ExportedVideo struct {
+	ID              string     `json:"id"`
+	Title           string     `json:"title"`
+	Description     string     `json:"description"`
+	UserID          string     `json:"user_id"`
+	ThumbnailURL    string     `json:"thumbnail_url"`
+	VideoURL        string     `json:"video_url,omitempty"`
+	DurationSeconds int64      `json:"duration_seconds"`
+	ViewCount       int64      `json:"view_count"`
+	Status          int32      `json:"status"`
+	StatusReason    string     `json:"status_reason,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	Tags            []string   `json:"tags"`
+	Visibility      int32      `json:"visibility"`
+	Resolution      int32      `json:"resolution"`
+	ContentHash     string     `json:"content_hash,omitempty"`
+	MediaID         string     `json:"media_id,omitempty"`
+	SourceKey       string     `json:"source_key,omitempty"`
+	FileSizeBytes   int64      `json:"file_size_bytes"`
+	DeletedAt       *time.Time `json:"deleted_at,omitempty"`
+	CallbackURL     string     `json:"callback_url,omitempty"`
+}
+
+// ExportedTranscodingJob is a single rendition's transcoding record in an export
+type ExportedTranscodingJob struct {
+	JobID        string  `json:"job_id"`
+	Resolution   int32   `json:"resolution"`
+	Status       int32   `json:"status"`
+	Progress     float32 `json:"progress"`
+	ErrorMessage string  `json:"error_message,omitempty"`
+}
+
+// ExportVideo collects a video's metadata and transcoding jobs into a single
+// document. The export holds fields only the owner may see, so only the
+// owner may export a video, including one in the trash.
+func (s *Service) ExportVideo(ctx context.Context, videoID string, userID string) (*VideoExport, error) {
+	userID, err := s.resolveActingUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	video, err := s.storage.GetVideo(ctx, videoID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get video: %w", err)
+	}
+
+	if video.UserID != userID {
+		return nil, fmt.Errorf("%w: not authorized to export this video", ErrPermissionDenied)
+	}
+
+	status, err := s.transcodingService.GetTranscodingStatus(ctx, videoID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transcoding jobs: %w", err)
+	}
+
+	jobs := make([]ExportedTranscodingJob, 0, len(status.Jobs))
+	for _, job := range status.Jobs {
+		jobs = append(jobs, ExportedTranscodingJob{
+			JobID:        job.JobID,
+			Resolution:   int32(job.Resolution),
+			Status:       int32(job.Status),
+			Progress:     job.Progress,
+			ErrorMessage: job.ErrorMessage,
+		})
+	}
+
+	return &VideoExport{
+		Version:    exportFormatVersion,
+		ExportedAt: time.Now(),
+		Video: ExportedVideo{
+			ID:              video.ID,
+			Title:           video.Title,
+			Description:     video.Description,
+			UserID:          video.UserID,
+			ThumbnailURL:    video.ThumbnailURL,
+			VideoURL:        video.VideoURL,
+			DurationSeconds: video.DurationSeconds,
+			ViewCount:       video.ViewCount,
+			Status:          int32(video.Status),
+			StatusReason:    video.StatusReason,
+			CreatedAt:       video.CreatedAt,
+			UpdatedAt:       video.UpdatedAt,
+			Tags:            video.Tags,
+			Visibility:      int32(video.Visibility),
+			Resolution:      int32(video.Resolution),
+			ContentHash:     video.ContentHash,
+			MediaID:         video.MediaID,
+			SourceKey:       video.SourceKey,
+			FileSizeBytes:   video.FileSizeBytes,
+			DeletedAt:       video.DeletedAt,
+			CallbackURL:     video.CallbackURL,
+		},
+		TranscodingJobs: jobs,
+	}, nil
+}
+
+// ImportVideo restores a video and its transcoding jobs from an export.
+// Importing is an upsert, so re-running an import is safe. Users may only
+// import their own videos, and never over another user's video.
+func (s *Service) ImportVideo(ctx context.Context, doc *VideoExport, userID string) (*Video, error) {
+	userID, err := s.resolveActingUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if doc == nil || doc.Video.ID == "" {
+		return nil, fmt.Errorf("%w: export document has no video", ErrInvalidArgument)
+	}
+
+	if doc.Version != exportFormatVersion {
+		return nil, fmt.Errorf("%w: unsupported export version %d", ErrInvalidArgument, doc.Version)
+	}
+
+	if doc.Video.UserID != userID {
+		return nil, fmt.Errorf("%w: not authorized to import this video", ErrPermissionDenied)
+	}
+
+	// GetVideos skips missing IDs, so a fresh import isn't an error
+	existing, err := s.storage.GetVideos(ctx, []string{doc.Video.ID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get video: %w", err)
+	}
+	for _, v := range existing {
+		if v.UserID != userID {
+			return nil, fmt.Errorf("%w: video %s belongs to another user", ErrPermissionDenied, doc.Video.ID)
+		}
+	}
+
+	video := &Video{
+		ID:              doc.Video.ID,
+		Title:           doc.Video.Title,
+		Description:     doc.Video.Description,
+		UserID:          doc.Video.UserID,
+		ThumbnailURL:    doc.Video.ThumbnailURL,
+		VideoURL:        doc.Video.VideoURL,
+		DurationSeconds: doc.Video.DurationSeconds,
+		ViewCount:       doc.Video.ViewCount,
+		Status:          pb.VideoStatus(doc.Video.Status),
+		StatusReason:    doc.Video.StatusReason,
+		CreatedAt:       doc.Video.CreatedAt,
+		UpdatedAt:       doc.Video.UpdatedAt,
+		Tags:            doc.Video.Tags,
+		Visibility:      pb.VideoVisibility(doc.Video.Visibility),
+		Resolution:      pb.VideoResolution(doc.Video.Resolution),
+		ContentHash:     doc.Video.ContentHash,
+		MediaID:         doc.Video.MediaID,
+		SourceKey:       doc.Video.SourceKey,
+		FileSizeBytes:   doc.Video.FileSizeBytes,
+		DeletedAt:       doc.Video.DeletedAt,
+		CallbackURL:     doc.Video.CallbackURL,
+	}
+
+	if err := s.storage.SaveVideo(ctx, video); err != nil {
+		return nil, fmt.Errorf("failed to save video: %w", err)
+	}
+	s.videoCache.invalidate(video.ID)
+
+	if len(doc.TranscodingJobs) > 0 {
+		jobs := make([]*TranscodingJob, 0, len(doc.TranscodingJobs))
+		for _, job := range doc.TranscodingJobs {
+			jobs = append(jobs, &TranscodingJob{
+				JobID:        job.JobID,
+				Resolution:   pb.VideoResolution(job.Resolution),
+				Status:       pb.TranscodingStatus(job.Status),
+				Progress:     job.Progress,
+				ErrorMessage: job.ErrorMessage,
+			})
+		}
+
+		inputPath := s.sourceKey(video)
+		if err := s.transcodingService.RestoreTranscodingJobs(ctx, video.ID, inputPath, jobs); err != nil {
+			return nil, fmt.Errorf("failed to restore transcoding jobs: %w", err)
+		}
+	}
+
+	return video, nil
+}
@@ -0,0 +1,124 @@
+package video_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"videostreaming/internal/service/video"
+	pb "videostreaming/proto/video"
+)
+
+// fullVideo returns a video with every field set, so a round trip that
+// drops any of them shows up
+func fullVideo(t *testing.T) *video.Video {
+	t.Helper()
+
+	deletedAt := time.Date(2024, 3, 4, 5, 6, 7, 0, time.UTC)
+	v := &video.Video{
+		ID:              "v1",
+		Title:           "Title",
+		Description:     "Description",
+		UserID:          "owner",
+		ThumbnailURL:    "thumbnails/v1.jpg",
+		VideoURL:        "videos/v1/video.mp4",
+		DurationSeconds: 90,
+		ViewCount:       42,
+		Status:          pb.VideoStatus_VIDEO_STATUS_READY,
+		StatusReason:    "reason",
+		CreatedAt:       time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		UpdatedAt:       time.Date(2024, 2, 3, 4, 5, 6, 0, time.UTC),
+		Tags:            []string{"a", "b"},
+		Visibility:      pb.VideoVisibility_VIDEO_VISIBILITY_PRIVATE,
+		Resolution:      pb.VideoResolution_VIDEO_RESOLUTION_1080P,
+		ContentHash:     "hash",
+		MediaID:         "media",
+		SourceKey:       "recordings/s1.flv",
+		FileSizeBytes:   1 << 20,
+		DeletedAt:       &deletedAt,
+		CallbackURL:     "https://example.com/hook",
+	}
+
+	fields := reflect.ValueOf(v).Elem()
+	for i := 0; i < fields.NumField(); i++ {
+		if fields.Field(i).IsZero() {
+			t.Fatalf("fullVideo leaves %s unset", fields.Type().Field(i).Name)
+		}
+	}
+	return v
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	original := fullVideo(t)
+	_, sourceStorage := newTestService(t, original)
+	source := video.NewService(sourceStorage, nil, &fakeTranscoder{}, nil)
+
+	ctx := video.WithAuthenticatedUser(context.Background(), "owner")
+	doc, err := source.ExportVideo(ctx, "v1", "")
+	if err != nil {
+		t.Fatalf("ExportVideo: %v", err)
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("failed to encode export: %v", err)
+	}
+	decoded := new(video.VideoExport)
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatalf("failed to decode export: %v", err)
+	}
+
+	_, targetStorage := newTestService(t)
+	target := video.NewService(targetStorage, nil, &fakeTranscoder{}, nil)
+	if _, err := target.ImportVideo(ctx, decoded, ""); err != nil {
+		t.Fatalf("ImportVideo: %v", err)
+	}
+
+	imported, err := targetStorage.GetVideo(context.Background(), "v1")
+	if err != nil {
+		t.Fatalf("GetVideo: %v", err)
+	}
+	if !reflect.DeepEqual(imported, original) {
+		t.Errorf("imported video differs from the original:\n got  %+v\n want %+v", imported, original)
+	}
+}
+
+func TestExportImportPermissions(t *testing.T) {
+	original := fullVideo(t)
+	_, storage := newTestService(t, original)
+	svc := video.NewService(storage, nil, &fakeTranscoder{}, nil)
+
+	intruder := video.WithAuthenticatedUser(context.Background(), "intruder")
+	if _, err := svc.ExportVideo(intruder, "v1", ""); !errors.Is(err, video.ErrPermissionDenied) {
+		t.Errorf("ExportVideo by another user error = %v, want %v", err, video.ErrPermissionDenied)
+	}
+	if _, err := svc.ExportVideo(intruder, "v1", "owner"); !errors.Is(err, video.ErrPermissionDenied) {
+		t.Errorf("ExportVideo claiming to be the owner error = %v, want %v", err, video.ErrPermissionDenied)
+	}
+
+	owner := video.WithAuthenticatedUser(context.Background(), "owner")
+	doc, err := svc.ExportVideo(owner, "v1", "")
+	if err != nil {
+		t.Fatalf("ExportVideo: %v", err)
+	}
+
+	// Importing someone else's export, or over someone else's video, fails
+	if _, err := svc.ImportVideo(intruder, doc, ""); !errors.Is(err, video.ErrPermissionDenied) {
+		t.Errorf("ImportVideo of another user's export error = %v, want %v", err, video.ErrPermissionDenied)
+	}
+	doc.Video.UserID = "intruder"
+	if _, err := svc.ImportVideo(intruder, doc, ""); !errors.Is(err, video.ErrPermissionDenied) {
+		t.Errorf("ImportVideo over another user's video error = %v, want %v", err, video.ErrPermissionDenied)
+	}
+
+	stored, err := storage.GetVideo(context.Background(), "v1")
+	if err != nil {
+		t.Fatalf("GetVideo: %v", err)
+	}
+	if stored.UserID != "owner" {
+		t.Errorf("video now belongs to %q, want owner", stored.UserID)
+	}
+}
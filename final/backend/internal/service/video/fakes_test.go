@@ -0,0 +1,225 @@
+package video_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"videostreaming/internal/service/video"
+	pb "videostreaming/proto/video"
+)
+
+// fakeFileStorage keeps files in memory, typed by a content type per path
+type fakeFileStorage struct {
+	mu        sync.Mutex
+	files     map[string][]byte
+	types     map[string]string
+	deleteErr error // Returned by DeleteFile when set
+}
+
+func newFakeFileStorage() *fakeFileStorage {
+	return &fakeFileStorage{files: make(map[string][]byte), types: make(map[string]string)}
+}
+
+// put stores a file as if a client had uploaded it
+func (f *fakeFileStorage) put(path, contentType string, data []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.files[path] = data
+	f.types[path] = contentType
+}
+
+func (f *fakeFileStorage) has(path string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.files[path]
+	return ok
+}
+
+func (f *fakeFileStorage) GenerateUploadURL(ctx context.Context, path string, contentType string, expiresIn time.Duration) (string, error) {
+	return "https://files.test/upload/" + path, nil
+}
+
+func (f *fakeFileStorage) GenerateDownloadURL(ctx context.Context, path string, expiresIn time.Duration) (string, error) {
+	return "https://files.test/download/" + path, nil
+}
+
+func (f *fakeFileStorage) DeleteFile(ctx context.Context, path string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.deleteErr != nil {
+		return f.deleteErr
+	}
+	delete(f.files, path)
+	delete(f.types, path)
+	return nil
+}
+
+func (f *fakeFileStorage) DeletePrefix(ctx context.Context, prefix string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for path := range f.files {
+		if strings.HasPrefix(path, prefix) {
+			delete(f.files, path)
+			delete(f.types, path)
+		}
+	}
+	return nil
+}
+
+func (f *fakeFileStorage) HashFile(ctx context.Context, path string) (string, error) {
+	return "", os.ErrNotExist
+}
+
+func (f *fakeFileStorage) OpenReader(ctx context.Context, path string) (io.ReadCloser, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, ok := f.files[path]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (f *fakeFileStorage) StatObject(ctx context.Context, path string) (int64, string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, ok := f.files[path]
+	if !ok {
+		return 0, "", os.ErrNotExist
+	}
+	return int64(len(data)), f.types[path], nil
+}
+
+// fakeTranscoder accepts every job and reports a fixed media duration and
+// resolution. Jobs finish with status, COMPLETED unless set.
+type fakeTranscoder struct {
+	mu              sync.Mutex
+	durationSeconds int64
+	resolution      pb.VideoResolution
+	probeErr        error
+	status          pb.TranscodingStatus
+	started         []string
+	thumbnails      []float64
+}
+
+func (t *fakeTranscoder) StartTranscoding(ctx context.Context, videoID string, inputPath string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.started = append(t.started, videoID)
+	return nil
+}
+
+func (t *fakeTranscoder) GetTranscodingStatus(ctx context.Context, videoID string) (*video.TranscodingStatus, error) {
+	status := t.status
+	if status == pb.TranscodingStatus_TRANSCODING_STATUS_UNSPECIFIED {
+		status = pb.TranscodingStatus_TRANSCODING_STATUS_COMPLETED
+	}
+	return &video.TranscodingStatus{VideoID: videoID, Status: status}, nil
+}
+
+func (t *fakeTranscoder) ExtractThumbnail(ctx context.Context, inputPath string, atSeconds float64, outputPath string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.thumbnails = append(t.thumbnails, atSeconds)
+	return nil
+}
+
+func (t *fakeTranscoder) RestoreTranscodingJobs(ctx context.Context, videoID string, inputPath string, jobs []*video.TranscodingJob) error {
+	return nil
+}
+
+func (t *fakeTranscoder) SubscribeTranscodingStatus(videoID string) (<-chan struct{}, func()) {
+	return make(chan struct{}), func() {}
+}
+
+func (t *fakeTranscoder) GetMediaInfo(ctx context.Context, inputPath string) (*video.MediaInfo, error) {
+	if t.probeErr != nil {
+		return nil, t.probeErr
+	}
+	return &video.MediaInfo{DurationSeconds: t.durationSeconds, Resolution: t.resolution}, nil
+}
+
+// fakeRecordings serves a single recording and remembers which were deleted
+type fakeRecordings struct {
+	mu      sync.Mutex
+	key     string
+	deleted []string
+}
+
+func (r *fakeRecordings) FindRecording(ctx context.Context, streamKey string, since time.Time) (string, error) {
+	return r.key, nil
+}
+
+func (r *fakeRecordings) DeleteRecording(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deleted = append(r.deleted, key)
+	return nil
+}
+
+// fakeStreamingEngine reports no readers of its own, leaving viewer counts
+// to the ones stored through JoinStream
+type fakeStreamingEngine struct{}
+
+func (e *fakeStreamingEngine) GenerateStreamKey(ctx context.Context, userID string) (string, error) {
+	return "key-" + userID, nil
+}
+
+func (e *fakeStreamingEngine) GetRTMPURL() string {
+	return "rtmp://localhost/live"
+}
+
+func (e *fakeStreamingEngine) GetStreamPlaybackURL(streamID string) string {
+	return "http://localhost/hls/" + streamID + ".m3u8"
+}
+
+func (e *fakeStreamingEngine) GetWebRTCPlaybackURL(streamID string) string {
+	return "http://localhost/webrtc/" + streamID
+}
+
+func (e *fakeStreamingEngine) GetViewerCount(ctx context.Context, streamID string) (int64, error) {
+	return 0, nil
+}
+
+func (e *fakeStreamingEngine) IsStreamActive(streamID string) bool {
+	return true
+}
+
+// fakeMultipartUploader tracks multipart uploads and, on completion, stores
+// a file of size bytes in files as the assembled object
+type fakeMultipartUploader struct {
+	mu        sync.Mutex
+	files     *fakeFileStorage
+	size      int
+	completed []video.CompletedPart
+}
+
+func (u *fakeMultipartUploader) CreateMultipartUpload(ctx context.Context, key string, contentType string) (string, error) {
+	return "upload-" + key, nil
+}
+
+func (u *fakeMultipartUploader) PresignUploadPart(ctx context.Context, key string, uploadID string, partNumber int32, expiresIn time.Duration) (string, error) {
+	return fmt.Sprintf("https://files.test/upload/%s?uploadId=%s&partNumber=%d", key, uploadID, partNumber), nil
+}
+
+func (u *fakeMultipartUploader) CompleteMultipartUpload(ctx context.Context, key string, uploadID string, parts []video.CompletedPart) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if uploadID != "upload-"+key {
+		return errors.New("no such upload")
+	}
+	u.completed = parts
+	u.files.put(key, "video/mp4", bytes.Repeat([]byte{0}, u.size))
+	return nil
+}
+
+func (u *fakeMultipartUploader) AbortMultipartUpload(ctx context.Context, key string, uploadID string) error {
+	return nil
+}
@@ -0,0 +1,131 @@
+package video_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/reflection"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"videostreaming/internal/service/video"
+	pb "videostreaming/proto/video"
+)
+
+// dialTestServer serves svc with reflection over an in-memory listener and
+// returns a client connection to it
+func dialTestServer(t *testing.T, svc *video.Service) *grpc.ClientConn {
+	t.Helper()
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	pb.RegisterVideoServiceServer(server, svc)
+	reflection.Register(server)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("failed to dial test server: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestGRPCListVideos(t *testing.T) {
+	svc, _ := newTestService(t,
+		testVideo("v1", "alice", pb.VideoVisibility_VIDEO_VISIBILITY_PUBLIC),
+		testVideo("v2", "alice", pb.VideoVisibility_VIDEO_VISIBILITY_PUBLIC),
+		testVideo("v3", "bob", pb.VideoVisibility_VIDEO_VISIBILITY_PUBLIC),
+	)
+	conn := dialTestServer(t, svc)
+
+	codecs := []struct {
+		name string
+		opts []grpc.CallOption
+	}{
+		{"protobuf", nil},
+		{"json", []grpc.CallOption{grpc.CallContentSubtype(pb.CodecName)}},
+	}
+	for _, codec := range codecs {
+		t.Run(codec.name, func(t *testing.T) {
+			resp := new(pb.ListVideosResponse)
+			err := conn.Invoke(context.Background(), "/video.VideoService/ListVideos",
+				&pb.ListVideosRequest{UserId: "alice", PageSize: 10}, resp, codec.opts...)
+			if err != nil {
+				t.Fatalf("ListVideos: %v", err)
+			}
+
+			if resp.TotalCount != 2 || len(resp.Videos) != 2 {
+				t.Fatalf("got %d of %d videos, want 2 of 2", len(resp.Videos), resp.TotalCount)
+			}
+			for _, v := range resp.Videos {
+				if v.UserId != "alice" {
+					t.Errorf("video %s belongs to %q, want alice", v.Id, v.UserId)
+				}
+				if v.CreatedAt == nil || v.CreatedAt.AsTime().IsZero() {
+					t.Errorf("video %s lost its created_at", v.Id)
+				}
+			}
+		})
+	}
+}
+
+func TestGRPCReflectionDescribesVideoService(t *testing.T) {
+	svc, _ := newTestService(t)
+	conn := dialTestServer(t, svc)
+
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+	if err != nil {
+		t.Fatalf("ServerReflectionInfo: %v", err)
+	}
+	defer stream.CloseSend()
+
+	err = stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_FileContainingSymbol{
+			FileContainingSymbol: "video.VideoService",
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to send reflection request: %v", err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("failed to receive reflection response: %v", err)
+	}
+	if errResp := resp.GetErrorResponse(); errResp != nil {
+		t.Fatalf("reflection error: %s", errResp.ErrorMessage)
+	}
+
+	// The first file holds the symbol; the rest are its dependencies
+	files := new(descriptorpb.FileDescriptorSet)
+	for _, raw := range resp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+		file := new(descriptorpb.FileDescriptorProto)
+		if err := proto.Unmarshal(raw, file); err != nil {
+			t.Fatalf("failed to decode file descriptor: %v", err)
+		}
+		files.File = append(files.File, file)
+	}
+	registry, err := protodesc.NewFiles(files)
+	if err != nil {
+		t.Fatalf("reflected descriptors don't resolve: %v", err)
+	}
+	desc, err := registry.FindDescriptorByName("video.VideoService.ListVideos")
+	if err != nil {
+		t.Fatalf("ListVideos not described: %v", err)
+	}
+	if got := desc.FullName(); got != "video.VideoService.ListVideos" {
+		t.Errorf("described %s, want video.VideoService.ListVideos", got)
+	}
+}
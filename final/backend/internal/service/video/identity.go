@@ -0,0 +1,60 @@
+package video
+
+import (
+	"context"
+	"fmt"
+)
+
+// authenticatedUserKey is the context key holding the authenticated user ID
+type authenticatedUserKey struct{}
+
+// WithAuthenticatedUser returns a copy of ctx carrying the ID of the user
+// the transport layer authenticated for this request
+func WithAuthenticatedUser(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, authenticatedUserKey{}, userID)
+}
+
+// AuthenticatedUserID returns the user ID stored by WithAuthenticatedUser,
+// or ErrUnauthenticated when the request carries no authenticated identity
+func AuthenticatedUserID(ctx context.Context) (string, error) {
+	userID, _ := ctx.Value(authenticatedUserKey{}).(string)
+	if userID == "" {
+		return "", ErrUnauthenticated
+	}
+	return userID, nil
+}
+
+// resolveActingUser returns the user a mutating request acts as. An
+// authenticated identity always wins; a different user ID in the request
+// body is rejected rather than silently ignored. Without authentication the
+// body value is used as before, unless the service requires authentication,
+// in which case the request fails with ErrUnauthenticated. Read queries keep
+// filtering by the request's user ID.
+func (s *Service) resolveActingUser(ctx context.Context, requestUserID string) (string, error) {
+	userID, err := AuthenticatedUserID(ctx)
+	if err != nil {
+		if s.requireAuthentication {
+			return "", err
+		}
+		return requestUserID, nil
+	}
+	if requestUserID != "" && requestUserID != userID {
+		return "", fmt.Errorf("%w: user_id does not match the authenticated user", ErrPermissionDenied)
+	}
+	return userID, nil
+}
+
+// viewerID returns the user whose access a read is checked against. That
+// is the authenticated identity whenever there is one, whatever user ID the
+// request claims. Without one the claim is only trusted while the service
+// doesn't require authentication; otherwise the caller is anonymous and
+// sees no private videos.
+func (s *Service) viewerID(ctx context.Context, requestUserID string) string {
+	if userID, err := AuthenticatedUserID(ctx); err == nil {
+		return userID
+	}
+	if s.requireAuthentication {
+		return ""
+	}
+	return requestUserID
+}
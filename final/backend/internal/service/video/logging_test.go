@@ -0,0 +1,62 @@
+package video_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"videostreaming/internal/service/video"
+	"videostreaming/internal/storage/memory"
+	pb "videostreaming/proto/video"
+)
+
+func TestDeleteErrorIsLoggedWithFields(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+
+	files := newFakeFileStorage()
+	files.deleteErr = errors.New("disk on fire")
+	svc := video.NewService(memory.NewVideoStorage(), files, &fakeTranscoder{}, nil,
+		video.WithUserStorageQuota(10), video.WithVideoCacheTTL(0), video.WithLogger(logger))
+	ctx := video.WithAuthenticatedUser(context.Background(), "alice")
+
+	upload, err := svc.InitiateUpload(ctx, &pb.InitiateUploadRequest{Title: "Too big", ContentType: "video/mp4"})
+	if err != nil {
+		t.Fatalf("InitiateUpload: %v", err)
+	}
+	files.put("videos/"+upload.VideoId, "video/mp4", bytes.Repeat([]byte{0}, 20))
+
+	// Going over the quota deletes the upload, which fails
+	if _, err := svc.CompleteUpload(ctx, &pb.CompleteUploadRequest{VideoId: upload.VideoId}); !errors.Is(err, video.ErrQuotaExceeded) {
+		t.Fatalf("CompleteUpload error = %v, want %v", err, video.ErrQuotaExceeded)
+	}
+
+	var entry map[string]any
+	for _, line := range bytes.Split(bytes.TrimSpace(logs.Bytes()), []byte("\n")) {
+		var e map[string]any
+		if err := json.Unmarshal(line, &e); err != nil {
+			t.Fatalf("log line %q is not JSON: %v", line, err)
+		}
+		if e["msg"] == "Failed to delete rejected upload" {
+			entry = e
+		}
+	}
+	if entry == nil {
+		t.Fatalf("delete error not logged:\n%s", logs.String())
+	}
+
+	want := map[string]any{
+		"level":    "ERROR",
+		"video_id": upload.VideoId,
+		"user_id":  "alice",
+		"error":    "disk on fire",
+	}
+	for key, value := range want {
+		if entry[key] != value {
+			t.Errorf("log field %s = %v, want %v", key, entry[key], value)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package video_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"videostreaming/internal/service/video"
+	"videostreaming/internal/storage/memory"
+	pb "videostreaming/proto/video"
+)
+
+func TestCompleteUploadRecordsMediaInfo(t *testing.T) {
+	tests := []struct {
+		name           string
+		transcoder     *fakeTranscoder
+		wantDuration   int64
+		wantResolution pb.VideoResolution
+	}{
+		{
+			name:           "video",
+			transcoder:     &fakeTranscoder{durationSeconds: 95, resolution: pb.VideoResolution_VIDEO_RESOLUTION_1080P},
+			wantDuration:   95,
+			wantResolution: pb.VideoResolution_VIDEO_RESOLUTION_1080P,
+		},
+		{
+			name:           "audio only",
+			transcoder:     &fakeTranscoder{durationSeconds: 1800, resolution: pb.VideoResolution_VIDEO_RESOLUTION_AUDIO_ONLY},
+			wantDuration:   1800,
+			wantResolution: pb.VideoResolution_VIDEO_RESOLUTION_AUDIO_ONLY,
+		},
+		{
+			// Transcoding reports what is wrong with the file
+			name:           "probe fails",
+			transcoder:     &fakeTranscoder{probeErr: errors.New("moov atom not found")},
+			wantResolution: pb.VideoResolution_VIDEO_RESOLUTION_UNSPECIFIED,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := memory.NewVideoStorage()
+			files := newFakeFileStorage()
+			svc := video.NewService(storage, files, tt.transcoder, nil, video.WithVideoCacheTTL(0))
+			ctx := video.WithAuthenticatedUser(context.Background(), "alice")
+
+			upload, err := svc.InitiateUpload(ctx, &pb.InitiateUploadRequest{Title: "New video", ContentType: "video/mp4"})
+			if err != nil {
+				t.Fatalf("InitiateUpload: %v", err)
+			}
+			files.put("videos/"+upload.VideoId, "video/mp4", []byte("media"))
+			if _, err := svc.CompleteUpload(ctx, &pb.CompleteUploadRequest{VideoId: upload.VideoId}); err != nil {
+				t.Fatalf("CompleteUpload: %v", err)
+			}
+
+			stored, err := storage.GetVideo(context.Background(), upload.VideoId)
+			if err != nil {
+				t.Fatalf("GetVideo: %v", err)
+			}
+			if stored.DurationSeconds != tt.wantDuration || stored.Resolution != tt.wantResolution {
+				t.Errorf("stored %ds at %v, want %ds at %v", stored.DurationSeconds, stored.Resolution, tt.wantDuration, tt.wantResolution)
+			}
+			if stored.Status != pb.VideoStatus_VIDEO_STATUS_PROCESSING {
+				t.Errorf("status = %v, want PROCESSING", stored.Status)
+			}
+			if len(tt.transcoder.started) != 1 {
+				t.Errorf("transcoding started %d times, want once", len(tt.transcoder.started))
+			}
+		})
+	}
+}
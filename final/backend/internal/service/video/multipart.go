@@ -0,0 +1,108 @@
+package video
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	pb "videostreaming/proto/video"
+)
+
+const (
+	// minUploadPartSize is the smallest part object stores accept, except for the last
+	minUploadPartSize = 5 << 20
+	// maxUploadParts is the most parts a single multipart upload may have
+	maxUploadParts = 10000
+)
+
+// CompletedPart identifies one uploaded part of a multipart upload
+type CompletedPart struct {
+	PartNumber int32
+	ETag       string
+}
+
+// MultipartUploader defines the interface for uploading large files in parts
+// that clients send separately and can retry one at a time
+type MultipartUploader interface {
+	CreateMultipartUpload(ctx context.Context, key string, contentType string) (string, error)
+	PresignUploadPart(ctx context.Context, key string, uploadID string, partNumber int32, expiresIn time.Duration) (string, error)
+	CompleteMultipartUpload(ctx context.Context, key string, uploadID string, parts []CompletedPart) error
+	AbortMultipartUpload(ctx context.Context, key string, uploadID string) error
+}
+
+// WithMultipartUploads makes InitiateUpload hand out one presigned URL per
+// part for files larger than threshold bytes, split into parts of partSize
+// bytes. Parts are grown as needed to stay within the part count limit.
+func WithMultipartUploads(uploader MultipartUploader, threshold, partSize int64) Option {
+	return func(s *Service) {
+		s.multipartUploader = uploader
+		s.multipartThreshold = threshold
+		s.multipartPartSize = partSize
+	}
+}
+
+// useMultipart reports whether an upload of size bytes should be split into parts
+func (s *Service) useMultipart(size int64) bool {
+	return s.multipartUploader != nil && size > s.multipartThreshold
+}
+
+// uploadPartSize returns the part size for an upload of size bytes
+func (s *Service) uploadPartSize(size int64) int64 {
+	partSize := s.multipartPartSize
+	if partSize < minUploadPartSize {
+		partSize = minUploadPartSize
+	}
+	if minimum := (size + maxUploadParts - 1) / maxUploadParts; partSize < minimum {
+		partSize = minimum
+	}
+	return partSize
+}
+
+// initiateMultipartUpload starts a multipart upload for objectKey and
+// presigns a URL for each of its parts
+func (s *Service) initiateMultipartUpload(ctx context.Context, objectKey, contentType string, size int64) (*pb.InitiateUploadResponse, error) {
+	uploadID, err := s.multipartUploader.CreateMultipartUpload(ctx, objectKey, contentType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create multipart upload: %w", err)
+	}
+
+	partSize := s.uploadPartSize(size)
+	partCount := (size + partSize - 1) / partSize
+	partURLs := make([]string, 0, partCount)
+	for partNumber := int32(1); int64(partNumber) <= partCount; partNumber++ {
+		partURL, err := s.multipartUploader.PresignUploadPart(ctx, objectKey, uploadID, partNumber, s.uploadExpiry)
+		if err != nil {
+			if abortErr := s.multipartUploader.AbortMultipartUpload(ctx, objectKey, uploadID); abortErr != nil {
+				s.logger.ErrorContext(ctx, "Failed to abort multipart upload", "object_key", objectKey, "upload_id", uploadID, "error", abortErr)
+			}
+			return nil, fmt.Errorf("failed to presign upload part %d: %w", partNumber, err)
+		}
+		partURLs = append(partURLs, partURL)
+	}
+
+	return &pb.InitiateUploadResponse{
+		UploadId:      uploadID,
+		PartUrls:      partURLs,
+		PartSizeBytes: partSize,
+	}, nil
+}
+
+// completeMultipartUpload assembles the uploaded parts into objectKey
+func (s *Service) completeMultipartUpload(ctx context.Context, objectKey, uploadID string, uploaded []*pb.UploadPart) error {
+	if s.multipartUploader == nil {
+		return fmt.Errorf("%w: multipart uploads are not enabled", ErrInvalidArgument)
+	}
+
+	parts := make([]CompletedPart, 0, len(uploaded))
+	for _, part := range uploaded {
+		if part.PartNumber < 1 || part.Etag == "" {
+			return fmt.Errorf("%w: upload part needs a part number and ETag", ErrInvalidArgument)
+		}
+		parts = append(parts, CompletedPart{PartNumber: part.PartNumber, ETag: part.Etag})
+	}
+
+	if err := s.multipartUploader.CompleteMultipartUpload(ctx, objectKey, uploadID, parts); err != nil {
+		return fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package video_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"videostreaming/internal/service/video"
+	"videostreaming/internal/storage/memory"
+	pb "videostreaming/proto/video"
+)
+
+func TestInitiateUploadInParts(t *testing.T) {
+	const mb = 1 << 20
+	tests := []struct {
+		name         string
+		size         int64
+		wantParts    int
+		wantPartSize int64
+	}{
+		{"below the threshold", 50 * mb, 0, 0},
+		{"split into parts", 250 * mb, 3, 100 * mb},
+		// Parts grow so the upload stays within 10000 of them
+		{"huge file", 2 << 40, 10000, (2<<40 + 9999) / 10000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := newFakeFileStorage()
+			uploader := &fakeMultipartUploader{files: files}
+			svc := video.NewService(memory.NewVideoStorage(), files, &fakeTranscoder{}, nil,
+				video.WithMultipartUploads(uploader, 100*mb, 100*mb))
+			ctx := video.WithAuthenticatedUser(context.Background(), "owner")
+
+			resp, err := svc.InitiateUpload(ctx, &pb.InitiateUploadRequest{Title: "Upload", FileSizeBytes: tt.size, ContentType: "video/mp4"})
+			if err != nil {
+				t.Fatalf("InitiateUpload: %v", err)
+			}
+			if tt.wantParts == 0 {
+				if resp.UploadUrl == "" || len(resp.PartUrls) != 0 {
+					t.Errorf("got %d part URLs and upload URL %q, want a single upload URL", len(resp.PartUrls), resp.UploadUrl)
+				}
+				return
+			}
+			if len(resp.PartUrls) != tt.wantParts || resp.PartSizeBytes != tt.wantPartSize {
+				t.Errorf("got %d parts of %d bytes, want %d of %d", len(resp.PartUrls), resp.PartSizeBytes, tt.wantParts, tt.wantPartSize)
+			}
+			if resp.UploadId != "upload-videos/"+resp.VideoId {
+				t.Errorf("UploadId = %q, want the multipart upload's ID", resp.UploadId)
+			}
+		})
+	}
+}
+
+func TestCompleteUploadAssemblesParts(t *testing.T) {
+	files := newFakeFileStorage()
+	uploader := &fakeMultipartUploader{files: files, size: 1024}
+	transcoder := &fakeTranscoder{}
+	svc := video.NewService(memory.NewVideoStorage(), files, transcoder, nil,
+		video.WithMultipartUploads(uploader, 100, 5<<20), video.WithVideoCacheTTL(0))
+	ctx := video.WithAuthenticatedUser(context.Background(), "owner")
+
+	upload, err := svc.InitiateUpload(ctx, &pb.InitiateUploadRequest{Title: "Upload", FileSizeBytes: 1024, ContentType: "video/mp4"})
+	if err != nil {
+		t.Fatalf("InitiateUpload: %v", err)
+	}
+
+	// Parts without an ETag are rejected before anything is assembled
+	_, err = svc.CompleteUpload(ctx, &pb.CompleteUploadRequest{VideoId: upload.VideoId, UploadId: upload.UploadId, Parts: []*pb.UploadPart{{PartNumber: 1}}})
+	if !errors.Is(err, video.ErrInvalidArgument) {
+		t.Errorf("CompleteUpload without an ETag error = %v, want %v", err, video.ErrInvalidArgument)
+	}
+
+	resp, err := svc.CompleteUpload(ctx, &pb.CompleteUploadRequest{
+		VideoId:  upload.VideoId,
+		UploadId: upload.UploadId,
+		Parts:    []*pb.UploadPart{{PartNumber: 1, Etag: `"a"`}},
+	})
+	if err != nil {
+		t.Fatalf("CompleteUpload: %v", err)
+	}
+	if resp.Status != pb.VideoStatus_VIDEO_STATUS_PROCESSING {
+		t.Errorf("status = %v, want PROCESSING", resp.Status)
+	}
+	if len(uploader.completed) != 1 || uploader.completed[0].ETag != `"a"` {
+		t.Errorf("assembled parts %v, want the one uploaded", uploader.completed)
+	}
+	if len(transcoder.started) != 1 || transcoder.started[0] != upload.VideoId {
+		t.Errorf("transcoding started for %v, want [%s]", transcoder.started, upload.VideoId)
+	}
+}
@@ -0,0 +1,52 @@
+package video
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// defaultPageSize is used when a list request doesn't set a page size
+const defaultPageSize = 20
+
+// pageToken is the decoded form of the opaque tokens handed to clients.
+// It is JSON so that fields such as a cursor can be added later without
+// breaking tokens that are already in circulation.
+type pageToken struct {
+	Offset int `json:"o"`
+}
+
+// encodePageToken returns an opaque token pointing at offset
+func encodePageToken(offset int) string {
+	data, _ := json.Marshal(pageToken{Offset: offset})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodePageToken returns the offset stored in a token from encodePageToken.
+// An empty token refers to the first page.
+func decodePageToken(token string) (int, error) {
+	if token == "" {
+		return 0, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, fmt.Errorf("%w: malformed page token", ErrInvalidArgument)
+	}
+
+	var pt pageToken
+	if err := json.Unmarshal(data, &pt); err != nil || pt.Offset < 0 {
+		return 0, fmt.Errorf("%w: malformed page token", ErrInvalidArgument)
+	}
+
+	return pt.Offset, nil
+}
+
+// nextPageToken returns the token for the page after one that started at
+// offset and held count items, or "" when there are no more items
+func nextPageToken(offset, count, total int) string {
+	if count == 0 || offset+count >= total {
+		return ""
+	}
+	return encodePageToken(offset + count)
+}
@@ -0,0 +1,110 @@
+package video_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"videostreaming/internal/service/video"
+	"videostreaming/internal/storage/memory"
+	pb "videostreaming/proto/video"
+)
+
+// pageCount is how many items the pagination tests spread over three pages
+const pageCount = 55
+
+// walkPages calls list with each page token in turn until it returns none,
+// and fails the test when an item repeats or one goes missing
+func walkPages(t *testing.T, list func(token string) ([]string, string, error)) {
+	t.Helper()
+
+	seen := make(map[string]bool, pageCount)
+	token, pages := "", 0
+	for {
+		ids, next, err := list(token)
+		if err != nil {
+			t.Fatalf("page %d: %v", pages, err)
+		}
+		pages++
+		for _, id := range ids {
+			if seen[id] {
+				t.Errorf("page %d repeats %s", pages, id)
+			}
+			seen[id] = true
+		}
+		if next == "" {
+			break
+		}
+		if pages > pageCount {
+			t.Fatalf("still paging after %d pages", pages)
+		}
+		token = next
+	}
+
+	if pages != 3 {
+		t.Errorf("walked %d pages, want 3", pages)
+	}
+	if len(seen) != pageCount {
+		t.Errorf("saw %d distinct items, want %d", len(seen), pageCount)
+	}
+}
+
+func TestListVideosPages(t *testing.T) {
+	base := time.Now()
+	var videos []*video.Video
+	for i := 0; i < pageCount; i++ {
+		v := testVideo(fmt.Sprintf("v%02d", i), "owner", pb.VideoVisibility_VIDEO_VISIBILITY_PUBLIC)
+		v.Status = pb.VideoStatus_VIDEO_STATUS_PROCESSING
+		v.CreatedAt = base.Add(time.Duration(i) * time.Second)
+		videos = append(videos, v)
+	}
+	svc, _ := newTestService(t, videos...)
+
+	walkPages(t, func(token string) ([]string, string, error) {
+		resp, err := svc.ListVideos(context.Background(), &pb.ListVideosRequest{UserId: "owner", PageSize: 20, PageToken: token})
+		if err != nil {
+			return nil, "", err
+		}
+		if resp.TotalCount != pageCount {
+			t.Errorf("TotalCount = %d, want %d", resp.TotalCount, pageCount)
+		}
+		var ids []string
+		for _, v := range resp.Videos {
+			ids = append(ids, v.Id)
+		}
+		return ids, resp.NextPageToken, nil
+	})
+}
+
+func TestGetLiveStreamsPages(t *testing.T) {
+	storage := memory.NewVideoStorage()
+	saveLiveStreams(t, storage, "owner", "key", pageCount)
+	svc := video.NewService(storage, nil, nil, &fakeStreamingEngine{})
+
+	walkPages(t, func(token string) ([]string, string, error) {
+		resp, err := svc.GetLiveStreams(context.Background(), &pb.GetLiveStreamsRequest{UserId: "owner", PageSize: 20, PageToken: token})
+		if err != nil {
+			return nil, "", err
+		}
+		var ids []string
+		for _, stream := range resp.Streams {
+			ids = append(ids, stream.StreamId)
+		}
+		return ids, resp.NextPageToken, nil
+	})
+}
+
+func TestMalformedPageToken(t *testing.T) {
+	svc := video.NewService(memory.NewVideoStorage(), nil, nil, &fakeStreamingEngine{})
+
+	for _, token := range []string{"not base64!", "bm90IGpzb24", "eyJvIjotMX0"} {
+		if _, err := svc.ListVideos(context.Background(), &pb.ListVideosRequest{UserId: "owner", PageToken: token}); !errors.Is(err, video.ErrInvalidArgument) {
+			t.Errorf("ListVideos with token %q error = %v, want %v", token, err, video.ErrInvalidArgument)
+		}
+		if _, err := svc.GetLiveStreams(context.Background(), &pb.GetLiveStreamsRequest{PageToken: token}); !errors.Is(err, video.ErrInvalidArgument) {
+			t.Errorf("GetLiveStreams with token %q error = %v, want %v", token, err, video.ErrInvalidArgument)
+		}
+	}
+}
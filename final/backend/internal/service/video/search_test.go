@@ -0,0 +1,108 @@
+package video_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"videostreaming/internal/service/video"
+	pb "videostreaming/proto/video"
+)
+
+func TestSearchVideos(t *testing.T) {
+	public := pb.VideoVisibility_VIDEO_VISIBILITY_PUBLIC
+	cats := testVideo("cats", "owner", public)
+	cats.Title = "Funny Cats compilation"
+	catsAndDogs := testVideo("cats-and-dogs", "owner", public)
+	catsAndDogs.Title = "Cats vs dogs"
+	catsAndDogs.CreatedAt = cats.CreatedAt.Add(-time.Hour)
+	tagged := testVideo("tagged", "owner", public)
+	tagged.Description = "A walk in the park"
+	tagged.Tags = []string{"dogs", "outdoors"}
+	tagged.CreatedAt = cats.CreatedAt.Add(-2 * time.Hour)
+	hidden := testVideo("hidden", "other", pb.VideoVisibility_VIDEO_VISIBILITY_PRIVATE)
+	hidden.Title = "Private cats"
+	svc, _ := newTestService(t, cats, catsAndDogs, tagged, hidden, testVideo("other", "owner", public))
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		// Videos matching both words rank first, then the newest
+		{"cats dogs", []string{"cats-and-dogs", "cats", "tagged"}},
+		{"CATS", []string{"cats", "cats-and-dogs"}},
+		{"outdoors", []string{"tagged"}},
+		{"park", []string{"tagged"}},
+		{"horses", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			resp, err := svc.SearchVideos(context.Background(), &pb.SearchVideosRequest{Query: tt.query})
+			if err != nil {
+				t.Fatalf("SearchVideos: %v", err)
+			}
+			var got []string
+			for _, v := range resp.Videos {
+				got = append(got, v.Id)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("SearchVideos(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("SearchVideos(%q) = %v, want %v", tt.query, got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestSearchVideosPages(t *testing.T) {
+	var videos []*video.Video
+	for _, id := range []string{"a", "b", "c"} {
+		v := testVideo(id, "owner", pb.VideoVisibility_VIDEO_VISIBILITY_PUBLIC)
+		v.Tags = []string{"music"}
+		videos = append(videos, v)
+	}
+	svc, _ := newTestService(t, videos...)
+
+	seen := make(map[string]bool)
+	req := &pb.SearchVideosRequest{Query: "music", PageSize: 2}
+	for pages := 0; ; pages++ {
+		if pages > 2 {
+			t.Fatalf("search did not run out of pages")
+		}
+		resp, err := svc.SearchVideos(context.Background(), req)
+		if err != nil {
+			t.Fatalf("SearchVideos: %v", err)
+		}
+		if resp.TotalCount != 3 {
+			t.Errorf("TotalCount = %d, want 3", resp.TotalCount)
+		}
+		for _, v := range resp.Videos {
+			if seen[v.Id] {
+				t.Errorf("video %s found on more than one page", v.Id)
+			}
+			seen[v.Id] = true
+		}
+		if resp.NextPageToken == "" {
+			break
+		}
+		req.PageToken = resp.NextPageToken
+	}
+	if len(seen) != 3 {
+		t.Errorf("found %d videos across pages, want 3", len(seen))
+	}
+}
+
+func TestSearchVideosRequiresQuery(t *testing.T) {
+	svc, _ := newTestService(t)
+
+	for _, query := range []string{"", "   "} {
+		if _, err := svc.SearchVideos(context.Background(), &pb.SearchVideosRequest{Query: query}); !errors.Is(err, video.ErrInvalidArgument) {
+			t.Errorf("SearchVideos(%q) error = %v, want %v", query, err, video.ErrInvalidArgument)
+		}
+	}
+}
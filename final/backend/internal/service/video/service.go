@@ -2,7 +2,15 @@ package video
 
 import (
 	"context"
+	"crypto/subtle"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,20 +20,56 @@ import (
 	pb "videostreaming/proto/video"
 )
 
+// Pinger is implemented by storages backed by a database, reporting
+// whether it can currently be reached
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
 // Storage defines the interface for video data persistence
 type Storage interface {
 	SaveVideo(ctx context.Context, video *Video) error
 	GetVideo(ctx context.Context, id string) (*Video, error)
-	ListVideos(ctx context.Context, userID string, limit int, offset int) ([]*Video, int, error)
+	// GetVideos returns the videos that exist among ids, in any order
+	GetVideos(ctx context.Context, ids []string) ([]*Video, error)
+	ListVideos(ctx context.Context, filter ListVideosFilter, limit int, offset int) ([]*Video, int, error)
+	SearchVideos(ctx context.Context, query string, limit int, offset int) ([]*Video, int, error)
 	DeleteVideo(ctx context.Context, id string, userID string) error
+	IncrementViewCount(ctx context.Context, videoID string) (int64, error)
+	ListVideosByContentHash(ctx context.Context, hash string) ([]*Video, error)
+	// GetUserStorageUsage returns the total FileSizeBytes of a user's videos
+	GetUserStorageUsage(ctx context.Context, userID string) (int64, error)
+	// ListDeletedVideos returns up to limit videos moved to the trash before deletedBefore
+	ListDeletedVideos(ctx context.Context, deletedBefore time.Time, limit int) ([]*Video, error)
 	
 	// Live streaming methods
 	SaveStreamKey(ctx context.Context, userID string, streamKey string) error
+	// GetStreamKey returns a user's stream key, or an error wrapping
+	// ErrNotFound when they have none yet
 	GetStreamKey(ctx context.Context, userID string) (string, error)
+	// GetStreamKeyOwner returns the user a stream key belongs to, or an
+	// empty string when no user currently holds it
+	GetStreamKeyOwner(ctx context.Context, streamKey string) (string, error)
 	SaveLiveStream(ctx context.Context, stream *LiveStream) error
 	GetLiveStream(ctx context.Context, streamID string) (*LiveStream, error)
+	GetLiveStreamByID(ctx context.Context, streamID string) (*LiveStream, error)
+	GetLiveStreamsByIDs(ctx context.Context, streamIDs []string) ([]*LiveStream, error)
 	EndLiveStream(ctx context.Context, streamID string, userID string) error
+	// AdjustViewerCount adds delta to a live stream's stored viewer count,
+	// never taking it below zero, and returns the new count. A positive delta
+	// that would take the count above maxViewers fails with
+	// ErrStreamAtCapacity in the same atomic step; 0 means unlimited.
+	AdjustViewerCount(ctx context.Context, streamID string, delta int64, maxViewers int64) (int64, error)
 	ListLiveStreams(ctx context.Context, userID string, limit int, offset int) ([]*LiveStream, int, error)
+	// ListLiveStreamsByCategory is ListLiveStreams filtered to one category
+	// instead of one user; an empty category matches every stream
+	ListLiveStreamsByCategory(ctx context.Context, category string, limit int, offset int) ([]*LiveStream, int, error)
+	
+	// Live stream chat
+	SaveStreamMessage(ctx context.Context, message *StreamMessage) error
+	// ListStreamMessages returns up to limit of a stream's messages posted
+	// before the given time, newest first
+	ListStreamMessages(ctx context.Context, streamID string, limit int, before time.Time) ([]*StreamMessage, error)
 }
 
 // FileStorage defines the interface for file storage operations
@@ -39,12 +83,46 @@ type FileStorage interface {
 	
 	// Delete a file
 	DeleteFile(ctx context.Context, path string) error
+	
+	// Delete every file under a prefix
+	DeletePrefix(ctx context.Context, prefix string) error
+	
+	// Hash a file's content, returning a hex-encoded SHA-256 digest
+	HashFile(ctx context.Context, path string) (string, error)
+	
+	// Open a file for reading; the caller must close the reader.
+	// A missing file is reported as os.ErrNotExist.
+	OpenReader(ctx context.Context, path string) (io.ReadCloser, error)
+	
+	// Look up a file's size and content type.
+	// A missing file is reported as os.ErrNotExist.
+	StatObject(ctx context.Context, path string) (int64, string, error)
 }
 
 // TranscodingService defines the interface for video transcoding operations
 type TranscodingService interface {
 	StartTranscoding(ctx context.Context, videoID string, inputPath string) error
 	GetTranscodingStatus(ctx context.Context, videoID string) (*TranscodingStatus, error)
+	ExtractThumbnail(ctx context.Context, inputPath string, atSeconds float64, outputPath string) error
+	RestoreTranscodingJobs(ctx context.Context, videoID string, inputPath string, jobs []*TranscodingJob) error
+	
+	// Receive a signal whenever a video's jobs change, until the returned
+	// function is called. Signals may be coalesced.
+	SubscribeTranscodingStatus(videoID string) (<-chan struct{}, func())
+	
+	// Probe a media file for its duration and source resolution
+	GetMediaInfo(ctx context.Context, inputPath string) (*MediaInfo, error)
+}
+
+// CookieSigner defines the interface for issuing signed cookies that
+// authorize access to every object under a path prefix (e.g. CloudFront)
+type CookieSigner interface {
+	SignCookies(ctx context.Context, prefix string, expiresIn time.Duration) ([]*http.Cookie, error)
+}
+
+// Notifier defines the interface for telling users about their videos
+type Notifier interface {
+	NotifyVideoFailed(ctx context.Context, videoID string, userID string, reason string) error
 }
 
 // StreamingEngine defines the interface for live streaming operations
@@ -52,6 +130,9 @@ type StreamingEngine interface {
 	GenerateStreamKey(ctx context.Context, userID string) (string, error)
 	GetRTMPURL() string
 	GetStreamPlaybackURL(streamID string) string
+	GetWebRTCPlaybackURL(streamID string) string
+	GetViewerCount(ctx context.Context, streamID string) (int64, error)
+	IsStreamActive(streamID string) bool
 }
 
 // Video represents a video in the system
@@ -65,11 +146,18 @@ type Video struct {
 	DurationSeconds  int64
 	ViewCount        int64
 	Status           pb.VideoStatus
+	StatusReason     string // Why the video is FAILED, empty otherwise
 	CreatedAt        time.Time
 	UpdatedAt        time.Time
 	Tags             []string
 	Visibility       pb.VideoVisibility
 	Resolution       pb.VideoResolution
+	ContentHash      string // SHA-256 of the uploaded file, set on completion
+	MediaID          string // Video whose media this one shares, empty for its own
+	SourceKey        string // Original media outside the videos prefix, e.g. a stream recording
+	FileSizeBytes    int64  // Size of the uploaded file, counted against the owner's quota
+	DeletedAt        *time.Time // When the video was moved to the trash, nil otherwise
+	CallbackURL      string     // Where to send a webhook once the video is ready or failed, if anywhere
 }
 
 // LiveStream represents an active live stream
@@ -80,6 +168,7 @@ type LiveStream struct {
 	Description   string
 	ThumbnailURL  string
 	PlaybackURL   string
+	WebRTCPlaybackURL string
 	ViewerCount   int64
 	Status        pb.StreamStatus
 	StartedAt     time.Time
@@ -87,6 +176,19 @@ type LiveStream struct {
 	Tags          []string
 	Category      string
 	StreamKey     string
+	MaxViewers    int64 // Concurrent viewer cap; 0 means unlimited
+}
+
+// ListVideosFilter narrows down the videos a listing returns and picks their
+// order. Zero-valued fields don't filter, except that private videos are only
+// listed when they belong to ViewerID; an unspecified SortBy lists the newest
+// videos first.
+type ListVideosFilter struct {
+	UserID     string
+	Status     pb.VideoStatus
+	Visibility pb.VideoVisibility
+	SortBy     pb.VideoSortBy
+	ViewerID   string
 }
 
 // TranscodingStatus represents the status of a video transcoding job
@@ -97,6 +199,12 @@ type TranscodingStatus struct {
 	OverallProgress float32
 }
 
+// MediaInfo describes an uploaded media file
+type MediaInfo struct {
+	DurationSeconds int64
+	Resolution      pb.VideoResolution // AUDIO_ONLY for media without a video stream
+}
+
 // TranscodingJob represents a single resolution transcoding job
 type TranscodingJob struct {
 	JobID         string
@@ -106,56 +214,223 @@ type TranscodingJob struct {
 	ErrorMessage  string
 }
 
+// Metrics records service level metrics
+type Metrics interface {
+	StreamStarted()
+	StreamEnded()
+}
+
+// noopMetrics discards all metrics
+type noopMetrics struct{}
+
+func (noopMetrics) StreamStarted() {}
+func (noopMetrics) StreamEnded()   {}
+
 // Service implements the video service
 type Service struct {
 	storage            Storage
 	fileStorage        FileStorage
 	transcodingService TranscodingService
 	streamingEngine    StreamingEngine
+	cookieSigner       CookieSigner
+	notifier           Notifier
+	videoCache         *videoCache
+	logger             *slog.Logger
+	metrics            Metrics
 	
 	// Configuration
-	uploadExpiry       time.Duration
-	downloadExpiry     time.Duration
-	videoKeyPrefix     string
-	thumbnailKeyPrefix string
-	rtmpURL            string
+	uploadExpiry        time.Duration
+	downloadExpiry      time.Duration
+	videoCacheTTL       time.Duration
+	videoCacheSize      int
+	videoKeyPrefix      string
+	thumbnailKeyPrefix  string
+	transcodedKeyPrefix string
+	rtmpURL             string
+	requireActivePublisher bool
+	requireAuthentication  bool
+	maxViewersPerStream    int64
+	dedupeScope            DedupeScope
+	autoArchive            bool
+	recordings             RecordingLocator
+	multipartUploader      MultipartUploader
+	multipartThreshold     int64
+	multipartPartSize      int64
+	userStorageQuota       int64
+	trashRetention         time.Duration
+
+	recordingRetention       time.Duration
+	recordingRetentionByUser map[string]time.Duration
+	deleteRecordingAfterVOD  bool
+	
+	// Validation limits
+	maxDescriptionLength int
+	
 	pb.UnimplementedVideoServiceServer
 }
 
+// Option configures optional Service settings
+type Option func(*Service)
+
+// WithMaxDescriptionLength caps video and stream descriptions at n characters.
+// A value of zero or less disables the cap.
+func WithMaxDescriptionLength(n int) Option {
+	return func(s *Service) {
+		s.maxDescriptionLength = n
+	}
+}
+
+// WithCookieSigner enables signed-cookie access to transcoded HLS output
+func WithCookieSigner(signer CookieSigner) Option {
+	return func(s *Service) {
+		s.cookieSigner = signer
+	}
+}
+
+// WithVideoCacheTTL sets how long GetVideo may serve cached metadata.
+// A value of zero or less disables caching; concurrent reads of the same
+// video are still coalesced.
+func WithVideoCacheTTL(ttl time.Duration) Option {
+	return func(s *Service) {
+		s.videoCacheTTL = ttl
+	}
+}
+
+// WithVideoCacheSize caps how many videos GetVideo keeps cached.
+// A value of zero or less disables caching, like WithVideoCacheTTL.
+func WithVideoCacheSize(size int) Option {
+	return func(s *Service) {
+		s.videoCacheSize = size
+	}
+}
+
+// WithRequireActivePublisher makes StartStream refuse to go live until a
+// publisher is pushing to the streaming engine with the stream key
+func WithRequireActivePublisher(require bool) Option {
+	return func(s *Service) {
+		s.requireActivePublisher = require
+	}
+}
+
+// WithRequireAuthentication makes every request that acts as a user need
+// an authenticated identity, instead of trusting the user ID it carries.
+// Turn it on whenever the transports authenticate their callers.
+func WithRequireAuthentication(require bool) Option {
+	return func(s *Service) {
+		s.requireAuthentication = require
+	}
+}
+
+// WithMaxViewersPerStream caps concurrent viewers on every stream.
+// Streamers may pick a lower cap for their own stream. A value of zero or
+// less means unlimited.
+func WithMaxViewersPerStream(n int64) Option {
+	return func(s *Service) {
+		s.maxViewersPerStream = n
+	}
+}
+
+// WithNotifier informs video owners when their upload fails
+func WithNotifier(notifier Notifier) Option {
+	return func(s *Service) {
+		s.notifier = notifier
+	}
+}
+
+// WithUserStorageQuota caps the total size of each user's uploads in bytes.
+// A value of zero or less means unlimited.
+func WithUserStorageQuota(bytes int64) Option {
+	return func(s *Service) {
+		s.userStorageQuota = bytes
+	}
+}
+
+// WithLogger sets the logger for errors the service handles itself.
+// Without it the service logs to slog.Default().
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Service) {
+		s.logger = logger
+	}
+}
+
+// WithMetrics records live stream activity in metrics
+func WithMetrics(metrics Metrics) Option {
+	return func(s *Service) {
+		s.metrics = metrics
+	}
+}
+
 // NewService creates a new video service
 func NewService(
 	storage Storage, 
 	fileStorage FileStorage, 
 	transcodingService TranscodingService, 
 	streamingEngine StreamingEngine,
+	opts ...Option,
 ) *Service {
-	return &Service{
-		storage:            storage,
-		fileStorage:        fileStorage,
-		transcodingService: transcodingService,
-		streamingEngine:    streamingEngine,
-		uploadExpiry:       time.Hour,
-		downloadExpiry:     time.Hour * 24,
-		videoKeyPrefix:     "videos/",
-		thumbnailKeyPrefix: "thumbnails/",
+	s := &Service{
+		storage:             storage,
+		fileStorage:         fileStorage,
+		transcodingService:  transcodingService,
+		streamingEngine:     streamingEngine,
+		uploadExpiry:        time.Hour,
+		downloadExpiry:      time.Hour * 24,
+		videoCacheTTL:       defaultVideoCacheTTL,
+		videoCacheSize:      defaultVideoCacheSize,
+		videoKeyPrefix:      "videos/",
+		thumbnailKeyPrefix:  "thumbnails/",
+		transcodedKeyPrefix: "transcoded/",
+		dedupeScope:         DedupeOff,
+		logger:              slog.Default(),
+		metrics:             noopMetrics{},
+		
+		maxDescriptionLength: defaultMaxDescriptionLength,
 	}
+	
+	for _, opt := range opts {
+		opt(s)
+	}
+	
+	s.videoCache = newVideoCache(s.videoCacheTTL, s.videoCacheSize)
+	
+	return s
 }
 
 // InitiateUpload handles the request to start a video upload
 func (s *Service) InitiateUpload(ctx context.Context, req *pb.InitiateUploadRequest) (*pb.InitiateUploadResponse, error) {
+	userID, err := s.resolveActingUser(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	
+	description, err := s.sanitizeDescription(req.Description)
+	if err != nil {
+		return nil, err
+	}
+	
+	if err := s.checkStorageQuota(ctx, userID, req.FileSizeBytes, 0); err != nil {
+		return nil, err
+	}
+	
+	if err := validateCallbackURL(req.CallbackUrl); err != nil {
+		return nil, err
+	}
+	
 	videoID := uuid.New().String()
 	uploadID := uuid.New().String()
 	
 	video := &Video{
-		ID:          videoID,
-		Title:       req.Title,
-		Description: req.Description,
-		UserID:      req.UserId,
-		Status:      pb.VideoStatus_VIDEO_STATUS_UPLOADING,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
-		Tags:        req.Tags,
-		Visibility:  req.Visibility,
+		ID:            videoID,
+		Title:         req.Title,
+		Description:   description,
+		UserID:        userID,
+		Status:        pb.VideoStatus_VIDEO_STATUS_UPLOADING,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+		Tags:          req.Tags,
+		Visibility:    req.Visibility,
+		FileSizeBytes: req.FileSizeBytes,
+		CallbackURL:   req.CallbackUrl,
 	}
 	
 	// Save initial video metadata
@@ -163,8 +438,19 @@ func (s *Service) InitiateUpload(ctx context.Context, req *pb.InitiateUploadRequ
 		return nil, fmt.Errorf("failed to save video metadata: %w", err)
 	}
 	
-	// Generate upload URL
 	objectKey := s.videoKeyPrefix + videoID
+	
+	// Large files are uploaded in parts under the object store's upload ID
+	if s.useMultipart(req.FileSizeBytes) {
+		resp, err := s.initiateMultipartUpload(ctx, objectKey, req.ContentType, req.FileSizeBytes)
+		if err != nil {
+			return nil, err
+		}
+		resp.VideoId = videoID
+		return resp, nil
+	}
+	
+	// Generate upload URL
 	uploadURL, err := s.fileStorage.GenerateUploadURL(ctx, objectKey, req.ContentType, s.uploadExpiry)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate upload URL: %w", err)
@@ -179,22 +465,69 @@ func (s *Service) InitiateUpload(ctx context.Context, req *pb.InitiateUploadRequ
 
 // CompleteUpload handles the request to finalize a video upload
 func (s *Service) CompleteUpload(ctx context.Context, req *pb.CompleteUploadRequest) (*pb.CompleteUploadResponse, error) {
-	video, err := s.storage.GetVideo(ctx, req.VideoId)
+	video, err := s.findVideo(ctx, req.VideoId)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get video: %w", err)
 	}
 	
-	// Update video status to processing
+	// Multipart uploads only become an object once their parts are assembled
+	if len(req.Parts) > 0 {
+		if err := s.completeMultipartUpload(ctx, s.videoKeyPrefix+video.ID, req.UploadId, req.Parts); err != nil {
+			return nil, err
+		}
+	}
+	
+	size, err := s.checkUploadedObject(ctx, s.videoKeyPrefix+video.ID)
+	if err != nil {
+		return nil, err
+	}
+	
+	// The stored object is authoritative over the size the client declared,
+	// so the quota is checked again now that the real size is known
+	if err := s.checkStorageQuota(ctx, video.UserID, size, video.FileSizeBytes); err != nil {
+		if errors.Is(err, ErrQuotaExceeded) {
+			s.rejectUpload(ctx, video, err)
+		}
+		return nil, err
+	}
+	video.FileSizeBytes = size
+	
+	// Identical uploads reuse the media that was already transcoded
+	if s.dedupeScope != DedupeOff {
+		linked, err := s.linkDuplicate(ctx, video)
+		if err != nil {
+			s.logger.WarnContext(ctx, "Skipping duplicate detection", "video_id", video.ID, "user_id", video.UserID, "error", err)
+		}
+		if linked {
+			return &pb.CompleteUploadResponse{
+				VideoId: req.VideoId,
+				Status:  pb.VideoStatus_VIDEO_STATUS_READY,
+			}, nil
+		}
+	}
+	
+	// Record what was actually uploaded; transcoding reports any real problem
+	objectKey := s.videoKeyPrefix + req.VideoId
+	if info, err := s.transcodingService.GetMediaInfo(ctx, objectKey); err != nil {
+		s.logger.WarnContext(ctx, "Failed to read media info", "video_id", video.ID, "error", err)
+	} else {
+		video.DurationSeconds = info.DurationSeconds
+		video.Resolution = info.Resolution
+	}
+	
+	// Update video status to processing, clearing any earlier failure
 	video.Status = pb.VideoStatus_VIDEO_STATUS_PROCESSING
+	video.StatusReason = ""
 	video.UpdatedAt = time.Now()
 	
 	if err := s.storage.SaveVideo(ctx, video); err != nil {
 		return nil, fmt.Errorf("failed to update video status: %w", err)
 	}
+	s.videoCache.invalidate(video.ID)
 	
 	// Start transcoding process
-	objectKey := s.videoKeyPrefix + req.VideoId
 	if err := s.transcodingService.StartTranscoding(ctx, req.VideoId, objectKey); err != nil {
+		s.failVideo(ctx, video, fmt.Sprintf("the upload could not be processed: %v", err))
 		return nil, fmt.Errorf("failed to start transcoding: %w", err)
 	}
 	
@@ -204,18 +537,128 @@ func (s *Service) CompleteUpload(ctx context.Context, req *pb.CompleteUploadRequ
 	}, nil
 }
 
+// checkUploadedObject makes sure the client really uploaded a non-empty
+// video file before it is sent to transcoding, and returns the file's size
+func (s *Service) checkUploadedObject(ctx context.Context, objectKey string) (int64, error) {
+	size, contentType, err := s.fileStorage.StatObject(ctx, objectKey)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, fmt.Errorf("%w: no file has been uploaded for this video", ErrFailedPrecondition)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to check uploaded file: %w", err)
+	}
+	
+	if size == 0 {
+		return 0, fmt.Errorf("%w: the uploaded file is empty", ErrFailedPrecondition)
+	}
+	if !strings.HasPrefix(contentType, "video/") {
+		return 0, fmt.Errorf("%w: uploaded file has content type %q, not a video", ErrInvalidArgument, contentType)
+	}
+	
+	return size, nil
+}
+
+// rejectUpload deletes an uploaded file that can't be kept and fails its
+// video, which then no longer counts against the owner's quota
+func (s *Service) rejectUpload(ctx context.Context, video *Video, reason error) {
+	if err := s.fileStorage.DeleteFile(ctx, s.videoKeyPrefix+video.ID); err != nil {
+		s.logger.ErrorContext(ctx, "Failed to delete rejected upload", "video_id", video.ID, "user_id", video.UserID, "error", err)
+	}
+	video.FileSizeBytes = 0
+	if err := s.failVideo(ctx, video, fmt.Sprintf("the upload was rejected: %v", reason)); err != nil {
+		s.logger.ErrorContext(ctx, "Failed to fail rejected upload", "video_id", video.ID, "error", err)
+	}
+}
+
+// HandleTranscodingFinished moves a processing video to READY or FAILED once
+// all of its transcoding jobs have finished. It is called whenever a job
+// completes and does nothing while other jobs are still running.
+func (s *Service) HandleTranscodingFinished(ctx context.Context, videoID string) error {
+	video, err := s.storage.GetVideo(ctx, videoID)
+	if err != nil {
+		return fmt.Errorf("failed to get video: %w", err)
+	}
+	
+	if video.Status != pb.VideoStatus_VIDEO_STATUS_PROCESSING {
+		return nil
+	}
+	
+	status, err := s.transcodingService.GetTranscodingStatus(ctx, videoID)
+	if err != nil {
+		return fmt.Errorf("failed to get transcoding status: %w", err)
+	}
+	
+	switch status.Status {
+	case pb.TranscodingStatus_TRANSCODING_STATUS_COMPLETED, pb.TranscodingStatus_TRANSCODING_STATUS_PARTIAL:
+		// Give videos without a thumbnail of their own one from their media
+		if video.ThumbnailURL == "" && video.Resolution != pb.VideoResolution_VIDEO_RESOLUTION_AUDIO_ONLY {
+			if err := s.captureFrame(ctx, video, autoThumbnailOffset(video.DurationSeconds)); err != nil {
+				s.logger.WarnContext(ctx, "Failed to generate thumbnail", "video_id", video.ID, "error", err)
+			}
+		}
+		video.Status = pb.VideoStatus_VIDEO_STATUS_READY
+		video.StatusReason = ""
+		video.UpdatedAt = time.Now()
+		if err := s.storage.SaveVideo(ctx, video); err != nil {
+			return fmt.Errorf("failed to update video status: %w", err)
+		}
+		s.videoCache.invalidate(video.ID)
+		// Partial results still need the recording to be transcoded again
+		if status.Status == pb.TranscodingStatus_TRANSCODING_STATUS_COMPLETED {
+			s.deleteArchivedRecording(ctx, video)
+		}
+	case pb.TranscodingStatus_TRANSCODING_STATUS_FAILED:
+		reason := "transcoding failed"
+		for _, job := range status.Jobs {
+			if job.ErrorMessage != "" {
+				reason = fmt.Sprintf("transcoding failed: %s", job.ErrorMessage)
+				break
+			}
+		}
+		return s.failVideo(ctx, video, reason)
+	}
+	
+	return nil
+}
+
+// failVideo marks a video FAILED with a user-facing reason and notifies its owner
+func (s *Service) failVideo(ctx context.Context, video *Video, reason string) error {
+	video.Status = pb.VideoStatus_VIDEO_STATUS_FAILED
+	video.StatusReason = reason
+	video.UpdatedAt = time.Now()
+	
+	if err := s.storage.SaveVideo(ctx, video); err != nil {
+		return fmt.Errorf("failed to update video status: %w", err)
+	}
+	s.videoCache.invalidate(video.ID)
+	
+	if s.notifier != nil {
+		if err := s.notifier.NotifyVideoFailed(ctx, video.ID, video.UserID, reason); err != nil {
+			s.logger.ErrorContext(ctx, "Failed to notify user about failed video", "video_id", video.ID, "user_id", video.UserID, "error", err)
+		}
+	}
+	
+	return nil
+}
+
 // GetVideo retrieves video metadata
 func (s *Service) GetVideo(ctx context.Context, req *pb.GetVideoRequest) (*pb.Video, error) {
-	video, err := s.storage.GetVideo(ctx, req.VideoId)
+	video, err := s.videoCache.get(ctx, req.VideoId, s.findVideo)
 	if (err != nil) {
 		return nil, fmt.Errorf("failed to get video: %w", err)
 	}
 	
+	// Unlisted videos stay reachable by ID; private ones only by their owner
+	if !canView(video, s.viewerID(ctx, req.UserId)) {
+		return nil, fmt.Errorf("%w: video is private", ErrPermissionDenied)
+	}
+	
 	// Generate download URL for the video if it's ready
 	if video.Status == pb.VideoStatus_VIDEO_STATUS_READY {
-		objectKey := s.videoKeyPrefix + video.ID
+		objectKey := s.sourceKey(video)
 		videoURL, err := s.fileStorage.GenerateDownloadURL(ctx, objectKey, s.downloadExpiry)
-		if err != nil {
+		// Recordings deleted after VOD leave only the transcoded renditions
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
 			return nil, fmt.Errorf("failed to generate download URL: %w", err)
 		}
 		video.VideoURL = videoURL
@@ -224,22 +667,85 @@ func (s *Service) GetVideo(ctx context.Context, req *pb.GetVideoRequest) (*pb.Vi
 	return toProtoVideo(video), nil
 }
 
+// maxVideoBatchSize caps how many videos GetVideos resolves at once
+const maxVideoBatchSize = 100
+
+// GetVideos retrieves several videos in one storage round-trip. Videos that
+// don't exist, are in the trash or are private to another user are left
+// out; the rest keep the request order.
+func (s *Service) GetVideos(ctx context.Context, req *pb.GetVideosRequest) (*pb.GetVideosResponse, error) {
+	if len(req.VideoIds) > maxVideoBatchSize {
+		return nil, fmt.Errorf("%w: at most %d video ids per request", ErrInvalidArgument, maxVideoBatchSize)
+	}
+	
+	// Drop duplicates so each video appears once, at its first position
+	ids := make([]string, 0, len(req.VideoIds))
+	seen := make(map[string]bool, len(req.VideoIds))
+	for _, id := range req.VideoIds {
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return &pb.GetVideosResponse{Videos: []*pb.Video{}}, nil
+	}
+	
+	videos, err := s.storage.GetVideos(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get videos: %w", err)
+	}
+	
+	// Storage may return them in any order
+	viewerID := s.viewerID(ctx, req.UserId)
+	byID := make(map[string]*Video, len(videos))
+	for _, video := range videos {
+		byID[video.ID] = video
+	}
+	protoVideos := make([]*pb.Video, 0, len(videos))
+	for _, id := range ids {
+		video, ok := byID[id]
+		if !ok || video.DeletedAt != nil || !canView(video, viewerID) {
+			continue
+		}
+		protoVideos = append(protoVideos, toProtoVideo(video))
+	}
+	
+	return &pb.GetVideosResponse{
+		Videos: protoVideos,
+	}, nil
+}
+
+// canView reports whether userID may see the video
+func canView(video *Video, userID string) bool {
+	return video.Visibility != pb.VideoVisibility_VIDEO_VISIBILITY_PRIVATE || video.UserID == userID
+}
+
 // ListVideos retrieves a list of videos
 func (s *Service) ListVideos(ctx context.Context, req *pb.ListVideosRequest) (*pb.ListVideosResponse, error) {
 	// Parse pagination
 	limit := int(req.PageSize)
 	if limit <= 0 {
-		limit = 20 // Default page size
+		limit = defaultPageSize
 	}
 	
-	offset := 0
-	if req.PageToken != "" {
-		// In a real implementation, we would decode the page token to get the offset
-		// For simplicity, we're using a simple string here
-		// In production, use a proper pagination token scheme
+	offset, err := decodePageToken(req.PageToken)
+	if err != nil {
+		return nil, err
 	}
 	
-	videos, total, err := s.storage.ListVideos(ctx, req.UserId, limit, offset)
+	// Private videos are only listed for their owner. Storage leaves them out
+	// before paginating, so pages stay full and the total only counts what
+	// the caller may see.
+	filter := ListVideosFilter{
+		UserID:     req.UserId,
+		Status:     req.Status,
+		Visibility: req.Visibility,
+		SortBy:     req.SortBy,
+		ViewerID:   s.viewerID(ctx, req.UserId),
+	}
+	videos, total, err := s.storage.ListVideos(ctx, filter, limit, offset)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list videos: %w", err)
 	}
@@ -249,55 +755,340 @@ func (s *Service) ListVideos(ctx context.Context, req *pb.ListVideosRequest) (*p
 		protoVideos = append(protoVideos, toProtoVideo(video))
 	}
 	
-	nextPageToken := ""
-	if len(videos) == limit {
-		// Generate next page token
-		nextPageToken = fmt.Sprintf("%d", offset+limit)
+	return &pb.ListVideosResponse{
+		Videos:        protoVideos,
+		NextPageToken: nextPageToken(offset, len(videos), total),
+		TotalCount:    int32(total),
+	}, nil
+}
+
+// SearchVideos finds videos whose title, description or tags match the query,
+// best matches first
+func (s *Service) SearchVideos(ctx context.Context, req *pb.SearchVideosRequest) (*pb.ListVideosResponse, error) {
+	query := strings.TrimSpace(req.Query)
+	if query == "" {
+		return nil, fmt.Errorf("%w: search query is required", ErrInvalidArgument)
+	}
+	
+	limit := int(req.PageSize)
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	
+	offset, err := decodePageToken(req.PageToken)
+	if err != nil {
+		return nil, err
+	}
+	
+	videos, total, err := s.storage.SearchVideos(ctx, query, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search videos: %w", err)
+	}
+	
+	// Unlike listing, search only surfaces other users' public videos, so
+	// unlisted ones stay reachable by link alone
+	viewerID := s.viewerID(ctx, req.UserId)
+	protoVideos := make([]*pb.Video, 0, len(videos))
+	for _, video := range videos {
+		if video.Visibility != pb.VideoVisibility_VIDEO_VISIBILITY_PUBLIC && video.UserID != viewerID {
+			continue
+		}
+		protoVideos = append(protoVideos, toProtoVideo(video))
 	}
 	
 	return &pb.ListVideosResponse{
 		Videos:        protoVideos,
-		NextPageToken: nextPageToken,
+		NextPageToken: nextPageToken(offset, len(videos), total),
 		TotalCount:    int32(total),
 	}, nil
 }
 
-// DeleteVideo removes a video
-func (s *Service) DeleteVideo(ctx context.Context, req *pb.DeleteVideoRequest) (*emptypb.Empty, error) {
-	if err := s.storage.DeleteVideo(ctx, req.VideoId, req.UserId); err != nil {
-		return nil, fmt.Errorf("failed to delete video from database: %w", err)
+// UpdateVideo edits a video's metadata, applying only the fields set in the request
+func (s *Service) UpdateVideo(ctx context.Context, req *pb.UpdateVideoRequest) (*pb.Video, error) {
+	userID, err := s.resolveActingUser(ctx, req.UserId)
+	if err != nil {
+		return nil, err
 	}
 	
-	// Delete the video file from storage
-	objectKey := s.videoKeyPrefix + req.VideoId
-	if err := s.fileStorage.DeleteFile(ctx, objectKey); err != nil {
-		// Log the error but don't fail the request
-		fmt.Printf("failed to delete video file from storage: %v", err)
+	video, err := s.findVideo(ctx, req.VideoId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get video: %w", err)
 	}
 	
-	// Delete thumbnail if exists
-	thumbnailKey := s.thumbnailKeyPrefix + req.VideoId
-	if err := s.fileStorage.DeleteFile(ctx, thumbnailKey); err != nil {
-		// Log the error but don't fail the request
-		fmt.Printf("failed to delete thumbnail from storage: %v", err)
+	if video.UserID != userID {
+		return nil, fmt.Errorf("%w: not authorized to update this video", ErrPermissionDenied)
 	}
 	
-	return &emptypb.Empty{}, nil
+	if req.Title != nil {
+		if *req.Title == "" {
+			return nil, fmt.Errorf("%w: title cannot be empty", ErrInvalidArgument)
+		}
+		video.Title = *req.Title
+	}
+	
+	if req.Description != nil {
+		description, err := s.sanitizeDescription(*req.Description)
+		if err != nil {
+			return nil, err
+		}
+		video.Description = description
+	}
+	
+	if req.Tags != nil {
+		video.Tags = req.Tags.Tags
+	}
+	
+	if req.Visibility != nil {
+		switch *req.Visibility {
+		case pb.VideoVisibility_VIDEO_VISIBILITY_PUBLIC,
+			pb.VideoVisibility_VIDEO_VISIBILITY_PRIVATE,
+			pb.VideoVisibility_VIDEO_VISIBILITY_UNLISTED:
+			video.Visibility = *req.Visibility
+		default:
+			return nil, fmt.Errorf("%w: unknown visibility %d", ErrInvalidArgument, *req.Visibility)
+		}
+	}
+	
+	video.UpdatedAt = time.Now()
+	
+	if err := s.storage.SaveVideo(ctx, video); err != nil {
+		return nil, fmt.Errorf("failed to update video: %w", err)
+	}
+	s.videoCache.invalidate(video.ID)
+	
+	return toProtoVideo(video), nil
+}
+
+// IncrementViewCount records a view of a video and returns the updated
+// video. Videos in the trash or private to another user can't be viewed.
+func (s *Service) IncrementViewCount(ctx context.Context, req *pb.IncrementViewCountRequest) (*pb.Video, error) {
+	video, err := s.findVideo(ctx, req.VideoId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get video: %w", err)
+	}
+	
+	if !canView(video, s.viewerID(ctx, "")) {
+		return nil, fmt.Errorf("%w: video is private", ErrPermissionDenied)
+	}
+	
+	viewCount, err := s.storage.IncrementViewCount(ctx, req.VideoId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to increment view count: %w", err)
+	}
+	
+	// Report the count produced by this increment, not a later one. The
+	// response is built from our own copy, never from what storage holds.
+	response := *video
+	response.ViewCount = viewCount
+	
+	return toProtoVideo(&response), nil
+}
+
+// CaptureThumbnail replaces a video's thumbnail with the frame at the requested offset
+func (s *Service) CaptureThumbnail(ctx context.Context, req *pb.CaptureThumbnailRequest) (*pb.Video, error) {
+	userID, err := s.resolveActingUser(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	
+	video, err := s.findVideo(ctx, req.VideoId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get video: %w", err)
+	}
+	
+	if video.UserID != userID {
+		return nil, fmt.Errorf("%w: not authorized to modify this video", ErrPermissionDenied)
+	}
+	
+	if video.Status == pb.VideoStatus_VIDEO_STATUS_UPLOADING {
+		return nil, fmt.Errorf("%w: video upload has not completed", ErrInvalidArgument)
+	}
+	
+	if video.Status == pb.VideoStatus_VIDEO_STATUS_ARCHIVED {
+		return nil, fmt.Errorf("%w: video is archived", ErrFailedPrecondition)
+	}
+	
+	// Videos whose media info couldn't be read at upload are probed now,
+	// so that the timestamp is always checked against a known duration
+	sourceKey := s.sourceKey(video)
+	if video.DurationSeconds <= 0 {
+		info, err := s.transcodingService.GetMediaInfo(ctx, sourceKey)
+		if err != nil {
+			return nil, fmt.Errorf("%w: video duration is unknown: %v", ErrFailedPrecondition, err)
+		}
+		if info.DurationSeconds <= 0 {
+			return nil, fmt.Errorf("%w: video duration is unknown", ErrFailedPrecondition)
+		}
+		video.DurationSeconds = info.DurationSeconds
+	}
+	
+	if req.AtSeconds < 0 || req.AtSeconds >= float64(video.DurationSeconds) {
+		return nil, fmt.Errorf("%w: timestamp %.2fs is outside the video duration", ErrInvalidArgument, req.AtSeconds)
+	}
+	
+	if err := s.captureFrame(ctx, video, req.AtSeconds); err != nil {
+		return nil, err
+	}
+	video.UpdatedAt = time.Now()
+	
+	if err := s.storage.SaveVideo(ctx, video); err != nil {
+		return nil, fmt.Errorf("failed to save video: %w", err)
+	}
+	s.videoCache.invalidate(video.ID)
+	
+	return toProtoVideo(video), nil
+}
+
+// captureFrame stores the source frame at atSeconds as the video's thumbnail
+// and points ThumbnailURL at it. The video itself is not saved.
+func (s *Service) captureFrame(ctx context.Context, video *Video, atSeconds float64) error {
+	thumbnailKey := s.thumbnailKeyPrefix + video.ID
+	if err := s.transcodingService.ExtractThumbnail(ctx, s.sourceKey(video), atSeconds, thumbnailKey); err != nil {
+		return fmt.Errorf("failed to capture thumbnail: %w", err)
+	}
+	
+	thumbnailURL, err := s.fileStorage.GenerateDownloadURL(ctx, thumbnailKey, s.downloadExpiry)
+	if err != nil {
+		return fmt.Errorf("failed to generate thumbnail URL: %w", err)
+	}
+	
+	video.ThumbnailURL = thumbnailURL
+	return nil
+}
+
+// autoThumbnailPosition is how far into a video, as a fraction of its
+// duration, the frame for its automatic thumbnail is taken
+const autoThumbnailPosition = 0.1
+
+// autoThumbnailOffset returns where to take the automatic thumbnail of a
+// video, falling back to the first frame when the offset isn't inside it
+func autoThumbnailOffset(durationSeconds int64) float64 {
+	atSeconds := float64(durationSeconds) * autoThumbnailPosition
+	if atSeconds <= 0 || atSeconds >= float64(durationSeconds) {
+		return 0
+	}
+	return atSeconds
+}
+
+// InitiateThumbnailUpload returns a URL the owner can upload a custom
+// thumbnail image to. SetThumbnail must be called once the upload finishes.
+func (s *Service) InitiateThumbnailUpload(ctx context.Context, req *pb.InitiateThumbnailUploadRequest) (*pb.InitiateThumbnailUploadResponse, error) {
+	userID, err := s.resolveActingUser(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	
+	if err := validateThumbnailContentType(req.ContentType); err != nil {
+		return nil, err
+	}
+	
+	video, err := s.findVideo(ctx, req.VideoId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get video: %w", err)
+	}
+	
+	if video.UserID != userID {
+		return nil, fmt.Errorf("%w: not authorized to modify this video", ErrPermissionDenied)
+	}
+	
+	objectKey := s.thumbnailKeyPrefix + video.ID
+	uploadURL, err := s.fileStorage.GenerateUploadURL(ctx, objectKey, req.ContentType, s.uploadExpiry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate upload URL: %w", err)
+	}
+	
+	return &pb.InitiateThumbnailUploadResponse{
+		VideoId:   video.ID,
+		UploadUrl: uploadURL,
+	}, nil
+}
+
+// SetThumbnail makes the uploaded thumbnail object the video's thumbnail
+func (s *Service) SetThumbnail(ctx context.Context, videoID string, userID string) (*pb.Video, error) {
+	userID, err := s.resolveActingUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	
+	video, err := s.findVideo(ctx, videoID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get video: %w", err)
+	}
+	
+	if video.UserID != userID {
+		return nil, fmt.Errorf("%w: not authorized to modify this video", ErrPermissionDenied)
+	}
+	
+	thumbnailKey := s.thumbnailKeyPrefix + video.ID
+	thumbnailURL, err := s.fileStorage.GenerateDownloadURL(ctx, thumbnailKey, s.downloadExpiry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate thumbnail URL: %w", err)
+	}
+	
+	video.ThumbnailURL = thumbnailURL
+	video.UpdatedAt = time.Now()
+	
+	if err := s.storage.SaveVideo(ctx, video); err != nil {
+		return nil, fmt.Errorf("failed to save video: %w", err)
+	}
+	s.videoCache.invalidate(video.ID)
+	
+	return toProtoVideo(video), nil
+}
+
+// IssuePlaybackCookies returns signed cookies granting access to every HLS
+// segment of a video, so players don't need a signed URL per segment
+func (s *Service) IssuePlaybackCookies(ctx context.Context, videoID string, userID string) ([]*http.Cookie, error) {
+	// Anyone may watch public videos, so this is a read like GetVideo
+	userID = s.viewerID(ctx, userID)
+	
+	if s.cookieSigner == nil {
+		return nil, fmt.Errorf("%w: signed cookies are not configured", ErrFailedPrecondition)
+	}
+	
+	video, err := s.findVideo(ctx, videoID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get video: %w", err)
+	}
+	
+	if !canView(video, userID) {
+		return nil, fmt.Errorf("%w: video is private", ErrPermissionDenied)
+	}
+	
+	if video.Status != pb.VideoStatus_VIDEO_STATUS_READY {
+		return nil, fmt.Errorf("%w: video is not ready for playback", ErrInvalidArgument)
+	}
+	
+	prefix := s.transcodedKeyPrefix + video.mediaID() + "/"
+	cookies, err := s.cookieSigner.SignCookies(ctx, prefix, s.downloadExpiry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign playback cookies: %w", err)
+	}
+	
+	return cookies, nil
 }
 
 // GetStreamKey retrieves or creates a streaming key for a user
 func (s *Service) GetStreamKey(ctx context.Context, req *pb.GetStreamKeyRequest) (*pb.StreamKeyResponse, error) {
+	userID, err := s.resolveActingUser(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	
 	// Try to get existing stream key
-	streamKey, err := s.storage.GetStreamKey(ctx, req.UserId)
+	streamKey, err := s.storage.GetStreamKey(ctx, userID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("failed to get stream key: %w", err)
+	}
 	if err != nil {
 		// Generate a new stream key
-		streamKey, err = s.streamingEngine.GenerateStreamKey(ctx, req.UserId)
+		streamKey, err = s.streamingEngine.GenerateStreamKey(ctx, userID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to generate stream key: %w", err)
 		}
 		
 		// Save the stream key
-		if err := s.storage.SaveStreamKey(ctx, req.UserId, streamKey); err != nil {
+		if err := s.storage.SaveStreamKey(ctx, userID, streamKey); err != nil {
 			return nil, fmt.Errorf("failed to save stream key: %w", err)
 		}
 	}
@@ -308,44 +1099,210 @@ func (s *Service) GetStreamKey(ctx context.Context, req *pb.GetStreamKeyRequest)
 	}, nil
 }
 
+// rotatedStreamsPageSize is how many of a user's live streams RotateStreamKey
+// reads at a time when looking for those started with the old key
+const rotatedStreamsPageSize = 100
+
+// RotateStreamKey replaces a user's stream key with a new one, so a leaked
+// key stops working for StartStream. With EndActiveStreams set, live streams
+// started with the old key are ended too.
+func (s *Service) RotateStreamKey(ctx context.Context, req *pb.RotateStreamKeyRequest) (*pb.StreamKeyResponse, error) {
+	userID, err := s.resolveActingUser(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	
+	// A user who never had a key simply gets their first one
+	oldKey, err := s.storage.GetStreamKey(ctx, userID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("failed to get stream key: %w", err)
+	}
+	
+	streamKey, err := s.streamingEngine.GenerateStreamKey(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate stream key: %w", err)
+	}
+	if err := s.storage.SaveStreamKey(ctx, userID, streamKey); err != nil {
+		return nil, fmt.Errorf("failed to save stream key: %w", err)
+	}
+	
+	if req.EndActiveStreams && oldKey != "" {
+		// Collect every page first, ending streams shifts the later ones
+		var started []*LiveStream
+		for offset := 0; ; offset += rotatedStreamsPageSize {
+			streams, total, err := s.storage.ListLiveStreams(ctx, userID, rotatedStreamsPageSize, offset)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list live streams: %w", err)
+			}
+			for _, stream := range streams {
+				if stream.StreamKey == oldKey {
+					started = append(started, stream)
+				}
+			}
+			if len(streams) == 0 || offset+len(streams) >= total {
+				break
+			}
+		}
+		for _, stream := range started {
+			if _, err := s.EndStream(ctx, &pb.EndStreamRequest{StreamId: stream.StreamID, UserId: userID}); err != nil {
+				return nil, fmt.Errorf("failed to end stream %s: %w", stream.StreamID, err)
+			}
+		}
+	}
+	
+	return &pb.StreamKeyResponse{
+		StreamKey: streamKey,
+		RtmpUrl:   s.streamingEngine.GetRTMPURL(),
+	}, nil
+}
+
 // StartStream begins a new live stream
 func (s *Service) StartStream(ctx context.Context, req *pb.StartStreamRequest) (*pb.StreamResponse, error) {
-	// Verify the stream key
-	existingKey, err := s.storage.GetStreamKey(ctx, req.UserId)
-	if err != nil || existingKey != req.StreamKey {
-		return nil, fmt.Errorf("invalid stream key")
+	// Act as the authenticated user, not whoever the body claims to be
+	userID, err := s.resolveActingUser(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	
+	// Verify the stream key belongs to that user; a failed lookup says
+	// nothing about the key, so it must not be reported as a wrong one
+	existingKey, err := s.storage.GetStreamKey(ctx, userID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("failed to get stream key: %w", err)
+	}
+	if err != nil || req.StreamKey == "" || subtle.ConstantTimeCompare([]byte(existingKey), []byte(req.StreamKey)) != 1 {
+		return nil, ErrInvalidStreamKey
+	}
+	
+	// Publishers push to the ingest path named after their stream key
+	if s.requireActivePublisher && !s.streamingEngine.IsStreamActive(req.StreamKey) {
+		return nil, fmt.Errorf("%w: no active RTMP publisher found for this stream key", ErrFailedPrecondition)
+	}
+	
+	description, err := s.sanitizeDescription(req.Description)
+	if err != nil {
+		return nil, err
 	}
 	
 	streamID := uuid.New().String()
 	
 	liveStream := &LiveStream{
 		StreamID:    streamID,
-		UserID:      req.UserId,
+		UserID:      userID,
 		Title:       req.Title,
-		Description: req.Description,
+		Description: description,
 		PlaybackURL: s.streamingEngine.GetStreamPlaybackURL(streamID),
+		WebRTCPlaybackURL: s.streamingEngine.GetWebRTCPlaybackURL(streamID),
 		StartedAt:   time.Now(),
+		Category:    normalizeStreamCategory(req.Category),
 		Tags:        req.Tags,
 		Status:      pb.StreamStatus_STREAM_STATUS_LIVE,
 		StreamKey:   req.StreamKey,
+		MaxViewers:  s.streamViewerLimit(req.MaxViewers),
 	}
 	
 	if err := s.storage.SaveLiveStream(ctx, liveStream); err != nil {
 		return nil, fmt.Errorf("failed to save live stream: %w", err)
 	}
+	s.metrics.StreamStarted()
 	
 	return &pb.StreamResponse{
 		StreamId:    streamID,
 		PlaybackUrl: liveStream.PlaybackURL,
 		StreamKey:   req.StreamKey,
+		WebrtcPlaybackUrl: liveStream.WebRTCPlaybackURL,
+	}, nil
+}
+
+// streamViewerLimit returns the viewer cap for a new stream, letting the
+// streamer lower but never raise the server-wide cap
+func (s *Service) streamViewerLimit(requested int64) int64 {
+	limit := s.maxViewersPerStream
+	if limit < 0 {
+		limit = 0
+	}
+	if requested > 0 && (limit == 0 || requested < limit) {
+		limit = requested
+	}
+	return limit
+}
+
+// JoinStream admits a viewer to a live stream, counts them in the stream's
+// stored viewer count and returns where to watch it. Viewers that join should
+// call LeaveStream when they go, or the stored count keeps them.
+// Storage checks the stream's cap while counting the viewer, so viewers
+// joining at the same moment can't exceed it.
+func (s *Service) JoinStream(ctx context.Context, req *pb.JoinStreamRequest) (*pb.JoinStreamResponse, error) {
+	stream, err := s.storage.GetLiveStream(ctx, req.StreamId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get live stream: %w", err)
+	}
+	
+	if stream.MaxViewers > 0 && s.liveViewerCount(ctx, stream) >= stream.MaxViewers {
+		return nil, fmt.Errorf("%w: stream allows at most %d viewers", ErrStreamAtCapacity, stream.MaxViewers)
+	}
+	
+	viewerCount, err := s.storage.AdjustViewerCount(ctx, stream.StreamID, 1, stream.MaxViewers)
+	if errors.Is(err, ErrStreamAtCapacity) {
+		return nil, fmt.Errorf("%w: stream allows at most %d viewers", ErrStreamAtCapacity, stream.MaxViewers)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to count viewer: %w", err)
+	}
+	
+	protoStream := s.liveStreamProto(stream)
+	
+	return &pb.JoinStreamResponse{
+		StreamId:          stream.StreamID,
+		PlaybackUrl:       protoStream.PlaybackUrl,
+		WebrtcPlaybackUrl: protoStream.WebrtcPlaybackUrl,
+		ViewerCount:       viewerCount,
+	}, nil
+}
+
+// LeaveStream takes a viewer who joined through JoinStream out of the
+// stream's stored viewer count. Extra leaves never take it below zero.
+func (s *Service) LeaveStream(ctx context.Context, req *pb.LeaveStreamRequest) (*pb.LeaveStreamResponse, error) {
+	viewerCount, err := s.storage.AdjustViewerCount(ctx, req.StreamId, -1, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to uncount viewer: %w", err)
+	}
+	
+	return &pb.LeaveStreamResponse{
+		StreamId:    req.StreamId,
+		ViewerCount: viewerCount,
 	}, nil
 }
 
 // EndStream terminates a live stream
 func (s *Service) EndStream(ctx context.Context, req *pb.EndStreamRequest) (*emptypb.Empty, error) {
-	if err := s.storage.EndLiveStream(ctx, req.StreamId, req.UserId); err != nil {
+	userID, err := s.resolveActingUser(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	
+	// Only the call that actually ends the stream archives and counts it
+	var toArchive *LiveStream
+	stream, err := s.storage.GetLiveStreamByID(ctx, req.StreamId)
+	wasLive := err == nil && stream.Status != pb.StreamStatus_STREAM_STATUS_ENDED
+	if s.autoArchive && wasLive {
+		archived := *stream
+		toArchive = &archived
+	}
+	
+	if err := s.storage.EndLiveStream(ctx, req.StreamId, userID); err != nil {
 		return nil, fmt.Errorf("failed to end live stream: %w", err)
 	}
+	if wasLive {
+		s.metrics.StreamEnded()
+	}
+	
+	// The stream has ended either way; a missing recording only loses the VOD
+	if toArchive != nil {
+		if _, err := s.archiveStream(ctx, toArchive); err != nil {
+			s.logger.ErrorContext(ctx, "Failed to archive stream", "stream_id", toArchive.StreamID, "user_id", toArchive.UserID, "error", err)
+		}
+	}
 	
 	return &emptypb.Empty{}, nil
 }
@@ -355,12 +1312,12 @@ func (s *Service) GetLiveStreams(ctx context.Context, req *pb.GetLiveStreamsRequ
 	// Parse pagination
 	limit := int(req.PageSize)
 	if limit <= 0 {
-		limit = 20 // Default page size
+		limit = defaultPageSize
 	}
 	
-	offset := 0
-	if req.PageToken != "" {
-		// In a real implementation, we would decode the page token to get the offset
+	offset, err := decodePageToken(req.PageToken)
+	if err != nil {
+		return nil, err
 	}
 	
 	streams, total, err := s.storage.ListLiveStreams(ctx, req.UserId, limit, offset)
@@ -368,19 +1325,35 @@ func (s *Service) GetLiveStreams(ctx context.Context, req *pb.GetLiveStreamsRequ
 		return nil, fmt.Errorf("failed to list live streams: %w", err)
 	}
 	
-	protoStreams := make([]*pb.LiveStream, 0, len(streams))
-	for _, stream := range streams {
-		protoStreams = append(protoStreams, toLiveStreamProto(stream))
+	return &pb.GetLiveStreamsResponse{
+		Streams:       s.liveStreamProtos(ctx, streams),
+		NextPageToken: nextPageToken(offset, len(streams), total),
+		TotalCount:    int32(total),
+	}, nil
+}
+
+// GetLiveStreamsByCategory retrieves active live streams in a category,
+// matched case-insensitively. An empty category browses all of them.
+func (s *Service) GetLiveStreamsByCategory(ctx context.Context, req *pb.GetLiveStreamsByCategoryRequest) (*pb.GetLiveStreamsResponse, error) {
+	limit := int(req.PageSize)
+	if limit <= 0 {
+		limit = defaultPageSize
 	}
 	
-	nextPageToken := ""
-	if len(streams) == limit {
-		nextPageToken = fmt.Sprintf("%d", offset+limit)
+	offset, err := decodePageToken(req.PageToken)
+	if err != nil {
+		return nil, err
+	}
+	
+	category := normalizeStreamCategory(req.Category)
+	streams, total, err := s.storage.ListLiveStreamsByCategory(ctx, category, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list live streams in category: %w", err)
 	}
 	
 	return &pb.GetLiveStreamsResponse{
-		Streams:       protoStreams,
-		NextPageToken: nextPageToken,
+		Streams:       s.liveStreamProtos(ctx, streams),
+		NextPageToken: nextPageToken(offset, len(streams), total),
 		TotalCount:    int32(total),
 	}, nil
 }
@@ -411,22 +1384,162 @@ func (s *Service) GetTranscodingStatus(ctx context.Context, req *pb.GetTranscodi
 	}, nil
 }
 
+// WatchTranscodingStatus sends the transcoding status of a video and then
+// the latest status after every change, returning once no job is left to
+// finish or the client goes away
+func (s *Service) WatchTranscodingStatus(req *pb.GetTranscodingStatusRequest, stream pb.VideoService_WatchTranscodingStatusServer) error {
+	ctx := stream.Context()
+	
+	// Subscribe before the first read so no change in between is missed
+	changed, unsubscribe := s.transcodingService.SubscribeTranscodingStatus(req.VideoId)
+	defer unsubscribe()
+	
+	for {
+		status, err := s.GetTranscodingStatus(ctx, req)
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(status); err != nil {
+			return err
+		}
+		if transcodingFinished(status.Status) {
+			return nil
+		}
+		
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// transcodingFinished reports whether an overall transcoding status can no longer change
+func transcodingFinished(status pb.TranscodingStatus) bool {
+	switch status {
+	case pb.TranscodingStatus_TRANSCODING_STATUS_COMPLETED,
+		pb.TranscodingStatus_TRANSCODING_STATUS_FAILED,
+		pb.TranscodingStatus_TRANSCODING_STATUS_PARTIAL,
+		pb.TranscodingStatus_TRANSCODING_STATUS_NOT_FOUND:
+		return true
+	default:
+		return false
+	}
+}
+
 // GetStream retrieves a specific live stream
 func (s *Service) GetStream(ctx context.Context, req *pb.GetStreamRequest) (*pb.GetStreamResponse, error) {
-	stream, err := s.storage.GetLiveStream(ctx, req.StreamId)
+	// Ended streams are still shown so their history can be viewed
+	stream, err := s.storage.GetLiveStreamByID(ctx, req.StreamId)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get live stream: %w", err)
 	}
 	
 	if stream == nil {
-		return nil, fmt.Errorf("stream not found")
+		return nil, ErrStreamNotFound
 	}
 	
+	protoStream := s.liveStreamProto(stream)
+	protoStream.ViewerCount = s.liveViewerCount(ctx, stream)
+	
 	return &pb.GetStreamResponse{
-		Stream: toLiveStreamProto(stream),
+		Stream: protoStream,
 	}, nil
 }
 
+// maxStreamBatchSize caps how many streams GetStreamsByIDs resolves at once
+const maxStreamBatchSize = 100
+
+// GetStreamsByIDs retrieves several live streams in one storage round-trip.
+// Streams that don't exist are left out; the rest keep the requested order.
+func (s *Service) GetStreamsByIDs(ctx context.Context, req *pb.GetStreamsByIDsRequest) (*pb.GetStreamsByIDsResponse, error) {
+	if len(req.StreamIds) > maxStreamBatchSize {
+		return nil, fmt.Errorf("%w: at most %d stream ids per request", ErrInvalidArgument, maxStreamBatchSize)
+	}
+	
+	// Drop duplicates so each stream appears once, at its first position
+	ids := make([]string, 0, len(req.StreamIds))
+	seen := make(map[string]bool, len(req.StreamIds))
+	for _, id := range req.StreamIds {
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return &pb.GetStreamsByIDsResponse{Streams: []*pb.LiveStream{}}, nil
+	}
+	
+	streams, err := s.storage.GetLiveStreamsByIDs(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get live streams: %w", err)
+	}
+	
+	// Storage may return them in any order
+	byID := make(map[string]*LiveStream, len(streams))
+	for _, stream := range streams {
+		byID[stream.StreamID] = stream
+	}
+	ordered := make([]*LiveStream, 0, len(streams))
+	for _, id := range ids {
+		if stream, ok := byID[id]; ok {
+			ordered = append(ordered, stream)
+		}
+	}
+	
+	return &pb.GetStreamsByIDsResponse{
+		Streams: s.liveStreamProtos(ctx, ordered),
+	}, nil
+}
+
+// liveStreamProtos converts streams to proto with their live viewer counts
+func (s *Service) liveStreamProtos(ctx context.Context, streams []*LiveStream) []*pb.LiveStream {
+	protoStreams := make([]*pb.LiveStream, len(streams))
+	var wg sync.WaitGroup
+	for i, stream := range streams {
+		protoStreams[i] = s.liveStreamProto(stream)
+		
+		// Ask the streaming engine for every stream at once
+		wg.Add(1)
+		go func(protoStream *pb.LiveStream, stream *LiveStream) {
+			defer wg.Done()
+			protoStream.ViewerCount = s.liveViewerCount(ctx, stream)
+		}(protoStreams[i], stream)
+	}
+	wg.Wait()
+	
+	return protoStreams
+}
+
+// liveStreamProto converts a stream to proto, filling in the WebRTC URL for
+// streams saved before it was stored
+func (s *Service) liveStreamProto(stream *LiveStream) *pb.LiveStream {
+	protoStream := toLiveStreamProto(stream)
+	if protoStream.WebrtcPlaybackUrl == "" {
+		protoStream.WebrtcPlaybackUrl = s.streamingEngine.GetWebRTCPlaybackURL(stream.StreamID)
+	}
+	return protoStream
+}
+
+// liveViewerCount returns the current audience of a live stream: the larger
+// of the streaming engine's reader count and the stored count of viewers
+// that joined through JoinStream. Each misses some viewers, e.g. the engine
+// those watching through a CDN, so the larger is the better estimate.
+func (s *Service) liveViewerCount(ctx context.Context, stream *LiveStream) int64 {
+	if stream.Status != pb.StreamStatus_STREAM_STATUS_LIVE {
+		return stream.ViewerCount
+	}
+	
+	count, err := s.streamingEngine.GetViewerCount(ctx, stream.StreamID)
+	if err != nil {
+		s.logger.WarnContext(ctx, "Failed to get viewer count", "stream_id", stream.StreamID, "error", err)
+		return stream.ViewerCount
+	}
+	
+	return max(count, stream.ViewerCount)
+}
+
 // Helper function to convert internal Video type to proto
 func toProtoVideo(v *Video) *pb.Video {
 	return &pb.Video{
@@ -439,17 +1552,19 @@ func toProtoVideo(v *Video) *pb.Video {
 		DurationSeconds: v.DurationSeconds,
 		ViewCount:      v.ViewCount,
 		Status:         v.Status,
+		StatusReason:   v.StatusReason,
 		CreatedAt:      timestamppb.New(v.CreatedAt),
 		UpdatedAt:      timestamppb.New(v.UpdatedAt),
 		Tags:           v.Tags,
 		Visibility:     v.Visibility,
 		Resolution:     v.Resolution,
+		FileSizeBytes:  v.FileSizeBytes,
 	}
 }
 
 // Helper method to convert LiveStream to proto
 func toLiveStreamProto(stream *LiveStream) *pb.LiveStream {
-	return &pb.LiveStream{
+	protoStream := &pb.LiveStream{
 		StreamId:     stream.StreamID,
 		UserId:       stream.UserID,
 		Title:        stream.Title,
@@ -459,8 +1574,15 @@ func toLiveStreamProto(stream *LiveStream) *pb.LiveStream {
 		ViewerCount:  stream.ViewerCount,
 		StartedAt:    timestamppb.New(stream.StartedAt),
 		Tags:         stream.Tags,
-		StreamKey:    stream.StreamKey, // Include the stream key in the API response
+		WebrtcPlaybackUrl: stream.WebRTCPlaybackURL,
+		Status:       stream.Status,
+		MaxViewers:   stream.MaxViewers,
+		Category:     stream.Category,
 	}
+	if stream.EndedAt != nil {
+		protoStream.EndedAt = timestamppb.New(*stream.EndedAt)
+	}
+	return protoStream
 }
 
 // CreateStreamWithKey creates a new stream with a specified key
@@ -473,6 +1595,11 @@ func (s *Service) CreateStreamWithKey(
 	tags []string, 
 	streamKey string,
 ) (*LiveStream, error) {
+	description, err := s.sanitizeDescription(description)
+	if err != nil {
+		return nil, err
+	}
+	
 	// Generate a stream ID
 	streamID := uuid.New().String()
 	
@@ -482,7 +1609,7 @@ func (s *Service) CreateStreamWithKey(
 		UserID:      userID,
 		Title:       title,
 		Description: description,
-		Category:    category,
+		Category:    normalizeStreamCategory(category),
 		Tags:        tags,
 		Status:      pb.StreamStatus_STREAM_STATUS_CREATED,
 		StreamKey:   streamKey,
@@ -498,7 +1625,7 @@ func (s *Service) CreateStreamWithKey(
 
 // GetStreamByID retrieves a stream by ID
 func (s *Service) GetStreamByID(ctx context.Context, streamID string) (*LiveStream, error) {
-	return s.storage.GetLiveStream(ctx, streamID)
+	return s.storage.GetLiveStreamByID(ctx, streamID)
 }
 
 // ListAllActiveStreams returns all currently active streams
@@ -0,0 +1,377 @@
+package video_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"videostreaming/internal/service/video"
+	"videostreaming/internal/storage/memory"
+	pb "videostreaming/proto/video"
+)
+
+// newTestService returns a service over fresh in-memory storage, holding
+// the given videos
+func newTestService(t *testing.T, videos ...*video.Video) (*video.Service, *memory.VideoStorage) {
+	t.Helper()
+
+	storage := memory.NewVideoStorage()
+	for _, v := range videos {
+		if err := storage.SaveVideo(context.Background(), v); err != nil {
+			t.Fatalf("SaveVideo(%s): %v", v.ID, err)
+		}
+	}
+	return video.NewService(storage, nil, nil, nil, video.WithVideoCacheTTL(0)), storage
+}
+
+func testVideo(id, userID string, visibility pb.VideoVisibility) *video.Video {
+	return &video.Video{
+		ID:         id,
+		Title:      "Video " + id,
+		UserID:     userID,
+		Status:     pb.VideoStatus_VIDEO_STATUS_READY,
+		Visibility: visibility,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	}
+}
+
+func TestIncrementViewCountConcurrent(t *testing.T) {
+	svc, storage := newTestService(t, testVideo("v1", "owner", pb.VideoVisibility_VIDEO_VISIBILITY_PUBLIC))
+
+	const views = 100
+	counts := make(chan int64, views)
+	var wg sync.WaitGroup
+	for i := 0; i < views; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := svc.IncrementViewCount(context.Background(), &pb.IncrementViewCountRequest{VideoId: "v1"})
+			if err != nil {
+				t.Errorf("IncrementViewCount: %v", err)
+				return
+			}
+			counts <- v.ViewCount
+		}()
+	}
+	wg.Wait()
+	close(counts)
+
+	// Every increment reports the count it produced, so none repeat
+	seen := make(map[int64]bool, views)
+	for count := range counts {
+		if count < 1 || count > views || seen[count] {
+			t.Errorf("unexpected or repeated view count %d", count)
+		}
+		seen[count] = true
+	}
+
+	stored, err := storage.GetVideo(context.Background(), "v1")
+	if err != nil {
+		t.Fatalf("GetVideo: %v", err)
+	}
+	if stored.ViewCount != views {
+		t.Errorf("stored view count = %d, want %d", stored.ViewCount, views)
+	}
+}
+
+func TestIncrementViewCountHiddenVideos(t *testing.T) {
+	deletedAt := time.Now()
+	trashed := testVideo("trashed", "owner", pb.VideoVisibility_VIDEO_VISIBILITY_PUBLIC)
+	trashed.DeletedAt = &deletedAt
+	svc, storage := newTestService(t,
+		testVideo("private", "owner", pb.VideoVisibility_VIDEO_VISIBILITY_PRIVATE),
+		trashed,
+	)
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		videoID string
+		wantErr error
+	}{
+		{"private, anonymous", context.Background(), "private", video.ErrPermissionDenied},
+		{"private, other user", video.WithAuthenticatedUser(context.Background(), "someone"), "private", video.ErrPermissionDenied},
+		{"trashed", video.WithAuthenticatedUser(context.Background(), "owner"), "trashed", video.ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.IncrementViewCount(tt.ctx, &pb.IncrementViewCountRequest{VideoId: tt.videoID})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("IncrementViewCount error = %v, want %v", err, tt.wantErr)
+			}
+			stored, _ := storage.GetVideo(context.Background(), tt.videoID)
+			if stored.ViewCount != 0 {
+				t.Errorf("view count = %d, want it left at 0", stored.ViewCount)
+			}
+		})
+	}
+
+	// The owner may view their own private video
+	ctx := video.WithAuthenticatedUser(context.Background(), "owner")
+	v, err := svc.IncrementViewCount(ctx, &pb.IncrementViewCountRequest{VideoId: "private"})
+	if err != nil {
+		t.Fatalf("IncrementViewCount as owner: %v", err)
+	}
+	if v.ViewCount != 1 {
+		t.Errorf("view count = %d, want 1", v.ViewCount)
+	}
+}
+
+func TestUpdateVideoKeepsConcurrentViews(t *testing.T) {
+	svc, storage := newTestService(t, testVideo("v1", "owner", pb.VideoVisibility_VIDEO_VISIBILITY_PUBLIC))
+	ctx := video.WithAuthenticatedUser(context.Background(), "owner")
+
+	const views = 50
+	var wg sync.WaitGroup
+	for i := 0; i < views; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := svc.IncrementViewCount(ctx, &pb.IncrementViewCountRequest{VideoId: "v1"}); err != nil {
+				t.Errorf("IncrementViewCount: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			title := "Renamed"
+			if _, err := svc.UpdateVideo(ctx, &pb.UpdateVideoRequest{VideoId: "v1", Title: &title}); err != nil {
+				t.Errorf("UpdateVideo: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	stored, err := storage.GetVideo(context.Background(), "v1")
+	if err != nil {
+		t.Fatalf("GetVideo: %v", err)
+	}
+	if stored.ViewCount != views {
+		t.Errorf("view count = %d after concurrent updates, want %d", stored.ViewCount, views)
+	}
+}
+
+func TestRequireAuthentication(t *testing.T) {
+	storage := memory.NewVideoStorage()
+	if err := storage.SaveVideo(context.Background(), testVideo("v1", "owner", pb.VideoVisibility_VIDEO_VISIBILITY_PUBLIC)); err != nil {
+		t.Fatalf("SaveVideo: %v", err)
+	}
+	svc := video.NewService(storage, nil, nil, nil, video.WithRequireAuthentication(true))
+	title := "Hijacked"
+
+	// Naming the owner in the body is no longer enough
+	_, err := svc.UpdateVideo(context.Background(), &pb.UpdateVideoRequest{VideoId: "v1", UserId: "owner", Title: &title})
+	if !errors.Is(err, video.ErrUnauthenticated) {
+		t.Errorf("anonymous UpdateVideo error = %v, want %v", err, video.ErrUnauthenticated)
+	}
+
+	ctx := video.WithAuthenticatedUser(context.Background(), "intruder")
+	_, err = svc.UpdateVideo(ctx, &pb.UpdateVideoRequest{VideoId: "v1", UserId: "owner", Title: &title})
+	if !errors.Is(err, video.ErrPermissionDenied) {
+		t.Errorf("UpdateVideo as another user error = %v, want %v", err, video.ErrPermissionDenied)
+	}
+
+	ctx = video.WithAuthenticatedUser(context.Background(), "owner")
+	if _, err := svc.UpdateVideo(ctx, &pb.UpdateVideoRequest{VideoId: "v1", Title: &title}); err != nil {
+		t.Errorf("UpdateVideo as owner: %v", err)
+	}
+}
+
+func TestPrivateVideoVisibility(t *testing.T) {
+	// Not READY, so reads don't need file storage for a download URL
+	private := testVideo("private", "owner", pb.VideoVisibility_VIDEO_VISIBILITY_PRIVATE)
+	private.Status = pb.VideoStatus_VIDEO_STATUS_PROCESSING
+	private.Title = "secret plans"
+
+	storage := memory.NewVideoStorage()
+	if err := storage.SaveVideo(context.Background(), private); err != nil {
+		t.Fatalf("SaveVideo: %v", err)
+	}
+	svc := video.NewService(storage, nil, nil, nil, video.WithRequireAuthentication(true), video.WithVideoCacheTTL(0))
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		wantSeen bool
+	}{
+		{"owner", video.WithAuthenticatedUser(context.Background(), "owner"), true},
+		{"other user claiming to be the owner", video.WithAuthenticatedUser(context.Background(), "intruder"), false},
+		{"anonymous caller claiming to be the owner", context.Background(), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Every request names the owner; only the identity may count
+			_, err := svc.GetVideo(tt.ctx, &pb.GetVideoRequest{VideoId: "private", UserId: "owner"})
+			if seen := err == nil; seen != tt.wantSeen {
+				t.Errorf("GetVideo error = %v, want seen = %v", err, tt.wantSeen)
+			}
+			if !tt.wantSeen && !errors.Is(err, video.ErrPermissionDenied) {
+				t.Errorf("GetVideo error = %v, want %v", err, video.ErrPermissionDenied)
+			}
+
+			batch, err := svc.GetVideos(tt.ctx, &pb.GetVideosRequest{VideoIds: []string{"private"}, UserId: "owner"})
+			if err != nil {
+				t.Fatalf("GetVideos: %v", err)
+			}
+			if seen := len(batch.Videos) == 1; seen != tt.wantSeen {
+				t.Errorf("GetVideos returned %d videos, want seen = %v", len(batch.Videos), tt.wantSeen)
+			}
+
+			list, err := svc.ListVideos(tt.ctx, &pb.ListVideosRequest{UserId: "owner"})
+			if err != nil {
+				t.Fatalf("ListVideos: %v", err)
+			}
+			if seen := len(list.Videos) == 1; seen != tt.wantSeen {
+				t.Errorf("ListVideos returned %d videos, want seen = %v", len(list.Videos), tt.wantSeen)
+			}
+
+			search, err := svc.SearchVideos(tt.ctx, &pb.SearchVideosRequest{Query: "secret", UserId: "owner"})
+			if err != nil {
+				t.Fatalf("SearchVideos: %v", err)
+			}
+			if seen := len(search.Videos) == 1; seen != tt.wantSeen {
+				t.Errorf("SearchVideos returned %d videos, want seen = %v", len(search.Videos), tt.wantSeen)
+			}
+		})
+	}
+}
+
+func TestListVideosSkipsPrivateBeforePaging(t *testing.T) {
+	base := time.Now()
+	var videos []*video.Video
+	for i, visibility := range []pb.VideoVisibility{
+		pb.VideoVisibility_VIDEO_VISIBILITY_PUBLIC,
+		pb.VideoVisibility_VIDEO_VISIBILITY_UNLISTED,
+		pb.VideoVisibility_VIDEO_VISIBILITY_PRIVATE,
+		pb.VideoVisibility_VIDEO_VISIBILITY_PRIVATE,
+		pb.VideoVisibility_VIDEO_VISIBILITY_PRIVATE,
+	} {
+		v := testVideo(fmt.Sprintf("v%d", i), "owner", visibility)
+		v.CreatedAt = base.Add(time.Duration(i) * time.Minute)
+		videos = append(videos, v)
+	}
+	svc, _ := newTestService(t, videos...)
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		wantTotal int32
+		wantFirst []string
+	}{
+		// The newest videos are private, yet the first page is still full
+		{"other user", video.WithAuthenticatedUser(context.Background(), "someone"), 2, []string{"v1", "v0"}},
+		{"owner", video.WithAuthenticatedUser(context.Background(), "owner"), 5, []string{"v4", "v3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := svc.ListVideos(tt.ctx, &pb.ListVideosRequest{UserId: "owner", PageSize: 2})
+			if err != nil {
+				t.Fatalf("ListVideos: %v", err)
+			}
+			var ids []string
+			for _, v := range resp.Videos {
+				ids = append(ids, v.Id)
+			}
+			if resp.TotalCount != tt.wantTotal || !slices.Equal(ids, tt.wantFirst) {
+				t.Errorf("ListVideos = %v of %d, want %v of %d", ids, resp.TotalCount, tt.wantFirst, tt.wantTotal)
+			}
+			if wantMore := tt.wantTotal > 2; (resp.NextPageToken != "") != wantMore {
+				t.Errorf("NextPageToken = %q, want one = %v", resp.NextPageToken, wantMore)
+			}
+		})
+	}
+}
+
+func TestJoinStreamCapConcurrent(t *testing.T) {
+	storage := memory.NewVideoStorage()
+	err := storage.SaveLiveStream(context.Background(), &video.LiveStream{
+		StreamID:   "s1",
+		UserID:     "owner",
+		Status:     pb.StreamStatus_STREAM_STATUS_LIVE,
+		StartedAt:  time.Now(),
+		MaxViewers: 5,
+	})
+	if err != nil {
+		t.Fatalf("SaveLiveStream: %v", err)
+	}
+	svc := video.NewService(storage, nil, nil, &fakeStreamingEngine{})
+
+	const viewers = 50
+	var admitted, turnedAway atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < viewers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := svc.JoinStream(context.Background(), &pb.JoinStreamRequest{StreamId: "s1"})
+			switch {
+			case err == nil:
+				admitted.Add(1)
+			case errors.Is(err, video.ErrStreamAtCapacity):
+				turnedAway.Add(1)
+			default:
+				t.Errorf("JoinStream: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Viewers joining at the same moment never take the stream past its cap
+	if admitted.Load() != 5 || turnedAway.Load() != viewers-5 {
+		t.Errorf("admitted %d and turned away %d viewers, want 5 and %d", admitted.Load(), turnedAway.Load(), viewers-5)
+	}
+
+	// A viewer leaving makes room for another
+	if _, err := svc.LeaveStream(context.Background(), &pb.LeaveStreamRequest{StreamId: "s1"}); err != nil {
+		t.Fatalf("LeaveStream: %v", err)
+	}
+	resp, err := svc.JoinStream(context.Background(), &pb.JoinStreamRequest{StreamId: "s1"})
+	if err != nil {
+		t.Fatalf("JoinStream after a viewer left: %v", err)
+	}
+	if resp.ViewerCount != 5 {
+		t.Errorf("viewer count = %d, want 5", resp.ViewerCount)
+	}
+}
+
+func TestGetVideos(t *testing.T) {
+	public := pb.VideoVisibility_VIDEO_VISIBILITY_PUBLIC
+	trashed := testVideo("trashed", "owner", public)
+	deletedAt := time.Now()
+	trashed.DeletedAt = &deletedAt
+	svc, _ := newTestService(t, testVideo("v1", "owner", public), testVideo("v2", "owner", public), testVideo("v3", "other", public), trashed)
+
+	// Missing and trashed videos are skipped, duplicates returned once,
+	// and the rest keep the requested order
+	resp, err := svc.GetVideos(context.Background(), &pb.GetVideosRequest{
+		VideoIds: []string{"v3", "missing", "v1", "trashed", "v3", "", "v2"},
+	})
+	if err != nil {
+		t.Fatalf("GetVideos: %v", err)
+	}
+	var got []string
+	for _, v := range resp.Videos {
+		got = append(got, v.Id)
+	}
+	if want := []string{"v3", "v1", "v2"}; !slices.Equal(got, want) {
+		t.Errorf("GetVideos = %v, want %v", got, want)
+	}
+
+	resp, err = svc.GetVideos(context.Background(), &pb.GetVideosRequest{})
+	if err != nil || len(resp.Videos) != 0 {
+		t.Errorf("GetVideos without IDs = %v, %v; want no videos", resp, err)
+	}
+
+	tooMany := make([]string, 101)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("v%d", i)
+	}
+	if _, err := svc.GetVideos(context.Background(), &pb.GetVideosRequest{VideoIds: tooMany}); !errors.Is(err, video.ErrInvalidArgument) {
+		t.Errorf("GetVideos of %d IDs error = %v, want %v", len(tooMany), err, video.ErrInvalidArgument)
+	}
+}
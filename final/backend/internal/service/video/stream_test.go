@@ -0,0 +1,375 @@
+package video_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"videostreaming/internal/service/video"
+	"videostreaming/internal/storage/memory"
+	pb "videostreaming/proto/video"
+)
+
+// saveLiveStreams stores count live streams of userID started with streamKey
+func saveLiveStreams(t *testing.T, storage video.Storage, userID, streamKey string, count int) {
+	t.Helper()
+
+	for i := 0; i < count; i++ {
+		err := storage.SaveLiveStream(context.Background(), &video.LiveStream{
+			StreamID:  fmt.Sprintf("%s-%s-%d", userID, streamKey, i),
+			UserID:    userID,
+			Title:     fmt.Sprintf("Stream %d", i),
+			StreamKey: streamKey,
+			Status:    pb.StreamStatus_STREAM_STATUS_LIVE,
+			StartedAt: time.Now(),
+		})
+		if err != nil {
+			t.Fatalf("SaveLiveStream: %v", err)
+		}
+	}
+}
+
+func TestRotateStreamKeyEndsEveryStream(t *testing.T) {
+	storage := memory.NewVideoStorage()
+	if err := storage.SaveStreamKey(context.Background(), "owner", "old-key"); err != nil {
+		t.Fatalf("SaveStreamKey: %v", err)
+	}
+	// More streams than fit in one page, plus some the rotation leaves alone
+	saveLiveStreams(t, storage, "owner", "old-key", 250)
+	saveLiveStreams(t, storage, "owner", "other-key", 3)
+	svc := video.NewService(storage, nil, nil, &fakeStreamingEngine{})
+
+	resp, err := svc.RotateStreamKey(context.Background(), &pb.RotateStreamKeyRequest{UserId: "owner", EndActiveStreams: true})
+	if err != nil {
+		t.Fatalf("RotateStreamKey: %v", err)
+	}
+	if resp.StreamKey != "key-owner" {
+		t.Errorf("stream key = %q, want the newly generated one", resp.StreamKey)
+	}
+
+	streams, total, err := storage.ListLiveStreams(context.Background(), "owner", 1000, 0)
+	if err != nil {
+		t.Fatalf("ListLiveStreams: %v", err)
+	}
+	if total != 3 {
+		t.Errorf("%d streams still live, want only the 3 started with another key", total)
+	}
+	for _, stream := range streams {
+		if stream.StreamKey == "old-key" {
+			t.Errorf("stream %s started with the old key is still live", stream.StreamID)
+		}
+	}
+}
+
+// failingKeyStorage fails every stream key lookup
+type failingKeyStorage struct {
+	*memory.VideoStorage
+	saved int
+}
+
+func (s *failingKeyStorage) GetStreamKey(ctx context.Context, userID string) (string, error) {
+	return "", errors.New("connection refused")
+}
+
+func (s *failingKeyStorage) SaveStreamKey(ctx context.Context, userID string, streamKey string) error {
+	s.saved++
+	return s.VideoStorage.SaveStreamKey(ctx, userID, streamKey)
+}
+
+func TestGetStreamKey(t *testing.T) {
+	storage := memory.NewVideoStorage()
+	svc := video.NewService(storage, nil, nil, &fakeStreamingEngine{})
+
+	// A user without a key gets one, and the same one after that
+	for i := 0; i < 2; i++ {
+		resp, err := svc.GetStreamKey(context.Background(), &pb.GetStreamKeyRequest{UserId: "owner"})
+		if err != nil {
+			t.Fatalf("GetStreamKey: %v", err)
+		}
+		if resp.StreamKey != "key-owner" {
+			t.Errorf("stream key = %q, want key-owner", resp.StreamKey)
+		}
+	}
+
+	// A storage failure must not replace the key the user streams with
+	failing := &failingKeyStorage{VideoStorage: storage}
+	svc = video.NewService(failing, nil, nil, &fakeStreamingEngine{})
+	if _, err := svc.GetStreamKey(context.Background(), &pb.GetStreamKeyRequest{UserId: "owner"}); err == nil {
+		t.Errorf("GetStreamKey succeeded while storage is failing")
+	}
+	if _, err := svc.RotateStreamKey(context.Background(), &pb.RotateStreamKeyRequest{UserId: "owner", EndActiveStreams: true}); err == nil {
+		t.Errorf("RotateStreamKey succeeded without knowing which streams to end")
+	}
+	if failing.saved != 0 {
+		t.Errorf("saved %d new stream keys while storage is failing, want none", failing.saved)
+	}
+}
+
+func TestGetEndedStream(t *testing.T) {
+	storage := memory.NewVideoStorage()
+	saveLiveStreams(t, storage, "owner", "key", 1)
+	svc := video.NewService(storage, nil, nil, &fakeStreamingEngine{})
+
+	if _, err := svc.EndStream(context.Background(), &pb.EndStreamRequest{StreamId: "owner-key-0", UserId: "owner"}); err != nil {
+		t.Fatalf("EndStream: %v", err)
+	}
+
+	resp, err := svc.GetStream(context.Background(), &pb.GetStreamRequest{StreamId: "owner-key-0"})
+	if err != nil {
+		t.Fatalf("GetStream: %v", err)
+	}
+	if resp.Stream.Status != pb.StreamStatus_STREAM_STATUS_ENDED || resp.Stream.EndedAt == nil {
+		t.Errorf("GetStream = %v ended at %v, want %v with an end time", resp.Stream.Status, resp.Stream.EndedAt, pb.StreamStatus_STREAM_STATUS_ENDED)
+	}
+	if resp.Stream.Title == "" || resp.Stream.StartedAt == nil {
+		t.Errorf("GetStream = %+v, want the stream's history kept", resp.Stream)
+	}
+
+	stream, err := svc.GetStreamByID(context.Background(), "owner-key-0")
+	if err != nil {
+		t.Fatalf("GetStreamByID: %v", err)
+	}
+	if stream.Status != pb.StreamStatus_STREAM_STATUS_ENDED {
+		t.Errorf("GetStreamByID status = %v, want %v", stream.Status, pb.StreamStatus_STREAM_STATUS_ENDED)
+	}
+}
+
+// viewersEngine reports a fixed reader count per stream
+type viewersEngine struct {
+	fakeStreamingEngine
+	readers map[string]int64
+}
+
+func (e *viewersEngine) GetViewerCount(ctx context.Context, streamID string) (int64, error) {
+	return e.readers[streamID], nil
+}
+
+func TestLiveStreamsReportEngineViewers(t *testing.T) {
+	storage := memory.NewVideoStorage()
+	saveLiveStreams(t, storage, "owner", "key", 2)
+	engine := &viewersEngine{readers: map[string]int64{"owner-key-0": 7}}
+	svc := video.NewService(storage, nil, nil, engine)
+
+	resp, err := svc.GetLiveStreams(context.Background(), &pb.GetLiveStreamsRequest{UserId: "owner"})
+	if err != nil {
+		t.Fatalf("GetLiveStreams: %v", err)
+	}
+	counts := make(map[string]int64)
+	for _, stream := range resp.Streams {
+		counts[stream.StreamId] = stream.ViewerCount
+	}
+	if counts["owner-key-0"] != 7 || counts["owner-key-1"] != 0 {
+		t.Errorf("viewer counts = %v, want 7 and 0 from the streaming engine", counts)
+	}
+
+	got, err := svc.GetStream(context.Background(), &pb.GetStreamRequest{StreamId: "owner-key-0"})
+	if err != nil {
+		t.Fatalf("GetStream: %v", err)
+	}
+	if got.Stream.ViewerCount != 7 {
+		t.Errorf("GetStream viewer count = %d, want 7", got.Stream.ViewerCount)
+	}
+}
+
+// publisherEngine reports a publisher on the listed stream keys only
+type publisherEngine struct {
+	fakeStreamingEngine
+	publishing map[string]bool
+}
+
+func (e *publisherEngine) IsStreamActive(streamID string) bool {
+	return e.publishing[streamID]
+}
+
+func TestStartStreamRequiresActivePublisher(t *testing.T) {
+	tests := []struct {
+		name       string
+		require    bool
+		publishing bool
+		wantErr    error
+	}{
+		{"publisher connected", true, true, nil},
+		{"no publisher", true, false, video.ErrFailedPrecondition},
+		{"check disabled", false, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := memory.NewVideoStorage()
+			if err := storage.SaveStreamKey(context.Background(), "owner", "key-owner"); err != nil {
+				t.Fatalf("SaveStreamKey: %v", err)
+			}
+			engine := &publisherEngine{publishing: map[string]bool{"key-owner": tt.publishing}}
+			svc := video.NewService(storage, nil, nil, engine, video.WithRequireActivePublisher(tt.require))
+
+			ctx := video.WithAuthenticatedUser(context.Background(), "owner")
+			_, err := svc.StartStream(ctx, &pb.StartStreamRequest{StreamKey: "key-owner", Title: "Live"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("StartStream error = %v, want %v", err, tt.wantErr)
+			}
+
+			_, total, err := storage.ListLiveStreams(context.Background(), "owner", 10, 0)
+			if err != nil {
+				t.Fatalf("ListLiveStreams: %v", err)
+			}
+			wantStreams := 0
+			if tt.wantErr == nil {
+				wantStreams = 1
+			}
+			if total != wantStreams {
+				t.Errorf("%d live streams saved, want %d", total, wantStreams)
+			}
+		})
+	}
+}
+
+func TestEndStreamTwice(t *testing.T) {
+	storage := memory.NewVideoStorage()
+	saveLiveStreams(t, storage, "owner", "key", 2)
+	svc := video.NewService(storage, nil, nil, &fakeStreamingEngine{})
+
+	// Ending an ended stream is not an error
+	for i := 0; i < 2; i++ {
+		if _, err := svc.EndStream(context.Background(), &pb.EndStreamRequest{StreamId: "owner-key-0", UserId: "owner"}); err != nil {
+			t.Fatalf("EndStream #%d: %v", i+1, err)
+		}
+	}
+
+	resp, err := svc.GetLiveStreams(context.Background(), &pb.GetLiveStreamsRequest{UserId: "owner"})
+	if err != nil {
+		t.Fatalf("GetLiveStreams: %v", err)
+	}
+	if len(resp.Streams) != 1 || resp.Streams[0].StreamId != "owner-key-1" || resp.TotalCount != 1 {
+		t.Errorf("GetLiveStreams = %d streams of %d, want only owner-key-1", len(resp.Streams), resp.TotalCount)
+	}
+
+	// The ended stream is kept rather than deleted
+	stream, err := storage.GetLiveStreamByID(context.Background(), "owner-key-0")
+	if err != nil {
+		t.Fatalf("GetLiveStreamByID: %v", err)
+	}
+	if stream.Status != pb.StreamStatus_STREAM_STATUS_ENDED || stream.EndedAt == nil {
+		t.Errorf("stream = %v ended at %v, want ENDED with an end time", stream.Status, stream.EndedAt)
+	}
+}
+
+// streamMetrics tracks the active stream gauge
+type streamMetrics struct {
+	active int
+}
+
+func (m *streamMetrics) StreamStarted() { m.active++ }
+func (m *streamMetrics) StreamEnded()   { m.active-- }
+
+func TestActiveStreamsMetric(t *testing.T) {
+	storage := memory.NewVideoStorage()
+	if err := storage.SaveStreamKey(context.Background(), "owner", "key-owner"); err != nil {
+		t.Fatalf("SaveStreamKey: %v", err)
+	}
+	metrics := &streamMetrics{}
+	svc := video.NewService(storage, nil, nil, &fakeStreamingEngine{}, video.WithMetrics(metrics))
+	ctx := video.WithAuthenticatedUser(context.Background(), "owner")
+
+	var streamIDs []string
+	for i := 0; i < 2; i++ {
+		resp, err := svc.StartStream(ctx, &pb.StartStreamRequest{StreamKey: "key-owner", Title: "Live"})
+		if err != nil {
+			t.Fatalf("StartStream: %v", err)
+		}
+		streamIDs = append(streamIDs, resp.StreamId)
+	}
+	if metrics.active != 2 {
+		t.Errorf("active streams = %d after starting two, want 2", metrics.active)
+	}
+
+	// Ending a stream twice only counts once
+	for i := 0; i < 2; i++ {
+		if _, err := svc.EndStream(ctx, &pb.EndStreamRequest{StreamId: streamIDs[0], UserId: "owner"}); err != nil {
+			t.Fatalf("EndStream: %v", err)
+		}
+	}
+	if metrics.active != 1 {
+		t.Errorf("active streams = %d after ending one, want 1", metrics.active)
+	}
+}
+
+func TestRotateStreamKey(t *testing.T) {
+	storage := memory.NewVideoStorage()
+	if err := storage.SaveStreamKey(context.Background(), "owner", "old-key"); err != nil {
+		t.Fatalf("SaveStreamKey: %v", err)
+	}
+	svc := video.NewService(storage, nil, nil, &fakeStreamingEngine{})
+	ctx := video.WithAuthenticatedUser(context.Background(), "owner")
+	live, err := svc.StartStream(ctx, &pb.StartStreamRequest{StreamKey: "old-key", Title: "Live"})
+	if err != nil {
+		t.Fatalf("StartStream: %v", err)
+	}
+
+	resp, err := svc.RotateStreamKey(ctx, &pb.RotateStreamKeyRequest{})
+	if err != nil {
+		t.Fatalf("RotateStreamKey: %v", err)
+	}
+	if resp.StreamKey == "" || resp.StreamKey == "old-key" {
+		t.Fatalf("rotated stream key = %q, want a new one", resp.StreamKey)
+	}
+	// Unless asked to, rotating leaves running streams alone
+	if _, err := storage.GetLiveStream(context.Background(), live.StreamId); err != nil {
+		t.Errorf("stream started with the old key ended by the rotation: %v", err)
+	}
+
+	// Only the new key can start a stream now
+	if _, err := svc.StartStream(ctx, &pb.StartStreamRequest{StreamKey: "old-key", Title: "Live"}); !errors.Is(err, video.ErrInvalidStreamKey) {
+		t.Errorf("StartStream with the old key error = %v, want %v", err, video.ErrInvalidStreamKey)
+	}
+	if _, err := svc.StartStream(ctx, &pb.StartStreamRequest{StreamKey: resp.StreamKey, Title: "Live"}); err != nil {
+		t.Errorf("StartStream with the new key: %v", err)
+	}
+
+	got, err := svc.GetStreamKey(ctx, &pb.GetStreamKeyRequest{})
+	if err != nil {
+		t.Fatalf("GetStreamKey: %v", err)
+	}
+	if got.StreamKey != resp.StreamKey {
+		t.Errorf("GetStreamKey = %q, want the rotated key %q", got.StreamKey, resp.StreamKey)
+	}
+}
+
+func TestJoinAndLeaveStream(t *testing.T) {
+	storage := memory.NewVideoStorage()
+	saveLiveStreams(t, storage, "owner", "key-owner", 1)
+	svc := video.NewService(storage, nil, nil, &fakeStreamingEngine{})
+	ctx := context.Background()
+	const streamID = "owner-key-owner-0"
+
+	for want := int64(1); want <= 2; want++ {
+		resp, err := svc.JoinStream(ctx, &pb.JoinStreamRequest{StreamId: streamID})
+		if err != nil {
+			t.Fatalf("JoinStream: %v", err)
+		}
+		if resp.ViewerCount != want {
+			t.Errorf("viewer count after joining = %d, want %d", resp.ViewerCount, want)
+		}
+	}
+
+	// Clients that report leaving more than once never take the count negative
+	for _, want := range []int64{1, 0, 0} {
+		resp, err := svc.LeaveStream(ctx, &pb.LeaveStreamRequest{StreamId: streamID})
+		if err != nil {
+			t.Fatalf("LeaveStream: %v", err)
+		}
+		if resp.ViewerCount != want {
+			t.Errorf("viewer count after leaving = %d, want %d", resp.ViewerCount, want)
+		}
+	}
+	if resp, err := svc.JoinStream(ctx, &pb.JoinStreamRequest{StreamId: streamID}); err != nil || resp.ViewerCount != 1 {
+		t.Errorf("JoinStream after extra leaves = %v, %v; want a count of 1", resp, err)
+	}
+
+	if _, err := svc.JoinStream(ctx, &pb.JoinStreamRequest{StreamId: "missing"}); !errors.Is(err, video.ErrStreamNotFound) {
+		t.Errorf("JoinStream of a missing stream error = %v, want %v", err, video.ErrStreamNotFound)
+	}
+	if _, err := svc.LeaveStream(ctx, &pb.LeaveStreamRequest{StreamId: "missing"}); !errors.Is(err, video.ErrStreamNotFound) {
+		t.Errorf("LeaveStream of a missing stream error = %v, want %v", err, video.ErrStreamNotFound)
+	}
+}
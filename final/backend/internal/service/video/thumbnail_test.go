@@ -0,0 +1,103 @@
+package video_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"videostreaming/internal/service/video"
+	pb "videostreaming/proto/video"
+)
+
+func TestCaptureThumbnailChecksDuration(t *testing.T) {
+	tests := []struct {
+		name         string
+		duration     int64
+		probed       int64
+		probeErr     error
+		atSeconds    float64
+		wantErr      error
+		wantDuration int64
+	}{
+		{"within known duration", 60, 0, nil, 30, nil, 60},
+		{"past known duration", 60, 0, nil, 60, video.ErrInvalidArgument, 60},
+		{"negative", 60, 0, nil, -1, video.ErrInvalidArgument, 60},
+		{"within probed duration", 0, 60, nil, 30, nil, 60},
+		{"past probed duration", 0, 60, nil, 90, video.ErrInvalidArgument, 0},
+		{"probe fails", 0, 0, errors.New("not a video"), 5, video.ErrFailedPrecondition, 0},
+		{"probe finds no duration", 0, 0, nil, 5, video.ErrFailedPrecondition, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := testVideo("v1", "owner", pb.VideoVisibility_VIDEO_VISIBILITY_PUBLIC)
+			v.DurationSeconds = tt.duration
+			_, storage := newTestService(t, v)
+			transcoder := &fakeTranscoder{durationSeconds: tt.probed, probeErr: tt.probeErr}
+			svc := video.NewService(storage, newFakeFileStorage(), transcoder, nil, video.WithVideoCacheTTL(0))
+
+			ctx := video.WithAuthenticatedUser(context.Background(), "owner")
+			_, err := svc.CaptureThumbnail(ctx, &pb.CaptureThumbnailRequest{VideoId: "v1", AtSeconds: tt.atSeconds})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CaptureThumbnail error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil && len(transcoder.thumbnails) != 0 {
+				t.Errorf("frame captured at %v despite the error", transcoder.thumbnails)
+			}
+
+			stored, err := storage.GetVideo(context.Background(), "v1")
+			if err != nil {
+				t.Fatalf("GetVideo: %v", err)
+			}
+			if stored.DurationSeconds != tt.wantDuration {
+				t.Errorf("stored duration = %d, want %d", stored.DurationSeconds, tt.wantDuration)
+			}
+		})
+	}
+}
+
+func TestTranscodedVideoGetsThumbnail(t *testing.T) {
+	tests := []struct {
+		name       string
+		duration   int64
+		resolution pb.VideoResolution
+		thumbnail  string
+		wantFrames []float64
+		wantURL    string
+	}{
+		{"a tenth in", 120, pb.VideoResolution_VIDEO_RESOLUTION_1080P, "", []float64{12}, "https://files.test/download/thumbnails/v1"},
+		{"unknown duration", 0, pb.VideoResolution_VIDEO_RESOLUTION_1080P, "", []float64{0}, "https://files.test/download/thumbnails/v1"},
+		{"own thumbnail", 120, pb.VideoResolution_VIDEO_RESOLUTION_1080P, "https://example.com/mine.jpg", nil, "https://example.com/mine.jpg"},
+		{"audio only", 120, pb.VideoResolution_VIDEO_RESOLUTION_AUDIO_ONLY, "", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := testVideo("v1", "owner", pb.VideoVisibility_VIDEO_VISIBILITY_PUBLIC)
+			v.Status = pb.VideoStatus_VIDEO_STATUS_PROCESSING
+			v.DurationSeconds = tt.duration
+			v.Resolution = tt.resolution
+			v.ThumbnailURL = tt.thumbnail
+			_, storage := newTestService(t, v)
+			transcoder := &fakeTranscoder{}
+			svc := video.NewService(storage, newFakeFileStorage(), transcoder, nil, video.WithVideoCacheTTL(0))
+
+			if err := svc.HandleTranscodingFinished(context.Background(), "v1"); err != nil {
+				t.Fatalf("HandleTranscodingFinished: %v", err)
+			}
+			if !reflect.DeepEqual(transcoder.thumbnails, tt.wantFrames) {
+				t.Errorf("frames captured at %v, want %v", transcoder.thumbnails, tt.wantFrames)
+			}
+
+			stored, err := storage.GetVideo(context.Background(), "v1")
+			if err != nil {
+				t.Fatalf("GetVideo: %v", err)
+			}
+			if stored.Status != pb.VideoStatus_VIDEO_STATUS_READY {
+				t.Errorf("status = %v, want READY", stored.Status)
+			}
+			if stored.ThumbnailURL != tt.wantURL {
+				t.Errorf("ThumbnailURL = %q, want %q", stored.ThumbnailURL, tt.wantURL)
+			}
+		})
+	}
+}
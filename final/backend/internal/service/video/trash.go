@@ -0,0 +1,176 @@
+package video
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	pb "videostreaming/proto/video"
+)
+
+// purgeBatchSize is how many deleted videos PurgeDeletedVideos loads at a time
+const purgeBatchSize = 100
+
+// WithTrashRetention sets how long deleted videos stay restorable before
+// PurgeDeletedVideos removes them for good. A value of zero or less keeps
+// them in the trash forever.
+func WithTrashRetention(retention time.Duration) Option {
+	return func(s *Service) {
+		s.trashRetention = retention
+	}
+}
+
+// findVideo loads a video for a user facing request, treating videos in the
+// trash as not found
+func (s *Service) findVideo(ctx context.Context, id string) (*Video, error) {
+	video, err := s.storage.GetVideo(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if video.DeletedAt != nil {
+		return nil, fmt.Errorf("%w: video has been deleted", ErrNotFound)
+	}
+	return video, nil
+}
+
+// DeleteVideo moves a video to the trash. It disappears from reads and
+// listings but keeps its files until it is purged, and can be brought back
+// with RestoreVideo in the meantime.
+func (s *Service) DeleteVideo(ctx context.Context, req *pb.DeleteVideoRequest) (*emptypb.Empty, error) {
+	userID, err := s.resolveActingUser(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	video, err := s.findVideo(ctx, req.VideoId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get video: %w", err)
+	}
+
+	if video.UserID != userID {
+		return nil, fmt.Errorf("%w: not authorized to delete this video", ErrPermissionDenied)
+	}
+
+	now := time.Now()
+	video.DeletedAt = &now
+	video.UpdatedAt = now
+
+	if err := s.storage.SaveVideo(ctx, video); err != nil {
+		return nil, fmt.Errorf("failed to delete video: %w", err)
+	}
+	s.videoCache.invalidate(video.ID)
+
+	return &emptypb.Empty{}, nil
+}
+
+// RestoreVideo takes a video back out of the trash
+func (s *Service) RestoreVideo(ctx context.Context, req *pb.RestoreVideoRequest) (*pb.Video, error) {
+	userID, err := s.resolveActingUser(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	video, err := s.storage.GetVideo(ctx, req.VideoId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get video: %w", err)
+	}
+
+	if video.UserID != userID {
+		return nil, fmt.Errorf("%w: not authorized to restore this video", ErrPermissionDenied)
+	}
+
+	if video.DeletedAt == nil {
+		return nil, fmt.Errorf("%w: video is not deleted", ErrFailedPrecondition)
+	}
+
+	video.DeletedAt = nil
+	video.UpdatedAt = time.Now()
+
+	if err := s.storage.SaveVideo(ctx, video); err != nil {
+		return nil, fmt.Errorf("failed to restore video: %w", err)
+	}
+	s.videoCache.invalidate(video.ID)
+
+	return toProtoVideo(video), nil
+}
+
+// PurgeDeletedVideos permanently removes videos that were deleted longer
+// than the trash retention before now, files first so that a failed delete
+// is retried on the next run. It returns the number of videos removed.
+func (s *Service) PurgeDeletedVideos(ctx context.Context, now time.Time) (int, error) {
+	if s.trashRetention <= 0 {
+		return 0, nil
+	}
+	cutoff := now.Add(-s.trashRetention)
+
+	purged := 0
+	for {
+		videos, err := s.storage.ListDeletedVideos(ctx, cutoff, purgeBatchSize)
+		if err != nil {
+			return purged, fmt.Errorf("failed to list deleted videos: %w", err)
+		}
+
+		for _, video := range videos {
+			if err := s.purgeVideo(ctx, video); err != nil {
+				return purged, err
+			}
+			purged++
+		}
+
+		if len(videos) < purgeBatchSize {
+			return purged, nil
+		}
+	}
+}
+
+// purgeVideo deletes a video's files and then its record. Media shared with
+// duplicate uploads is left for the videos still using it.
+func (s *Service) purgeVideo(ctx context.Context, video *Video) error {
+	inUse, err := s.mediaInUse(ctx, video)
+	if err != nil {
+		return fmt.Errorf("failed to check shared media of video %s: %w", video.ID, err)
+	}
+
+	if !inUse {
+		if err := s.fileStorage.DeletePrefix(ctx, s.transcodedKeyPrefix+video.mediaID()+"/"); err != nil {
+			return fmt.Errorf("failed to delete transcoded files of video %s: %w", video.ID, err)
+		}
+		if err := s.fileStorage.DeleteFile(ctx, s.sourceKey(video)); err != nil {
+			return fmt.Errorf("failed to delete source file of video %s: %w", video.ID, err)
+		}
+	}
+	if err := s.fileStorage.DeleteFile(ctx, s.thumbnailKeyPrefix+video.ID); err != nil {
+		return fmt.Errorf("failed to delete thumbnail of video %s: %w", video.ID, err)
+	}
+
+	if err := s.storage.DeleteVideo(ctx, video.ID, video.UserID); err != nil {
+		return fmt.Errorf("failed to delete video %s: %w", video.ID, err)
+	}
+	s.videoCache.invalidate(video.ID)
+
+	return nil
+}
+
+// RunTrashPurge purges expired videos immediately and then on every
+// interval until ctx is done
+func (s *Service) RunTrashPurge(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := s.PurgeDeletedVideos(ctx, time.Now())
+		if err != nil {
+			s.logger.ErrorContext(ctx, "Trash purge failed", "purged", purged, "error", err)
+		} else if purged > 0 {
+			s.logger.InfoContext(ctx, "Trash purge removed expired videos", "purged", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
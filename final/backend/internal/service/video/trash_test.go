@@ -0,0 +1,136 @@
+package video_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"videostreaming/internal/service/video"
+	"videostreaming/internal/storage/memory"
+	pb "videostreaming/proto/video"
+)
+
+// newTrashService returns a service that purges deleted videos a day after
+// their deletion, with files kept in memory
+func newTrashService(t *testing.T, videos ...*video.Video) (*video.Service, *memory.VideoStorage, *fakeFileStorage) {
+	t.Helper()
+
+	_, storage := newTestService(t, videos...)
+	files := newFakeFileStorage()
+	svc := video.NewService(storage, files, nil, nil,
+		video.WithVideoCacheTTL(0), video.WithTrashRetention(24*time.Hour))
+	return svc, storage, files
+}
+
+func TestDeleteAndRestoreVideo(t *testing.T) {
+	svc, _, _ := newTrashService(t, testVideo("v1", "owner", pb.VideoVisibility_VIDEO_VISIBILITY_PUBLIC))
+	owner := video.WithAuthenticatedUser(context.Background(), "owner")
+	intruder := video.WithAuthenticatedUser(context.Background(), "intruder")
+
+	if _, err := svc.DeleteVideo(intruder, &pb.DeleteVideoRequest{VideoId: "v1"}); !errors.Is(err, video.ErrPermissionDenied) {
+		t.Fatalf("DeleteVideo by another user error = %v, want %v", err, video.ErrPermissionDenied)
+	}
+	if _, err := svc.DeleteVideo(owner, &pb.DeleteVideoRequest{VideoId: "v1"}); err != nil {
+		t.Fatalf("DeleteVideo: %v", err)
+	}
+	if _, err := svc.GetVideo(owner, &pb.GetVideoRequest{VideoId: "v1"}); !errors.Is(err, video.ErrNotFound) {
+		t.Fatalf("GetVideo of a deleted video error = %v, want %v", err, video.ErrNotFound)
+	}
+	if _, err := svc.DeleteVideo(owner, &pb.DeleteVideoRequest{VideoId: "v1"}); !errors.Is(err, video.ErrNotFound) {
+		t.Errorf("second DeleteVideo error = %v, want %v", err, video.ErrNotFound)
+	}
+
+	if _, err := svc.RestoreVideo(intruder, &pb.RestoreVideoRequest{VideoId: "v1"}); !errors.Is(err, video.ErrPermissionDenied) {
+		t.Errorf("RestoreVideo by another user error = %v, want %v", err, video.ErrPermissionDenied)
+	}
+	restored, err := svc.RestoreVideo(owner, &pb.RestoreVideoRequest{VideoId: "v1"})
+	if err != nil {
+		t.Fatalf("RestoreVideo: %v", err)
+	}
+	if restored.Id != "v1" {
+		t.Errorf("RestoreVideo returned %q, want v1", restored.Id)
+	}
+	if _, err := svc.GetVideo(owner, &pb.GetVideoRequest{VideoId: "v1"}); err != nil {
+		t.Errorf("GetVideo after restore: %v", err)
+	}
+	if _, err := svc.RestoreVideo(owner, &pb.RestoreVideoRequest{VideoId: "v1"}); !errors.Is(err, video.ErrFailedPrecondition) {
+		t.Errorf("RestoreVideo of a live video error = %v, want %v", err, video.ErrFailedPrecondition)
+	}
+}
+
+func TestPurgeDeletedVideos(t *testing.T) {
+	deleted := testVideo("v1", "owner", pb.VideoVisibility_VIDEO_VISIBILITY_PUBLIC)
+	kept := testVideo("v2", "owner", pb.VideoVisibility_VIDEO_VISIBILITY_PUBLIC)
+	svc, storage, files := newTrashService(t, deleted, kept)
+	for _, path := range []string{"videos/v1", "transcoded/v1/720p.mp4", "thumbnails/v1", "videos/v2", "thumbnails/v2"} {
+		files.put(path, "video/mp4", []byte("data"))
+	}
+
+	owner := video.WithAuthenticatedUser(context.Background(), "owner")
+	if _, err := svc.DeleteVideo(owner, &pb.DeleteVideoRequest{VideoId: "v1"}); err != nil {
+		t.Fatalf("DeleteVideo: %v", err)
+	}
+
+	// Still restorable within the retention
+	if n, err := svc.PurgeDeletedVideos(context.Background(), time.Now().Add(23*time.Hour)); err != nil || n != 0 {
+		t.Fatalf("PurgeDeletedVideos within the retention = %d, %v; want 0, nil", n, err)
+	}
+	if !files.has("videos/v1") {
+		t.Fatalf("source file purged within the retention")
+	}
+
+	n, err := svc.PurgeDeletedVideos(context.Background(), time.Now().Add(25*time.Hour))
+	if err != nil {
+		t.Fatalf("PurgeDeletedVideos: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("purged %d videos, want 1", n)
+	}
+	if _, err := storage.GetVideo(context.Background(), "v1"); err == nil {
+		t.Errorf("purged video still stored")
+	}
+	for _, path := range []string{"videos/v1", "transcoded/v1/720p.mp4", "thumbnails/v1"} {
+		if files.has(path) {
+			t.Errorf("%s kept after the purge", path)
+		}
+	}
+	for _, path := range []string{"videos/v2", "thumbnails/v2"} {
+		if !files.has(path) {
+			t.Errorf("%s of a live video was purged", path)
+		}
+	}
+	if _, err := svc.RestoreVideo(owner, &pb.RestoreVideoRequest{VideoId: "v1"}); err == nil {
+		t.Errorf("RestoreVideo of a purged video succeeded")
+	}
+}
+
+func TestPurgeKeepsSharedMedia(t *testing.T) {
+	deleted := testVideo("v1", "owner", pb.VideoVisibility_VIDEO_VISIBILITY_PUBLIC)
+	duplicate := testVideo("v2", "other", pb.VideoVisibility_VIDEO_VISIBILITY_PUBLIC)
+	for _, v := range []*video.Video{deleted, duplicate} {
+		v.ContentHash = "hash"
+		v.MediaID = "media"
+	}
+	svc, _, files := newTrashService(t, deleted, duplicate)
+	for _, path := range []string{"videos/media", "transcoded/media/720p.mp4", "thumbnails/v1", "thumbnails/v2"} {
+		files.put(path, "video/mp4", []byte("data"))
+	}
+
+	owner := video.WithAuthenticatedUser(context.Background(), "owner")
+	if _, err := svc.DeleteVideo(owner, &pb.DeleteVideoRequest{VideoId: "v1"}); err != nil {
+		t.Fatalf("DeleteVideo: %v", err)
+	}
+	if _, err := svc.PurgeDeletedVideos(context.Background(), time.Now().Add(25*time.Hour)); err != nil {
+		t.Fatalf("PurgeDeletedVideos: %v", err)
+	}
+
+	if files.has("thumbnails/v1") {
+		t.Errorf("thumbnail of the purged video kept")
+	}
+	for _, path := range []string{"videos/media", "transcoded/media/720p.mp4", "thumbnails/v2"} {
+		if !files.has(path) {
+			t.Errorf("%s still used by the duplicate was purged", path)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package video
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// defaultMaxDescriptionLength is the description cap in characters when none is configured
+const defaultMaxDescriptionLength = 5000
+
+// sanitizeDescription strips control characters (except newlines and tabs)
+// and invalid UTF-8 from a description, then enforces the length cap
+func (s *Service) sanitizeDescription(description string) (string, error) {
+	description = strings.ToValidUTF8(description, "")
+	description = strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, description)
+	description = strings.TrimSpace(description)
+
+	if s.maxDescriptionLength > 0 && utf8.RuneCountInString(description) > s.maxDescriptionLength {
+		return "", fmt.Errorf("%w: description exceeds %d characters", ErrInvalidArgument, s.maxDescriptionLength)
+	}
+
+	return description, nil
+}
+
+// normalizeStreamCategory trims and lowercases a stream category, so that
+// "Gaming" and "gaming " are browsed together
+func normalizeStreamCategory(category string) string {
+	return strings.ToLower(strings.TrimSpace(category))
+}
+
+// thumbnailContentTypes lists the image formats accepted for uploaded thumbnails
+var thumbnailContentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+}
+
+// validateThumbnailContentType checks that an upload is a supported image format
+func validateThumbnailContentType(contentType string) error {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || !thumbnailContentTypes[mediaType] {
+		return fmt.Errorf("%w: thumbnail must be image/jpeg or image/png, got %q", ErrInvalidArgument, contentType)
+	}
+	return nil
+}
+
+// validateCallbackURL checks that a webhook callback URL, if given, is an
+// absolute http or https URL. Where its host points is only checked when a
+// webhook is sent, as DNS may change in between.
+func validateCallbackURL(callbackURL string) error {
+	if callbackURL == "" {
+		return nil
+	}
+	u, err := url.Parse(callbackURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: callback URL must be an absolute http or https URL", ErrInvalidArgument)
+	}
+	return nil
+}
+
+// checkStorageQuota rejects an upload of size bytes that would take the
+// user's stored videos over the configured quota. counted is how much of the
+// user's usage already belongs to this upload, such as the size declared
+// when it was initiated, and is replaced by size rather than added to it.
+func (s *Service) checkStorageQuota(ctx context.Context, userID string, size int64, counted int64) error {
+	if size < 0 {
+		return fmt.Errorf("%w: file size must not be negative", ErrInvalidArgument)
+	}
+	if s.userStorageQuota <= 0 {
+		return nil
+	}
+
+	usage, err := s.storage.GetUserStorageUsage(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get storage usage: %w", err)
+	}
+	usage -= counted
+	if usage+size > s.userStorageQuota {
+		return fmt.Errorf("%w: %d of %d bytes used, upload needs %d", ErrQuotaExceeded, usage, s.userStorageQuota, size)
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package video_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"videostreaming/internal/service/video"
+	"videostreaming/internal/storage/memory"
+	pb "videostreaming/proto/video"
+)
+
+func TestCompleteUploadChecksQuotaAgainstStoredSize(t *testing.T) {
+	const quota = 100
+	existing := testVideo("existing", "alice", pb.VideoVisibility_VIDEO_VISIBILITY_PUBLIC)
+	existing.FileSizeBytes = 60
+
+	tests := []struct {
+		name       string
+		declared   int64
+		uploaded   int
+		wantErr    error
+		wantStatus pb.VideoStatus
+	}{
+		{"declared zero, uploaded too much", 0, 50, video.ErrQuotaExceeded, pb.VideoStatus_VIDEO_STATUS_FAILED},
+		{"declared less than uploaded", 10, 41, video.ErrQuotaExceeded, pb.VideoStatus_VIDEO_STATUS_FAILED},
+		{"fits the quota", 10, 40, nil, pb.VideoStatus_VIDEO_STATUS_PROCESSING},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := memory.NewVideoStorage()
+			if err := storage.SaveVideo(context.Background(), existing); err != nil {
+				t.Fatalf("SaveVideo: %v", err)
+			}
+			files := newFakeFileStorage()
+			svc := video.NewService(storage, files, &fakeTranscoder{durationSeconds: 60}, nil,
+				video.WithUserStorageQuota(quota), video.WithVideoCacheTTL(0))
+			ctx := video.WithAuthenticatedUser(context.Background(), "alice")
+
+			upload, err := svc.InitiateUpload(ctx, &pb.InitiateUploadRequest{
+				Title:         "New video",
+				FileSizeBytes: tt.declared,
+				ContentType:   "video/mp4",
+			})
+			if err != nil {
+				t.Fatalf("InitiateUpload: %v", err)
+			}
+			objectKey := "videos/" + upload.VideoId
+			files.put(objectKey, "video/mp4", bytes.Repeat([]byte{0}, tt.uploaded))
+
+			_, err = svc.CompleteUpload(ctx, &pb.CompleteUploadRequest{VideoId: upload.VideoId})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CompleteUpload error = %v, want %v", err, tt.wantErr)
+			}
+
+			stored, err := storage.GetVideo(context.Background(), upload.VideoId)
+			if err != nil {
+				t.Fatalf("GetVideo: %v", err)
+			}
+			if stored.Status != tt.wantStatus {
+				t.Errorf("status = %v, want %v", stored.Status, tt.wantStatus)
+			}
+
+			usage, _ := storage.GetUserStorageUsage(context.Background(), "alice")
+			if tt.wantErr != nil {
+				if files.has(objectKey) {
+					t.Error("rejected upload was not deleted")
+				}
+				if usage != existing.FileSizeBytes {
+					t.Errorf("usage = %d after rejection, want %d", usage, existing.FileSizeBytes)
+				}
+			} else if usage != existing.FileSizeBytes+int64(tt.uploaded) {
+				t.Errorf("usage = %d, want %d", usage, existing.FileSizeBytes+int64(tt.uploaded))
+			}
+		})
+	}
+}
+
+func TestCompleteUploadChecksUploadedObject(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		data        []byte
+		wantErr     error
+	}{
+		{"never uploaded", "", nil, video.ErrFailedPrecondition},
+		{"empty file", "video/mp4", []byte{}, video.ErrFailedPrecondition},
+		{"not a video", "text/html; charset=utf-8", []byte("<html>"), video.ErrInvalidArgument},
+		{"video", "video/mp4", []byte("video bytes"), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := memory.NewVideoStorage()
+			files := newFakeFileStorage()
+			transcoder := &fakeTranscoder{}
+			svc := video.NewService(storage, files, transcoder, nil, video.WithVideoCacheTTL(0))
+			ctx := video.WithAuthenticatedUser(context.Background(), "alice")
+
+			upload, err := svc.InitiateUpload(ctx, &pb.InitiateUploadRequest{Title: "New video", ContentType: "video/mp4"})
+			if err != nil {
+				t.Fatalf("InitiateUpload: %v", err)
+			}
+			if tt.data != nil {
+				files.put("videos/"+upload.VideoId, tt.contentType, tt.data)
+			}
+
+			_, err = svc.CompleteUpload(ctx, &pb.CompleteUploadRequest{VideoId: upload.VideoId})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CompleteUpload error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil {
+				return
+			}
+
+			// A rejected upload stays where it was and is never transcoded
+			stored, err := storage.GetVideo(context.Background(), upload.VideoId)
+			if err != nil {
+				t.Fatalf("GetVideo: %v", err)
+			}
+			if stored.Status != pb.VideoStatus_VIDEO_STATUS_UPLOADING || len(transcoder.started) != 0 {
+				t.Errorf("video is %v with %d transcodes started, want UPLOADING with none", stored.Status, len(transcoder.started))
+			}
+		})
+	}
+}
+
+func TestInitiateUploadChecksQuota(t *testing.T) {
+	const quota = 100
+	tests := []struct {
+		name     string
+		quota    int64
+		declared int64
+		wantErr  error
+	}{
+		{"just under", quota, 39, nil},
+		{"at the quota", quota, 40, nil},
+		{"just over", quota, 41, video.ErrQuotaExceeded},
+		{"negative size", quota, -1, video.ErrInvalidArgument},
+		{"no quota", 0, 1 << 40, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 60 of the 100 bytes are taken already
+			existing := testVideo("existing", "alice", pb.VideoVisibility_VIDEO_VISIBILITY_PUBLIC)
+			existing.FileSizeBytes = 60
+			storage := memory.NewVideoStorage()
+			if err := storage.SaveVideo(context.Background(), existing); err != nil {
+				t.Fatalf("SaveVideo: %v", err)
+			}
+			svc := video.NewService(storage, newFakeFileStorage(), nil, nil,
+				video.WithUserStorageQuota(tt.quota), video.WithVideoCacheTTL(0))
+			ctx := video.WithAuthenticatedUser(context.Background(), "alice")
+
+			_, err := svc.InitiateUpload(ctx, &pb.InitiateUploadRequest{
+				Title:         "New video",
+				FileSizeBytes: tt.declared,
+				ContentType:   "video/mp4",
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("InitiateUpload error = %v, want %v", err, tt.wantErr)
+			}
+
+			// A rejected upload leaves no video behind to count against the quota
+			wantUsage := existing.FileSizeBytes
+			if tt.wantErr == nil {
+				wantUsage += tt.declared
+			}
+			if usage, _ := storage.GetUserStorageUsage(context.Background(), "alice"); usage != wantUsage {
+				t.Errorf("usage = %d, want %d", usage, wantUsage)
+			}
+		})
+	}
+}
+
+func TestUploadRecordsFileSize(t *testing.T) {
+	storage := memory.NewVideoStorage()
+	files := newFakeFileStorage()
+	svc := video.NewService(storage, files, &fakeTranscoder{durationSeconds: 60}, nil, video.WithVideoCacheTTL(0))
+	ctx := video.WithAuthenticatedUser(context.Background(), "alice")
+
+	upload, err := svc.InitiateUpload(ctx, &pb.InitiateUploadRequest{Title: "New video", FileSizeBytes: 1000, ContentType: "video/mp4"})
+	if err != nil {
+		t.Fatalf("InitiateUpload: %v", err)
+	}
+	got, err := svc.GetVideo(ctx, &pb.GetVideoRequest{VideoId: upload.VideoId})
+	if err != nil {
+		t.Fatalf("GetVideo: %v", err)
+	}
+	if got.FileSizeBytes != 1000 {
+		t.Errorf("file size = %d after initiating, want the declared 1000", got.FileSizeBytes)
+	}
+
+	// The stored object's size replaces the declared one
+	files.put("videos/"+upload.VideoId, "video/mp4", bytes.Repeat([]byte{0}, 1234))
+	if _, err := svc.CompleteUpload(ctx, &pb.CompleteUploadRequest{VideoId: upload.VideoId}); err != nil {
+		t.Fatalf("CompleteUpload: %v", err)
+	}
+	got, err = svc.GetVideo(ctx, &pb.GetVideoRequest{VideoId: upload.VideoId})
+	if err != nil {
+		t.Fatalf("GetVideo: %v", err)
+	}
+	if got.FileSizeBytes != 1234 {
+		t.Errorf("file size = %d after completing, want the uploaded 1234", got.FileSizeBytes)
+	}
+}
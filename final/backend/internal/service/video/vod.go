@@ -0,0 +1,115 @@
+package video
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	pb "videostreaming/proto/video"
+)
+
+// RecordingLocator finds the file a live stream was recorded to
+type RecordingLocator interface {
+	FindRecording(ctx context.Context, streamKey string, since time.Time) (string, error)
+	DeleteRecording(ctx context.Context, key string) error
+}
+
+// WithAutoArchive turns every ended live stream into a regular video by
+// transcoding the recording that recordings locates
+func WithAutoArchive(recordings RecordingLocator) Option {
+	return func(s *Service) {
+		s.recordings = recordings
+		s.autoArchive = recordings != nil
+	}
+}
+
+// WithRecordingRetention sets how long live stream recordings are kept:
+// perUser overrides defaultRetention for the streams of single users, and
+// zero keeps recordings forever
+func WithRecordingRetention(defaultRetention time.Duration, perUser map[string]time.Duration) Option {
+	return func(s *Service) {
+		s.recordingRetention = defaultRetention
+		s.recordingRetentionByUser = perUser
+	}
+}
+
+// WithDeleteRecordingAfterVOD deletes a stream's recording once the video
+// archived from it has been transcoded in full
+func WithDeleteRecordingAfterVOD(enabled bool) Option {
+	return func(s *Service) {
+		s.deleteRecordingAfterVOD = enabled
+	}
+}
+
+// RecordingRetention returns how long the recordings of streamKey are kept,
+// which depends on the user the key belongs to
+func (s *Service) RecordingRetention(ctx context.Context, streamKey string) (time.Duration, error) {
+	if streamKey == "" || len(s.recordingRetentionByUser) == 0 {
+		return s.recordingRetention, nil
+	}
+
+	userID, err := s.storage.GetStreamKeyOwner(ctx, streamKey)
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up owner of stream key: %w", err)
+	}
+	if retention, ok := s.recordingRetentionByUser[userID]; ok && userID != "" {
+		return retention, nil
+	}
+	return s.recordingRetention, nil
+}
+
+// deleteArchivedRecording removes the recording a fully transcoded video was
+// archived from, when recordings are only kept until then
+func (s *Service) deleteArchivedRecording(ctx context.Context, video *Video) {
+	if !s.deleteRecordingAfterVOD || s.recordings == nil || video.SourceKey == "" {
+		return
+	}
+	if err := s.recordings.DeleteRecording(ctx, video.SourceKey); err != nil {
+		s.logger.WarnContext(ctx, "Failed to delete archived recording", "video_id", video.ID, "source_key", video.SourceKey, "error", err)
+	}
+}
+
+// sourceKey returns the file storage key of the video's original media
+func (s *Service) sourceKey(video *Video) string {
+	if video.SourceKey != "" {
+		return video.SourceKey
+	}
+	return s.videoKeyPrefix + video.mediaID()
+}
+
+// archiveStream creates a video from a finished stream's recording and
+// starts transcoding it
+func (s *Service) archiveStream(ctx context.Context, stream *LiveStream) (*Video, error) {
+	recordingKey, err := s.recordings.FindRecording(ctx, stream.StreamKey, stream.StartedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find recording: %w", err)
+	}
+
+	now := time.Now()
+	video := &Video{
+		ID:          uuid.New().String(),
+		Title:       stream.Title,
+		Description: stream.Description,
+		UserID:      stream.UserID,
+		Status:      pb.VideoStatus_VIDEO_STATUS_PROCESSING,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Tags:        stream.Tags,
+		Visibility:  pb.VideoVisibility_VIDEO_VISIBILITY_PUBLIC,
+		SourceKey:   recordingKey,
+	}
+
+	if err := s.storage.SaveVideo(ctx, video); err != nil {
+		return nil, fmt.Errorf("failed to save video: %w", err)
+	}
+
+	if err := s.transcodingService.StartTranscoding(ctx, video.ID, recordingKey); err != nil {
+		s.failVideo(ctx, video, fmt.Sprintf("the stream recording could not be processed: %v", err))
+		return nil, fmt.Errorf("failed to start transcoding: %w", err)
+	}
+
+	s.logger.InfoContext(ctx, "Archived stream as video", "stream_id", stream.StreamID, "video_id", video.ID, "user_id", video.UserID)
+	return video, nil
+}
@@ -0,0 +1,163 @@
+package video_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"videostreaming/internal/service/video"
+	"videostreaming/internal/storage/memory"
+	pb "videostreaming/proto/video"
+)
+
+func TestRecordingRetentionPerUser(t *testing.T) {
+	storage := memory.NewVideoStorage()
+	ctx := context.Background()
+	for userID, streamKey := range map[string]string{"alice": "alice-key", "bob": "bob-key", "carol": "carol-key"} {
+		if err := storage.SaveStreamKey(ctx, userID, streamKey); err != nil {
+			t.Fatalf("SaveStreamKey: %v", err)
+		}
+	}
+	svc := video.NewService(storage, nil, nil, nil,
+		video.WithRecordingRetention(24*time.Hour, map[string]time.Duration{
+			"alice": 30 * 24 * time.Hour,
+			"bob":   0,
+		}),
+	)
+
+	tests := []struct {
+		streamKey string
+		want      time.Duration
+	}{
+		{"alice-key", 30 * 24 * time.Hour},
+		{"bob-key", 0},
+		{"carol-key", 24 * time.Hour},
+		{"unknown-key", 24 * time.Hour},
+		{"", 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		got, err := svc.RecordingRetention(ctx, tt.streamKey)
+		if err != nil {
+			t.Fatalf("RecordingRetention(%q): %v", tt.streamKey, err)
+		}
+		if got != tt.want {
+			t.Errorf("RecordingRetention(%q) = %v, want %v", tt.streamKey, got, tt.want)
+		}
+	}
+}
+
+func TestDeleteRecordingAfterVOD(t *testing.T) {
+	tests := []struct {
+		name        string
+		enabled     bool
+		status      pb.TranscodingStatus
+		wantDeleted bool
+	}{
+		{"completed", true, pb.TranscodingStatus_TRANSCODING_STATUS_COMPLETED, true},
+		{"partial", true, pb.TranscodingStatus_TRANSCODING_STATUS_PARTIAL, false},
+		{"disabled", false, pb.TranscodingStatus_TRANSCODING_STATUS_COMPLETED, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archived := testVideo("v1", "owner", pb.VideoVisibility_VIDEO_VISIBILITY_PUBLIC)
+			archived.Status = pb.VideoStatus_VIDEO_STATUS_PROCESSING
+			archived.SourceKey = "recordings/live/key/session.mp4"
+			archived.ThumbnailURL = "thumbnails/v1"
+			_, storage := newTestService(t, archived)
+
+			recordings := &fakeRecordings{key: archived.SourceKey}
+			svc := video.NewService(storage, newFakeFileStorage(), &fakeTranscoder{status: tt.status}, nil,
+				video.WithAutoArchive(recordings),
+				video.WithDeleteRecordingAfterVOD(tt.enabled),
+			)
+
+			if err := svc.HandleTranscodingFinished(context.Background(), "v1"); err != nil {
+				t.Fatalf("HandleTranscodingFinished: %v", err)
+			}
+
+			deleted := len(recordings.deleted) == 1 && recordings.deleted[0] == archived.SourceKey
+			if deleted != tt.wantDeleted {
+				t.Errorf("deleted recordings = %v, want deleted %v", recordings.deleted, tt.wantDeleted)
+			}
+		})
+	}
+}
+
+func TestEndStreamArchivesRecording(t *testing.T) {
+	_, storage := newTestService(t)
+	stream := &video.LiveStream{
+		StreamID:  "s1",
+		UserID:    "owner",
+		Title:     "Stream",
+		Status:    pb.StreamStatus_STREAM_STATUS_LIVE,
+		StartedAt: time.Now().Add(-time.Hour),
+		Tags:      []string{"live"},
+		StreamKey: "key",
+	}
+	if err := storage.SaveLiveStream(context.Background(), stream); err != nil {
+		t.Fatalf("SaveLiveStream: %v", err)
+	}
+
+	// A session recorded to several segments is archived from its playlist
+	recordings := &fakeRecordings{key: "recordings/live/key/session.ffconcat"}
+	transcoder := &fakeTranscoder{}
+	svc := video.NewService(storage, newFakeFileStorage(), transcoder, nil,
+		video.WithVideoCacheTTL(0),
+		video.WithAutoArchive(recordings),
+	)
+
+	ctx := video.WithAuthenticatedUser(context.Background(), "owner")
+	if _, err := svc.EndStream(ctx, &pb.EndStreamRequest{StreamId: "s1"}); err != nil {
+		t.Fatalf("EndStream: %v", err)
+	}
+
+	videos, total, err := storage.ListVideos(context.Background(), video.ListVideosFilter{UserID: "owner"}, 10, 0)
+	if err != nil {
+		t.Fatalf("ListVideos: %v", err)
+	}
+	if total != 1 {
+		t.Fatalf("EndStream created %d videos, want 1", total)
+	}
+	archived := videos[0]
+	if archived.Title != "Stream" || archived.SourceKey != recordings.key || archived.Status != pb.VideoStatus_VIDEO_STATUS_PROCESSING {
+		t.Errorf("archived video = %+v, want the stream's title, recording and PROCESSING", archived)
+	}
+	if len(transcoder.started) != 1 || transcoder.started[0] != archived.ID {
+		t.Errorf("transcoding started for %v, want [%s]", transcoder.started, archived.ID)
+	}
+}
+
+func TestEndStreamArchivesOnce(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       []video.Option
+		wantVideos int
+	}{
+		{"auto archive", []video.Option{video.WithAutoArchive(&fakeRecordings{key: "recordings/live/key/session.mp4"})}, 1},
+		{"auto archive off", nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, storage := newTestService(t)
+			saveLiveStreams(t, storage, "owner", "key", 1)
+			transcoder := &fakeTranscoder{}
+			svc := video.NewService(storage, newFakeFileStorage(), transcoder, nil, append(tt.opts, video.WithVideoCacheTTL(0))...)
+
+			// Ending an already ended stream must not archive it again
+			ctx := video.WithAuthenticatedUser(context.Background(), "owner")
+			for i := 0; i < 2; i++ {
+				if _, err := svc.EndStream(ctx, &pb.EndStreamRequest{StreamId: "owner-key-0"}); err != nil {
+					t.Fatalf("EndStream: %v", err)
+				}
+			}
+
+			_, total, err := storage.ListVideos(context.Background(), video.ListVideosFilter{UserID: "owner"}, 10, 0)
+			if err != nil {
+				t.Fatalf("ListVideos: %v", err)
+			}
+			if total != tt.wantVideos || len(transcoder.started) != tt.wantVideos {
+				t.Errorf("created %d videos and started %d transcodes, want %d of each", total, len(transcoder.started), tt.wantVideos)
+			}
+		})
+	}
+}
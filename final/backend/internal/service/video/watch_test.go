@@ -0,0 +1,133 @@
+package video_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"videostreaming/internal/service/video"
+	pb "videostreaming/proto/video"
+)
+
+// scriptedTranscoder reports the statuses in turn, one per
+// GetTranscodingStatus call, and signals changes whenever the test sends on
+// changed
+type scriptedTranscoder struct {
+	fakeTranscoder
+	changed chan struct{}
+
+	mu       sync.Mutex
+	statuses []*video.TranscodingStatus
+}
+
+func (t *scriptedTranscoder) GetTranscodingStatus(ctx context.Context, videoID string) (*video.TranscodingStatus, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	status := t.statuses[0]
+	if len(t.statuses) > 1 {
+		t.statuses = t.statuses[1:]
+	}
+	return status, nil
+}
+
+func (t *scriptedTranscoder) SubscribeTranscodingStatus(videoID string) (<-chan struct{}, func()) {
+	return t.changed, func() {}
+}
+
+// watchStream collects what a WatchTranscodingStatus call sends
+type watchStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent chan *pb.TranscodingStatusResponse
+}
+
+func (s *watchStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *watchStream) Send(status *pb.TranscodingStatusResponse) error {
+	s.sent <- status
+	return nil
+}
+
+func TestWatchTranscodingStatus(t *testing.T) {
+	processing := pb.TranscodingStatus_TRANSCODING_STATUS_PROCESSING
+	transcoder := &scriptedTranscoder{
+		changed: make(chan struct{}),
+		statuses: []*video.TranscodingStatus{
+			{VideoID: "v1", Status: processing},
+			{VideoID: "v1", Status: processing, OverallProgress: 50},
+			{VideoID: "v1", Status: pb.TranscodingStatus_TRANSCODING_STATUS_COMPLETED, OverallProgress: 100},
+		},
+	}
+	svc := video.NewService(nil, nil, transcoder, nil)
+	stream := &watchStream{ctx: context.Background(), sent: make(chan *pb.TranscodingStatusResponse, 3)}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- svc.WatchTranscodingStatus(&pb.GetTranscodingStatusRequest{VideoId: "v1"}, stream)
+	}()
+
+	// The current status is sent right away, then one per change
+	want := []struct {
+		status   pb.TranscodingStatus
+		progress float32
+	}{
+		{processing, 0},
+		{processing, 50},
+		{pb.TranscodingStatus_TRANSCODING_STATUS_COMPLETED, 100},
+	}
+	for i, w := range want {
+		if i > 0 {
+			transcoder.changed <- struct{}{}
+		}
+		select {
+		case got := <-stream.sent:
+			if got.Status != w.status || got.OverallProgress != w.progress {
+				t.Errorf("update %d = %v at %.0f%%, want %v at %.0f%%", i, got.Status, got.OverallProgress, w.status, w.progress)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("update %d not sent", i)
+		}
+	}
+
+	// Once every job is done the stream ends
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("WatchTranscodingStatus: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("WatchTranscodingStatus did not return after completion")
+	}
+}
+
+func TestWatchTranscodingStatusClientGone(t *testing.T) {
+	transcoder := &scriptedTranscoder{
+		changed:  make(chan struct{}),
+		statuses: []*video.TranscodingStatus{{VideoID: "v1", Status: pb.TranscodingStatus_TRANSCODING_STATUS_PROCESSING}},
+	}
+	svc := video.NewService(nil, nil, transcoder, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &watchStream{ctx: ctx, sent: make(chan *pb.TranscodingStatusResponse, 1)}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- svc.WatchTranscodingStatus(&pb.GetTranscodingStatusRequest{VideoId: "v1"}, stream)
+	}()
+	<-stream.sent
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("WatchTranscodingStatus error = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("WatchTranscodingStatus kept running after the client went away")
+	}
+}
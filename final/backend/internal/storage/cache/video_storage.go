@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"videostreaming/internal/service/video"
+)
+
+// VideoStorage is a video.Storage decorator that keeps recently read video
+// metadata in a bounded LRU cache. Entries expire after a TTL and are
+// dropped whenever the video is written through this decorator. Every
+// other method goes straight to the wrapped storage.
+type VideoStorage struct {
+	video.Storage
+
+	size int
+	ttl  time.Duration
+
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // front is most recently used
+	// version changes on every invalidation so that a read which raced
+	// with a write doesn't put the stale value back into the cache
+	version uint64
+}
+
+type entry struct {
+	id        string
+	video     *video.Video
+	expiresAt time.Time
+}
+
+// NewVideoStorage wraps backing with a cache holding at most size videos
+// for up to ttl each
+func NewVideoStorage(backing video.Storage, size int, ttl time.Duration) *VideoStorage {
+	return &VideoStorage{
+		Storage: backing,
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// Ping checks the backing storage, which is always reachable when it has no Ping
+func (s *VideoStorage) Ping(ctx context.Context) error {
+	if pinger, ok := s.Storage.(video.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// GetVideo returns the cached video or loads it from the wrapped storage
+func (s *VideoStorage) GetVideo(ctx context.Context, id string) (*video.Video, error) {
+	s.mutex.Lock()
+	if elem, ok := s.entries[id]; ok {
+		e := elem.Value.(*entry)
+		if time.Now().Before(e.expiresAt) {
+			s.order.MoveToFront(elem)
+			v := *e.video
+			s.mutex.Unlock()
+			return &v, nil
+		}
+		s.remove(elem)
+	}
+	version := s.version
+	s.mutex.Unlock()
+
+	v, err := s.Storage.GetVideo(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	cached := *v
+	s.mutex.Lock()
+	if s.version == version {
+		s.add(id, &cached)
+	}
+	s.mutex.Unlock()
+
+	return v, nil
+}
+
+// SaveVideo writes through to the wrapped storage and drops the cached copy
+func (s *VideoStorage) SaveVideo(ctx context.Context, v *video.Video) error {
+	defer s.invalidate(v.ID)
+	return s.Storage.SaveVideo(ctx, v)
+}
+
+// DeleteVideo deletes from the wrapped storage and drops the cached copy
+func (s *VideoStorage) DeleteVideo(ctx context.Context, id string, userID string) error {
+	defer s.invalidate(id)
+	return s.Storage.DeleteVideo(ctx, id, userID)
+}
+
+// IncrementViewCount updates the wrapped storage and drops the cached copy
+func (s *VideoStorage) IncrementViewCount(ctx context.Context, videoID string) (int64, error) {
+	defer s.invalidate(videoID)
+	return s.Storage.IncrementViewCount(ctx, videoID)
+}
+
+// add inserts a video, evicting the least recently used one when full.
+// The caller must hold the mutex.
+func (s *VideoStorage) add(id string, v *video.Video) {
+	if elem, ok := s.entries[id]; ok {
+		s.remove(elem)
+	}
+
+	s.entries[id] = s.order.PushFront(&entry{
+		id:        id,
+		video:     v,
+		expiresAt: time.Now().Add(s.ttl),
+	})
+
+	for s.order.Len() > s.size {
+		s.remove(s.order.Back())
+	}
+}
+
+// remove drops a single entry. The caller must hold the mutex.
+func (s *VideoStorage) remove(elem *list.Element) {
+	s.order.Remove(elem)
+	delete(s.entries, elem.Value.(*entry).id)
+}
+
+func (s *VideoStorage) invalidate(id string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.version++
+	if elem, ok := s.entries[id]; ok {
+		s.remove(elem)
+	}
+}
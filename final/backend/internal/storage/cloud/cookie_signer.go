@@ -0,0 +1,138 @@
+package cloud
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CloudFrontCookieSigner issues CloudFront signed cookies that authorize
+// access to every object under a path prefix with a single custom policy
+type CloudFrontCookieSigner struct {
+	keyPairID    string
+	privateKey   *rsa.PrivateKey
+	baseURL      string
+	cookieDomain string
+}
+
+// NewCloudFrontCookieSigner creates a signer from a CloudFront key pair.
+// baseURL is the distribution URL (e.g. https://d111111abcdef8.cloudfront.net)
+// and cookieDomain is the domain the cookies are scoped to.
+func NewCloudFrontCookieSigner(keyPairID string, privateKeyPEM []byte, baseURL, cookieDomain string) (*CloudFrontCookieSigner, error) {
+	block, _ := pem.Decode(privateKeyPEM)
+	if block == nil {
+		return nil, errors.New("failed to decode private key PEM")
+	}
+
+	privateKey, err := parseRSAPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	return &CloudFrontCookieSigner{
+		keyPairID:    keyPairID,
+		privateKey:   privateKey,
+		baseURL:      strings.TrimRight(baseURL, "/"),
+		cookieDomain: cookieDomain,
+	}, nil
+}
+
+// SignCookies returns the CloudFront-Policy, CloudFront-Signature and
+// CloudFront-Key-Pair-Id cookies granting access to prefix until the expiry
+func (s *CloudFrontCookieSigner) SignCookies(ctx context.Context, prefix string, expiresIn time.Duration) ([]*http.Cookie, error) {
+	expiresAt := time.Now().Add(expiresIn)
+	resource := fmt.Sprintf("%s/%s*", s.baseURL, strings.TrimLeft(prefix, "/"))
+
+	policy, err := json.Marshal(cloudFrontPolicy{
+		Statement: []cloudFrontStatement{{
+			Resource: resource,
+			Condition: cloudFrontCondition{
+				DateLessThan: cloudFrontEpoch{EpochTime: expiresAt.Unix()},
+			},
+		}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode policy: %w", err)
+	}
+
+	// CloudFront only accepts SHA-1 signatures for signed cookies
+	hash := sha1.Sum(policy)
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.privateKey, crypto.SHA1, hash[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign policy: %w", err)
+	}
+
+	values := map[string]string{
+		"CloudFront-Policy":      cloudFrontEncode(policy),
+		"CloudFront-Signature":   cloudFrontEncode(signature),
+		"CloudFront-Key-Pair-Id": s.keyPairID,
+	}
+
+	cookies := make([]*http.Cookie, 0, len(values))
+	for _, name := range []string{"CloudFront-Policy", "CloudFront-Signature", "CloudFront-Key-Pair-Id"} {
+		cookies = append(cookies, &http.Cookie{
+			Name:     name,
+			Value:    values[name],
+			Domain:   s.cookieDomain,
+			Path:     "/",
+			Expires:  expiresAt,
+			Secure:   true,
+			HttpOnly: true,
+			SameSite: http.SameSiteNoneMode,
+		})
+	}
+
+	return cookies, nil
+}
+
+type cloudFrontPolicy struct {
+	Statement []cloudFrontStatement `json:"Statement"`
+}
+
+type cloudFrontStatement struct {
+	Resource  string              `json:"Resource"`
+	Condition cloudFrontCondition `json:"Condition"`
+}
+
+type cloudFrontCondition struct {
+	DateLessThan cloudFrontEpoch `json:"DateLessThan"`
+}
+
+type cloudFrontEpoch struct {
+	EpochTime int64 `json:"AWS:EpochTime"`
+}
+
+// cloudFrontEncode applies CloudFront's URL-safe variant of base64
+func cloudFrontEncode(data []byte) string {
+	return strings.NewReplacer("+", "-", "=", "_", "/", "~").Replace(base64.StdEncoding.EncodeToString(data))
+}
+
+// parseRSAPrivateKey accepts both PKCS#1 and PKCS#8 encoded RSA keys
+func parseRSAPrivateKey(der []byte) (*rsa.PrivateKey, error) {
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not an RSA key")
+	}
+
+	return rsaKey, nil
+}
@@ -2,7 +2,10 @@ package cloud
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -131,6 +134,47 @@ func (s *S3Storage) DeleteObject(ctx context.Context, key string) error {
 	return nil
 }
 
+// OpenReader fetches an object and returns its body for streaming.
+// The caller must close the returned reader.
+func (s *S3Storage) OpenReader(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return nil, fmt.Errorf("object %s: %w", key, os.ErrNotExist)
+		}
+		return nil, fmt.Errorf("failed to get object: %w", err)
+	}
+
+	return resp.Body, nil
+}
+
+// StatObject returns an object's size and content type
+func (s *S3Storage) StatObject(ctx context.Context, key string) (int64, string, error) {
+	resp, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return 0, "", fmt.Errorf("object %s: %w", key, os.ErrNotExist)
+		}
+		return 0, "", fmt.Errorf("failed to get object metadata: %w", err)
+	}
+
+	return aws.ToInt64(resp.ContentLength), aws.ToString(resp.ContentType), nil
+}
+
+// isNotFound reports whether S3 rejected a request because the key doesn't
+// exist. GET answers with NoSuchKey, HEAD with a bodiless NotFound.
+func isNotFound(err error) bool {
+	var noSuchKey *types.NoSuchKey
+	var notFound *types.NotFound
+	return errors.As(err, &noSuchKey) || errors.As(err, &notFound)
+}
+
 // CopyObject copies an object within the same bucket
 func (s *S3Storage) CopyObject(ctx context.Context, sourceKey, destinationKey string) error {
 	_, err := s.client.CopyObject(ctx, &s3.CopyObjectInput{
@@ -0,0 +1,265 @@
+package cloud
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// fakeS3 serves the subset of the S3 REST API that S3Storage uses, for a
+// single bucket addressed path-style
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string]fakeObject
+	// uploads maps the ID of each multipart upload in progress to its key
+	uploads map[string]string
+	// completed holds the part numbers each finished upload was assembled from
+	completed map[string][]int32
+}
+
+type fakeObject struct {
+	contentType string
+	data        []byte
+}
+
+// newFakeS3 starts a fake S3 server and returns storage talking to it
+func newFakeS3(t *testing.T) (*S3Storage, *fakeS3) {
+	t.Helper()
+
+	fake := &fakeS3{
+		objects:   make(map[string]fakeObject),
+		uploads:   make(map[string]string),
+		completed: make(map[string][]int32),
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	client := s3.New(s3.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(server.URL),
+		UsePathStyle: true,
+		Credentials:  credentials.NewStaticCredentialsProvider("access", "secret", ""),
+	})
+	return &S3Storage{client: client, bucketName: "videos", region: "us-east-1", endpoint: server.URL}, fake
+}
+
+func (f *fakeS3) put(key, contentType string, data []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.objects[key] = fakeObject{contentType: contentType, data: data}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key, ok := strings.CutPrefix(r.URL.Path, "/videos/")
+	if !ok {
+		writeS3Error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	f.mu.Lock()
+	object, found := f.objects[key]
+	f.mu.Unlock()
+
+	query := r.URL.Query()
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		f.createUpload(w, key)
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		f.completeUpload(w, r, key, query.Get("uploadId"))
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		f.mu.Lock()
+		delete(f.uploads, query.Get("uploadId"))
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		if !found {
+			if r.Method == http.MethodHead {
+				// HEAD errors carry no body
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Content-Type", object.contentType)
+		w.Header().Set("Content-Length", fmt.Sprint(len(object.data)))
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			w.Write(object.data)
+		}
+	default:
+		writeS3Error(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func (f *fakeS3) createUpload(w http.ResponseWriter, key string) {
+	f.mu.Lock()
+	uploadID := fmt.Sprintf("upload-%d", len(f.uploads)+len(f.completed)+1)
+	f.uploads[uploadID] = key
+	f.mu.Unlock()
+
+	writeS3XML(w, struct {
+		XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+		Bucket   string
+		Key      string
+		UploadId string
+	}{Bucket: "videos", Key: key, UploadId: uploadID})
+}
+
+func (f *fakeS3) completeUpload(w http.ResponseWriter, r *http.Request, key, uploadID string) {
+	var body struct {
+		Parts []struct {
+			PartNumber int32
+			ETag       string
+		} `xml:"Part"`
+	}
+	if err := xml.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeS3Error(w, http.StatusBadRequest, "MalformedXML")
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.uploads[uploadID] != key {
+		writeS3Error(w, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+	delete(f.uploads, uploadID)
+	for _, part := range body.Parts {
+		f.completed[uploadID] = append(f.completed[uploadID], part.PartNumber)
+	}
+
+	writeS3XML(w, struct {
+		XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+		Bucket  string
+		Key     string
+		ETag    string
+	}{Bucket: "videos", Key: key, ETag: `"assembled"`})
+}
+
+// writeS3XML answers 200 with an XML document
+func writeS3XML(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(v)
+}
+
+// writeS3Error answers with an S3 error document
+func writeS3Error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string
+		Message string
+	}{Code: code, Message: code})
+}
+
+func TestS3OpenReader(t *testing.T) {
+	storage, fake := newFakeS3(t)
+	fake.put("videos/v1/source.mp4", "video/mp4", []byte("video bytes"))
+
+	r, err := storage.OpenReader(context.Background(), "videos/v1/source.mp4")
+	if err != nil {
+		t.Fatalf("OpenReader: %v", err)
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || string(data) != "video bytes" {
+		t.Errorf("read %q, %v; want the stored bytes", data, err)
+	}
+
+	if _, err := storage.OpenReader(context.Background(), "videos/missing.mp4"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("OpenReader of a missing key error = %v, want %v", err, os.ErrNotExist)
+	}
+}
+
+func TestS3MultipartUpload(t *testing.T) {
+	storage, fake := newFakeS3(t)
+	ctx := context.Background()
+
+	uploadID, err := storage.CreateMultipartUpload(ctx, "videos/v1", "video/mp4")
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload: %v", err)
+	}
+	if uploadID == "" {
+		t.Fatal("CreateMultipartUpload returned no upload ID")
+	}
+
+	partURL, err := storage.PresignUploadPart(ctx, "videos/v1", uploadID, 2, time.Hour)
+	if err != nil {
+		t.Fatalf("PresignUploadPart: %v", err)
+	}
+	u, err := url.Parse(partURL)
+	if err != nil {
+		t.Fatalf("failed to parse %q: %v", partURL, err)
+	}
+	query := u.Query()
+	if u.Path != "/videos/videos/v1" || query.Get("uploadId") != uploadID || query.Get("partNumber") != "2" {
+		t.Errorf("part URL %s does not address part 2 of %s", partURL, uploadID)
+	}
+	if query.Get("X-Amz-Signature") == "" || query.Get("X-Amz-Expires") != "3600" {
+		t.Errorf("part URL %s is not presigned for an hour", partURL)
+	}
+
+	// Parts may be reported in any order, S3 wants them ascending
+	parts := []CompletedPart{{PartNumber: 3, ETag: `"c"`}, {PartNumber: 1, ETag: `"a"`}, {PartNumber: 2, ETag: `"b"`}}
+	if err := storage.CompleteMultipartUpload(ctx, "videos/v1", uploadID, parts); err != nil {
+		t.Fatalf("CompleteMultipartUpload: %v", err)
+	}
+	if got := fake.completed[uploadID]; !slices.Equal(got, []int32{1, 2, 3}) {
+		t.Errorf("upload assembled from parts %v, want [1 2 3]", got)
+	}
+
+	// Completing an upload that no longer exists fails
+	if err := storage.CompleteMultipartUpload(ctx, "videos/v1", uploadID, parts); err == nil {
+		t.Errorf("CompleteMultipartUpload of a finished upload succeeded")
+	}
+}
+
+func TestS3AbortMultipartUpload(t *testing.T) {
+	storage, fake := newFakeS3(t)
+	ctx := context.Background()
+
+	uploadID, err := storage.CreateMultipartUpload(ctx, "videos/v1", "video/mp4")
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload: %v", err)
+	}
+	if err := storage.AbortMultipartUpload(ctx, "videos/v1", uploadID); err != nil {
+		t.Fatalf("AbortMultipartUpload: %v", err)
+	}
+	if _, ok := fake.uploads[uploadID]; ok {
+		t.Errorf("upload %s still in progress after abort", uploadID)
+	}
+}
+
+func TestS3StatObject(t *testing.T) {
+	storage, fake := newFakeS3(t)
+	fake.put("videos/v1/source.mp4", "video/mp4", []byte("video bytes"))
+
+	size, contentType, err := storage.StatObject(context.Background(), "videos/v1/source.mp4")
+	if err != nil {
+		t.Fatalf("StatObject: %v", err)
+	}
+	if size != int64(len("video bytes")) || contentType != "video/mp4" {
+		t.Errorf("StatObject = %d bytes of %s, want %d bytes of video/mp4", size, contentType, len("video bytes"))
+	}
+
+	if _, _, err := storage.StatObject(context.Background(), "videos/missing.mp4"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("StatObject of a missing key error = %v, want %v", err, os.ErrNotExist)
+	}
+}
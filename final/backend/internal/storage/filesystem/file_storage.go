@@ -2,69 +2,134 @@ package filesystem
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"net/url"
+	"io"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
+// ErrInvalidPath is returned for paths that would resolve outside the storage root
+var ErrInvalidPath = errors.New("invalid path")
+
 // FileSystemStorage implements the FileStorage interface using the local filesystem
 type FileSystemStorage struct {
 	rootDir     string
+	realRootDir string // rootDir with symlinks resolved
 	baseURL     string
+	signingKey  []byte // HMAC key for upload and download URLs
 }
 
-// NewFileSystemStorage creates a new file system storage
-func NewFileSystemStorage(rootDir, baseURL string) (*FileSystemStorage, error) {
+// NewFileSystemStorage creates a new file system storage. signingKey signs
+// the upload and download URLs it hands out and must not be empty.
+func NewFileSystemStorage(rootDir, baseURL string, signingKey []byte) (*FileSystemStorage, error) {
+	if len(signingKey) == 0 {
+		return nil, errors.New("URL signing key is required")
+	}
+	
 	// Ensure the root directory exists
 	if err := os.MkdirAll(rootDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create root directory: %w", err)
 	}
 
+	absRoot, err := filepath.Abs(rootDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve root directory: %w", err)
+	}
+	realRoot, err := filepath.EvalSymlinks(absRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve root directory: %w", err)
+	}
+
 	return &FileSystemStorage{
-		rootDir: rootDir,
-		baseURL: baseURL,
+		rootDir:     absRoot,
+		realRootDir: realRoot,
+		baseURL:     baseURL,
+		signingKey:  signingKey,
 	}, nil
 }
 
-// GenerateUploadURL creates a URL for uploading a file
-// In a real implementation, this would be more sophisticated,
-// possibly using signed URLs or a separate API endpoint
+// resolvePath maps a storage key to a filesystem path, rejecting keys that
+// are absolute, climb out of the root with "..", or pass through a symlink
+// that points outside it
+func (fs *FileSystemStorage) resolvePath(path string) (string, error) {
+	if filepath.IsAbs(path) || strings.HasPrefix(path, "/") || strings.HasPrefix(path, "\\") {
+		return "", fmt.Errorf("%w: %q is absolute", ErrInvalidPath, path)
+	}
+
+	fullPath := filepath.Join(fs.rootDir, path)
+	if !isWithin(fs.rootDir, fullPath) {
+		return "", fmt.Errorf("%w: %q escapes the storage root", ErrInvalidPath, path)
+	}
+
+	// Resolve symlinks in the part of the path that already exists; the
+	// rest is about to be created and can't be a link yet
+	existing, rest := fullPath, ""
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			if !isWithin(fs.realRootDir, filepath.Join(resolved, rest)) {
+				return "", fmt.Errorf("%w: %q links outside the storage root", ErrInvalidPath, path)
+			}
+			return fullPath, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = filepath.Dir(existing)
+	}
+}
+
+// isWithin reports whether path is root or lies below it
+func isWithin(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// GenerateUploadURL creates a URL for uploading a file, signed like download
+// URLs so that it only accepts this path until expiresIn has passed
 func (fs *FileSystemStorage) GenerateUploadURL(ctx context.Context, path string, contentType string, expiresIn time.Duration) (string, error) {
 	// Create any necessary directories
-	fullPath := filepath.Join(fs.rootDir, path)
+	fullPath, err := fs.resolvePath(path)
+	if err != nil {
+		return "", err
+	}
 	dir := filepath.Dir(fullPath)
 	
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return "", fmt.Errorf("failed to create directory: %w", err)
 	}
 	
-	// In a real implementation, we would use signed URLs or a separate API
-	// For this implementation, we'll just return a URL pointing to our API endpoint
-	// that would handle the upload
-	return fmt.Sprintf("%s/upload?path=%s&contentType=%s", 
-		fs.baseURL, 
-		url.QueryEscape(path), 
-		url.QueryEscape(contentType)), nil
+	return fs.signedUploadURL(path, contentType, time.Now().Add(expiresIn)), nil
 }
 
-// GenerateDownloadURL creates a URL for downloading a file
+// GenerateDownloadURL creates a URL for downloading a file, signed so that
+// it only works for this path until expiresIn has passed
 func (fs *FileSystemStorage) GenerateDownloadURL(ctx context.Context, path string, expiresIn time.Duration) (string, error) {
 	// Check if the file exists
-	fullPath := filepath.Join(fs.rootDir, path)
+	fullPath, err := fs.resolvePath(path)
+	if err != nil {
+		return "", err
+	}
 	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
 		return "", fmt.Errorf("file does not exist: %w", err)
 	}
 	
-	// In a real implementation, we would generate a signed URL
-	// For this implementation, we'll just return a URL to serve the file
-	return fmt.Sprintf("%s/download/%s", fs.baseURL, url.PathEscape(path)), nil
+	return fs.signedDownloadURL(path, time.Now().Add(expiresIn)), nil
 }
 
 // DeleteFile removes a file from the filesystem
 func (fs *FileSystemStorage) DeleteFile(ctx context.Context, path string) error {
-	fullPath := filepath.Join(fs.rootDir, path)
+	fullPath, err := fs.resolvePath(path)
+	if err != nil {
+		return err
+	}
 	
 	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
 		// File doesn't exist, nothing to delete
@@ -74,6 +139,71 @@ func (fs *FileSystemStorage) DeleteFile(ctx context.Context, path string) error
 	return os.Remove(fullPath)
 }
 
+// DeletePrefix removes the directory a key prefix maps to, with everything in it
+func (fs *FileSystemStorage) DeletePrefix(ctx context.Context, prefix string) error {
+	// Never let an empty prefix wipe the whole root
+	if strings.Trim(prefix, "/") == "" {
+		return fmt.Errorf("refusing to delete empty prefix")
+	}
+	
+	fullPath, err := fs.resolvePath(prefix)
+	if err != nil {
+		return err
+	}
+	
+	return os.RemoveAll(fullPath)
+}
+
+// HashFile returns the hex-encoded SHA-256 digest of a file's content
+func (fs *FileSystemStorage) HashFile(ctx context.Context, path string) (string, error) {
+	fullPath, err := fs.resolvePath(path)
+	if err != nil {
+		return "", err
+	}
+	
+	file, err := os.Open(fullPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+	
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// OpenReader opens a file for streaming its content. The caller must close
+// the returned reader.
+func (fs *FileSystemStorage) OpenReader(ctx context.Context, path string) (io.ReadCloser, error) {
+	fullPath, err := fs.resolvePath(path)
+	if err != nil {
+		return nil, err
+	}
+	
+	return os.Open(fullPath)
+}
+
+// StatObject returns a file's size and its content type, which is sniffed
+// from the first bytes since the filesystem keeps no metadata
+func (fs *FileSystemStorage) StatObject(ctx context.Context, path string) (int64, string, error) {
+	file, info, err := fs.OpenFile(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer file.Close()
+	
+	header := make([]byte, 512)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return 0, "", fmt.Errorf("failed to read file: %w", err)
+	}
+	
+	return info.Size(), http.DetectContentType(header[:n]), nil
+}
+
 // DeleteObject implements the S3Storage interface for backward compatibility
 func (fs *FileSystemStorage) DeleteObject(ctx context.Context, key string) error {
 	return fs.DeleteFile(ctx, key)
@@ -81,7 +211,10 @@ func (fs *FileSystemStorage) DeleteObject(ctx context.Context, key string) error
 
 // SaveFile saves data to a file
 func (fs *FileSystemStorage) SaveFile(path string, data []byte) error {
-	fullPath := filepath.Join(fs.rootDir, path)
+	fullPath, err := fs.resolvePath(path)
+	if err != nil {
+		return err
+	}
 	
 	// Create any necessary directories
 	dir := filepath.Dir(fullPath)
@@ -92,13 +225,60 @@ func (fs *FileSystemStorage) SaveFile(path string, data []byte) error {
 	return os.WriteFile(fullPath, data, 0644)
 }
 
+// CreateFile opens a file for writing, creating any missing directories and
+// truncating an existing file. The caller must close the returned writer.
+func (fs *FileSystemStorage) CreateFile(path string) (io.WriteCloser, error) {
+	fullPath, err := fs.resolvePath(path)
+	if err != nil {
+		return nil, err
+	}
+	
+	// Create any necessary directories
+	dir := filepath.Dir(fullPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	
+	return os.Create(fullPath)
+}
+
+// OpenFile opens a file for reading along with its metadata, so it can be
+// served with seeking and conditional requests. Directories are reported as
+// not existing. The caller must close the returned file.
+func (fs *FileSystemStorage) OpenFile(path string) (io.ReadSeekCloser, os.FileInfo, error) {
+	fullPath, err := fs.resolvePath(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	
+	file, err := os.Open(fullPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	if info.IsDir() {
+		file.Close()
+		return nil, nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+	}
+	
+	return file, info, nil
+}
+
 // ReadFile reads data from a file
 func (fs *FileSystemStorage) ReadFile(path string) ([]byte, error) {
-	fullPath := filepath.Join(fs.rootDir, path)
+	fullPath, err := fs.resolvePath(path)
+	if err != nil {
+		return nil, err
+	}
 	return os.ReadFile(fullPath)
 }
 
 // GetFilePath returns the full path to a file
-func (fs *FileSystemStorage) GetFilePath(path string) string {
-	return filepath.Join(fs.rootDir, path)
+func (fs *FileSystemStorage) GetFilePath(path string) (string, error) {
+	return fs.resolvePath(path)
 }
\ No newline at end of file
@@ -0,0 +1,185 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newEscapeFixture returns a storage whose root holds a symlink "link" to a
+// directory outside it, and that directory, which holds a file "secret"
+func newEscapeFixture(t *testing.T) (*FileSystemStorage, string) {
+	t.Helper()
+
+	fs := newTestStorage(t)
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(fs.rootDir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	return fs, outside
+}
+
+func TestResolvePath(t *testing.T) {
+	fs, outside := newEscapeFixture(t)
+	rel, err := filepath.Rel(fs.rootDir, filepath.Join(outside, "secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{"plain key", "videos/v1/video.mp4", false},
+		{"dot segments staying inside", "videos/../thumbnails/./v1.jpg", false},
+		{"root", ".", false},
+		{"parent", "..", true},
+		{"parent file", "../secret", true},
+		{"nested parent", "videos/../../secret", true},
+		{"sibling directory", rel, true},
+		{"absolute", "/etc/passwd", true},
+		{"absolute inside root", filepath.Join(fs.rootDir, "videos"), true},
+		{"backslash", "\\etc\\passwd", true},
+		{"symlink", "link", true},
+		{"through symlink", "link/secret", true},
+		{"new file through symlink", "link/new/file.mp4", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := fs.resolvePath(tt.path)
+			if tt.wantErr && !errors.Is(err, ErrInvalidPath) {
+				t.Errorf("resolvePath(%q) error = %v, want %v", tt.path, err, ErrInvalidPath)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("resolvePath(%q): %v", tt.path, err)
+			}
+		})
+	}
+}
+
+func TestFileMethodsRejectEscapes(t *testing.T) {
+	ctx := context.Background()
+	methods := []struct {
+		name string
+		call func(fs *FileSystemStorage, path string) error
+	}{
+		{"SaveFile", func(fs *FileSystemStorage, path string) error {
+			return fs.SaveFile(path, []byte("overwritten"))
+		}},
+		{"CreateFile", func(fs *FileSystemStorage, path string) error {
+			w, err := fs.CreateFile(path)
+			if err == nil {
+				w.Close()
+			}
+			return err
+		}},
+		{"ReadFile", func(fs *FileSystemStorage, path string) error {
+			_, err := fs.ReadFile(path)
+			return err
+		}},
+		{"OpenFile", func(fs *FileSystemStorage, path string) error {
+			f, _, err := fs.OpenFile(path)
+			if err == nil {
+				f.Close()
+			}
+			return err
+		}},
+		{"OpenReader", func(fs *FileSystemStorage, path string) error {
+			r, err := fs.OpenReader(ctx, path)
+			if err == nil {
+				r.Close()
+			}
+			return err
+		}},
+		{"HashFile", func(fs *FileSystemStorage, path string) error {
+			_, err := fs.HashFile(ctx, path)
+			return err
+		}},
+		{"DeleteFile", func(fs *FileSystemStorage, path string) error {
+			return fs.DeleteFile(ctx, path)
+		}},
+		{"DeletePrefix", func(fs *FileSystemStorage, path string) error {
+			return fs.DeletePrefix(ctx, path)
+		}},
+	}
+
+	for _, m := range methods {
+		t.Run(m.name, func(t *testing.T) {
+			fs, outside := newEscapeFixture(t)
+			rel, err := filepath.Rel(fs.rootDir, outside)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, path := range []string{rel + "/secret", "videos/../" + rel + "/secret", filepath.Join(outside, "secret"), "link/secret"} {
+				if err := m.call(fs, path); !errors.Is(err, ErrInvalidPath) {
+					t.Errorf("%s(%q) error = %v, want %v", m.name, path, err, ErrInvalidPath)
+				}
+			}
+
+			data, err := os.ReadFile(filepath.Join(outside, "secret"))
+			if err != nil || string(data) != "secret" {
+				t.Errorf("file outside the root was touched: %q, %v", data, err)
+			}
+		})
+	}
+}
+
+func TestOpenReader(t *testing.T) {
+	fs := newTestStorage(t)
+	if err := fs.SaveFile("videos/v1/source.mp4", []byte("video bytes")); err != nil {
+		t.Fatalf("SaveFile: %v", err)
+	}
+
+	r, err := fs.OpenReader(context.Background(), "videos/v1/source.mp4")
+	if err != nil {
+		t.Fatalf("OpenReader: %v", err)
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || string(data) != "video bytes" {
+		t.Errorf("read %q, %v; want the saved bytes", data, err)
+	}
+
+	if _, err := fs.OpenReader(context.Background(), "videos/missing.mp4"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("OpenReader of a missing key error = %v, want %v", err, os.ErrNotExist)
+	}
+}
+
+func TestStatObject(t *testing.T) {
+	fs := newTestStorage(t)
+	// An MP4 starts with an ftyp box, which is what content sniffing looks for
+	mp4 := append([]byte{0, 0, 0, 0x18, 'f', 't', 'y', 'p', 'm', 'p', '4', '2'}, make([]byte, 100)...)
+	if err := fs.SaveFile("videos/v1/source.mp4", mp4); err != nil {
+		t.Fatalf("SaveFile: %v", err)
+	}
+	if err := fs.SaveFile("videos/v2/empty", nil); err != nil {
+		t.Fatalf("SaveFile: %v", err)
+	}
+
+	size, contentType, err := fs.StatObject(context.Background(), "videos/v1/source.mp4")
+	if err != nil {
+		t.Fatalf("StatObject: %v", err)
+	}
+	if size != int64(len(mp4)) || contentType != "video/mp4" {
+		t.Errorf("StatObject = %d bytes of %s, want %d bytes of video/mp4", size, contentType, len(mp4))
+	}
+
+	if size, _, err := fs.StatObject(context.Background(), "videos/v2/empty"); err != nil || size != 0 {
+		t.Errorf("StatObject of an empty file = %d, %v; want 0", size, err)
+	}
+	if _, _, err := fs.StatObject(context.Background(), "videos/missing"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("StatObject of a missing key error = %v, want %v", err, os.ErrNotExist)
+	}
+	// A directory is not an object
+	if _, _, err := fs.StatObject(context.Background(), "videos/v1"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("StatObject of a directory error = %v, want %v", err, os.ErrNotExist)
+	}
+}
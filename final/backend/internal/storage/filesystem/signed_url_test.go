@@ -0,0 +1,118 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestStorage(t *testing.T) *FileSystemStorage {
+	t.Helper()
+
+	fs, err := NewFileSystemStorage(t.TempDir(), "http://localhost:8080", []byte("test-signing-key"))
+	if err != nil {
+		t.Fatalf("NewFileSystemStorage: %v", err)
+	}
+	return fs
+}
+
+// parseSignedURL returns the path, expires and sig parameters of a signed URL
+func parseSignedURL(t *testing.T, raw string) (path, expires, sig string) {
+	t.Helper()
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("failed to parse %q: %v", raw, err)
+	}
+	query := u.Query()
+	return query.Get("path"), query.Get("expires"), query.Get("sig")
+}
+
+func TestUploadURLSignature(t *testing.T) {
+	fs := newTestStorage(t)
+	now := time.Now()
+
+	raw, err := fs.GenerateUploadURL(context.Background(), "videos/v1/source.mp4", "video/mp4", time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateUploadURL: %v", err)
+	}
+	path, expires, sig := parseSignedURL(t, raw)
+	if path != "videos/v1/source.mp4" {
+		t.Fatalf("upload URL path = %q, want videos/v1/source.mp4", path)
+	}
+
+	// A download link for the same key must not double as an upload link
+	download := fs.signedDownloadURL(path, now.Add(time.Hour))
+	u, _ := url.Parse(download)
+	downloadSig := u.Query().Get("sig")
+
+	tests := []struct {
+		name    string
+		path    string
+		expires string
+		sig     string
+		now     time.Time
+		wantErr error
+	}{
+		{"valid", path, expires, sig, now, nil},
+		{"other path", "videos/v2/source.mp4", expires, sig, now, ErrInvalidSignature},
+		{"missing signature", path, expires, "", now, ErrInvalidSignature},
+		{"extended expiry", path, "9999999999", sig, now, ErrInvalidSignature},
+		{"bad expiry", path, "soon", sig, now, ErrInvalidSignature},
+		{"download signature", path, expires, downloadSig, now, ErrInvalidSignature},
+		{"expired", path, expires, sig, now.Add(2 * time.Hour), ErrURLExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := fs.VerifyUploadURL(tt.path, tt.expires, tt.sig, tt.now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("VerifyUploadURL error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDownloadURLRejectsUploadSignature(t *testing.T) {
+	fs := newTestStorage(t)
+
+	raw, err := fs.GenerateUploadURL(context.Background(), "videos/v1/source.mp4", "video/mp4", time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateUploadURL: %v", err)
+	}
+	path, expires, sig := parseSignedURL(t, raw)
+
+	if err := fs.VerifyDownloadURL(path, expires, sig, time.Now()); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("VerifyDownloadURL error = %v, want %v", err, ErrInvalidSignature)
+	}
+}
+
+func TestDownloadURLSignature(t *testing.T) {
+	fs := newTestStorage(t)
+	if err := os.MkdirAll(filepath.Join(fs.rootDir, "videos", "v1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(fs.rootDir, "videos", "v1", "video.mp4"), []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := fs.GenerateDownloadURL(context.Background(), "videos/v1/video.mp4", time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateDownloadURL: %v", err)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("failed to parse %q: %v", raw, err)
+	}
+	query := u.Query()
+
+	if err := fs.VerifyDownloadURL("videos/v1/video.mp4", query.Get("expires"), query.Get("sig"), time.Now()); err != nil {
+		t.Errorf("VerifyDownloadURL: %v", err)
+	}
+	if err := fs.VerifyDownloadURL("videos/v2/video.mp4", query.Get("expires"), query.Get("sig"), time.Now()); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("VerifyDownloadURL for another path error = %v, want %v", err, ErrInvalidSignature)
+	}
+}
@@ -0,0 +1,80 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"videostreaming/internal/service/transcode"
+	pb "videostreaming/proto/video"
+)
+
+// TranscodeStorage implements an in-memory storage for transcoding jobs.
+// Jobs are lost on restart, so it only suits development.
+type TranscodeStorage struct {
+	jobs  map[string]*transcode.TranscodingJob // maps job ID to its latest state
+	mutex sync.RWMutex
+}
+
+// NewTranscodeStorage creates a new in-memory transcoding job storage
+func NewTranscodeStorage() *TranscodeStorage {
+	return &TranscodeStorage{
+		jobs: make(map[string]*transcode.TranscodingJob),
+	}
+}
+
+// SaveTranscodingJob saves a copy of a job, replacing it if it already exists
+func (s *TranscodeStorage) SaveTranscodingJob(ctx context.Context, job *transcode.TranscodingJob) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.jobs[job.ID] = copyTranscodingJob(job)
+	return nil
+}
+
+// UpdateTranscodingJob stores the latest state of a job
+func (s *TranscodeStorage) UpdateTranscodingJob(ctx context.Context, job *transcode.TranscodingJob) error {
+	return s.SaveTranscodingJob(ctx, job)
+}
+
+// GetTranscodingJobs retrieves every transcoding job of a video, oldest first
+func (s *TranscodeStorage) GetTranscodingJobs(ctx context.Context, videoID string) ([]*transcode.TranscodingJob, error) {
+	return s.findJobs(func(job *transcode.TranscodingJob) bool {
+		return job.VideoID == videoID
+	}), nil
+}
+
+// ListActiveTranscodingJobs retrieves the jobs that are queued or still processing
+func (s *TranscodeStorage) ListActiveTranscodingJobs(ctx context.Context) ([]*transcode.TranscodingJob, error) {
+	return s.findJobs(func(job *transcode.TranscodingJob) bool {
+		return job.Status == pb.TranscodingStatus_TRANSCODING_STATUS_QUEUED ||
+			job.Status == pb.TranscodingStatus_TRANSCODING_STATUS_PROCESSING
+	}), nil
+}
+
+// findJobs returns copies of the jobs matching keep, oldest first
+func (s *TranscodeStorage) findJobs(keep func(job *transcode.TranscodingJob) bool) []*transcode.TranscodingJob {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	jobs := make([]*transcode.TranscodingJob, 0)
+	for _, job := range s.jobs {
+		if keep(job) {
+			jobs = append(jobs, copyTranscodingJob(job))
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].StartTime.Before(jobs[j].StartTime)
+	})
+	return jobs
+}
+
+// copyTranscodingJob returns a copy of a job, so callers never share the stored one
+func copyTranscodingJob(job *transcode.TranscodingJob) *transcode.TranscodingJob {
+	c := *job
+	if job.CompletionTime != nil {
+		completed := *job.CompletionTime
+		c.CompletionTime = &completed
+	}
+	return &c
+}
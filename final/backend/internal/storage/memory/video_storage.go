@@ -2,10 +2,14 @@ package memory
 
 import (
 	"context"
-	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 	
 	"videostreaming/internal/service/video"
+	pb "videostreaming/proto/video"
 )
 
 // VideoStorage implements an in-memory storage for videos
@@ -13,6 +17,7 @@ type VideoStorage struct {
 	videos     map[string]*video.Video
 	liveStreams map[string]*video.LiveStream
 	streamKeys map[string]string // maps userID to streamKey
+	streamMessages map[string][]*video.StreamMessage // maps streamID to its chat, oldest first
 	mutex      sync.RWMutex
 }
 
@@ -22,16 +27,22 @@ func NewVideoStorage() *VideoStorage {
 		videos:     make(map[string]*video.Video),
 		liveStreams: make(map[string]*video.LiveStream),
 		streamKeys: make(map[string]string),
+		streamMessages: make(map[string][]*video.StreamMessage),
 		mutex:      sync.RWMutex{},
 	}
 }
 
-// SaveVideo saves a video to storage
+// SaveVideo saves a copy of a video to storage. An existing video keeps
+// its stored view count, which only IncrementViewCount changes.
 func (s *VideoStorage) SaveVideo(ctx context.Context, video *video.Video) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	
-	s.videos[video.ID] = video
+	saved := copyVideo(video)
+	if existing, ok := s.videos[video.ID]; ok {
+		saved.ViewCount = existing.ViewCount
+	}
+	s.videos[video.ID] = saved
 	return nil
 }
 
@@ -42,33 +53,137 @@ func (s *VideoStorage) GetVideo(ctx context.Context, id string) (*video.Video, e
 	
 	v, ok := s.videos[id]
 	if !ok {
-		return nil, errors.New("video not found")
+		return nil, video.ErrNotFound
+	}
+	
+	return copyVideo(v), nil
+}
+
+// GetVideos retrieves the videos that exist among ids
+func (s *VideoStorage) GetVideos(ctx context.Context, ids []string) ([]*video.Video, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	
+	result := make([]*video.Video, 0, len(ids))
+	for _, id := range ids {
+		if v, ok := s.videos[id]; ok {
+			result = append(result, copyVideo(v))
+		}
 	}
 	
-	return v, nil
+	return result, nil
 }
 
 // ListVideos returns a list of videos
-func (s *VideoStorage) ListVideos(ctx context.Context, userID string, limit int, offset int) ([]*video.Video, int, error) {
+func (s *VideoStorage) ListVideos(ctx context.Context, filter video.ListVideosFilter, limit int, offset int) ([]*video.Video, int, error) {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 	
+	// Apply whichever filters are set, leaving out videos in the trash and
+	// other users' private videos
+	var matches []*video.Video
+	for _, v := range s.videos {
+		if v.DeletedAt == nil &&
+			(filter.UserID == "" || v.UserID == filter.UserID) &&
+			(filter.Status == pb.VideoStatus_VIDEO_STATUS_UNSPECIFIED || v.Status == filter.Status) &&
+			(filter.Visibility == pb.VideoVisibility_VIDEO_VISIBILITY_UNSPECIFIED || v.Visibility == filter.Visibility) &&
+			(v.Visibility != pb.VideoVisibility_VIDEO_VISIBILITY_PRIVATE || v.UserID == filter.ViewerID) {
+			matches = append(matches, v)
+		}
+	}
+	
+	// Map iteration order is random, so sort before paginating
+	sortVideos(matches, filter.SortBy)
+	
 	var result []*video.Video
-	var count int
+	for i := offset; i < len(matches) && (limit <= 0 || len(result) < limit); i++ {
+		result = append(result, copyVideo(matches[i]))
+	}
 	
-	// Filter by userID if provided
-	for _, v := range s.videos {
-		if userID == "" || v.UserID == userID {
-			count++
-			
-			// Apply pagination
-			if count > offset && (limit <= 0 || len(result) < limit) {
-				result = append(result, v)
+	return result, len(matches), nil
+}
+
+// sortVideos orders videos as requested, breaking ties by ID so that pages
+// never overlap
+func sortVideos(videos []*video.Video, sortBy pb.VideoSortBy) {
+	sort.Slice(videos, func(i, j int) bool {
+		a, b := videos[i], videos[j]
+		switch sortBy {
+		case pb.VideoSortBy_VIDEO_SORT_BY_CREATED_ASC:
+			if !a.CreatedAt.Equal(b.CreatedAt) {
+				return a.CreatedAt.Before(b.CreatedAt)
+			}
+		case pb.VideoSortBy_VIDEO_SORT_BY_VIEWS_DESC:
+			if a.ViewCount != b.ViewCount {
+				return a.ViewCount > b.ViewCount
+			}
+		case pb.VideoSortBy_VIDEO_SORT_BY_TITLE_ASC:
+			if a.Title != b.Title {
+				return a.Title < b.Title
+			}
+		default:
+			if !a.CreatedAt.Equal(b.CreatedAt) {
+				return a.CreatedAt.After(b.CreatedAt)
 			}
 		}
+		return a.ID < b.ID
+	})
+}
+
+// SearchVideos returns videos whose title, description or tags contain any
+// word of the query, ignoring case. Videos matching more words come first,
+// then newer ones.
+func (s *VideoStorage) SearchVideos(ctx context.Context, query string, limit int, offset int) ([]*video.Video, int, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	
+	words := strings.Fields(strings.ToLower(query))
+	
+	type match struct {
+		video *video.Video
+		score int
 	}
+	var matches []match
+	for _, v := range s.videos {
+		if v.DeletedAt != nil {
+			continue
+		}
+		if score := searchScore(v, words); score > 0 {
+			matches = append(matches, match{video: v, score: score})
+		}
+	}
+	
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if !a.video.CreatedAt.Equal(b.video.CreatedAt) {
+			return a.video.CreatedAt.After(b.video.CreatedAt)
+		}
+		return a.video.ID < b.video.ID
+	})
 	
-	return result, count, nil
+	var result []*video.Video
+	for i := offset; i < len(matches) && (limit <= 0 || len(result) < limit); i++ {
+		result = append(result, copyVideo(matches[i].video))
+	}
+	
+	return result, len(matches), nil
+}
+
+// searchScore counts how many of the lowercase words occur in a video's
+// title, description or tags
+func searchScore(v *video.Video, words []string) int {
+	text := strings.ToLower(v.Title + "\n" + v.Description + "\n" + strings.Join(v.Tags, "\n"))
+	
+	score := 0
+	for _, word := range words {
+		if strings.Contains(text, word) {
+			score++
+		}
+	}
+	return score
 }
 
 // DeleteVideo removes a video from storage
@@ -78,18 +193,85 @@ func (s *VideoStorage) DeleteVideo(ctx context.Context, id string, userID string
 	
 	v, ok := s.videos[id]
 	if (!ok) {
-		return errors.New("video not found")
+		return video.ErrNotFound
 	}
 	
 	// Check if the user owns the video
 	if v.UserID != userID {
-		return errors.New("not authorized to delete this video")
+		return fmt.Errorf("%w: not authorized to delete this video", video.ErrPermissionDenied)
 	}
 	
 	delete(s.videos, id)
 	return nil
 }
 
+// ListVideosByContentHash returns every video whose upload has the given hash
+func (s *VideoStorage) ListVideosByContentHash(ctx context.Context, hash string) ([]*video.Video, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	
+	var result []*video.Video
+	for _, v := range s.videos {
+		if v.ContentHash == hash {
+			result = append(result, copyVideo(v))
+		}
+	}
+	
+	return result, nil
+}
+
+// GetUserStorageUsage sums the file sizes of a user's videos
+func (s *VideoStorage) GetUserStorageUsage(ctx context.Context, userID string) (int64, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	
+	var usage int64
+	for _, v := range s.videos {
+		if v.UserID == userID {
+			usage += v.FileSizeBytes
+		}
+	}
+	
+	return usage, nil
+}
+
+// ListDeletedVideos returns up to limit videos moved to the trash before
+// deletedBefore, longest deleted first
+func (s *VideoStorage) ListDeletedVideos(ctx context.Context, deletedBefore time.Time, limit int) ([]*video.Video, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	
+	var result []*video.Video
+	for _, v := range s.videos {
+		if v.DeletedAt != nil && v.DeletedAt.Before(deletedBefore) {
+			result = append(result, copyVideo(v))
+		}
+	}
+	
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].DeletedAt.Before(*result[j].DeletedAt)
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	
+	return result, nil
+}
+
+// IncrementViewCount atomically increments a video's view count
+func (s *VideoStorage) IncrementViewCount(ctx context.Context, videoID string) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	v, ok := s.videos[videoID]
+	if !ok {
+		return 0, video.ErrNotFound
+	}
+	
+	v.ViewCount++
+	return v.ViewCount, nil
+}
+
 // SaveStreamKey stores a stream key for a user
 func (s *VideoStorage) SaveStreamKey(ctx context.Context, userID string, streamKey string) error {
 	s.mutex.Lock()
@@ -106,18 +288,36 @@ func (s *VideoStorage) GetStreamKey(ctx context.Context, userID string) (string,
 	
 	key, ok := s.streamKeys[userID]
 	if (!ok) {
-		return "", errors.New("stream key not found")
+		return "", video.ErrNotFound
 	}
 	
 	return key, nil
 }
 
-// SaveLiveStream saves a live stream
+// GetStreamKeyOwner returns the user holding a stream key, if any
+func (s *VideoStorage) GetStreamKeyOwner(ctx context.Context, streamKey string) (string, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	
+	for userID, key := range s.streamKeys {
+		if key == streamKey {
+			return userID, nil
+		}
+	}
+	return "", nil
+}
+
+// SaveLiveStream saves a copy of a live stream. An existing stream keeps
+// its stored viewer count, which only AdjustViewerCount changes.
 func (s *VideoStorage) SaveLiveStream(ctx context.Context, stream *video.LiveStream) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	
-	s.liveStreams[stream.StreamID] = stream
+	saved := copyLiveStream(stream)
+	if existing, ok := s.liveStreams[stream.StreamID]; ok {
+		saved.ViewerCount = existing.ViewerCount
+	}
+	s.liveStreams[stream.StreamID] = saved
 	return nil
 }
 
@@ -127,55 +327,174 @@ func (s *VideoStorage) GetLiveStream(ctx context.Context, streamID string) (*vid
 	defer s.mutex.RUnlock()
 	
 	stream, ok := s.liveStreams[streamID]
-	if (!ok) {
-		return nil, errors.New("live stream not found")
+	if (!ok) || stream.Status == pb.StreamStatus_STREAM_STATUS_ENDED {
+		return nil, video.ErrStreamNotFound
+	}
+	
+	return copyLiveStream(stream), nil
+}
+
+// GetLiveStreamByID retrieves a live stream by ID, including ended streams
+func (s *VideoStorage) GetLiveStreamByID(ctx context.Context, streamID string) (*video.LiveStream, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	
+	stream, ok := s.liveStreams[streamID]
+	if !ok {
+		return nil, video.ErrStreamNotFound
 	}
 	
-	return stream, nil
+	return copyLiveStream(stream), nil
 }
 
-// EndLiveStream ends a live stream
+// GetLiveStreamsByIDs retrieves the live streams that exist among streamIDs
+func (s *VideoStorage) GetLiveStreamsByIDs(ctx context.Context, streamIDs []string) ([]*video.LiveStream, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	
+	result := make([]*video.LiveStream, 0, len(streamIDs))
+	for _, id := range streamIDs {
+		if stream, ok := s.liveStreams[id]; ok && stream.Status != pb.StreamStatus_STREAM_STATUS_ENDED {
+			result = append(result, copyLiveStream(stream))
+		}
+	}
+	
+	return result, nil
+}
+
+// EndLiveStream marks a live stream as ended and keeps it for history.
+// Ending an already ended stream succeeds without changing it.
 func (s *VideoStorage) EndLiveStream(ctx context.Context, streamID string, userID string) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	
 	stream, ok := s.liveStreams[streamID]
 	if (!ok) {
-		return errors.New("live stream not found")
+		return video.ErrStreamNotFound
 	}
 	
 	// Check if the user owns the stream
 	if stream.UserID != userID {
-		return errors.New("not authorized to end this stream")
+		return fmt.Errorf("%w: not authorized to end this stream", video.ErrPermissionDenied)
 	}
 	
-	// In a real implementation, we would mark the stream as ended
-	// but keep it in the database. For this in-memory implementation,
-	// we'll just remove it.
-	delete(s.liveStreams, streamID)
+	if stream.Status == pb.StreamStatus_STREAM_STATUS_ENDED {
+		return nil
+	}
+	
+	now := time.Now()
+	stream.Status = pb.StreamStatus_STREAM_STATUS_ENDED
+	stream.EndedAt = &now
 	
 	return nil
 }
 
-// ListLiveStreams returns active live streams
-func (s *VideoStorage) ListLiveStreams(ctx context.Context, userID string, limit int, offset int) ([]*video.LiveStream, int, error) {
+// AdjustViewerCount changes the viewer count of a live stream, clamped at
+// zero and, for viewers joining, capped at maxViewers
+func (s *VideoStorage) AdjustViewerCount(ctx context.Context, streamID string, delta int64, maxViewers int64) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	stream, ok := s.liveStreams[streamID]
+	if !ok || stream.Status == pb.StreamStatus_STREAM_STATUS_ENDED {
+		return 0, video.ErrStreamNotFound
+	}
+	if delta > 0 && maxViewers > 0 && stream.ViewerCount+delta > maxViewers {
+		return stream.ViewerCount, video.ErrStreamAtCapacity
+	}
+	
+	stream.ViewerCount = max(stream.ViewerCount+delta, 0)
+	return stream.ViewerCount, nil
+}
+
+// SaveStreamMessage appends a chat message to its stream
+func (s *VideoStorage) SaveStreamMessage(ctx context.Context, message *video.StreamMessage) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	s.streamMessages[message.StreamID] = append(s.streamMessages[message.StreamID], message)
+	return nil
+}
+
+// ListStreamMessages returns up to limit of a stream's messages posted
+// before the given time, newest first
+func (s *VideoStorage) ListStreamMessages(ctx context.Context, streamID string, limit int, before time.Time) ([]*video.StreamMessage, error) {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 	
-	var result []*video.LiveStream
-	var count int
+	// Messages are appended as they are posted, so walk back from the newest
+	all := s.streamMessages[streamID]
+	result := make([]*video.StreamMessage, 0, limit)
+	for i := len(all) - 1; i >= 0 && len(result) < limit; i-- {
+		if all[i].CreatedAt.Before(before) {
+			result = append(result, all[i])
+		}
+	}
 	
+	return result, nil
+}
+
+// ListLiveStreams returns active live streams
+func (s *VideoStorage) ListLiveStreams(ctx context.Context, userID string, limit int, offset int) ([]*video.LiveStream, int, error) {
 	// Filter by userID if provided
+	result, total := s.listLiveStreams(func(stream *video.LiveStream) bool {
+		return userID == "" || stream.UserID == userID
+	}, limit, offset)
+	return result, total, nil
+}
+
+// ListLiveStreamsByCategory returns active live streams in a category, or
+// in every category when it is empty
+func (s *VideoStorage) ListLiveStreamsByCategory(ctx context.Context, category string, limit int, offset int) ([]*video.LiveStream, int, error) {
+	result, total := s.listLiveStreams(func(stream *video.LiveStream) bool {
+		return category == "" || stream.Category == category
+	}, limit, offset)
+	return result, total, nil
+}
+
+// listLiveStreams returns a page of the streams that haven't ended and
+// match keep, along with how many match in total
+func (s *VideoStorage) listLiveStreams(keep func(*video.LiveStream) bool, limit int, offset int) ([]*video.LiveStream, int) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	
+	var matches []*video.LiveStream
 	for _, stream := range s.liveStreams {
-		if userID == "" || stream.UserID == userID {
-			count++
-			
-			// Apply pagination
-			if count > offset && (limit <= 0 || len(result) < limit) {
-				result = append(result, stream)
-			}
+		if stream.Status == pb.StreamStatus_STREAM_STATUS_ENDED {
+			continue
+		}
+		if keep(stream) {
+			matches = append(matches, stream)
 		}
 	}
 	
-	return result, count, nil
-}
\ No newline at end of file
+	// Newest first like the MongoDB storage, with the ID as a tie-breaker so
+	// that pages never overlap
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if !a.StartedAt.Equal(b.StartedAt) {
+			return a.StartedAt.After(b.StartedAt)
+		}
+		return a.StreamID < b.StreamID
+	})
+	
+	var result []*video.LiveStream
+	for i := offset; i < len(matches) && (limit <= 0 || len(result) < limit); i++ {
+		result = append(result, copyLiveStream(matches[i]))
+	}
+	
+	return result, len(matches)
+}
+
+// copyVideo returns a copy of a stored video, so that callers can modify
+// what they read, and counters can change under the lock, without racing
+func copyVideo(v *video.Video) *video.Video {
+	c := *v
+	return &c
+}
+
+// copyLiveStream returns a copy of a stored live stream, like copyVideo
+func copyLiveStream(stream *video.LiveStream) *video.LiveStream {
+	c := *stream
+	return &c
+}
@@ -0,0 +1,199 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"videostreaming/internal/service/video"
+	pb "videostreaming/proto/video"
+)
+
+// walkPages pages through a listing of total items pageSize at a time and
+// returns their IDs in order, failing the test when one shows up twice
+func walkPages(t *testing.T, total, pageSize int, list func(limit, offset int) ([]string, int, error)) []string {
+	t.Helper()
+
+	var ids []string
+	seen := make(map[string]bool)
+	for offset := 0; offset < total; offset += pageSize {
+		page, count, err := list(pageSize, offset)
+		if err != nil {
+			t.Fatalf("listing at offset %d: %v", offset, err)
+		}
+		if count != total {
+			t.Errorf("total at offset %d = %d, want %d", offset, count, total)
+		}
+		for _, id := range page {
+			if seen[id] {
+				t.Errorf("%s listed on more than one page", id)
+			}
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) != total {
+		t.Errorf("pages held %d items, want all %d", len(ids), total)
+	}
+	return ids
+}
+
+func TestListVideosPages(t *testing.T) {
+	storage := NewVideoStorage()
+	ctx := context.Background()
+
+	// Some videos share a creation time, leaving the ID to order them
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 30; i++ {
+		err := storage.SaveVideo(ctx, &video.Video{
+			ID:         fmt.Sprintf("v%02d", i),
+			UserID:     "owner",
+			Visibility: pb.VideoVisibility_VIDEO_VISIBILITY_PUBLIC,
+			CreatedAt:  base.Add(time.Duration(i/3) * time.Minute),
+		})
+		if err != nil {
+			t.Fatalf("SaveVideo: %v", err)
+		}
+	}
+
+	list := func(limit, offset int) ([]string, int, error) {
+		videos, total, err := storage.ListVideos(ctx, video.ListVideosFilter{UserID: "owner"}, limit, offset)
+		var ids []string
+		for _, v := range videos {
+			ids = append(ids, v.ID)
+		}
+		return ids, total, err
+	}
+	first := walkPages(t, 30, 7, list)
+	if first[0] != "v27" || first[29] != "v02" {
+		t.Errorf("listed %s first and %s last, want the newest first", first[0], first[29])
+	}
+	if again := walkPages(t, 30, 7, list); fmt.Sprint(again) != fmt.Sprint(first) {
+		t.Errorf("second walk listed %v, want the same order as %v", again, first)
+	}
+}
+
+func TestListVideosSortBy(t *testing.T) {
+	storage := NewVideoStorage()
+	ctx := context.Background()
+
+	// b and d share a creation time and a title, a and c a view count
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, v := range []*video.Video{
+		{ID: "a", Title: "Birds", ViewCount: 10, CreatedAt: base},
+		{ID: "b", Title: "Cooking", ViewCount: 50, CreatedAt: base.Add(2 * time.Minute)},
+		{ID: "c", Title: "Alpine", ViewCount: 10, CreatedAt: base.Add(time.Minute)},
+		{ID: "d", Title: "Cooking", ViewCount: 0, CreatedAt: base.Add(2 * time.Minute)},
+	} {
+		v.UserID = "owner"
+		v.Visibility = pb.VideoVisibility_VIDEO_VISIBILITY_PUBLIC
+		if err := storage.SaveVideo(ctx, v); err != nil {
+			t.Fatalf("SaveVideo: %v", err)
+		}
+	}
+
+	tests := []struct {
+		sortBy pb.VideoSortBy
+		want   string
+	}{
+		{pb.VideoSortBy_VIDEO_SORT_BY_UNSPECIFIED, "[b d c a]"},
+		{pb.VideoSortBy_VIDEO_SORT_BY_CREATED_DESC, "[b d c a]"},
+		{pb.VideoSortBy_VIDEO_SORT_BY_CREATED_ASC, "[a c b d]"},
+		{pb.VideoSortBy_VIDEO_SORT_BY_VIEWS_DESC, "[b a c d]"},
+		{pb.VideoSortBy_VIDEO_SORT_BY_TITLE_ASC, "[c a b d]"},
+	}
+	for _, tt := range tests {
+		// Paging two at a time gives the same order as a single page
+		var ids []string
+		for offset := 0; offset < 4; offset += 2 {
+			videos, _, err := storage.ListVideos(ctx, video.ListVideosFilter{UserID: "owner", SortBy: tt.sortBy}, 2, offset)
+			if err != nil {
+				t.Fatalf("ListVideos: %v", err)
+			}
+			for _, v := range videos {
+				ids = append(ids, v.ID)
+			}
+		}
+		if got := fmt.Sprint(ids); got != tt.want {
+			t.Errorf("ListVideos sorted by %v = %s, want %s", tt.sortBy, got, tt.want)
+		}
+	}
+}
+
+func TestListLiveStreamsPages(t *testing.T) {
+	storage := NewVideoStorage()
+	ctx := context.Background()
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 30; i++ {
+		err := storage.SaveLiveStream(ctx, &video.LiveStream{
+			StreamID:  fmt.Sprintf("s%02d", i),
+			UserID:    "owner",
+			Status:    pb.StreamStatus_STREAM_STATUS_LIVE,
+			StartedAt: base.Add(time.Duration(i/3) * time.Minute),
+		})
+		if err != nil {
+			t.Fatalf("SaveLiveStream: %v", err)
+		}
+	}
+
+	list := func(limit, offset int) ([]string, int, error) {
+		streams, total, err := storage.ListLiveStreams(ctx, "owner", limit, offset)
+		var ids []string
+		for _, stream := range streams {
+			ids = append(ids, stream.StreamID)
+		}
+		return ids, total, err
+	}
+	first := walkPages(t, 30, 7, list)
+	if first[0] != "s27" || first[29] != "s02" {
+		t.Errorf("listed %s first and %s last, want the latest started first", first[0], first[29])
+	}
+	if again := walkPages(t, 30, 7, list); fmt.Sprint(again) != fmt.Sprint(first) {
+		t.Errorf("second walk listed %v, want the same order as %v", again, first)
+	}
+}
+
+func TestAdjustViewerCount(t *testing.T) {
+	storage := NewVideoStorage()
+	ctx := context.Background()
+
+	stream := &video.LiveStream{StreamID: "s1", UserID: "owner", Status: pb.StreamStatus_STREAM_STATUS_LIVE, StartedAt: time.Now()}
+	if err := storage.SaveLiveStream(ctx, stream); err != nil {
+		t.Fatalf("SaveLiveStream: %v", err)
+	}
+
+	count, err := storage.AdjustViewerCount(ctx, "s1", -5, 0)
+	if err != nil {
+		t.Fatalf("AdjustViewerCount: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("AdjustViewerCount went to %d, want it clamped at 0", count)
+	}
+	if count, err := storage.AdjustViewerCount(ctx, "s1", 1, 1); err != nil || count != 1 {
+		t.Errorf("AdjustViewerCount below the cap = %d, %v; want 1", count, err)
+	}
+	if _, err := storage.AdjustViewerCount(ctx, "s1", 1, 1); !errors.Is(err, video.ErrStreamAtCapacity) {
+		t.Errorf("AdjustViewerCount at the cap error = %v, want %v", err, video.ErrStreamAtCapacity)
+	}
+
+	// Saving the stream again keeps the viewers it has
+	if err := storage.SaveLiveStream(ctx, stream); err != nil {
+		t.Fatalf("SaveLiveStream: %v", err)
+	}
+	if count, err := storage.AdjustViewerCount(ctx, "s1", 1, 0); err != nil || count != 2 {
+		t.Errorf("AdjustViewerCount after saving again = %d, %v; want 2", count, err)
+	}
+
+	if _, err := storage.AdjustViewerCount(ctx, "missing", 1, 1); !errors.Is(err, video.ErrStreamNotFound) {
+		t.Errorf("AdjustViewerCount of a missing stream error = %v, want %v", err, video.ErrStreamNotFound)
+	}
+	if err := storage.EndLiveStream(ctx, "s1", "owner"); err != nil {
+		t.Fatalf("EndLiveStream: %v", err)
+	}
+	if _, err := storage.AdjustViewerCount(ctx, "s1", 1, 0); !errors.Is(err, video.ErrStreamNotFound) {
+		t.Errorf("AdjustViewerCount of an ended stream error = %v, want %v", err, video.ErrStreamNotFound)
+	}
+}
@@ -0,0 +1,518 @@
+//go:build integration
+
+// Integration tests against a real MongoDB server. Run them with
+//
+//	MONGO_URI=mongodb://... go test -tags integration ./internal/storage/mongodb/
+//
+// Each test works in a database of its own, dropped when it ends.
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"videostreaming/internal/service/transcode"
+	"videostreaming/internal/service/video"
+	pb "videostreaming/proto/video"
+)
+
+// newTestDatabase connects to MONGO_URI and returns the client and the
+// name of a fresh database
+func newTestDatabase(t *testing.T) (*mongo.Client, string) {
+	t.Helper()
+
+	uri := os.Getenv("MONGO_URI")
+	if uri == "" {
+		t.Skip("MONGO_URI not set")
+	}
+	ctx := context.Background()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(func() { client.Disconnect(context.Background()) })
+
+	database := fmt.Sprintf("video_test_%d", rand.Int63())
+	t.Cleanup(func() {
+		if err := client.Database(database).Drop(context.Background()); err != nil {
+			t.Errorf("failed to drop database %s: %v", database, err)
+		}
+	})
+	return client, database
+}
+
+func TestTranscodingJobs(t *testing.T) {
+	storage := NewTranscodeStorage(newTestDatabase(t))
+	ctx := context.Background()
+	if err := storage.EnsureIndexes(ctx); err != nil {
+		t.Fatalf("EnsureIndexes: %v", err)
+	}
+
+	// MongoDB keeps milliseconds in UTC, so these times round-trip exactly
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	jobs := []*transcode.TranscodingJob{
+		{ID: "j1", VideoID: "v1", Resolution: pb.VideoResolution_VIDEO_RESOLUTION_720P, Status: pb.TranscodingStatus_TRANSCODING_STATUS_PROCESSING, StartTime: base},
+		{ID: "j2", VideoID: "v1", Resolution: pb.VideoResolution_VIDEO_RESOLUTION_1080P, Status: pb.TranscodingStatus_TRANSCODING_STATUS_QUEUED, StartTime: base.Add(time.Second)},
+		{ID: "j3", VideoID: "v2", Status: pb.TranscodingStatus_TRANSCODING_STATUS_QUEUED, StartTime: base},
+	}
+	for _, job := range jobs {
+		if err := storage.SaveTranscodingJob(ctx, job); err != nil {
+			t.Fatalf("SaveTranscodingJob: %v", err)
+		}
+	}
+
+	// Updates replace the job in place rather than adding another
+	completed := base.Add(time.Minute)
+	jobs[2].Status = pb.TranscodingStatus_TRANSCODING_STATUS_COMPLETED
+	jobs[2].Progress = 100
+	jobs[2].RetryCount = 1
+	jobs[2].CompletionTime = &completed
+	if err := storage.UpdateTranscodingJob(ctx, jobs[2]); err != nil {
+		t.Fatalf("UpdateTranscodingJob: %v", err)
+	}
+
+	v1Jobs, err := storage.GetTranscodingJobs(ctx, "v1")
+	if err != nil {
+		t.Fatalf("GetTranscodingJobs: %v", err)
+	}
+	if len(v1Jobs) != 2 || v1Jobs[0].ID != "j1" || v1Jobs[1].ID != "j2" {
+		t.Errorf("GetTranscodingJobs(v1) = %v, want j1, j2", v1Jobs)
+	}
+
+	active, err := storage.ListActiveTranscodingJobs(ctx)
+	if err != nil {
+		t.Fatalf("ListActiveTranscodingJobs: %v", err)
+	}
+	if len(active) != 2 {
+		t.Errorf("ListActiveTranscodingJobs returned %d jobs, want j1 and j2", len(active))
+	}
+
+	v2Jobs, err := storage.GetTranscodingJobs(ctx, "v2")
+	if err != nil {
+		t.Fatalf("GetTranscodingJobs: %v", err)
+	}
+	if len(v2Jobs) != 1 {
+		t.Fatalf("GetTranscodingJobs(v2) returned %d jobs, want 1", len(v2Jobs))
+	}
+	got := v2Jobs[0]
+	if got.Status != pb.TranscodingStatus_TRANSCODING_STATUS_COMPLETED || got.Progress != 100 || got.RetryCount != 1 ||
+		got.CompletionTime == nil || !got.CompletionTime.Equal(completed) {
+		t.Errorf("GetTranscodingJobs(v2) = %+v, want the completed job", got)
+	}
+}
+
+// listIndexes returns the indexes of a collection as "field:order,..."
+// keys, mapped to whether each one is unique
+func listIndexes(t *testing.T, client *mongo.Client, database, collection string) map[string]bool {
+	t.Helper()
+
+	cursor, err := client.Database(database).Collection(collection).Indexes().List(context.Background())
+	if err != nil {
+		t.Fatalf("failed to list indexes on %s: %v", collection, err)
+	}
+	var specs []struct {
+		Key     bson.D `bson:"key"`
+		Unique  bool   `bson:"unique"`
+		Weights bson.M `bson:"weights"`
+	}
+	if err := cursor.All(context.Background(), &specs); err != nil {
+		t.Fatalf("failed to decode indexes on %s: %v", collection, err)
+	}
+
+	indexes := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		// A text index is keyed by its weighted fields rather than by _fts
+		if spec.Weights != nil {
+			for field := range spec.Weights {
+				indexes[field+":text"] = spec.Unique
+			}
+			continue
+		}
+		var fields []string
+		for _, e := range spec.Key {
+			fields = append(fields, fmt.Sprintf("%s:%v", e.Key, e.Value))
+		}
+		indexes[strings.Join(fields, ",")] = spec.Unique
+	}
+	return indexes
+}
+
+func TestEnsureIndexes(t *testing.T) {
+	client, database := newTestDatabase(t)
+	storage := NewVideoStorage(client, database)
+
+	// Startup calls it every time, so it must be repeatable
+	for i := 0; i < 2; i++ {
+		if err := storage.EnsureIndexes(context.Background()); err != nil {
+			t.Fatalf("EnsureIndexes #%d: %v", i+1, err)
+		}
+	}
+
+	want := map[string]map[string]bool{
+		"videos": {
+			"video_id:1":       true,
+			"user_id:1":        false,
+			"created_at:-1":    false,
+			"title:text":       false,
+			"description:text": false,
+			"tags:text":        false,
+		},
+		"stream_keys": {
+			"user_id:1": true,
+		},
+		"live_streams": {
+			"stream_id:1":               false,
+			"is_active:1,started_at:-1": false,
+		},
+	}
+	for collection, wantIndexes := range want {
+		indexes := listIndexes(t, client, database, collection)
+		for key, unique := range wantIndexes {
+			gotUnique, ok := indexes[key]
+			if !ok {
+				t.Errorf("%s has no index on %s; indexes: %v", collection, key, indexes)
+				continue
+			}
+			if gotUnique != unique {
+				t.Errorf("%s index on %s unique = %v, want %v", collection, key, gotUnique, unique)
+			}
+		}
+	}
+}
+
+func TestSaveStreamKeyKeepsCreatedAt(t *testing.T) {
+	client, database := newTestDatabase(t)
+	storage := NewVideoStorage(client, database)
+	ctx := context.Background()
+
+	loadKey := func() StreamKeyDocument {
+		t.Helper()
+		var doc StreamKeyDocument
+		err := client.Database(database).Collection("stream_keys").FindOne(ctx, bson.M{"user_id": "owner"}).Decode(&doc)
+		if err != nil {
+			t.Fatalf("failed to load stream key: %v", err)
+		}
+		return doc
+	}
+
+	if err := storage.SaveStreamKey(ctx, "owner", "first-key"); err != nil {
+		t.Fatalf("SaveStreamKey: %v", err)
+	}
+	first := loadKey()
+
+	// MongoDB stores milliseconds, so wait long enough to tell the saves apart
+	time.Sleep(20 * time.Millisecond)
+	if err := storage.SaveStreamKey(ctx, "owner", "second-key"); err != nil {
+		t.Fatalf("SaveStreamKey: %v", err)
+	}
+	second := loadKey()
+
+	if second.StreamKey != "second-key" {
+		t.Errorf("stream key = %q, want second-key", second.StreamKey)
+	}
+	if !second.CreatedAt.Equal(first.CreatedAt) {
+		t.Errorf("CreatedAt changed from %s to %s on update", first.CreatedAt, second.CreatedAt)
+	}
+	if !second.UpdatedAt.After(first.UpdatedAt) {
+		t.Errorf("UpdatedAt = %s, want it after the first save at %s", second.UpdatedAt, first.UpdatedAt)
+	}
+
+	key, err := storage.GetStreamKey(ctx, "owner")
+	if err != nil || key != "second-key" {
+		t.Errorf("GetStreamKey = %q, %v; want second-key", key, err)
+	}
+}
+
+func TestLiveStreamRoundTrip(t *testing.T) {
+	client, database := newTestDatabase(t)
+	storage := NewVideoStorage(client, database)
+	ctx := context.Background()
+
+	// MongoDB keeps milliseconds in UTC
+	stream := &video.LiveStream{
+		StreamID:    "s1",
+		UserID:      "owner",
+		Title:       "Speedrun",
+		Description: "Any%",
+		PlaybackURL: "https://cdn.example.com/live/s1/index.m3u8",
+		Status:      pb.StreamStatus_STREAM_STATUS_LIVE,
+		StartedAt:   time.Now().UTC().Truncate(time.Millisecond),
+		Tags:        []string{"games", "retro"},
+		Category:    "gaming",
+		StreamKey:   "key-owner",
+		MaxViewers:  100,
+	}
+	if err := storage.SaveLiveStream(ctx, stream); err != nil {
+		t.Fatalf("SaveLiveStream: %v", err)
+	}
+
+	got, err := storage.GetLiveStreamByID(ctx, "s1")
+	if err != nil {
+		t.Fatalf("GetLiveStreamByID: %v", err)
+	}
+	if !reflect.DeepEqual(got, stream) {
+		t.Errorf("GetLiveStreamByID = %+v, want %+v", got, stream)
+	}
+
+	if err := storage.EndLiveStream(ctx, "s1", "owner"); err != nil {
+		t.Fatalf("EndLiveStream: %v", err)
+	}
+	got, err = storage.GetLiveStreamByID(ctx, "s1")
+	if err != nil {
+		t.Fatalf("GetLiveStreamByID: %v", err)
+	}
+	if got.Status != pb.StreamStatus_STREAM_STATUS_ENDED || got.EndedAt == nil || got.EndedAt.Before(stream.StartedAt) {
+		t.Errorf("ended stream = %v ended at %v, want ENDED with an end time", got.Status, got.EndedAt)
+	}
+	if got.Category != "gaming" || got.StreamKey != "key-owner" || !got.StartedAt.Equal(stream.StartedAt) {
+		t.Errorf("ended stream = %+v, want the rest of it kept", got)
+	}
+
+	// Ended streams are no longer live
+	if _, err := storage.GetLiveStream(ctx, "s1"); !errors.Is(err, video.ErrStreamNotFound) {
+		t.Errorf("GetLiveStream of an ended stream error = %v, want %v", err, video.ErrStreamNotFound)
+	}
+}
+
+func TestAdjustViewerCount(t *testing.T) {
+	client, database := newTestDatabase(t)
+	storage := NewVideoStorage(client, database)
+	ctx := context.Background()
+
+	stream := &video.LiveStream{StreamID: "s1", UserID: "owner", Status: pb.StreamStatus_STREAM_STATUS_LIVE, StartedAt: time.Now()}
+	if err := storage.SaveLiveStream(ctx, stream); err != nil {
+		t.Fatalf("SaveLiveStream: %v", err)
+	}
+
+	count, err := storage.AdjustViewerCount(ctx, "s1", -5, 0)
+	if err != nil {
+		t.Fatalf("AdjustViewerCount: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("AdjustViewerCount went to %d, want it clamped at 0", count)
+	}
+	if count, err := storage.AdjustViewerCount(ctx, "s1", 1, 1); err != nil || count != 1 {
+		t.Errorf("AdjustViewerCount below the cap = %d, %v; want 1", count, err)
+	}
+	if _, err := storage.AdjustViewerCount(ctx, "s1", 1, 1); !errors.Is(err, video.ErrStreamAtCapacity) {
+		t.Errorf("AdjustViewerCount at the cap error = %v, want %v", err, video.ErrStreamAtCapacity)
+	}
+
+	// Saving the stream again keeps the viewers it has
+	if err := storage.SaveLiveStream(ctx, stream); err != nil {
+		t.Fatalf("SaveLiveStream: %v", err)
+	}
+	if count, err := storage.AdjustViewerCount(ctx, "s1", 1, 0); err != nil || count != 2 {
+		t.Errorf("AdjustViewerCount after saving again = %d, %v; want 2", count, err)
+	}
+
+	if _, err := storage.AdjustViewerCount(ctx, "missing", 1, 1); !errors.Is(err, video.ErrStreamNotFound) {
+		t.Errorf("AdjustViewerCount of a missing stream error = %v, want %v", err, video.ErrStreamNotFound)
+	}
+	if err := storage.EndLiveStream(ctx, "s1", "owner"); err != nil {
+		t.Fatalf("EndLiveStream: %v", err)
+	}
+	if _, err := storage.AdjustViewerCount(ctx, "s1", 1, 0); !errors.Is(err, video.ErrStreamNotFound) {
+		t.Errorf("AdjustViewerCount of an ended stream error = %v, want %v", err, video.ErrStreamNotFound)
+	}
+}
+
+func TestStreamMessages(t *testing.T) {
+	client, database := newTestDatabase(t)
+	storage := NewVideoStorage(client, database)
+	ctx := context.Background()
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		err := storage.SaveStreamMessage(ctx, &video.StreamMessage{
+			ID:        fmt.Sprintf("m%d", i),
+			StreamID:  "s1",
+			UserID:    "alice",
+			Text:      "hello",
+			CreatedAt: base.Add(time.Duration(i) * time.Minute),
+		})
+		if err != nil {
+			t.Fatalf("SaveStreamMessage: %v", err)
+		}
+	}
+	err := storage.SaveStreamMessage(ctx, &video.StreamMessage{ID: "other", StreamID: "s2", UserID: "bob", Text: "hi", CreatedAt: base})
+	if err != nil {
+		t.Fatalf("SaveStreamMessage: %v", err)
+	}
+
+	messages, err := storage.ListStreamMessages(ctx, "s1", 10, base.Add(2*time.Minute))
+	if err != nil {
+		t.Fatalf("ListStreamMessages: %v", err)
+	}
+	if len(messages) != 2 || messages[0].ID != "m1" || messages[1].ID != "m0" {
+		t.Errorf("ListStreamMessages = %v, want m1, m0", messages)
+	}
+	if got := messages[0]; got.UserID != "alice" || got.Text != "hello" || !got.CreatedAt.Equal(base.Add(time.Minute)) {
+		t.Errorf("message m1 = %+v, want it as saved", got)
+	}
+
+	latest, err := storage.ListStreamMessages(ctx, "s1", 1, base.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("ListStreamMessages: %v", err)
+	}
+	if len(latest) != 1 || latest[0].ID != "m2" {
+		t.Errorf("ListStreamMessages with a limit of 1 = %v, want m2", latest)
+	}
+}
+
+func TestListVideosSortBy(t *testing.T) {
+	client, database := newTestDatabase(t)
+	storage := NewVideoStorage(client, database)
+	ctx := context.Background()
+
+	// b and d share a creation time and a title, a and c a view count
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, v := range []*video.Video{
+		{ID: "a", Title: "Birds", ViewCount: 10, CreatedAt: base},
+		{ID: "b", Title: "Cooking", ViewCount: 50, CreatedAt: base.Add(2 * time.Minute)},
+		{ID: "c", Title: "Alpine", ViewCount: 10, CreatedAt: base.Add(time.Minute)},
+		{ID: "d", Title: "Cooking", ViewCount: 0, CreatedAt: base.Add(2 * time.Minute)},
+	} {
+		v.UserID = "owner"
+		v.Visibility = pb.VideoVisibility_VIDEO_VISIBILITY_PUBLIC
+		if err := storage.SaveVideo(ctx, v); err != nil {
+			t.Fatalf("SaveVideo: %v", err)
+		}
+	}
+
+	tests := []struct {
+		sortBy pb.VideoSortBy
+		want   string
+	}{
+		{pb.VideoSortBy_VIDEO_SORT_BY_UNSPECIFIED, "[b d c a]"},
+		{pb.VideoSortBy_VIDEO_SORT_BY_CREATED_DESC, "[b d c a]"},
+		{pb.VideoSortBy_VIDEO_SORT_BY_CREATED_ASC, "[a c b d]"},
+		{pb.VideoSortBy_VIDEO_SORT_BY_VIEWS_DESC, "[b a c d]"},
+		{pb.VideoSortBy_VIDEO_SORT_BY_TITLE_ASC, "[c a b d]"},
+	}
+	for _, tt := range tests {
+		// Paging two at a time gives the same order as a single page
+		var ids []string
+		for offset := 0; offset < 4; offset += 2 {
+			videos, _, err := storage.ListVideos(ctx, video.ListVideosFilter{UserID: "owner", SortBy: tt.sortBy}, 2, offset)
+			if err != nil {
+				t.Fatalf("ListVideos: %v", err)
+			}
+			for _, v := range videos {
+				ids = append(ids, v.ID)
+			}
+		}
+		if got := fmt.Sprint(ids); got != tt.want {
+			t.Errorf("ListVideos sorted by %v = %s, want %s", tt.sortBy, got, tt.want)
+		}
+	}
+}
+
+func TestSearchVideos(t *testing.T) {
+	client, database := newTestDatabase(t)
+	storage := NewVideoStorage(client, database)
+	ctx := context.Background()
+
+	// $text needs the text index in place
+	if err := storage.EnsureIndexes(ctx); err != nil {
+		t.Fatalf("EnsureIndexes: %v", err)
+	}
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	videos := []*video.Video{
+		{ID: "cats", UserID: "alice", Title: "Funny cats", CreatedAt: created},
+		{ID: "cats-and-dogs", UserID: "alice", Title: "Cats and dogs", CreatedAt: created},
+		{ID: "tagged", UserID: "alice", Description: "A walk in the park", Tags: []string{"dogs"}, CreatedAt: created},
+		{ID: "other", UserID: "alice", Title: "Horses", CreatedAt: created},
+	}
+	for _, v := range videos {
+		if err := storage.SaveVideo(ctx, v); err != nil {
+			t.Fatalf("SaveVideo: %v", err)
+		}
+	}
+
+	found, total, err := storage.SearchVideos(ctx, "cats dogs", 10, 0)
+	if err != nil {
+		t.Fatalf("SearchVideos: %v", err)
+	}
+	if total != 3 || len(found) != 3 {
+		t.Fatalf("SearchVideos found %d of %d, want the cats, dogs and tagged videos", len(found), total)
+	}
+	if found[0].ID != "cats-and-dogs" {
+		t.Errorf("best match = %s, want the video matching both words", found[0].ID)
+	}
+
+	found, total, err = storage.SearchVideos(ctx, "dogs", 10, 0)
+	if err != nil {
+		t.Fatalf("SearchVideos: %v", err)
+	}
+	ids := make(map[string]bool)
+	for _, v := range found {
+		ids[v.ID] = true
+	}
+	if total != 2 || !ids["tagged"] || !ids["cats-and-dogs"] {
+		t.Errorf("SearchVideos(dogs) found %v of %d, want the tagged video too", ids, total)
+	}
+}
+
+func TestGetUserStorageUsage(t *testing.T) {
+	client, database := newTestDatabase(t)
+	storage := NewVideoStorage(client, database)
+	ctx := context.Background()
+
+	videos := []*video.Video{
+		{ID: "v1", UserID: "alice", FileSizeBytes: 100},
+		{ID: "v2", UserID: "alice", FileSizeBytes: 250},
+		{ID: "v3", UserID: "bob", FileSizeBytes: 1000},
+	}
+	for _, v := range videos {
+		if err := storage.SaveVideo(ctx, v); err != nil {
+			t.Fatalf("SaveVideo: %v", err)
+		}
+	}
+
+	for userID, want := range map[string]int64{"alice": 350, "bob": 1000, "carol": 0} {
+		usage, err := storage.GetUserStorageUsage(ctx, userID)
+		if err != nil {
+			t.Fatalf("GetUserStorageUsage(%s): %v", userID, err)
+		}
+		if usage != want {
+			t.Errorf("GetUserStorageUsage(%s) = %d, want %d", userID, usage, want)
+		}
+	}
+}
+
+func TestGetVideos(t *testing.T) {
+	client, database := newTestDatabase(t)
+	storage := NewVideoStorage(client, database)
+	ctx := context.Background()
+
+	for _, id := range []string{"v1", "v2", "v3"} {
+		if err := storage.SaveVideo(ctx, &video.Video{ID: id, UserID: "alice"}); err != nil {
+			t.Fatalf("SaveVideo: %v", err)
+		}
+	}
+
+	videos, err := storage.GetVideos(ctx, []string{"v3", "missing", "v1"})
+	if err != nil {
+		t.Fatalf("GetVideos: %v", err)
+	}
+	found := make(map[string]bool)
+	for _, v := range videos {
+		found[v.ID] = true
+	}
+	if len(videos) != 2 || !found["v1"] || !found["v3"] {
+		t.Errorf("GetVideos found %v, want v1 and v3 only", found)
+	}
+}
@@ -0,0 +1,172 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"videostreaming/internal/service/transcode"
+	pb "videostreaming/proto/video"
+)
+
+// TranscodingJobDocument represents a transcoding job document in MongoDB
+type TranscodingJobDocument struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty"`
+	JobID          string             `bson:"job_id"`
+	VideoID        string             `bson:"video_id"`
+	InputPath      string             `bson:"input_path"`
+	OutputPath     string             `bson:"output_path"`
+	Resolution     int32              `bson:"resolution"`
+	Status         int32              `bson:"status"`
+	Progress       float32            `bson:"progress"`
+	StartTime      time.Time          `bson:"start_time"`
+	CompletionTime *time.Time         `bson:"completion_time,omitempty"`
+	ErrorMessage   string             `bson:"error_message,omitempty"`
+	RetryCount     int                `bson:"retry_count"`
+}
+
+// TranscodeStorage implements the transcode.TranscodeStorage interface using MongoDB
+type TranscodeStorage struct {
+	client         *mongo.Client
+	database       string
+	jobsCollection string
+}
+
+// NewTranscodeStorage creates a new MongoDB-based transcoding job storage
+func NewTranscodeStorage(client *mongo.Client, database string) *TranscodeStorage {
+	return &TranscodeStorage{
+		client:         client,
+		database:       database,
+		jobsCollection: "transcoding_jobs",
+	}
+}
+
+// EnsureIndexes creates the indexes the job queries rely on. Like
+// VideoStorage.EnsureIndexes it is safe to call at every startup.
+func (s *TranscodeStorage) EnsureIndexes(ctx context.Context) error {
+	models := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "job_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "video_id", Value: 1}, {Key: "start_time", Value: 1}}},
+		// Used to resume active jobs after a restart
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "start_time", Value: 1}}},
+	}
+
+	collection := s.client.Database(s.database).Collection(s.jobsCollection)
+	if _, err := collection.Indexes().CreateMany(ctx, models); err != nil {
+		return fmt.Errorf("failed to create indexes on %s: %w", s.jobsCollection, err)
+	}
+	return nil
+}
+
+// SaveTranscodingJob inserts a transcoding job, or replaces it if it already exists
+func (s *TranscodeStorage) SaveTranscodingJob(ctx context.Context, job *transcode.TranscodingJob) error {
+	if err := s.upsertJob(ctx, job); err != nil {
+		return fmt.Errorf("failed to save transcoding job: %w", err)
+	}
+	return nil
+}
+
+// UpdateTranscodingJob stores the latest state of a transcoding job
+func (s *TranscodeStorage) UpdateTranscodingJob(ctx context.Context, job *transcode.TranscodingJob) error {
+	if err := s.upsertJob(ctx, job); err != nil {
+		return fmt.Errorf("failed to update transcoding job: %w", err)
+	}
+	return nil
+}
+
+// upsertJob writes a job keyed by its job ID
+func (s *TranscodeStorage) upsertJob(ctx context.Context, job *transcode.TranscodingJob) error {
+	collection := s.client.Database(s.database).Collection(s.jobsCollection)
+
+	filter := bson.M{"job_id": job.ID}
+	update := bson.M{"$set": toTranscodingJobDocument(job)}
+
+	opts := options.Update().SetUpsert(true)
+	_, err := collection.UpdateOne(ctx, filter, update, opts)
+	return err
+}
+
+// GetTranscodingJobs retrieves every transcoding job of a video
+func (s *TranscodeStorage) GetTranscodingJobs(ctx context.Context, videoID string) ([]*transcode.TranscodingJob, error) {
+	jobs, err := s.findJobs(ctx, bson.M{"video_id": videoID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transcoding jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+// ListActiveTranscodingJobs retrieves the jobs that are queued or still processing
+func (s *TranscodeStorage) ListActiveTranscodingJobs(ctx context.Context) ([]*transcode.TranscodingJob, error) {
+	filter := bson.M{"status": bson.M{"$in": []int32{
+		int32(pb.TranscodingStatus_TRANSCODING_STATUS_QUEUED),
+		int32(pb.TranscodingStatus_TRANSCODING_STATUS_PROCESSING),
+	}}}
+
+	jobs, err := s.findJobs(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list active transcoding jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+// findJobs decodes every job matching filter, oldest first
+func (s *TranscodeStorage) findJobs(ctx context.Context, filter bson.M) ([]*transcode.TranscodingJob, error) {
+	collection := s.client.Database(s.database).Collection(s.jobsCollection)
+
+	opts := options.Find().SetSort(bson.M{"start_time": 1})
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var jobDocs []TranscodingJobDocument
+	if err := cursor.All(ctx, &jobDocs); err != nil {
+		return nil, err
+	}
+
+	jobs := make([]*transcode.TranscodingJob, 0, len(jobDocs))
+	for i := range jobDocs {
+		jobs = append(jobs, fromTranscodingJobDocument(&jobDocs[i]))
+	}
+	return jobs, nil
+}
+
+// toTranscodingJobDocument converts a transcode.TranscodingJob to a TranscodingJobDocument
+func toTranscodingJobDocument(job *transcode.TranscodingJob) *TranscodingJobDocument {
+	return &TranscodingJobDocument{
+		JobID:          job.ID,
+		VideoID:        job.VideoID,
+		InputPath:      job.InputPath,
+		OutputPath:     job.OutputPath,
+		Resolution:     int32(job.Resolution),
+		Status:         int32(job.Status),
+		Progress:       job.Progress,
+		StartTime:      job.StartTime,
+		CompletionTime: job.CompletionTime,
+		ErrorMessage:   job.ErrorMessage,
+		RetryCount:     job.RetryCount,
+	}
+}
+
+// fromTranscodingJobDocument converts a TranscodingJobDocument to a transcode.TranscodingJob
+func fromTranscodingJobDocument(doc *TranscodingJobDocument) *transcode.TranscodingJob {
+	return &transcode.TranscodingJob{
+		ID:             doc.JobID,
+		VideoID:        doc.VideoID,
+		InputPath:      doc.InputPath,
+		OutputPath:     doc.OutputPath,
+		Resolution:     pb.VideoResolution(doc.Resolution),
+		Status:         pb.TranscodingStatus(doc.Status),
+		Progress:       doc.Progress,
+		StartTime:      doc.StartTime,
+		CompletionTime: doc.CompletionTime,
+		ErrorMessage:   doc.ErrorMessage,
+		RetryCount:     doc.RetryCount,
+	}
+}
@@ -0,0 +1,36 @@
+package mongodb
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"videostreaming/internal/service/transcode"
+	pb "videostreaming/proto/video"
+)
+
+func TestTranscodingJobDocumentRoundTrip(t *testing.T) {
+	completed := time.Date(2024, 1, 1, 0, 5, 0, 0, time.UTC)
+	jobs := []*transcode.TranscodingJob{
+		{
+			ID:             "j1",
+			VideoID:        "v1",
+			InputPath:      "videos/v1",
+			OutputPath:     "transcoded/v1/720p",
+			Resolution:     pb.VideoResolution_VIDEO_RESOLUTION_720P,
+			Status:         pb.TranscodingStatus_TRANSCODING_STATUS_FAILED,
+			Progress:       42.5,
+			StartTime:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			CompletionTime: &completed,
+			ErrorMessage:   "encoder crashed",
+			RetryCount:     3,
+		},
+		// Still running, so there's no completion time
+		{ID: "j2", VideoID: "v1", Status: pb.TranscodingStatus_TRANSCODING_STATUS_PROCESSING},
+	}
+	for _, job := range jobs {
+		if got := fromTranscodingJobDocument(toTranscodingJobDocument(job)); !reflect.DeepEqual(got, job) {
+			t.Errorf("round trip = %+v, want %+v", got, job)
+		}
+	}
+}
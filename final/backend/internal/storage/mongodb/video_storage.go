@@ -10,6 +10,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 
 	"videostreaming/internal/service/video"
 	pb "videostreaming/proto/video"
@@ -27,11 +28,19 @@ type VideoDocument struct {
 	DurationSeconds int64              `bson:"duration_seconds"`
 	ViewCount      int64              `bson:"view_count"`
 	Status         int32              `bson:"status"`
+	StatusReason   string             `bson:"status_reason,omitempty"`
 	CreatedAt      time.Time          `bson:"created_at"`
 	UpdatedAt      time.Time          `bson:"updated_at"`
 	Tags           []string           `bson:"tags"`
 	Visibility     int32              `bson:"visibility"`
 	Resolution     int32              `bson:"resolution"`
+	ContentHash    string             `bson:"content_hash,omitempty"`
+	MediaID        string             `bson:"media_id,omitempty"`
+	SourceKey      string             `bson:"source_key,omitempty"`
+	FileSizeBytes  int64              `bson:"file_size_bytes"`
+	// Stored as null rather than omitted so that SaveVideo's $set clears it on restore
+	DeletedAt      *time.Time         `bson:"deleted_at"`
+	CallbackURL    string             `bson:"callback_url,omitempty"`
 }
 
 // StreamKeyDocument represents a stream key document in MongoDB
@@ -52,11 +61,26 @@ type LiveStreamDocument struct {
 	Description string             `bson:"description"`
 	ThumbnailURL string            `bson:"thumbnail_url"`
 	PlaybackURL string             `bson:"playback_url"`
+	WebRTCPlaybackURL string       `bson:"webrtc_playback_url,omitempty"`
 	ViewerCount int64              `bson:"viewer_count"`
 	IsActive    bool               `bson:"is_active"`
+	Status      int32              `bson:"status"`
 	StartedAt   time.Time          `bson:"started_at"`
 	EndedAt     *time.Time         `bson:"ended_at,omitempty"`
 	Tags        []string           `bson:"tags"`
+	Category    string             `bson:"category,omitempty"`
+	StreamKey   string             `bson:"stream_key,omitempty"`
+	MaxViewers  int64              `bson:"max_viewers,omitempty"`
+}
+
+// StreamMessageDocument represents a live stream chat message in MongoDB
+type StreamMessageDocument struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	MessageID string             `bson:"message_id"`
+	StreamID  string             `bson:"stream_id"`
+	UserID    string             `bson:"user_id"`
+	Text      string             `bson:"text"`
+	CreatedAt time.Time          `bson:"created_at"`
 }
 
 // VideoStorage implements the video.Storage interface using MongoDB
@@ -66,9 +90,11 @@ type VideoStorage struct {
 	videosCollection    string
 	streamKeysCollection string
 	liveStreamsCollection string
+	streamMessagesCollection string
 }
 
-// NewVideoStorage creates a new MongoDB-based video storage
+// NewVideoStorage creates a new MongoDB-based video storage.
+// Call EnsureIndexes before use so queries don't scan whole collections.
 func NewVideoStorage(client *mongo.Client, database string) *VideoStorage {
 	return &VideoStorage{
 		client:              client,
@@ -76,27 +102,56 @@ func NewVideoStorage(client *mongo.Client, database string) *VideoStorage {
 		videosCollection:    "videos",
 		streamKeysCollection: "stream_keys",
 		liveStreamsCollection: "live_streams",
+		streamMessagesCollection: "stream_messages",
 	}
 }
 
+// Ping checks that the MongoDB primary can be reached
+func (s *VideoStorage) Ping(ctx context.Context) error {
+	return s.client.Ping(ctx, readpref.Primary())
+}
+
 // SaveVideo saves a video to MongoDB
 func (s *VideoStorage) SaveVideo(ctx context.Context, video *video.Video) error {
 	collection := s.client.Database(s.database).Collection(s.videosCollection)
 	
 	filter := bson.M{"video_id": video.ID}
 	
-	videoDoc := s.toVideoDocument(video)
-	update := bson.M{"$set": videoDoc}
+	// The view count only seeds new documents; IncrementViewCount owns it
+	// afterwards, so saving a video read earlier can't undo concurrent views
+	update, err := setExceptCounter(s.toVideoDocument(video), "view_count")
+	if err != nil {
+		return fmt.Errorf("failed to encode video: %w", err)
+	}
 	
 	opts := options.Update().SetUpsert(true)
-	_, err := collection.UpdateOne(ctx, filter, update, opts)
-	if err != nil {
+	if _, err := collection.UpdateOne(ctx, filter, update, opts); err != nil {
 		return fmt.Errorf("failed to save video: %w", err)
 	}
 	
 	return nil
 }
 
+// setExceptCounter builds an upsert that sets every field of doc except
+// counter, which is only written when the upsert inserts the document
+func setExceptCounter(doc interface{}, counter string) (bson.M, error) {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var fields bson.M
+	if err := bson.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	
+	value := fields[counter]
+	delete(fields, counter)
+	return bson.M{
+		"$set":         fields,
+		"$setOnInsert": bson.M{counter: value},
+	}, nil
+}
+
 // GetVideo retrieves a video from MongoDB by ID
 func (s *VideoStorage) GetVideo(ctx context.Context, id string) (*video.Video, error) {
 	collection := s.client.Database(s.database).Collection(s.videosCollection)
@@ -107,7 +162,7 @@ func (s *VideoStorage) GetVideo(ctx context.Context, id string) (*video.Video, e
 	err := collection.FindOne(ctx, filter).Decode(&videoDoc)
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
-			return nil, fmt.Errorf("video not found: %w", err)
+			return nil, fmt.Errorf("%w: %v", video.ErrNotFound, err)
 		}
 		return nil, fmt.Errorf("failed to get video: %w", err)
 	}
@@ -115,13 +170,173 @@ func (s *VideoStorage) GetVideo(ctx context.Context, id string) (*video.Video, e
 	return s.fromVideoDocument(&videoDoc), nil
 }
 
+// GetVideos retrieves the videos that exist among ids with a single query
+func (s *VideoStorage) GetVideos(ctx context.Context, ids []string) ([]*video.Video, error) {
+	collection := s.client.Database(s.database).Collection(s.videosCollection)
+	
+	cursor, err := collection.Find(ctx, bson.M{"video_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get videos: %w", err)
+	}
+	defer cursor.Close(ctx)
+	
+	var videoDocs []VideoDocument
+	if err := cursor.All(ctx, &videoDocs); err != nil {
+		return nil, fmt.Errorf("failed to decode videos: %w", err)
+	}
+	
+	videos := make([]*video.Video, 0, len(videoDocs))
+	for _, doc := range videoDocs {
+		videos = append(videos, s.fromVideoDocument(&doc))
+	}
+	
+	return videos, nil
+}
+
+// ListVideosByContentHash retrieves every video whose upload has the given hash.
+// The content_hash index from EnsureIndexes keeps this lookup from scanning the collection.
+func (s *VideoStorage) ListVideosByContentHash(ctx context.Context, hash string) ([]*video.Video, error) {
+	collection := s.client.Database(s.database).Collection(s.videosCollection)
+	
+	cursor, err := collection.Find(ctx, bson.M{"content_hash": hash})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find videos by content hash: %w", err)
+	}
+	defer cursor.Close(ctx)
+	
+	var videoDocs []VideoDocument
+	if err := cursor.All(ctx, &videoDocs); err != nil {
+		return nil, fmt.Errorf("failed to decode videos: %w", err)
+	}
+	
+	videos := make([]*video.Video, 0, len(videoDocs))
+	for _, doc := range videoDocs {
+		videos = append(videos, s.fromVideoDocument(&doc))
+	}
+	
+	return videos, nil
+}
+
+// GetUserStorageUsage sums the file sizes of a user's videos
+func (s *VideoStorage) GetUserStorageUsage(ctx context.Context, userID string) (int64, error) {
+	collection := s.client.Database(s.database).Collection(s.videosCollection)
+	
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"user_id": userID}}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "total": bson.M{"$sum": "$file_size_bytes"}}}},
+	}
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, fmt.Errorf("failed to aggregate storage usage: %w", err)
+	}
+	defer cursor.Close(ctx)
+	
+	var results []struct {
+		Total int64 `bson:"total"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return 0, fmt.Errorf("failed to decode storage usage: %w", err)
+	}
+	
+	// A user without videos matches nothing and yields no group
+	if len(results) == 0 {
+		return 0, nil
+	}
+	return results[0].Total, nil
+}
+
+// ListDeletedVideos retrieves up to limit videos moved to the trash before
+// deletedBefore, longest deleted first
+func (s *VideoStorage) ListDeletedVideos(ctx context.Context, deletedBefore time.Time, limit int) ([]*video.Video, error) {
+	collection := s.client.Database(s.database).Collection(s.videosCollection)
+	
+	filter := bson.M{"deleted_at": bson.M{"$lt": deletedBefore}}
+	findOptions := options.Find().
+		SetLimit(int64(limit)).
+		SetSort(bson.D{{Key: "deleted_at", Value: 1}})
+	
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find deleted videos: %w", err)
+	}
+	defer cursor.Close(ctx)
+	
+	var videoDocs []VideoDocument
+	if err := cursor.All(ctx, &videoDocs); err != nil {
+		return nil, fmt.Errorf("failed to decode videos: %w", err)
+	}
+	
+	videos := make([]*video.Video, 0, len(videoDocs))
+	for _, doc := range videoDocs {
+		videos = append(videos, s.fromVideoDocument(&doc))
+	}
+	
+	return videos, nil
+}
+
+// EnsureIndexes creates the indexes the storage queries rely on. It is safe
+// to call repeatedly, since existing indexes are left alone, and callers
+// should invoke it once at startup before serving requests.
+func (s *VideoStorage) EnsureIndexes(ctx context.Context) error {
+	db := s.client.Database(s.database)
+	
+	indexes := map[string][]mongo.IndexModel{
+		s.videosCollection: {
+			{Keys: bson.D{{Key: "video_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "user_id", Value: 1}}},
+			{Keys: bson.D{{Key: "created_at", Value: -1}}},
+			// Used by SearchVideos; a collection can only have one text index
+			{Keys: bson.D{{Key: "title", Value: "text"}, {Key: "description", Value: "text"}, {Key: "tags", Value: "text"}}},
+			// Used to find duplicate uploads
+			{Keys: bson.D{{Key: "content_hash", Value: 1}}, Options: options.Index().SetSparse(true)},
+			// Used to find videos due for purging from the trash
+			{Keys: bson.D{{Key: "deleted_at", Value: 1}}},
+		},
+		s.streamKeysCollection: {
+			{Keys: bson.D{{Key: "user_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+			// Used to find whose recordings a stream directory holds
+			{Keys: bson.D{{Key: "stream_key", Value: 1}}},
+		},
+		s.liveStreamsCollection: {
+			{Keys: bson.D{{Key: "stream_id", Value: 1}}},
+			{Keys: bson.D{{Key: "is_active", Value: 1}, {Key: "started_at", Value: -1}}},
+			// Used to browse active streams by category
+			{Keys: bson.D{{Key: "category", Value: 1}, {Key: "is_active", Value: 1}, {Key: "started_at", Value: -1}}},
+		},
+		s.streamMessagesCollection: {
+			// Used to page through a stream's chat backward in time
+			{Keys: bson.D{{Key: "stream_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		},
+	}
+	
+	for collection, models := range indexes {
+		if _, err := db.Collection(collection).Indexes().CreateMany(ctx, models); err != nil {
+			return fmt.Errorf("failed to create indexes on %s: %w", collection, err)
+		}
+	}
+	
+	return nil
+}
+
 // ListVideos retrieves a list of videos from MongoDB
-func (s *VideoStorage) ListVideos(ctx context.Context, userID string, limit int, offset int) ([]*video.Video, int, error) {
+func (s *VideoStorage) ListVideos(ctx context.Context, listFilter video.ListVideosFilter, limit int, offset int) ([]*video.Video, int, error) {
 	collection := s.client.Database(s.database).Collection(s.videosCollection)
 	
-	filter := bson.M{}
-	if userID != "" {
-		filter["user_id"] = userID
+	// A null filter also matches documents saved before deleted_at existed
+	filter := bson.M{"deleted_at": nil}
+	if listFilter.UserID != "" {
+		filter["user_id"] = listFilter.UserID
+	}
+	if listFilter.Status != pb.VideoStatus_VIDEO_STATUS_UNSPECIFIED {
+		filter["status"] = int32(listFilter.Status)
+	}
+	if listFilter.Visibility != pb.VideoVisibility_VIDEO_VISIBILITY_UNSPECIFIED {
+		filter["visibility"] = int32(listFilter.Visibility)
+	}
+	// Other users' private videos are never listed
+	filter["$or"] = bson.A{
+		bson.M{"visibility": bson.M{"$ne": int32(pb.VideoVisibility_VIDEO_VISIBILITY_PRIVATE)}},
+		bson.M{"user_id": listFilter.ViewerID},
 	}
 	
 	// Count total videos matching filter
@@ -133,7 +348,7 @@ func (s *VideoStorage) ListVideos(ctx context.Context, userID string, limit int,
 	findOptions := options.Find().
 		SetLimit(int64(limit)).
 		SetSkip(int64(offset)).
-		SetSort(bson.M{"created_at": -1}) // Sort by creation date, newest first
+		SetSort(videoSort(listFilter.SortBy))
 	
 	cursor, err := collection.Find(ctx, filter, findOptions)
 	if err != nil {
@@ -154,6 +369,60 @@ func (s *VideoStorage) ListVideos(ctx context.Context, userID string, limit int,
 	return videos, int(total), nil
 }
 
+// SearchVideos finds videos matching any word of the query in their title,
+// description or tags through the text index, best matches first
+func (s *VideoStorage) SearchVideos(ctx context.Context, query string, limit int, offset int) ([]*video.Video, int, error) {
+	collection := s.client.Database(s.database).Collection(s.videosCollection)
+	
+	filter := bson.M{"$text": bson.M{"$search": query}, "deleted_at": nil}
+	
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count videos: %w", err)
+	}
+	
+	score := bson.M{"score": bson.M{"$meta": "textScore"}}
+	findOptions := options.Find().
+		SetLimit(int64(limit)).
+		SetSkip(int64(offset)).
+		SetProjection(score).
+		SetSort(score) // Sort by relevance, best match first
+	
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search videos: %w", err)
+	}
+	defer cursor.Close(ctx)
+	
+	var videoDocs []VideoDocument
+	if err := cursor.All(ctx, &videoDocs); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode videos: %w", err)
+	}
+	
+	videos := make([]*video.Video, 0, len(videoDocs))
+	for _, doc := range videoDocs {
+		videos = append(videos, s.fromVideoDocument(&doc))
+	}
+	
+	return videos, int(total), nil
+}
+
+// videoSort returns the sort document for a listing order. Ties are broken by
+// video ID so that skip-based pages never overlap.
+func videoSort(sortBy pb.VideoSortBy) bson.D {
+	switch sortBy {
+	case pb.VideoSortBy_VIDEO_SORT_BY_CREATED_ASC:
+		return bson.D{{Key: "created_at", Value: 1}, {Key: "video_id", Value: 1}}
+	case pb.VideoSortBy_VIDEO_SORT_BY_VIEWS_DESC:
+		return bson.D{{Key: "view_count", Value: -1}, {Key: "video_id", Value: 1}}
+	case pb.VideoSortBy_VIDEO_SORT_BY_TITLE_ASC:
+		return bson.D{{Key: "title", Value: 1}, {Key: "video_id", Value: 1}}
+	default:
+		// Newest first
+		return bson.D{{Key: "created_at", Value: -1}, {Key: "video_id", Value: 1}}
+	}
+}
+
 // DeleteVideo removes a video from MongoDB
 func (s *VideoStorage) DeleteVideo(ctx context.Context, id string, userID string) error {
 	collection := s.client.Database(s.database).Collection(s.videosCollection)
@@ -173,24 +442,52 @@ func (s *VideoStorage) DeleteVideo(ctx context.Context, id string, userID string
 	}
 	
 	if result.DeletedCount == 0 {
-		return fmt.Errorf("video not found or not authorized to delete")
+		return fmt.Errorf("%w: video not found or not authorized to delete", video.ErrNotFound)
 	}
 	
 	return nil
 }
 
+// IncrementViewCount atomically increments a video's view count using $inc
+func (s *VideoStorage) IncrementViewCount(ctx context.Context, videoID string) (int64, error) {
+	collection := s.client.Database(s.database).Collection(s.videosCollection)
+	
+	filter := bson.M{"video_id": videoID}
+	update := bson.M{"$inc": bson.M{"view_count": 1}}
+	
+	opts := options.FindOneAndUpdate().
+		SetReturnDocument(options.After).
+		SetProjection(bson.M{"view_count": 1})
+	
+	var videoDoc VideoDocument
+	err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&videoDoc)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return 0, fmt.Errorf("%w: %v", video.ErrNotFound, err)
+		}
+		return 0, fmt.Errorf("failed to increment view count: %w", err)
+	}
+	
+	return videoDoc.ViewCount, nil
+}
+
 // SaveStreamKey saves a stream key to MongoDB
 func (s *VideoStorage) SaveStreamKey(ctx context.Context, userID string, streamKey string) error {
 	collection := s.client.Database(s.database).Collection(s.streamKeysCollection)
 	
 	now := time.Now()
 	filter := bson.M{"user_id": userID}
-	update := bson.M{"$set": StreamKeyDocument{
-		UserID:    userID,
-		StreamKey: streamKey,
-		CreatedAt: now,
-		UpdatedAt: now,
-	}}
+	
+	// Keep the time the user's first key was issued across later rotations
+	update := bson.M{
+		"$set": bson.M{
+			"stream_key": streamKey,
+			"updated_at": now,
+		},
+		"$setOnInsert": bson.M{
+			"created_at": now,
+		},
+	}
 	
 	opts := options.Update().SetUpsert(true)
 	_, err := collection.UpdateOne(ctx, filter, update, opts)
@@ -211,7 +508,7 @@ func (s *VideoStorage) GetStreamKey(ctx context.Context, userID string) (string,
 	err := collection.FindOne(ctx, filter).Decode(&streamKeyDoc)
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
-			return "", fmt.Errorf("stream key not found: %w", err)
+			return "", fmt.Errorf("%w: stream key not found: %v", video.ErrNotFound, err)
 		}
 		return "", fmt.Errorf("failed to get stream key: %w", err)
 	}
@@ -219,18 +516,36 @@ func (s *VideoStorage) GetStreamKey(ctx context.Context, userID string) (string,
 	return streamKeyDoc.StreamKey, nil
 }
 
+// GetStreamKeyOwner returns the user holding a stream key, if any
+func (s *VideoStorage) GetStreamKeyOwner(ctx context.Context, streamKey string) (string, error) {
+	collection := s.client.Database(s.database).Collection(s.streamKeysCollection)
+	
+	var streamKeyDoc StreamKeyDocument
+	err := collection.FindOne(ctx, bson.M{"stream_key": streamKey}).Decode(&streamKeyDoc)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get stream key owner: %w", err)
+	}
+	
+	return streamKeyDoc.UserID, nil
+}
+
 // SaveLiveStream saves a live stream to MongoDB
 func (s *VideoStorage) SaveLiveStream(ctx context.Context, stream *video.LiveStream) error {
 	collection := s.client.Database(s.database).Collection(s.liveStreamsCollection)
 	
 	filter := bson.M{"stream_id": stream.StreamID}
 	
-	liveStreamDoc := s.toLiveStreamDocument(stream)
-	update := bson.M{"$set": liveStreamDoc}
+	// Likewise the viewer count belongs to AdjustViewerCount once the stream exists
+	update, err := setExceptCounter(s.toLiveStreamDocument(stream), "viewer_count")
+	if err != nil {
+		return fmt.Errorf("failed to encode live stream: %w", err)
+	}
 	
 	opts := options.Update().SetUpsert(true)
-	_, err := collection.UpdateOne(ctx, filter, update, opts)
-	if err != nil {
+	if _, err := collection.UpdateOne(ctx, filter, update, opts); err != nil {
 		return fmt.Errorf("failed to save live stream: %w", err)
 	}
 	
@@ -247,7 +562,25 @@ func (s *VideoStorage) GetLiveStream(ctx context.Context, streamID string) (*vid
 	err := collection.FindOne(ctx, filter).Decode(&liveStreamDoc)
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
-			return nil, fmt.Errorf("live stream not found: %w", err)
+			return nil, fmt.Errorf("%w: %v", video.ErrStreamNotFound, err)
+		}
+		return nil, fmt.Errorf("failed to get live stream: %w", err)
+	}
+	
+	return s.fromLiveStreamDocument(&liveStreamDoc), nil
+}
+
+// GetLiveStreamByID retrieves a live stream from MongoDB whether or not it has ended
+func (s *VideoStorage) GetLiveStreamByID(ctx context.Context, streamID string) (*video.LiveStream, error) {
+	collection := s.client.Database(s.database).Collection(s.liveStreamsCollection)
+	
+	filter := bson.M{"stream_id": streamID}
+	
+	var liveStreamDoc LiveStreamDocument
+	err := collection.FindOne(ctx, filter).Decode(&liveStreamDoc)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("%w: %v", video.ErrStreamNotFound, err)
 		}
 		return nil, fmt.Errorf("failed to get live stream: %w", err)
 	}
@@ -255,7 +588,131 @@ func (s *VideoStorage) GetLiveStream(ctx context.Context, streamID string) (*vid
 	return s.fromLiveStreamDocument(&liveStreamDoc), nil
 }
 
-// EndLiveStream marks a live stream as ended in MongoDB
+// GetLiveStreamsByIDs retrieves the active live streams among streamIDs with a single query
+func (s *VideoStorage) GetLiveStreamsByIDs(ctx context.Context, streamIDs []string) ([]*video.LiveStream, error) {
+	collection := s.client.Database(s.database).Collection(s.liveStreamsCollection)
+	
+	filter := bson.M{"stream_id": bson.M{"$in": streamIDs}, "is_active": true}
+	
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get live streams: %w", err)
+	}
+	defer cursor.Close(ctx)
+	
+	var liveStreamDocs []LiveStreamDocument
+	if err := cursor.All(ctx, &liveStreamDocs); err != nil {
+		return nil, fmt.Errorf("failed to decode live streams: %w", err)
+	}
+	
+	streams := make([]*video.LiveStream, 0, len(liveStreamDocs))
+	for _, doc := range liveStreamDocs {
+		streams = append(streams, s.fromLiveStreamDocument(&doc))
+	}
+	
+	return streams, nil
+}
+
+// AdjustViewerCount changes the viewer count of an active live stream.
+// Increments are a plain $inc, only matching while the count stays within
+// maxViewers; decrements use an update pipeline so the count is clamped at zero
+// within the same atomic update.
+func (s *VideoStorage) AdjustViewerCount(ctx context.Context, streamID string, delta int64, maxViewers int64) (int64, error) {
+	collection := s.client.Database(s.database).Collection(s.liveStreamsCollection)
+	
+	filter := bson.M{"stream_id": streamID, "is_active": true}
+	if delta > 0 && maxViewers > 0 {
+		filter["viewer_count"] = bson.M{"$lte": maxViewers - delta}
+	}
+	
+	var update interface{} = bson.M{"$inc": bson.M{"viewer_count": delta}}
+	if delta < 0 {
+		update = mongo.Pipeline{
+			{{Key: "$set", Value: bson.M{
+				"viewer_count": bson.M{"$max": bson.A{0, bson.M{"$add": bson.A{"$viewer_count", delta}}}},
+			}}},
+		}
+	}
+	
+	opts := options.FindOneAndUpdate().
+		SetReturnDocument(options.After).
+		SetProjection(bson.M{"viewer_count": 1})
+	
+	var liveStreamDoc LiveStreamDocument
+	err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&liveStreamDoc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// Either the stream isn't live or it is full
+		err = collection.FindOne(ctx, bson.M{"stream_id": streamID, "is_active": true},
+			options.FindOne().SetProjection(bson.M{"viewer_count": 1})).Decode(&liveStreamDoc)
+		if err == nil {
+			return liveStreamDoc.ViewerCount, video.ErrStreamAtCapacity
+		}
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return 0, fmt.Errorf("%w: %v", video.ErrStreamNotFound, err)
+		}
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to adjust viewer count: %w", err)
+	}
+	
+	return liveStreamDoc.ViewerCount, nil
+}
+
+// SaveStreamMessage saves a chat message to MongoDB
+func (s *VideoStorage) SaveStreamMessage(ctx context.Context, message *video.StreamMessage) error {
+	collection := s.client.Database(s.database).Collection(s.streamMessagesCollection)
+	
+	_, err := collection.InsertOne(ctx, StreamMessageDocument{
+		MessageID: message.ID,
+		StreamID:  message.StreamID,
+		UserID:    message.UserID,
+		Text:      message.Text,
+		CreatedAt: message.CreatedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save stream message: %w", err)
+	}
+	
+	return nil
+}
+
+// ListStreamMessages retrieves up to limit of a stream's messages posted
+// before the given time, newest first
+func (s *VideoStorage) ListStreamMessages(ctx context.Context, streamID string, limit int, before time.Time) ([]*video.StreamMessage, error) {
+	collection := s.client.Database(s.database).Collection(s.streamMessagesCollection)
+	
+	filter := bson.M{"stream_id": streamID, "created_at": bson.M{"$lt": before}}
+	findOptions := options.Find().
+		SetLimit(int64(limit)).
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}})
+	
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stream messages: %w", err)
+	}
+	defer cursor.Close(ctx)
+	
+	var messageDocs []StreamMessageDocument
+	if err := cursor.All(ctx, &messageDocs); err != nil {
+		return nil, fmt.Errorf("failed to decode stream messages: %w", err)
+	}
+	
+	messages := make([]*video.StreamMessage, 0, len(messageDocs))
+	for _, doc := range messageDocs {
+		messages = append(messages, &video.StreamMessage{
+			ID:        doc.MessageID,
+			StreamID:  doc.StreamID,
+			UserID:    doc.UserID,
+			Text:      doc.Text,
+			CreatedAt: doc.CreatedAt,
+		})
+	}
+	
+	return messages, nil
+}
+
+// EndLiveStream marks a live stream as ended in MongoDB.
+// Ending an already ended stream succeeds without changing it.
 func (s *VideoStorage) EndLiveStream(ctx context.Context, streamID string, userID string) error {
 	collection := s.client.Database(s.database).Collection(s.liveStreamsCollection)
 	
@@ -269,6 +726,7 @@ func (s *VideoStorage) EndLiveStream(ctx context.Context, streamID string, userI
 	update := bson.M{
 		"$set": bson.M{
 			"is_active": false,
+			"status":    int32(pb.StreamStatus_STREAM_STATUS_ENDED),
 			"ended_at": now,
 		},
 	}
@@ -279,7 +737,18 @@ func (s *VideoStorage) EndLiveStream(ctx context.Context, streamID string, userI
 	}
 	
 	if result.MatchedCount == 0 {
-		return fmt.Errorf("live stream not found or not active")
+		// Tell an already ended stream apart from a missing or foreign one
+		var existing LiveStreamDocument
+		err := collection.FindOne(ctx, bson.M{"stream_id": streamID}).Decode(&existing)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return video.ErrStreamNotFound
+			}
+			return fmt.Errorf("failed to get live stream: %w", err)
+		}
+		if existing.UserID != userID {
+			return fmt.Errorf("%w: not authorized to end this stream", video.ErrPermissionDenied)
+		}
 	}
 	
 	return nil
@@ -287,13 +756,30 @@ func (s *VideoStorage) EndLiveStream(ctx context.Context, streamID string, userI
 
 // ListLiveStreams retrieves a list of active live streams from MongoDB
 func (s *VideoStorage) ListLiveStreams(ctx context.Context, userID string, limit int, offset int) ([]*video.LiveStream, int, error) {
-	collection := s.client.Database(s.database).Collection(s.liveStreamsCollection)
-	
 	filter := bson.M{"is_active": true}
 	if userID != "" {
 		filter["user_id"] = userID
 	}
 	
+	return s.listLiveStreams(ctx, filter, limit, offset)
+}
+
+// ListLiveStreamsByCategory retrieves active live streams in a category;
+// an empty category matches every stream
+func (s *VideoStorage) ListLiveStreamsByCategory(ctx context.Context, category string, limit int, offset int) ([]*video.LiveStream, int, error) {
+	filter := bson.M{"is_active": true}
+	if category != "" {
+		filter["category"] = category
+	}
+	
+	return s.listLiveStreams(ctx, filter, limit, offset)
+}
+
+// listLiveStreams retrieves a page of the live streams matching filter,
+// along with how many match in total
+func (s *VideoStorage) listLiveStreams(ctx context.Context, filter bson.M, limit int, offset int) ([]*video.LiveStream, int, error) {
+	collection := s.client.Database(s.database).Collection(s.liveStreamsCollection)
+	
 	// Count total active live streams matching filter
 	total, err := collection.CountDocuments(ctx, filter)
 	if err != nil {
@@ -303,7 +789,7 @@ func (s *VideoStorage) ListLiveStreams(ctx context.Context, userID string, limit
 	findOptions := options.Find().
 		SetLimit(int64(limit)).
 		SetSkip(int64(offset)).
-		SetSort(bson.M{"started_at": -1}) // Sort by start time, newest first
+		SetSort(bson.D{{Key: "started_at", Value: -1}, {Key: "stream_id", Value: 1}}) // Newest first, stable across pages
 	
 	cursor, err := collection.Find(ctx, filter, findOptions)
 	if err != nil {
@@ -336,11 +822,18 @@ func (s *VideoStorage) toVideoDocument(v *video.Video) VideoDocument {
 		DurationSeconds: v.DurationSeconds,
 		ViewCount:      v.ViewCount,
 		Status:         int32(v.Status),
+		StatusReason:   v.StatusReason,
 		CreatedAt:      v.CreatedAt,
 		UpdatedAt:      v.UpdatedAt,
 		Tags:           v.Tags,
 		Visibility:     int32(v.Visibility),
 		Resolution:     int32(v.Resolution),
+		ContentHash:    v.ContentHash,
+		MediaID:        v.MediaID,
+		SourceKey:      v.SourceKey,
+		FileSizeBytes:  v.FileSizeBytes,
+		DeletedAt:      v.DeletedAt,
+		CallbackURL:    v.CallbackURL,
 	}
 }
 
@@ -356,16 +849,27 @@ func (s *VideoStorage) fromVideoDocument(doc *VideoDocument) *video.Video {
 		DurationSeconds: doc.DurationSeconds,
 		ViewCount:      doc.ViewCount,
 		Status:         pb.VideoStatus(doc.Status),
+		StatusReason:   doc.StatusReason,
 		CreatedAt:      doc.CreatedAt,
 		UpdatedAt:      doc.UpdatedAt,
 		Tags:           doc.Tags,
 		Visibility:     pb.VideoVisibility(doc.Visibility),
 		Resolution:     pb.VideoResolution(doc.Resolution),
+		ContentHash:    doc.ContentHash,
+		MediaID:        doc.MediaID,
+		SourceKey:      doc.SourceKey,
+		FileSizeBytes:  doc.FileSizeBytes,
+		DeletedAt:      doc.DeletedAt,
+		CallbackURL:    doc.CallbackURL,
 	}
 }
 
 // Helper function to convert internal video.LiveStream to LiveStreamDocument
 func (s *VideoStorage) toLiveStreamDocument(ls *video.LiveStream) LiveStreamDocument {
+	// Streams count as active until they end or fail
+	isActive := ls.Status != pb.StreamStatus_STREAM_STATUS_ENDED &&
+		ls.Status != pb.StreamStatus_STREAM_STATUS_ERROR
+	
 	return LiveStreamDocument{
 		StreamID:     ls.StreamID,
 		UserID:       ls.UserID,
@@ -373,16 +877,31 @@ func (s *VideoStorage) toLiveStreamDocument(ls *video.LiveStream) LiveStreamDocu
 		Description:  ls.Description,
 		ThumbnailURL: ls.ThumbnailURL,
 		PlaybackURL:  ls.PlaybackURL,
+		WebRTCPlaybackURL: ls.WebRTCPlaybackURL,
 		ViewerCount:  ls.ViewerCount,
-		IsActive:     true,
+		IsActive:     isActive,
+		Status:       int32(ls.Status),
 		StartedAt:    ls.StartedAt,
-		EndedAt:      nil,
+		EndedAt:      ls.EndedAt,
 		Tags:         ls.Tags,
+		Category:     ls.Category,
+		StreamKey:    ls.StreamKey,
+		MaxViewers:   ls.MaxViewers,
 	}
 }
 
 // Helper function to convert LiveStreamDocument to internal video.LiveStream
 func (s *VideoStorage) fromLiveStreamDocument(doc *LiveStreamDocument) *video.LiveStream {
+	// Documents written before the status was stored only know whether
+	// the stream was still active
+	status := pb.StreamStatus(doc.Status)
+	if status == pb.StreamStatus_STREAM_STATUS_UNSPECIFIED {
+		status = pb.StreamStatus_STREAM_STATUS_LIVE
+		if !doc.IsActive {
+			status = pb.StreamStatus_STREAM_STATUS_ENDED
+		}
+	}
+	
 	return &video.LiveStream{
 		StreamID:     doc.StreamID,
 		UserID:       doc.UserID,
@@ -390,8 +909,14 @@ func (s *VideoStorage) fromLiveStreamDocument(doc *LiveStreamDocument) *video.Li
 		Description:  doc.Description,
 		ThumbnailURL: doc.ThumbnailURL,
 		PlaybackURL:  doc.PlaybackURL,
+		WebRTCPlaybackURL: doc.WebRTCPlaybackURL,
 		ViewerCount:  doc.ViewerCount,
+		Status:       status,
 		StartedAt:    doc.StartedAt,
+		EndedAt:      doc.EndedAt,
 		Tags:         doc.Tags,
+		Category:     doc.Category,
+		StreamKey:    doc.StreamKey,
+		MaxViewers:   doc.MaxViewers,
 	}
 }
\ No newline at end of file
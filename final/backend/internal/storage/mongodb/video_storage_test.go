@@ -0,0 +1,100 @@
+package mongodb
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"videostreaming/internal/service/video"
+	pb "videostreaming/proto/video"
+)
+
+func TestVideoDocumentRoundTrip(t *testing.T) {
+	s := &VideoStorage{}
+	deleted := time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)
+	videos := []*video.Video{
+		{
+			ID:              "v1",
+			Title:           "Holiday",
+			Description:     "At the beach",
+			UserID:          "owner",
+			ThumbnailURL:    "https://cdn.example.com/v1.jpg",
+			VideoURL:        "https://cdn.example.com/v1/master.m3u8",
+			DurationSeconds: 90,
+			ViewCount:       7,
+			Status:          pb.VideoStatus_VIDEO_STATUS_READY,
+			CreatedAt:       time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			UpdatedAt:       time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+			Tags:            []string{"beach"},
+			Visibility:      pb.VideoVisibility_VIDEO_VISIBILITY_UNLISTED,
+			Resolution:      pb.VideoResolution_VIDEO_RESOLUTION_1080P,
+			ContentHash:     "e3b0c442",
+			MediaID:         "v0",
+			SourceKey:       "recordings/key/1.mp4",
+			FileSizeBytes:   52428800,
+			DeletedAt:       &deleted,
+			CallbackURL:     "https://example.com/hooks/video",
+		},
+		{ID: "v2", UserID: "owner", Status: pb.VideoStatus_VIDEO_STATUS_FAILED, StatusReason: "unsupported codec"},
+	}
+	for _, v := range videos {
+		doc := s.toVideoDocument(v)
+		if got := s.fromVideoDocument(&doc); !reflect.DeepEqual(got, v) {
+			t.Errorf("round trip = %+v, want %+v", got, v)
+		}
+	}
+}
+
+func TestLiveStreamDocumentRoundTrip(t *testing.T) {
+	s := &VideoStorage{}
+	ended := time.Date(2024, 1, 1, 2, 0, 0, 0, time.UTC)
+	streams := []*video.LiveStream{
+		{
+			StreamID:          "s1",
+			UserID:            "owner",
+			Title:             "Speedrun",
+			Description:       "Any%",
+			ThumbnailURL:      "https://cdn.example.com/s1.jpg",
+			PlaybackURL:       "https://cdn.example.com/live/s1/index.m3u8",
+			WebRTCPlaybackURL: "https://cdn.example.com/live/s1/whep",
+			ViewerCount:       12,
+			Status:            pb.StreamStatus_STREAM_STATUS_ENDED,
+			StartedAt:         time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			EndedAt:           &ended,
+			Tags:              []string{"games", "retro"},
+			Category:          "gaming",
+			StreamKey:         "key-owner",
+			MaxViewers:        100,
+		},
+		// Still live, so there's no end time
+		{StreamID: "s2", UserID: "owner", Status: pb.StreamStatus_STREAM_STATUS_LIVE, Category: "music", StreamKey: "key-owner"},
+	}
+	for _, stream := range streams {
+		doc := s.toLiveStreamDocument(stream)
+		if want := stream.Status == pb.StreamStatus_STREAM_STATUS_LIVE; doc.IsActive != want {
+			t.Errorf("%s: IsActive = %v, want %v for a %v stream", stream.StreamID, doc.IsActive, want, stream.Status)
+		}
+		if got := s.fromLiveStreamDocument(&doc); !reflect.DeepEqual(got, stream) {
+			t.Errorf("round trip = %+v, want %+v", got, stream)
+		}
+	}
+}
+
+func TestLiveStreamDocumentWithoutStatus(t *testing.T) {
+	s := &VideoStorage{}
+
+	// Documents written before the status was stored fall back on IsActive
+	tests := []struct {
+		isActive bool
+		want     pb.StreamStatus
+	}{
+		{true, pb.StreamStatus_STREAM_STATUS_LIVE},
+		{false, pb.StreamStatus_STREAM_STATUS_ENDED},
+	}
+	for _, tt := range tests {
+		got := s.fromLiveStreamDocument(&LiveStreamDocument{StreamID: "s1", IsActive: tt.isActive})
+		if got.Status != tt.want {
+			t.Errorf("status with is_active %v = %v, want %v", tt.isActive, got.Status, tt.want)
+		}
+	}
+}
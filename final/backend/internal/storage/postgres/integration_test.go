@@ -0,0 +1,489 @@
+//go:build integration
+
+// Integration tests against a real PostgreSQL server. Run them with
+//
+//	DATABASE_URL=postgres://... go test -tags integration ./internal/storage/postgres/
+//
+// Each test works in a schema of its own, dropped when it ends.
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"videostreaming/internal/service/transcode"
+	"videostreaming/internal/service/video"
+	pb "videostreaming/proto/video"
+)
+
+// newTestPool connects to DATABASE_URL with a fresh, migrated schema as
+// the search path
+func newTestPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+	ctx := context.Background()
+
+	admin, err := pgxpool.New(ctx, databaseURL)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(admin.Close)
+
+	schema := fmt.Sprintf("video_test_%d", rand.Int63())
+	if _, err := admin.Exec(ctx, "create schema "+schema); err != nil {
+		t.Fatalf("failed to create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec(context.Background(), "drop schema "+schema+" cascade"); err != nil {
+			t.Errorf("failed to drop schema %s: %v", schema, err)
+		}
+	})
+
+	config, err := pgxpool.ParseConfig(databaseURL)
+	if err != nil {
+		t.Fatalf("failed to parse DATABASE_URL: %v", err)
+	}
+	config.ConnConfig.RuntimeParams["search_path"] = schema
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	if err := NewVideoStorage(pool).Migrate(ctx); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	return pool
+}
+
+// newTestVideo returns a video with timestamps PostgreSQL stores exactly
+func newTestVideo(id, userID string, createdAt time.Time) *video.Video {
+	return &video.Video{
+		ID:         id,
+		Title:      "Video " + id,
+		UserID:     userID,
+		Status:     pb.VideoStatus_VIDEO_STATUS_READY,
+		Visibility: pb.VideoVisibility_VIDEO_VISIBILITY_PUBLIC,
+		CreatedAt:  createdAt,
+		UpdatedAt:  createdAt,
+		Tags:       []string{},
+	}
+}
+
+func TestMigrateIsRepeatable(t *testing.T) {
+	pool := newTestPool(t)
+
+	if err := NewVideoStorage(pool).Migrate(context.Background()); err != nil {
+		t.Fatalf("second Migrate: %v", err)
+	}
+}
+
+func TestVideoRoundTrip(t *testing.T) {
+	storage := NewVideoStorage(newTestPool(t))
+	ctx := context.Background()
+
+	deletedAt := time.Date(2024, 3, 4, 5, 6, 7, 0, time.UTC)
+	original := &video.Video{
+		ID:              "v1",
+		Title:           "Title",
+		Description:     "Description",
+		UserID:          "owner",
+		ThumbnailURL:    "thumbnails/v1",
+		VideoURL:        "videos/v1",
+		DurationSeconds: 90,
+		ViewCount:       42,
+		Status:          pb.VideoStatus_VIDEO_STATUS_READY,
+		StatusReason:    "reason",
+		CreatedAt:       time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		UpdatedAt:       time.Date(2024, 2, 3, 4, 5, 6, 0, time.UTC),
+		Tags:            []string{"a", "b"},
+		Visibility:      pb.VideoVisibility_VIDEO_VISIBILITY_PRIVATE,
+		Resolution:      pb.VideoResolution_VIDEO_RESOLUTION_1080P,
+		ContentHash:     "hash",
+		MediaID:         "media",
+		SourceKey:       "recordings/s1.mp4",
+		FileSizeBytes:   1 << 20,
+		DeletedAt:       &deletedAt,
+		CallbackURL:     "https://example.com/hook",
+	}
+	if err := storage.SaveVideo(ctx, original); err != nil {
+		t.Fatalf("SaveVideo: %v", err)
+	}
+
+	got, err := storage.GetVideo(ctx, "v1")
+	if err != nil {
+		t.Fatalf("GetVideo: %v", err)
+	}
+	// Timestamps come back in the local zone
+	got.CreatedAt = got.CreatedAt.UTC()
+	got.UpdatedAt = got.UpdatedAt.UTC()
+	gotDeletedAt := got.DeletedAt.UTC()
+	got.DeletedAt = &gotDeletedAt
+	if !reflect.DeepEqual(got, original) {
+		t.Errorf("GetVideo =\n %+v\nwant\n %+v", got, original)
+	}
+
+	// Saving again leaves the view count to IncrementViewCount
+	original.ViewCount = 0
+	original.Title = "Renamed"
+	if err := storage.SaveVideo(ctx, original); err != nil {
+		t.Fatalf("SaveVideo: %v", err)
+	}
+	views, err := storage.IncrementViewCount(ctx, "v1")
+	if err != nil {
+		t.Fatalf("IncrementViewCount: %v", err)
+	}
+	if views != 43 {
+		t.Errorf("IncrementViewCount = %d, want 43", views)
+	}
+
+	if _, err := storage.GetVideo(ctx, "missing"); err == nil {
+		t.Errorf("GetVideo of a missing video succeeded")
+	}
+}
+
+func TestListVideos(t *testing.T) {
+	storage := NewVideoStorage(newTestPool(t))
+	ctx := context.Background()
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, owner := range []string{"alice", "alice", "bob", "alice"} {
+		v := newTestVideo(fmt.Sprintf("v%d", i), owner, base.Add(time.Duration(i)*time.Hour))
+		v.FileSizeBytes = 100
+		if err := storage.SaveVideo(ctx, v); err != nil {
+			t.Fatalf("SaveVideo: %v", err)
+		}
+	}
+	// Videos in the trash still count towards the quota but aren't listed
+	trashed := newTestVideo("trashed", "alice", base)
+	trashed.FileSizeBytes = 100
+	trashed.DeletedAt = &base
+	if err := storage.SaveVideo(ctx, trashed); err != nil {
+		t.Fatalf("SaveVideo: %v", err)
+	}
+	// Private videos are only listed for their owner
+	private := newTestVideo("private", "alice", base.Add(10*time.Hour))
+	private.Visibility = pb.VideoVisibility_VIDEO_VISIBILITY_PRIVATE
+	if err := storage.SaveVideo(ctx, private); err != nil {
+		t.Fatalf("SaveVideo: %v", err)
+	}
+
+	videos, total, err := storage.ListVideos(ctx, video.ListVideosFilter{UserID: "alice", ViewerID: "bob"}, 2, 0)
+	if err != nil {
+		t.Fatalf("ListVideos: %v", err)
+	}
+	if total != 3 || len(videos) != 2 || videos[0].ID != "v3" || videos[1].ID != "v1" {
+		t.Errorf("ListVideos = %d of %d, want v3, v1 of 3", len(videos), total)
+	}
+	videos, total, err = storage.ListVideos(ctx, video.ListVideosFilter{UserID: "alice", ViewerID: "alice"}, 2, 0)
+	if err != nil {
+		t.Fatalf("ListVideos: %v", err)
+	}
+	if total != 4 || len(videos) != 2 || videos[0].ID != "private" {
+		t.Errorf("ListVideos for the owner = %d of %d, want private first of 4", len(videos), total)
+	}
+
+	usage, err := storage.GetUserStorageUsage(ctx, "alice")
+	if err != nil {
+		t.Fatalf("GetUserStorageUsage: %v", err)
+	}
+	if usage != 400 {
+		t.Errorf("GetUserStorageUsage = %d, want 400", usage)
+	}
+
+	deleted, err := storage.ListDeletedVideos(ctx, base.Add(time.Second), 10)
+	if err != nil {
+		t.Fatalf("ListDeletedVideos: %v", err)
+	}
+	if len(deleted) != 1 || deleted[0].ID != "trashed" {
+		t.Errorf("ListDeletedVideos = %v, want the trashed video", deleted)
+	}
+
+	if err := storage.DeleteVideo(ctx, "v0", "bob"); !errors.Is(err, video.ErrNotFound) {
+		t.Errorf("DeleteVideo by another user error = %v, want %v", err, video.ErrNotFound)
+	}
+	if _, err := storage.GetVideo(ctx, "missing"); !errors.Is(err, video.ErrNotFound) {
+		t.Errorf("GetVideo of a missing video error = %v, want %v", err, video.ErrNotFound)
+	}
+	if err := storage.DeleteVideo(ctx, "v0", "alice"); err != nil {
+		t.Errorf("DeleteVideo: %v", err)
+	}
+}
+
+func TestListVideosSortBy(t *testing.T) {
+	storage := NewVideoStorage(newTestPool(t))
+	ctx := context.Background()
+
+	// b and d share a creation time and a title, a and c a view count
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tv := range []struct {
+		id, title string
+		views     int64
+		createdAt time.Time
+	}{
+		{"a", "Birds", 10, base},
+		{"b", "Cooking", 50, base.Add(2 * time.Minute)},
+		{"c", "Alpine", 10, base.Add(time.Minute)},
+		{"d", "Cooking", 0, base.Add(2 * time.Minute)},
+	} {
+		v := newTestVideo(tv.id, "owner", tv.createdAt)
+		v.Title = tv.title
+		v.ViewCount = tv.views
+		if err := storage.SaveVideo(ctx, v); err != nil {
+			t.Fatalf("SaveVideo: %v", err)
+		}
+	}
+
+	tests := []struct {
+		sortBy pb.VideoSortBy
+		want   string
+	}{
+		{pb.VideoSortBy_VIDEO_SORT_BY_UNSPECIFIED, "[b d c a]"},
+		{pb.VideoSortBy_VIDEO_SORT_BY_CREATED_DESC, "[b d c a]"},
+		{pb.VideoSortBy_VIDEO_SORT_BY_CREATED_ASC, "[a c b d]"},
+		{pb.VideoSortBy_VIDEO_SORT_BY_VIEWS_DESC, "[b a c d]"},
+		{pb.VideoSortBy_VIDEO_SORT_BY_TITLE_ASC, "[c a b d]"},
+	}
+	for _, tt := range tests {
+		// Paging two at a time gives the same order as a single page
+		var ids []string
+		for offset := 0; offset < 4; offset += 2 {
+			videos, _, err := storage.ListVideos(ctx, video.ListVideosFilter{UserID: "owner", SortBy: tt.sortBy}, 2, offset)
+			if err != nil {
+				t.Fatalf("ListVideos: %v", err)
+			}
+			for _, v := range videos {
+				ids = append(ids, v.ID)
+			}
+		}
+		if got := fmt.Sprint(ids); got != tt.want {
+			t.Errorf("ListVideos sorted by %v = %s, want %s", tt.sortBy, got, tt.want)
+		}
+	}
+}
+
+func TestSearchVideos(t *testing.T) {
+	storage := NewVideoStorage(newTestPool(t))
+	ctx := context.Background()
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cats := newTestVideo("cats", "alice", base)
+	cats.Title = "Funny cats"
+	dogs := newTestVideo("dogs", "alice", base)
+	dogs.Tags = []string{"dogs"}
+	for _, v := range []*video.Video{cats, dogs, newTestVideo("other", "alice", base)} {
+		if err := storage.SaveVideo(ctx, v); err != nil {
+			t.Fatalf("SaveVideo: %v", err)
+		}
+	}
+
+	videos, total, err := storage.SearchVideos(ctx, "cats dogs", 10, 0)
+	if err != nil {
+		t.Fatalf("SearchVideos: %v", err)
+	}
+	if total != 2 || len(videos) != 2 {
+		t.Errorf("SearchVideos found %d of %d, want both cats and dogs", len(videos), total)
+	}
+}
+
+func TestLiveStreams(t *testing.T) {
+	storage := NewVideoStorage(newTestPool(t))
+	ctx := context.Background()
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	streams := []*video.LiveStream{
+		{StreamID: "s1", UserID: "alice", Status: pb.StreamStatus_STREAM_STATUS_LIVE, StartedAt: base, Category: "music", StreamKey: "k1"},
+		{StreamID: "s2", UserID: "bob", Status: pb.StreamStatus_STREAM_STATUS_LIVE, StartedAt: base.Add(time.Hour), Category: "games"},
+		{StreamID: "s3", UserID: "alice", Status: pb.StreamStatus_STREAM_STATUS_LIVE, StartedAt: base.Add(2 * time.Hour), Category: "music"},
+	}
+	for _, stream := range streams {
+		if err := storage.SaveLiveStream(ctx, stream); err != nil {
+			t.Fatalf("SaveLiveStream: %v", err)
+		}
+	}
+
+	alice, total, err := storage.ListLiveStreams(ctx, "alice", 10, 0)
+	if err != nil {
+		t.Fatalf("ListLiveStreams: %v", err)
+	}
+	if total != 2 || len(alice) != 2 || alice[0].StreamID != "s3" {
+		t.Errorf("ListLiveStreams(alice) = %d of %d, want s3, s1", len(alice), total)
+	}
+	if _, total, _ := storage.ListLiveStreams(ctx, "", 10, 0); total != 3 {
+		t.Errorf("ListLiveStreams of every user counted %d streams, want 3", total)
+	}
+
+	music, total, err := storage.ListLiveStreamsByCategory(ctx, "music", 10, 0)
+	if err != nil {
+		t.Fatalf("ListLiveStreamsByCategory: %v", err)
+	}
+	if total != 2 || len(music) != 2 || music[0].StreamID != "s3" {
+		t.Errorf("ListLiveStreamsByCategory(music) = %d of %d, want s3, s1", len(music), total)
+	}
+	if _, total, _ := storage.ListLiveStreamsByCategory(ctx, "", 10, 0); total != 3 {
+		t.Errorf("ListLiveStreamsByCategory of every category counted %d streams, want 3", total)
+	}
+
+	count, err := storage.AdjustViewerCount(ctx, "s1", -5, 0)
+	if err != nil {
+		t.Fatalf("AdjustViewerCount: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("AdjustViewerCount went to %d, want it clamped at 0", count)
+	}
+	if count, err := storage.AdjustViewerCount(ctx, "s1", 1, 1); err != nil || count != 1 {
+		t.Errorf("AdjustViewerCount below the cap = %d, %v; want 1", count, err)
+	}
+	if _, err := storage.AdjustViewerCount(ctx, "s1", 1, 1); !errors.Is(err, video.ErrStreamAtCapacity) {
+		t.Errorf("AdjustViewerCount at the cap error = %v, want %v", err, video.ErrStreamAtCapacity)
+	}
+	if _, err := storage.AdjustViewerCount(ctx, "missing", 1, 1); !errors.Is(err, video.ErrStreamNotFound) {
+		t.Errorf("AdjustViewerCount of a missing stream error = %v, want %v", err, video.ErrStreamNotFound)
+	}
+
+	// Only the owner ends a stream, and ending it twice is fine
+	if err := storage.EndLiveStream(ctx, "s1", "bob"); !errors.Is(err, video.ErrPermissionDenied) {
+		t.Errorf("EndLiveStream by another user error = %v, want %v", err, video.ErrPermissionDenied)
+	}
+	for i := 0; i < 2; i++ {
+		if err := storage.EndLiveStream(ctx, "s1", "alice"); err != nil {
+			t.Fatalf("EndLiveStream: %v", err)
+		}
+	}
+	if err := storage.EndLiveStream(ctx, "missing", "alice"); !errors.Is(err, video.ErrStreamNotFound) {
+		t.Errorf("EndLiveStream of a missing stream error = %v, want %v", err, video.ErrStreamNotFound)
+	}
+
+	if _, err := storage.GetLiveStream(ctx, "s1"); !errors.Is(err, video.ErrStreamNotFound) {
+		t.Errorf("GetLiveStream of an ended stream error = %v, want %v", err, video.ErrStreamNotFound)
+	}
+	ended, err := storage.GetLiveStreamByID(ctx, "s1")
+	if err != nil {
+		t.Fatalf("GetLiveStreamByID: %v", err)
+	}
+	if ended.Status != pb.StreamStatus_STREAM_STATUS_ENDED || ended.EndedAt == nil || ended.Category != "music" {
+		t.Errorf("ended stream = %+v, want ENDED with ended_at and its category", ended)
+	}
+
+	active, err := storage.GetLiveStreamsByIDs(ctx, []string{"s1", "s2", "missing"})
+	if err != nil {
+		t.Fatalf("GetLiveStreamsByIDs: %v", err)
+	}
+	if len(active) != 1 || active[0].StreamID != "s2" {
+		t.Errorf("GetLiveStreamsByIDs = %v, want only s2", active)
+	}
+}
+
+func TestStreamKeys(t *testing.T) {
+	storage := NewVideoStorage(newTestPool(t))
+	ctx := context.Background()
+
+	if err := storage.SaveStreamKey(ctx, "alice", "old-key"); err != nil {
+		t.Fatalf("SaveStreamKey: %v", err)
+	}
+	if err := storage.SaveStreamKey(ctx, "alice", "new-key"); err != nil {
+		t.Fatalf("SaveStreamKey: %v", err)
+	}
+
+	key, err := storage.GetStreamKey(ctx, "alice")
+	if err != nil || key != "new-key" {
+		t.Errorf("GetStreamKey = %q, %v; want new-key", key, err)
+	}
+	if owner, err := storage.GetStreamKeyOwner(ctx, "new-key"); err != nil || owner != "alice" {
+		t.Errorf("GetStreamKeyOwner(new-key) = %q, %v; want alice", owner, err)
+	}
+	if owner, err := storage.GetStreamKeyOwner(ctx, "old-key"); err != nil || owner != "" {
+		t.Errorf("GetStreamKeyOwner(old-key) = %q, %v; want nobody", owner, err)
+	}
+	if _, err := storage.GetStreamKey(ctx, "bob"); err == nil {
+		t.Errorf("GetStreamKey for a user without a key succeeded")
+	}
+}
+
+func TestStreamMessages(t *testing.T) {
+	storage := NewVideoStorage(newTestPool(t))
+	ctx := context.Background()
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		err := storage.SaveStreamMessage(ctx, &video.StreamMessage{
+			ID:        fmt.Sprintf("m%d", i),
+			StreamID:  "s1",
+			UserID:    "alice",
+			Text:      "hello",
+			CreatedAt: base.Add(time.Duration(i) * time.Minute),
+		})
+		if err != nil {
+			t.Fatalf("SaveStreamMessage: %v", err)
+		}
+	}
+
+	messages, err := storage.ListStreamMessages(ctx, "s1", 10, base.Add(2*time.Minute))
+	if err != nil {
+		t.Fatalf("ListStreamMessages: %v", err)
+	}
+	if len(messages) != 2 || messages[0].ID != "m1" || messages[1].ID != "m0" {
+		t.Errorf("ListStreamMessages = %v, want m1, m0", messages)
+	}
+}
+
+func TestTranscodingJobs(t *testing.T) {
+	storage := NewTranscodeStorage(newTestPool(t))
+	ctx := context.Background()
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	jobs := []*transcode.TranscodingJob{
+		{ID: "j1", VideoID: "v1", Resolution: pb.VideoResolution_VIDEO_RESOLUTION_720P, Status: pb.TranscodingStatus_TRANSCODING_STATUS_PROCESSING, StartTime: base},
+		{ID: "j2", VideoID: "v1", Resolution: pb.VideoResolution_VIDEO_RESOLUTION_1080P, Status: pb.TranscodingStatus_TRANSCODING_STATUS_QUEUED, StartTime: base.Add(time.Second)},
+		{ID: "j3", VideoID: "v2", Status: pb.TranscodingStatus_TRANSCODING_STATUS_QUEUED, StartTime: base},
+	}
+	for _, job := range jobs {
+		if err := storage.SaveTranscodingJob(ctx, job); err != nil {
+			t.Fatalf("SaveTranscodingJob: %v", err)
+		}
+	}
+
+	completed := base.Add(time.Minute)
+	jobs[2].Status = pb.TranscodingStatus_TRANSCODING_STATUS_COMPLETED
+	jobs[2].Progress = 100
+	jobs[2].CompletionTime = &completed
+	if err := storage.UpdateTranscodingJob(ctx, jobs[2]); err != nil {
+		t.Fatalf("UpdateTranscodingJob: %v", err)
+	}
+
+	v1Jobs, err := storage.GetTranscodingJobs(ctx, "v1")
+	if err != nil {
+		t.Fatalf("GetTranscodingJobs: %v", err)
+	}
+	if len(v1Jobs) != 2 || v1Jobs[0].ID != "j1" || v1Jobs[1].ID != "j2" {
+		t.Errorf("GetTranscodingJobs(v1) = %v, want j1, j2", v1Jobs)
+	}
+
+	active, err := storage.ListActiveTranscodingJobs(ctx)
+	if err != nil {
+		t.Fatalf("ListActiveTranscodingJobs: %v", err)
+	}
+	if len(active) != 2 {
+		t.Errorf("ListActiveTranscodingJobs returned %d jobs, want j1 and j2", len(active))
+	}
+
+	v2Jobs, err := storage.GetTranscodingJobs(ctx, "v2")
+	if err != nil {
+		t.Fatalf("GetTranscodingJobs: %v", err)
+	}
+	if len(v2Jobs) != 1 || v2Jobs[0].CompletionTime == nil || !v2Jobs[0].CompletionTime.Equal(completed) {
+		t.Errorf("GetTranscodingJobs(v2) = %+v, want the completed job", v2Jobs)
+	}
+}
@@ -0,0 +1,56 @@
+create table if not exists videos (
+    video_id varchar(64) primary key,
+    title text not null default '',
+    description text not null default '',
+    user_id varchar(64) not null,
+    thumbnail_url text not null default '',
+    video_url text not null default '',
+    duration_seconds bigint not null default 0,
+    view_count bigint not null default 0,
+    status integer not null default 0,
+    status_reason text not null default '',
+    created_at timestamptz not null default current_timestamp,
+    updated_at timestamptz not null default current_timestamp,
+    tags text[] not null default '{}',
+    visibility integer not null default 0,
+    resolution integer not null default 0,
+    content_hash varchar(64) not null default '',
+    media_id varchar(64) not null default '',
+    source_key text not null default ''
+);
+
+create index if not exists videos_user_id_idx on videos (user_id);
+create index if not exists videos_created_at_idx on videos (created_at desc);
+create index if not exists videos_content_hash_idx on videos (content_hash) where content_hash <> '';
+
+create table if not exists stream_keys (
+    user_id varchar(64) primary key,
+    stream_key varchar(128) not null,
+    created_at timestamptz not null default current_timestamp,
+    updated_at timestamptz not null default current_timestamp
+);
+
+-- Used to find whose recordings a stream directory holds
+create index if not exists stream_keys_stream_key_idx on stream_keys (stream_key);
+
+create table if not exists live_streams (
+    stream_id varchar(64) primary key,
+    user_id varchar(64) not null,
+    title text not null default '',
+    description text not null default '',
+    thumbnail_url text not null default '',
+    playback_url text not null default '',
+    webrtc_playback_url text not null default '',
+    viewer_count bigint not null default 0,
+    status integer not null default 0,
+    started_at timestamptz not null default current_timestamp,
+    ended_at timestamptz,
+    tags text[] not null default '{}',
+    category text not null default '',
+    stream_key varchar(128) not null default '',
+    max_viewers bigint not null default 0
+);
+
+-- Streams that have neither ended (3) nor failed (4)
+create index if not exists live_streams_active_idx on live_streams (started_at desc) where status not in (3, 4);
+create index if not exists live_streams_user_id_idx on live_streams (user_id);
@@ -0,0 +1,18 @@
+create table if not exists transcoding_jobs (
+    job_id varchar(64) primary key,
+    video_id varchar(64) not null,
+    input_path text not null,
+    output_path text not null,
+    resolution integer not null,
+    status integer not null,
+    progress real not null default 0,
+    start_time timestamptz not null,
+    completion_time timestamptz,
+    error_message text not null default '',
+    retry_count integer not null default 0
+);
+
+create index if not exists transcoding_jobs_video_id_idx on transcoding_jobs (video_id);
+
+-- Used to resume queued (1) and processing (2) jobs after a restart
+create index if not exists transcoding_jobs_active_idx on transcoding_jobs (start_time) where status in (1, 2);
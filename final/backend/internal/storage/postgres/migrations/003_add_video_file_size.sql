@@ -0,0 +1 @@
+alter table videos add column if not exists file_size_bytes bigint not null default 0;
@@ -0,0 +1 @@
+alter table videos add column if not exists callback_url text not null default '';
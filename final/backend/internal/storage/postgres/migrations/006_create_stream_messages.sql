@@ -0,0 +1,9 @@
+create table if not exists stream_messages (
+    message_id varchar(64) primary key,
+    stream_id varchar(64) not null,
+    user_id varchar(64) not null,
+    text text not null,
+    created_at timestamptz not null default current_timestamp
+);
+
+create index if not exists stream_messages_stream_id_created_at_idx on stream_messages (stream_id, created_at desc);
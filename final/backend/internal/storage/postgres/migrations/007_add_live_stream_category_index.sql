@@ -0,0 +1 @@
+create index if not exists live_streams_category_idx on live_streams (category, started_at desc) where status not in (3, 4);
@@ -0,0 +1,120 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"videostreaming/internal/service/transcode"
+	pb "videostreaming/proto/video"
+)
+
+const transcodingJobColumns = `job_id, video_id, input_path, output_path, resolution, status,
+		progress, start_time, completion_time, error_message, retry_count`
+
+// TranscodeStorage implements the transcode.TranscodeStorage interface using PostgreSQL
+type TranscodeStorage struct {
+	pool *pgxpool.Pool
+}
+
+// NewTranscodeStorage creates a new PostgreSQL-based transcoding job storage.
+// Its table is created by VideoStorage.Migrate, which applies every migration.
+func NewTranscodeStorage(pool *pgxpool.Pool) *TranscodeStorage {
+	return &TranscodeStorage{pool: pool}
+}
+
+// SaveTranscodingJob inserts a transcoding job, or replaces it if it already exists
+func (s *TranscodeStorage) SaveTranscodingJob(ctx context.Context, job *transcode.TranscodingJob) error {
+	if err := s.upsertJob(ctx, job); err != nil {
+		return fmt.Errorf("failed to save transcoding job: %w", err)
+	}
+	return nil
+}
+
+// UpdateTranscodingJob stores the latest state of a transcoding job
+func (s *TranscodeStorage) UpdateTranscodingJob(ctx context.Context, job *transcode.TranscodingJob) error {
+	if err := s.upsertJob(ctx, job); err != nil {
+		return fmt.Errorf("failed to update transcoding job: %w", err)
+	}
+	return nil
+}
+
+// upsertJob writes a job keyed by its job ID
+func (s *TranscodeStorage) upsertJob(ctx context.Context, job *transcode.TranscodingJob) error {
+	_, err := s.pool.Exec(ctx, `
+		insert into transcoding_jobs (`+transcodingJobColumns+`)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		on conflict (job_id) do update set
+			video_id = excluded.video_id,
+			input_path = excluded.input_path,
+			output_path = excluded.output_path,
+			resolution = excluded.resolution,
+			status = excluded.status,
+			progress = excluded.progress,
+			start_time = excluded.start_time,
+			completion_time = excluded.completion_time,
+			error_message = excluded.error_message,
+			retry_count = excluded.retry_count`,
+		job.ID, job.VideoID, job.InputPath, job.OutputPath, int32(job.Resolution), int32(job.Status),
+		job.Progress, job.StartTime, job.CompletionTime, job.ErrorMessage, job.RetryCount,
+	)
+	return err
+}
+
+// GetTranscodingJobs retrieves every transcoding job of a video
+func (s *TranscodeStorage) GetTranscodingJobs(ctx context.Context, videoID string) ([]*transcode.TranscodingJob, error) {
+	jobs, err := s.queryJobs(ctx, `select `+transcodingJobColumns+` from transcoding_jobs
+		where video_id = $1 order by start_time`, videoID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transcoding jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+// ListActiveTranscodingJobs retrieves the jobs that are queued or still processing
+func (s *TranscodeStorage) ListActiveTranscodingJobs(ctx context.Context) ([]*transcode.TranscodingJob, error) {
+	// The values are inlined so that the partial index applies
+	jobs, err := s.queryJobs(ctx, `select `+transcodingJobColumns+` from transcoding_jobs
+		where status in (1, 2) order by start_time`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list active transcoding jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+// queryJobs runs a query selecting transcodingJobColumns and scans every row
+func (s *TranscodeStorage) queryJobs(ctx context.Context, query string, args ...any) ([]*transcode.TranscodingJob, error) {
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []*transcode.TranscodingJob
+	for rows.Next() {
+		job, err := scanTranscodingJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// scanTranscodingJob reads a row selected with transcodingJobColumns
+func scanTranscodingJob(row pgx.Row) (*transcode.TranscodingJob, error) {
+	var job transcode.TranscodingJob
+	var resolution, status int32
+	err := row.Scan(
+		&job.ID, &job.VideoID, &job.InputPath, &job.OutputPath, &resolution, &status,
+		&job.Progress, &job.StartTime, &job.CompletionTime, &job.ErrorMessage, &job.RetryCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+	job.Resolution = pb.VideoResolution(resolution)
+	job.Status = pb.TranscodingStatus(status)
+	return &job, nil
+}
@@ -0,0 +1,633 @@
+package postgres
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"videostreaming/internal/service/video"
+	pb "videostreaming/proto/video"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+const (
+	videoColumns = `video_id, title, description, user_id, thumbnail_url, video_url,
+		duration_seconds, view_count, status, status_reason, created_at, updated_at,
+		tags, visibility, resolution, content_hash, media_id, source_key, file_size_bytes, deleted_at,
+		callback_url`
+
+	liveStreamColumns = `stream_id, user_id, title, description, thumbnail_url, playback_url,
+		webrtc_playback_url, viewer_count, status, started_at, ended_at, tags, category,
+		stream_key, max_viewers`
+
+	// activeStream matches streams that have neither ended (3) nor failed (4).
+	// The values are inlined so that the partial index applies.
+	activeStream = "status not in (3, 4)"
+)
+
+// VideoStorage implements the video.Storage interface using PostgreSQL
+type VideoStorage struct {
+	pool *pgxpool.Pool
+}
+
+// NewVideoStorage creates a new PostgreSQL-based video storage.
+// Call Migrate before use to create the tables it needs.
+func NewVideoStorage(pool *pgxpool.Pool) *VideoStorage {
+	return &VideoStorage{pool: pool}
+}
+
+// Ping checks that PostgreSQL can be reached
+func (s *VideoStorage) Ping(ctx context.Context) error {
+	return s.pool.Ping(ctx)
+}
+
+// Migrate applies the schema migrations in order. They only create what is
+// missing, so it is safe to call at every startup.
+func (s *VideoStorage) Migrate(ctx context.Context) error {
+	entries, err := migrations.ReadDir("migrations")
+	if err != nil {
+		return fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	// ReadDir returns the files sorted by name
+	for _, entry := range entries {
+		schema, err := migrations.ReadFile("migrations/" + entry.Name())
+		if err != nil {
+			return fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+		if _, err := s.pool.Exec(ctx, string(schema)); err != nil {
+			return fmt.Errorf("failed to apply migration %s: %w", entry.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+// SaveVideo inserts a video, or replaces it if it already exists. The view
+// count is only written on insert; afterwards IncrementViewCount owns it, so
+// saving a video read earlier can't undo concurrent views.
+func (s *VideoStorage) SaveVideo(ctx context.Context, v *video.Video) error {
+	_, err := s.pool.Exec(ctx, `
+		insert into videos (`+videoColumns+`)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)
+		on conflict (video_id) do update set
+			title = excluded.title,
+			description = excluded.description,
+			user_id = excluded.user_id,
+			thumbnail_url = excluded.thumbnail_url,
+			video_url = excluded.video_url,
+			duration_seconds = excluded.duration_seconds,
+			status = excluded.status,
+			status_reason = excluded.status_reason,
+			created_at = excluded.created_at,
+			updated_at = excluded.updated_at,
+			tags = excluded.tags,
+			visibility = excluded.visibility,
+			resolution = excluded.resolution,
+			content_hash = excluded.content_hash,
+			media_id = excluded.media_id,
+			source_key = excluded.source_key,
+			file_size_bytes = excluded.file_size_bytes,
+			deleted_at = excluded.deleted_at,
+			callback_url = excluded.callback_url`,
+		v.ID, v.Title, v.Description, v.UserID, v.ThumbnailURL, v.VideoURL,
+		v.DurationSeconds, v.ViewCount, int32(v.Status), v.StatusReason, v.CreatedAt, v.UpdatedAt,
+		tagsOrEmpty(v.Tags), int32(v.Visibility), int32(v.Resolution), v.ContentHash, v.MediaID, v.SourceKey,
+		v.FileSizeBytes, v.DeletedAt, v.CallbackURL,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save video: %w", err)
+	}
+
+	return nil
+}
+
+// GetVideo retrieves a video by ID
+func (s *VideoStorage) GetVideo(ctx context.Context, id string) (*video.Video, error) {
+	row := s.pool.QueryRow(ctx, `select `+videoColumns+` from videos where video_id = $1`, id)
+
+	v, err := scanVideo(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %v", video.ErrNotFound, err)
+		}
+		return nil, fmt.Errorf("failed to get video: %w", err)
+	}
+
+	return v, nil
+}
+
+// GetVideos retrieves the videos that exist among the given IDs
+func (s *VideoStorage) GetVideos(ctx context.Context, ids []string) ([]*video.Video, error) {
+	videos, err := s.queryVideos(ctx, `select `+videoColumns+` from videos where video_id = any($1)`, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get videos: %w", err)
+	}
+
+	return videos, nil
+}
+
+// ListVideos retrieves a page of videos matching the filter
+func (s *VideoStorage) ListVideos(ctx context.Context, filter video.ListVideosFilter, limit int, offset int) ([]*video.Video, int, error) {
+	// Videos in the trash are never listed
+	conditions := []string{"deleted_at is null"}
+	var args []any
+	if filter.UserID != "" {
+		args = append(args, filter.UserID)
+		conditions = append(conditions, fmt.Sprintf("user_id = $%d", len(args)))
+	}
+	if filter.Status != pb.VideoStatus_VIDEO_STATUS_UNSPECIFIED {
+		args = append(args, int32(filter.Status))
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if filter.Visibility != pb.VideoVisibility_VIDEO_VISIBILITY_UNSPECIFIED {
+		args = append(args, int32(filter.Visibility))
+		conditions = append(conditions, fmt.Sprintf("visibility = $%d", len(args)))
+	}
+	// Other users' private videos are never listed
+	args = append(args, int32(pb.VideoVisibility_VIDEO_VISIBILITY_PRIVATE), filter.ViewerID)
+	conditions = append(conditions, fmt.Sprintf("(visibility <> $%d or user_id = $%d)", len(args)-1, len(args)))
+
+	where := " where " + strings.Join(conditions, " and ")
+
+	var total int
+	if err := s.pool.QueryRow(ctx, `select count(*) from videos`+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count videos: %w", err)
+	}
+
+	args = append(args, limit, offset)
+	query := fmt.Sprintf(`select %s from videos%s order by %s limit $%d offset $%d`,
+		videoColumns, where, videoOrder(filter.SortBy), len(args)-1, len(args))
+
+	videos, err := s.queryVideos(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list videos: %w", err)
+	}
+
+	return videos, total, nil
+}
+
+// videoOrder returns the order by clause for a listing order. Ties are broken
+// by video ID so that offset-based pages never overlap.
+func videoOrder(sortBy pb.VideoSortBy) string {
+	switch sortBy {
+	case pb.VideoSortBy_VIDEO_SORT_BY_CREATED_ASC:
+		return "created_at asc, video_id"
+	case pb.VideoSortBy_VIDEO_SORT_BY_VIEWS_DESC:
+		return "view_count desc, video_id"
+	case pb.VideoSortBy_VIDEO_SORT_BY_TITLE_ASC:
+		return "title asc, video_id"
+	default:
+		// Newest first
+		return "created_at desc, video_id"
+	}
+}
+
+// SearchVideos finds videos matching any word of the query in their title,
+// description or tags using full-text search, best matches first
+func (s *VideoStorage) SearchVideos(ctx context.Context, query string, limit int, offset int) ([]*video.Video, int, error) {
+	// plainto_tsquery joins the words with AND; switch to OR to match the
+	// other storages, which rank documents by how many words they contain
+	const match = `
+		with search as (
+			select replace(plainto_tsquery('english', $1)::text, '&', '|')::tsquery as q
+		)
+		select %s from videos, search
+		where deleted_at is null
+			and to_tsvector('english', title || ' ' || description || ' ' || array_to_string(tags, ' ')) @@ search.q`
+
+	var total int
+	if err := s.pool.QueryRow(ctx, fmt.Sprintf(match, "count(*)"), query).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count videos: %w", err)
+	}
+
+	rankedQuery := fmt.Sprintf(match, videoColumns) + `
+		order by ts_rank(to_tsvector('english', title || ' ' || description || ' ' || array_to_string(tags, ' ')), search.q) desc,
+			created_at desc, video_id
+		limit $2 offset $3`
+
+	videos, err := s.queryVideos(ctx, rankedQuery, query, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search videos: %w", err)
+	}
+
+	return videos, total, nil
+}
+
+// DeleteVideo removes a video, checking ownership when userID is set
+func (s *VideoStorage) DeleteVideo(ctx context.Context, id string, userID string) error {
+	tag, err := s.pool.Exec(ctx,
+		`delete from videos where video_id = $1 and ($2::text = '' or user_id = $2)`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete video: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: video not found or not authorized to delete", video.ErrNotFound)
+	}
+
+	return nil
+}
+
+// IncrementViewCount atomically increments a video's view count
+func (s *VideoStorage) IncrementViewCount(ctx context.Context, videoID string) (int64, error) {
+	var viewCount int64
+	err := s.pool.QueryRow(ctx,
+		`update videos set view_count = view_count + 1 where video_id = $1 returning view_count`,
+		videoID,
+	).Scan(&viewCount)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, fmt.Errorf("%w: %v", video.ErrNotFound, err)
+		}
+		return 0, fmt.Errorf("failed to increment view count: %w", err)
+	}
+
+	return viewCount, nil
+}
+
+// ListVideosByContentHash retrieves every video whose upload has the given hash
+func (s *VideoStorage) ListVideosByContentHash(ctx context.Context, hash string) ([]*video.Video, error) {
+	videos, err := s.queryVideos(ctx,
+		`select `+videoColumns+` from videos where content_hash = $1 order by created_at, video_id`, hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find videos by content hash: %w", err)
+	}
+
+	return videos, nil
+}
+
+// GetUserStorageUsage sums the file sizes of a user's videos
+func (s *VideoStorage) GetUserStorageUsage(ctx context.Context, userID string) (int64, error) {
+	var usage int64
+	err := s.pool.QueryRow(ctx,
+		`select coalesce(sum(file_size_bytes), 0) from videos where user_id = $1`, userID,
+	).Scan(&usage)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get storage usage: %w", err)
+	}
+
+	return usage, nil
+}
+
+// ListDeletedVideos retrieves up to limit videos moved to the trash before
+// deletedBefore, longest deleted first
+func (s *VideoStorage) ListDeletedVideos(ctx context.Context, deletedBefore time.Time, limit int) ([]*video.Video, error) {
+	videos, err := s.queryVideos(ctx,
+		`select `+videoColumns+` from videos where deleted_at < $1 order by deleted_at, video_id limit $2`,
+		deletedBefore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find deleted videos: %w", err)
+	}
+
+	return videos, nil
+}
+
+// SaveStreamKey stores a user's stream key, keeping the time the first one was issued
+func (s *VideoStorage) SaveStreamKey(ctx context.Context, userID string, streamKey string) error {
+	now := time.Now()
+	_, err := s.pool.Exec(ctx, `
+		insert into stream_keys (user_id, stream_key, created_at, updated_at)
+		values ($1, $2, $3, $3)
+		on conflict (user_id) do update set
+			stream_key = excluded.stream_key,
+			updated_at = excluded.updated_at`,
+		userID, streamKey, now,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save stream key: %w", err)
+	}
+
+	return nil
+}
+
+// GetStreamKey retrieves a user's stream key
+func (s *VideoStorage) GetStreamKey(ctx context.Context, userID string) (string, error) {
+	var streamKey string
+	err := s.pool.QueryRow(ctx, `select stream_key from stream_keys where user_id = $1`, userID).Scan(&streamKey)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", fmt.Errorf("%w: stream key not found: %v", video.ErrNotFound, err)
+		}
+		return "", fmt.Errorf("failed to get stream key: %w", err)
+	}
+
+	return streamKey, nil
+}
+
+// GetStreamKeyOwner returns the user holding a stream key, if any
+func (s *VideoStorage) GetStreamKeyOwner(ctx context.Context, streamKey string) (string, error) {
+	var userID string
+	err := s.pool.QueryRow(ctx, `select user_id from stream_keys where stream_key = $1`, streamKey).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get stream key owner: %w", err)
+	}
+
+	return userID, nil
+}
+
+// SaveLiveStream inserts a live stream, or replaces it if it already exists.
+// Like the view count in SaveVideo, the viewer count is only written on
+// insert and is left to AdjustViewerCount afterwards.
+func (s *VideoStorage) SaveLiveStream(ctx context.Context, stream *video.LiveStream) error {
+	_, err := s.pool.Exec(ctx, `
+		insert into live_streams (`+liveStreamColumns+`)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		on conflict (stream_id) do update set
+			user_id = excluded.user_id,
+			title = excluded.title,
+			description = excluded.description,
+			thumbnail_url = excluded.thumbnail_url,
+			playback_url = excluded.playback_url,
+			webrtc_playback_url = excluded.webrtc_playback_url,
+			status = excluded.status,
+			started_at = excluded.started_at,
+			ended_at = excluded.ended_at,
+			tags = excluded.tags,
+			category = excluded.category,
+			stream_key = excluded.stream_key,
+			max_viewers = excluded.max_viewers`,
+		stream.StreamID, stream.UserID, stream.Title, stream.Description, stream.ThumbnailURL, stream.PlaybackURL,
+		stream.WebRTCPlaybackURL, stream.ViewerCount, int32(stream.Status), stream.StartedAt, stream.EndedAt,
+		tagsOrEmpty(stream.Tags), stream.Category, stream.StreamKey, stream.MaxViewers,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save live stream: %w", err)
+	}
+
+	return nil
+}
+
+// GetLiveStream retrieves a live stream by ID, as long as it is still active
+func (s *VideoStorage) GetLiveStream(ctx context.Context, streamID string) (*video.LiveStream, error) {
+	return s.getLiveStream(ctx, `select `+liveStreamColumns+` from live_streams where stream_id = $1 and `+activeStream, streamID)
+}
+
+// GetLiveStreamByID retrieves a live stream by ID whether or not it has ended
+func (s *VideoStorage) GetLiveStreamByID(ctx context.Context, streamID string) (*video.LiveStream, error) {
+	return s.getLiveStream(ctx, `select `+liveStreamColumns+` from live_streams where stream_id = $1`, streamID)
+}
+
+// getLiveStream runs a query expected to return a single live stream
+func (s *VideoStorage) getLiveStream(ctx context.Context, query string, streamID string) (*video.LiveStream, error) {
+	stream, err := scanLiveStream(s.pool.QueryRow(ctx, query, streamID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %v", video.ErrStreamNotFound, err)
+		}
+		return nil, fmt.Errorf("failed to get live stream: %w", err)
+	}
+
+	return stream, nil
+}
+
+// GetLiveStreamsByIDs retrieves the active live streams among the given IDs
+func (s *VideoStorage) GetLiveStreamsByIDs(ctx context.Context, streamIDs []string) ([]*video.LiveStream, error) {
+	streams, err := s.queryLiveStreams(ctx,
+		`select `+liveStreamColumns+` from live_streams where stream_id = any($1) and `+activeStream, streamIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get live streams: %w", err)
+	}
+
+	return streams, nil
+}
+
+// AdjustViewerCount changes the viewer count of a live stream in a single
+// update, clamped at zero. Viewers joining are only counted while the count
+// stays within maxViewers, which the update's where clause checks.
+func (s *VideoStorage) AdjustViewerCount(ctx context.Context, streamID string, delta int64, maxViewers int64) (int64, error) {
+	var viewerCount int64
+	err := s.pool.QueryRow(ctx,
+		`update live_streams set viewer_count = greatest(viewer_count + $2, 0)
+		where stream_id = $1 and `+activeStream+`
+			and ($2 <= 0 or $3 <= 0 or viewer_count + $2 <= $3)
+		returning viewer_count`,
+		streamID, delta, maxViewers,
+	).Scan(&viewerCount)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Either the stream isn't live or it is full
+		err = s.pool.QueryRow(ctx,
+			`select viewer_count from live_streams where stream_id = $1 and `+activeStream,
+			streamID,
+		).Scan(&viewerCount)
+		if err == nil {
+			return viewerCount, video.ErrStreamAtCapacity
+		}
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, fmt.Errorf("%w: %v", video.ErrStreamNotFound, err)
+		}
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to adjust viewer count: %w", err)
+	}
+
+	return viewerCount, nil
+}
+
+// EndLiveStream marks a live stream as ended.
+// Ending an already ended stream succeeds without changing it.
+func (s *VideoStorage) EndLiveStream(ctx context.Context, streamID string, userID string) error {
+	tag, err := s.pool.Exec(ctx,
+		`update live_streams set status = $3, ended_at = $4
+		where stream_id = $1 and user_id = $2 and `+activeStream,
+		streamID, userID, int32(pb.StreamStatus_STREAM_STATUS_ENDED), time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to end live stream: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		// Tell an already ended stream apart from a missing or foreign one
+		var owner string
+		err := s.pool.QueryRow(ctx, `select user_id from live_streams where stream_id = $1`, streamID).Scan(&owner)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return video.ErrStreamNotFound
+			}
+			return fmt.Errorf("failed to get live stream: %w", err)
+		}
+		if owner != userID {
+			return fmt.Errorf("%w: not authorized to end this stream", video.ErrPermissionDenied)
+		}
+	}
+
+	return nil
+}
+
+// ListLiveStreams retrieves a page of active live streams, newest first
+func (s *VideoStorage) ListLiveStreams(ctx context.Context, userID string, limit int, offset int) ([]*video.LiveStream, int, error) {
+	where := ` where ($1::text = '' or user_id = $1) and ` + activeStream
+
+	var total int
+	if err := s.pool.QueryRow(ctx, `select count(*) from live_streams`+where, userID).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count live streams: %w", err)
+	}
+
+	streams, err := s.queryLiveStreams(ctx,
+		`select `+liveStreamColumns+` from live_streams`+where+`
+		order by started_at desc, stream_id limit $2 offset $3`,
+		userID, limit, offset,
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list live streams: %w", err)
+	}
+
+	return streams, total, nil
+}
+
+// ListLiveStreamsByCategory retrieves a page of active live streams in a
+// category, newest first; an empty category matches every stream
+func (s *VideoStorage) ListLiveStreamsByCategory(ctx context.Context, category string, limit int, offset int) ([]*video.LiveStream, int, error) {
+	where := ` where ($1::text = '' or category = $1) and ` + activeStream
+
+	var total int
+	if err := s.pool.QueryRow(ctx, `select count(*) from live_streams`+where, category).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count live streams: %w", err)
+	}
+
+	streams, err := s.queryLiveStreams(ctx,
+		`select `+liveStreamColumns+` from live_streams`+where+`
+		order by started_at desc, stream_id limit $2 offset $3`,
+		category, limit, offset,
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list live streams: %w", err)
+	}
+
+	return streams, total, nil
+}
+
+// SaveStreamMessage stores a chat message
+func (s *VideoStorage) SaveStreamMessage(ctx context.Context, message *video.StreamMessage) error {
+	_, err := s.pool.Exec(ctx,
+		`insert into stream_messages (message_id, stream_id, user_id, text, created_at) values ($1, $2, $3, $4, $5)`,
+		message.ID, message.StreamID, message.UserID, message.Text, message.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save stream message: %w", err)
+	}
+
+	return nil
+}
+
+// ListStreamMessages retrieves up to limit of a stream's messages posted
+// before the given time, newest first
+func (s *VideoStorage) ListStreamMessages(ctx context.Context, streamID string, limit int, before time.Time) ([]*video.StreamMessage, error) {
+	rows, err := s.pool.Query(ctx,
+		`select message_id, stream_id, user_id, text, created_at from stream_messages
+		where stream_id = $1 and created_at < $2
+		order by created_at desc, message_id limit $3`,
+		streamID, before, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stream messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := make([]*video.StreamMessage, 0, limit)
+	for rows.Next() {
+		var message video.StreamMessage
+		if err := rows.Scan(&message.ID, &message.StreamID, &message.UserID, &message.Text, &message.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan stream message: %w", err)
+		}
+		messages = append(messages, &message)
+	}
+
+	return messages, rows.Err()
+}
+
+// queryVideos runs a query selecting videoColumns and scans every row
+func (s *VideoStorage) queryVideos(ctx context.Context, query string, args ...any) ([]*video.Video, error) {
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var videos []*video.Video
+	for rows.Next() {
+		v, err := scanVideo(rows)
+		if err != nil {
+			return nil, err
+		}
+		videos = append(videos, v)
+	}
+
+	return videos, rows.Err()
+}
+
+// queryLiveStreams runs a query selecting liveStreamColumns and scans every row
+func (s *VideoStorage) queryLiveStreams(ctx context.Context, query string, args ...any) ([]*video.LiveStream, error) {
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var streams []*video.LiveStream
+	for rows.Next() {
+		stream, err := scanLiveStream(rows)
+		if err != nil {
+			return nil, err
+		}
+		streams = append(streams, stream)
+	}
+
+	return streams, rows.Err()
+}
+
+// scanVideo reads a row of videoColumns into a video.Video
+func scanVideo(row pgx.Row) (*video.Video, error) {
+	var v video.Video
+	var status, visibility, resolution int32
+	err := row.Scan(
+		&v.ID, &v.Title, &v.Description, &v.UserID, &v.ThumbnailURL, &v.VideoURL,
+		&v.DurationSeconds, &v.ViewCount, &status, &v.StatusReason, &v.CreatedAt, &v.UpdatedAt,
+		&v.Tags, &visibility, &resolution, &v.ContentHash, &v.MediaID, &v.SourceKey,
+		&v.FileSizeBytes, &v.DeletedAt, &v.CallbackURL,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	v.Status = pb.VideoStatus(status)
+	v.Visibility = pb.VideoVisibility(visibility)
+	v.Resolution = pb.VideoResolution(resolution)
+	return &v, nil
+}
+
+// scanLiveStream reads a row of liveStreamColumns into a video.LiveStream
+func scanLiveStream(row pgx.Row) (*video.LiveStream, error) {
+	var stream video.LiveStream
+	var status int32
+	err := row.Scan(
+		&stream.StreamID, &stream.UserID, &stream.Title, &stream.Description, &stream.ThumbnailURL, &stream.PlaybackURL,
+		&stream.WebRTCPlaybackURL, &stream.ViewerCount, &status, &stream.StartedAt, &stream.EndedAt, &stream.Tags,
+		&stream.Category, &stream.StreamKey, &stream.MaxViewers,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	stream.Status = pb.StreamStatus(status)
+	return &stream, nil
+}
+
+// tagsOrEmpty stores missing tags as an empty array, since the column is not null
+func tagsOrEmpty(tags []string) []string {
+	if tags == nil {
+		return []string{}
+	}
+	return tags
+}
@@ -0,0 +1,43 @@
+package video
+
+import (
+	"fmt"
+
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// CodecName is the content subtype for exchanging VideoService messages as
+// JSON instead of the protobuf wire format, e.g. from scripts and browsers
+// without protobuf support. Go clients select it with
+// grpc.CallContentSubtype(CodecName); the default stays protobuf.
+const CodecName = "json"
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+// jsonCodec encodes gRPC messages in the protobuf JSON mapping, using the
+// field names from video.proto
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	message, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("json codec: %T is not a protobuf message", v)
+	}
+	return protojson.MarshalOptions{UseProtoNames: true}.Marshal(message)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	message, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("json codec: %T is not a protobuf message", v)
+	}
+	return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(data, message)
+}
+
+func (jsonCodec) Name() string {
+	return CodecName
+}
@@ -0,0 +1,6 @@
+package video
+
+// video.pb.go is generated from video.proto and registers its descriptor, so
+// standard protobuf clients and gRPC reflection can use the service. Rerun
+// this after editing the messages; video_grpc.go is kept up to date by hand.
+//go:generate protoc --go_out=. --go_opt=paths=source_relative video.proto
@@ -0,0 +1,796 @@
+package video
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// VideoServiceServer is the server API for the VideoService defined in video.proto
+type VideoServiceServer interface {
+	InitiateUpload(context.Context, *InitiateUploadRequest) (*InitiateUploadResponse, error)
+	CompleteUpload(context.Context, *CompleteUploadRequest) (*CompleteUploadResponse, error)
+	GetVideo(context.Context, *GetVideoRequest) (*Video, error)
+	GetVideos(context.Context, *GetVideosRequest) (*GetVideosResponse, error)
+	ListVideos(context.Context, *ListVideosRequest) (*ListVideosResponse, error)
+	SearchVideos(context.Context, *SearchVideosRequest) (*ListVideosResponse, error)
+	DeleteVideo(context.Context, *DeleteVideoRequest) (*emptypb.Empty, error)
+	RestoreVideo(context.Context, *RestoreVideoRequest) (*Video, error)
+	UpdateVideo(context.Context, *UpdateVideoRequest) (*Video, error)
+	InitiateThumbnailUpload(context.Context, *InitiateThumbnailUploadRequest) (*InitiateThumbnailUploadResponse, error)
+	IncrementViewCount(context.Context, *IncrementViewCountRequest) (*Video, error)
+	CaptureThumbnail(context.Context, *CaptureThumbnailRequest) (*Video, error)
+	ArchiveVideo(context.Context, *ArchiveVideoRequest) (*Video, error)
+	RestoreFromArchive(context.Context, *RestoreFromArchiveRequest) (*Video, error)
+	GetStreamKey(context.Context, *GetStreamKeyRequest) (*StreamKeyResponse, error)
+	RotateStreamKey(context.Context, *RotateStreamKeyRequest) (*StreamKeyResponse, error)
+	StartStream(context.Context, *StartStreamRequest) (*StreamResponse, error)
+	EndStream(context.Context, *EndStreamRequest) (*emptypb.Empty, error)
+	JoinStream(context.Context, *JoinStreamRequest) (*JoinStreamResponse, error)
+	LeaveStream(context.Context, *LeaveStreamRequest) (*LeaveStreamResponse, error)
+	PostStreamMessage(context.Context, *PostStreamMessageRequest) (*StreamMessage, error)
+	GetStreamMessages(context.Context, *GetStreamMessagesRequest) (*GetStreamMessagesResponse, error)
+	GetLiveStreams(context.Context, *GetLiveStreamsRequest) (*GetLiveStreamsResponse, error)
+	GetLiveStreamsByCategory(context.Context, *GetLiveStreamsByCategoryRequest) (*GetLiveStreamsResponse, error)
+	GetStream(context.Context, *GetStreamRequest) (*GetStreamResponse, error)
+	GetStreamsByIDs(context.Context, *GetStreamsByIDsRequest) (*GetStreamsByIDsResponse, error)
+	GetTranscodingStatus(context.Context, *GetTranscodingStatusRequest) (*TranscodingStatusResponse, error)
+	WatchTranscodingStatus(*GetTranscodingStatusRequest, VideoService_WatchTranscodingStatusServer) error
+}
+
+// UnimplementedVideoServiceServer answers codes.Unimplemented for every
+// method. Embed it so servers keep compiling as methods are added.
+type UnimplementedVideoServiceServer struct{}
+
+func (UnimplementedVideoServiceServer) InitiateUpload(context.Context, *InitiateUploadRequest) (*InitiateUploadResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method InitiateUpload not implemented")
+}
+
+func (UnimplementedVideoServiceServer) CompleteUpload(context.Context, *CompleteUploadRequest) (*CompleteUploadResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method CompleteUpload not implemented")
+}
+
+func (UnimplementedVideoServiceServer) GetVideo(context.Context, *GetVideoRequest) (*Video, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetVideo not implemented")
+}
+
+func (UnimplementedVideoServiceServer) GetVideos(context.Context, *GetVideosRequest) (*GetVideosResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetVideos not implemented")
+}
+
+func (UnimplementedVideoServiceServer) ListVideos(context.Context, *ListVideosRequest) (*ListVideosResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListVideos not implemented")
+}
+
+func (UnimplementedVideoServiceServer) SearchVideos(context.Context, *SearchVideosRequest) (*ListVideosResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method SearchVideos not implemented")
+}
+
+func (UnimplementedVideoServiceServer) DeleteVideo(context.Context, *DeleteVideoRequest) (*emptypb.Empty, error) {
+	return nil, status.Error(codes.Unimplemented, "method DeleteVideo not implemented")
+}
+
+func (UnimplementedVideoServiceServer) RestoreVideo(context.Context, *RestoreVideoRequest) (*Video, error) {
+	return nil, status.Error(codes.Unimplemented, "method RestoreVideo not implemented")
+}
+
+func (UnimplementedVideoServiceServer) UpdateVideo(context.Context, *UpdateVideoRequest) (*Video, error) {
+	return nil, status.Error(codes.Unimplemented, "method UpdateVideo not implemented")
+}
+
+func (UnimplementedVideoServiceServer) InitiateThumbnailUpload(context.Context, *InitiateThumbnailUploadRequest) (*InitiateThumbnailUploadResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method InitiateThumbnailUpload not implemented")
+}
+
+func (UnimplementedVideoServiceServer) IncrementViewCount(context.Context, *IncrementViewCountRequest) (*Video, error) {
+	return nil, status.Error(codes.Unimplemented, "method IncrementViewCount not implemented")
+}
+
+func (UnimplementedVideoServiceServer) CaptureThumbnail(context.Context, *CaptureThumbnailRequest) (*Video, error) {
+	return nil, status.Error(codes.Unimplemented, "method CaptureThumbnail not implemented")
+}
+
+func (UnimplementedVideoServiceServer) ArchiveVideo(context.Context, *ArchiveVideoRequest) (*Video, error) {
+	return nil, status.Error(codes.Unimplemented, "method ArchiveVideo not implemented")
+}
+
+func (UnimplementedVideoServiceServer) RestoreFromArchive(context.Context, *RestoreFromArchiveRequest) (*Video, error) {
+	return nil, status.Error(codes.Unimplemented, "method RestoreFromArchive not implemented")
+}
+
+func (UnimplementedVideoServiceServer) GetStreamKey(context.Context, *GetStreamKeyRequest) (*StreamKeyResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetStreamKey not implemented")
+}
+
+func (UnimplementedVideoServiceServer) RotateStreamKey(context.Context, *RotateStreamKeyRequest) (*StreamKeyResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method RotateStreamKey not implemented")
+}
+
+func (UnimplementedVideoServiceServer) StartStream(context.Context, *StartStreamRequest) (*StreamResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method StartStream not implemented")
+}
+
+func (UnimplementedVideoServiceServer) EndStream(context.Context, *EndStreamRequest) (*emptypb.Empty, error) {
+	return nil, status.Error(codes.Unimplemented, "method EndStream not implemented")
+}
+
+func (UnimplementedVideoServiceServer) JoinStream(context.Context, *JoinStreamRequest) (*JoinStreamResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method JoinStream not implemented")
+}
+
+func (UnimplementedVideoServiceServer) LeaveStream(context.Context, *LeaveStreamRequest) (*LeaveStreamResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method LeaveStream not implemented")
+}
+
+func (UnimplementedVideoServiceServer) PostStreamMessage(context.Context, *PostStreamMessageRequest) (*StreamMessage, error) {
+	return nil, status.Error(codes.Unimplemented, "method PostStreamMessage not implemented")
+}
+
+func (UnimplementedVideoServiceServer) GetStreamMessages(context.Context, *GetStreamMessagesRequest) (*GetStreamMessagesResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetStreamMessages not implemented")
+}
+
+func (UnimplementedVideoServiceServer) GetLiveStreams(context.Context, *GetLiveStreamsRequest) (*GetLiveStreamsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetLiveStreams not implemented")
+}
+
+func (UnimplementedVideoServiceServer) GetLiveStreamsByCategory(context.Context, *GetLiveStreamsByCategoryRequest) (*GetLiveStreamsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetLiveStreamsByCategory not implemented")
+}
+
+func (UnimplementedVideoServiceServer) GetStream(context.Context, *GetStreamRequest) (*GetStreamResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetStream not implemented")
+}
+
+func (UnimplementedVideoServiceServer) GetStreamsByIDs(context.Context, *GetStreamsByIDsRequest) (*GetStreamsByIDsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetStreamsByIDs not implemented")
+}
+
+func (UnimplementedVideoServiceServer) GetTranscodingStatus(context.Context, *GetTranscodingStatusRequest) (*TranscodingStatusResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetTranscodingStatus not implemented")
+}
+
+func (UnimplementedVideoServiceServer) WatchTranscodingStatus(*GetTranscodingStatusRequest, VideoService_WatchTranscodingStatusServer) error {
+	return status.Error(codes.Unimplemented, "method WatchTranscodingStatus not implemented")
+}
+
+// RegisterVideoServiceServer registers a VideoService implementation with a gRPC server
+func RegisterVideoServiceServer(s grpc.ServiceRegistrar, srv VideoServiceServer) {
+	s.RegisterService(&VideoService_ServiceDesc, srv)
+}
+
+func _VideoService_InitiateUpload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InitiateUploadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoServiceServer).InitiateUpload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/video.VideoService/InitiateUpload",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoServiceServer).InitiateUpload(ctx, req.(*InitiateUploadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoService_CompleteUpload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CompleteUploadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoServiceServer).CompleteUpload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/video.VideoService/CompleteUpload",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoServiceServer).CompleteUpload(ctx, req.(*CompleteUploadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoService_GetVideo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetVideoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoServiceServer).GetVideo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/video.VideoService/GetVideo",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoServiceServer).GetVideo(ctx, req.(*GetVideoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoService_GetVideos_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetVideosRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoServiceServer).GetVideos(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/video.VideoService/GetVideos",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoServiceServer).GetVideos(ctx, req.(*GetVideosRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoService_ListVideos_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListVideosRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoServiceServer).ListVideos(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/video.VideoService/ListVideos",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoServiceServer).ListVideos(ctx, req.(*ListVideosRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoService_SearchVideos_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchVideosRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoServiceServer).SearchVideos(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/video.VideoService/SearchVideos",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoServiceServer).SearchVideos(ctx, req.(*SearchVideosRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoService_DeleteVideo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteVideoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoServiceServer).DeleteVideo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/video.VideoService/DeleteVideo",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoServiceServer).DeleteVideo(ctx, req.(*DeleteVideoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoService_RestoreVideo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreVideoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoServiceServer).RestoreVideo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/video.VideoService/RestoreVideo",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoServiceServer).RestoreVideo(ctx, req.(*RestoreVideoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoService_UpdateVideo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateVideoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoServiceServer).UpdateVideo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/video.VideoService/UpdateVideo",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoServiceServer).UpdateVideo(ctx, req.(*UpdateVideoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoService_InitiateThumbnailUpload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InitiateThumbnailUploadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoServiceServer).InitiateThumbnailUpload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/video.VideoService/InitiateThumbnailUpload",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoServiceServer).InitiateThumbnailUpload(ctx, req.(*InitiateThumbnailUploadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoService_IncrementViewCount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IncrementViewCountRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoServiceServer).IncrementViewCount(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/video.VideoService/IncrementViewCount",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoServiceServer).IncrementViewCount(ctx, req.(*IncrementViewCountRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoService_CaptureThumbnail_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CaptureThumbnailRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoServiceServer).CaptureThumbnail(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/video.VideoService/CaptureThumbnail",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoServiceServer).CaptureThumbnail(ctx, req.(*CaptureThumbnailRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoService_ArchiveVideo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ArchiveVideoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoServiceServer).ArchiveVideo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/video.VideoService/ArchiveVideo",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoServiceServer).ArchiveVideo(ctx, req.(*ArchiveVideoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoService_RestoreFromArchive_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreFromArchiveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoServiceServer).RestoreFromArchive(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/video.VideoService/RestoreFromArchive",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoServiceServer).RestoreFromArchive(ctx, req.(*RestoreFromArchiveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoService_GetStreamKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStreamKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoServiceServer).GetStreamKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/video.VideoService/GetStreamKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoServiceServer).GetStreamKey(ctx, req.(*GetStreamKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoService_RotateStreamKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RotateStreamKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoServiceServer).RotateStreamKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/video.VideoService/RotateStreamKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoServiceServer).RotateStreamKey(ctx, req.(*RotateStreamKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoService_StartStream_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartStreamRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoServiceServer).StartStream(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/video.VideoService/StartStream",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoServiceServer).StartStream(ctx, req.(*StartStreamRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoService_EndStream_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EndStreamRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoServiceServer).EndStream(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/video.VideoService/EndStream",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoServiceServer).EndStream(ctx, req.(*EndStreamRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoService_JoinStream_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JoinStreamRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoServiceServer).JoinStream(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/video.VideoService/JoinStream",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoServiceServer).JoinStream(ctx, req.(*JoinStreamRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoService_LeaveStream_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LeaveStreamRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoServiceServer).LeaveStream(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/video.VideoService/LeaveStream",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoServiceServer).LeaveStream(ctx, req.(*LeaveStreamRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoService_PostStreamMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PostStreamMessageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoServiceServer).PostStreamMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/video.VideoService/PostStreamMessage",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoServiceServer).PostStreamMessage(ctx, req.(*PostStreamMessageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoService_GetStreamMessages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStreamMessagesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoServiceServer).GetStreamMessages(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/video.VideoService/GetStreamMessages",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoServiceServer).GetStreamMessages(ctx, req.(*GetStreamMessagesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoService_GetLiveStreams_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLiveStreamsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoServiceServer).GetLiveStreams(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/video.VideoService/GetLiveStreams",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoServiceServer).GetLiveStreams(ctx, req.(*GetLiveStreamsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoService_GetLiveStreamsByCategory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLiveStreamsByCategoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoServiceServer).GetLiveStreamsByCategory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/video.VideoService/GetLiveStreamsByCategory",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoServiceServer).GetLiveStreamsByCategory(ctx, req.(*GetLiveStreamsByCategoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoService_GetStream_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStreamRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoServiceServer).GetStream(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/video.VideoService/GetStream",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoServiceServer).GetStream(ctx, req.(*GetStreamRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoService_GetStreamsByIDs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStreamsByIDsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoServiceServer).GetStreamsByIDs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/video.VideoService/GetStreamsByIDs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoServiceServer).GetStreamsByIDs(ctx, req.(*GetStreamsByIDsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoService_GetTranscodingStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTranscodingStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoServiceServer).GetTranscodingStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/video.VideoService/GetTranscodingStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoServiceServer).GetTranscodingStatus(ctx, req.(*GetTranscodingStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoService_WatchTranscodingStatus_Handler(srv interface{}, stream grpc.ServerStream) error {
+	in := new(GetTranscodingStatusRequest)
+	if err := stream.RecvMsg(in); err != nil {
+		return err
+	}
+	return srv.(VideoServiceServer).WatchTranscodingStatus(in, &videoServiceWatchTranscodingStatusServer{stream})
+}
+
+// VideoService_WatchTranscodingStatusServer is the server side of a
+// WatchTranscodingStatus call
+type VideoService_WatchTranscodingStatusServer interface {
+	Send(*TranscodingStatusResponse) error
+	grpc.ServerStream
+}
+
+type videoServiceWatchTranscodingStatusServer struct {
+	grpc.ServerStream
+}
+
+func (x *videoServiceWatchTranscodingStatusServer) Send(m *TranscodingStatusResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// VideoService_ServiceDesc describes the VideoService for grpc.RegisterService
+var VideoService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "video.VideoService",
+	HandlerType: (*VideoServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "InitiateUpload",
+			Handler:    _VideoService_InitiateUpload_Handler,
+		},
+		{
+			MethodName: "CompleteUpload",
+			Handler:    _VideoService_CompleteUpload_Handler,
+		},
+		{
+			MethodName: "GetVideo",
+			Handler:    _VideoService_GetVideo_Handler,
+		},
+		{
+			MethodName: "GetVideos",
+			Handler:    _VideoService_GetVideos_Handler,
+		},
+		{
+			MethodName: "ListVideos",
+			Handler:    _VideoService_ListVideos_Handler,
+		},
+		{
+			MethodName: "SearchVideos",
+			Handler:    _VideoService_SearchVideos_Handler,
+		},
+		{
+			MethodName: "DeleteVideo",
+			Handler:    _VideoService_DeleteVideo_Handler,
+		},
+		{
+			MethodName: "RestoreVideo",
+			Handler:    _VideoService_RestoreVideo_Handler,
+		},
+		{
+			MethodName: "UpdateVideo",
+			Handler:    _VideoService_UpdateVideo_Handler,
+		},
+		{
+			MethodName: "InitiateThumbnailUpload",
+			Handler:    _VideoService_InitiateThumbnailUpload_Handler,
+		},
+		{
+			MethodName: "IncrementViewCount",
+			Handler:    _VideoService_IncrementViewCount_Handler,
+		},
+		{
+			MethodName: "CaptureThumbnail",
+			Handler:    _VideoService_CaptureThumbnail_Handler,
+		},
+		{
+			MethodName: "ArchiveVideo",
+			Handler:    _VideoService_ArchiveVideo_Handler,
+		},
+		{
+			MethodName: "RestoreFromArchive",
+			Handler:    _VideoService_RestoreFromArchive_Handler,
+		},
+		{
+			MethodName: "GetStreamKey",
+			Handler:    _VideoService_GetStreamKey_Handler,
+		},
+		{
+			MethodName: "RotateStreamKey",
+			Handler:    _VideoService_RotateStreamKey_Handler,
+		},
+		{
+			MethodName: "StartStream",
+			Handler:    _VideoService_StartStream_Handler,
+		},
+		{
+			MethodName: "EndStream",
+			Handler:    _VideoService_EndStream_Handler,
+		},
+		{
+			MethodName: "JoinStream",
+			Handler:    _VideoService_JoinStream_Handler,
+		},
+		{
+			MethodName: "LeaveStream",
+			Handler:    _VideoService_LeaveStream_Handler,
+		},
+		{
+			MethodName: "PostStreamMessage",
+			Handler:    _VideoService_PostStreamMessage_Handler,
+		},
+		{
+			MethodName: "GetStreamMessages",
+			Handler:    _VideoService_GetStreamMessages_Handler,
+		},
+		{
+			MethodName: "GetLiveStreams",
+			Handler:    _VideoService_GetLiveStreams_Handler,
+		},
+		{
+			MethodName: "GetLiveStreamsByCategory",
+			Handler:    _VideoService_GetLiveStreamsByCategory_Handler,
+		},
+		{
+			MethodName: "GetStream",
+			Handler:    _VideoService_GetStream_Handler,
+		},
+		{
+			MethodName: "GetStreamsByIDs",
+			Handler:    _VideoService_GetStreamsByIDs_Handler,
+		},
+		{
+			MethodName: "GetTranscodingStatus",
+			Handler:    _VideoService_GetTranscodingStatus_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchTranscodingStatus",
+			Handler:       _VideoService_WatchTranscodingStatus_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "video.proto",
+}